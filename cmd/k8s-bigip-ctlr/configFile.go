@@ -0,0 +1,366 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+
+	"github.com/spf13/pflag"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// The config file holds the options keyed by flag name, grouped in sections
+// like the flag sets, e.g.:
+//
+//	global:
+//	  log-level: DEBUG
+//	bigip:
+//	  bigip-url: 10.1.1.4
+//	  bigip-partition: [k8s]
+//	kubernetes:
+//	  namespace: [default, prod]
+//
+// List options take a YAML list, each element being passed like a repeated
+// flag.
+
+// reloadableOptions are applied again whenever the config file changes,
+// the other options are only read at startup
+var reloadableOptions = []string{
+	"log-level",
+	"verify-interval",
+	"node-label-selector",
+	"default-client-ssl",
+	"default-server-ssl",
+	"vs-snat-pool-name",
+}
+
+// cmdLineOptions are the flags given on the command line, which the config
+// file does not override
+var cmdLineOptions map[string]bool
+
+// configFileSections maps the sections of the config file to their flags
+func configFileSections() map[string]*pflag.FlagSet {
+	return map[string]*pflag.FlagSet{
+		"global":           globalFlags,
+		"bigip":            bigIPFlags,
+		"kubernetes":       kubeFlags,
+		"vxlan":            vxlanFlags,
+		"openshift-routes": osRouteFlags,
+	}
+}
+
+func isListOption(flag *pflag.Flag) bool {
+	return flag.Value.Type() == "stringArray" || flag.Value.Type() == "stringSlice"
+}
+
+// readConfigFile returns the values of the options in the config file path.
+// Unknown sections and options are errors.
+func readConfigFile(path string) (map[string][]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var sections map[string]map[string]interface{}
+	if err = yaml.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("%v: %v", path, err)
+	}
+
+	fileSections := configFileSections()
+	options := make(map[string][]string)
+	for section, values := range sections {
+		flagSet, ok := fileSections[section]
+		if !ok {
+			var names []string
+			for name := range fileSections {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("%v: unknown section %q, valid sections are: %s",
+				path, section, strings.Join(names, ", "))
+		}
+		for name, value := range values {
+			flag := flagSet.Lookup(name)
+			if flag == nil || name == "config-file" {
+				return nil, fmt.Errorf("%v: unknown option %q in section %q", path, name, section)
+			}
+			strs, err := optionValues(value)
+			if err != nil {
+				return nil, fmt.Errorf("%v: option %q: %v", path, name, err)
+			}
+			if len(strs) != 1 && !isListOption(flag) {
+				return nil, fmt.Errorf("%v: option %q takes a single value", path, name)
+			}
+			options[name] = strs
+		}
+	}
+	return options, nil
+}
+
+// optionValues formats a YAML value, or the elements of a YAML list, like
+// flag values
+func optionValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case bool:
+		return []string{strconv.FormatBool(v)}, nil
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, nil
+	case []interface{}:
+		var values []string
+		for _, element := range v {
+			if _, ok := element.([]interface{}); ok {
+				return nil, fmt.Errorf("nested lists are not supported")
+			}
+			elementValues, err := optionValues(element)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, elementValues...)
+		}
+		return values, nil
+	case nil:
+		return nil, fmt.Errorf("missing value")
+	}
+	return nil, fmt.Errorf("unsupported value %v", value)
+}
+
+// loadConfigFile sets the flags not given on the command line from the
+// config file path, if any
+func loadConfigFile(path string) error {
+	cmdLineOptions = make(map[string]bool)
+	flags.Visit(func(flag *pflag.Flag) {
+		cmdLineOptions[flag.Name] = true
+	})
+	if len(path) == 0 {
+		return nil
+	}
+
+	options, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if cmdLineOptions[name] {
+			continue
+		}
+		for _, value := range options[name] {
+			if err = flags.Set(name, value); err != nil {
+				return fmt.Errorf("%v: invalid value %q for option %q: %v", path, value, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// reloadableSettings are the settings of the reloadableOptions
+type reloadableSettings struct {
+	LogLevel          string
+	VerifyInterval    int
+	NodeLabelSelector string
+	ClientSSL         string
+	ServerSSL         string
+	VsSnatPoolName    string
+}
+
+// getReloadableSettings returns the reloadable settings of the config file
+// options. Options given on the command line keep their value and options
+// missing from the file revert to their default.
+func getReloadableSettings(options map[string][]string) (reloadableSettings, error) {
+	value := func(name string) string {
+		flag := flags.Lookup(name)
+		if cmdLineOptions[name] {
+			return flag.Value.String()
+		}
+		if values := options[name]; len(values) != 0 {
+			return values[len(values)-1]
+		}
+		return flag.DefValue
+	}
+	settings := reloadableSettings{
+		LogLevel:          strings.ToUpper(value("log-level")),
+		NodeLabelSelector: value("node-label-selector"),
+		ClientSSL:         value("default-client-ssl"),
+		ServerSSL:         value("default-server-ssl"),
+		VsSnatPoolName:    value("vs-snat-pool-name"),
+	}
+	if nil == log.NewLogLevel(settings.LogLevel) {
+		return settings, fmt.Errorf("Unknown log level requested: %s", settings.LogLevel)
+	}
+	interval, err := strconv.Atoi(value("verify-interval"))
+	if err != nil {
+		return settings, fmt.Errorf("Invalid verify-interval: %v", err)
+	}
+	settings.VerifyInterval = interval
+	if _, err = labels.Parse(settings.NodeLabelSelector); err != nil {
+		return settings, fmt.Errorf("Invalid node-label-selector: %v", err)
+	}
+	return settings, nil
+}
+
+// configFileWatcher tracks the config file and notifies listeners whenever
+// its reloadable settings change
+type configFileWatcher struct {
+	sync.Mutex
+	path      string
+	options   map[string][]string
+	current   reloadableSettings
+	lastErr   string
+	listeners []func(reloadableSettings)
+}
+
+func newConfigFileWatcher(path string) (*configFileWatcher, error) {
+	options, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	current, err := getReloadableSettings(options)
+	if err != nil {
+		return nil, err
+	}
+	return &configFileWatcher{
+		path:    path,
+		options: options,
+		current: current,
+	}, nil
+}
+
+// RegisterListener adds a function called with the new settings on every
+// change
+func (cw *configFileWatcher) RegisterListener(listener func(reloadableSettings)) {
+	cw.Lock()
+	defer cw.Unlock()
+	cw.listeners = append(cw.listeners, listener)
+}
+
+// reload reads the config file and hands its reloadable settings to the
+// listeners if they changed. An invalid file keeps the current settings.
+func (cw *configFileWatcher) reload() {
+	options, err := readConfigFile(cw.path)
+	var settings reloadableSettings
+	if err == nil {
+		settings, err = getReloadableSettings(options)
+	}
+
+	cw.Lock()
+	defer cw.Unlock()
+	if err != nil {
+		// Log once until the file changes
+		if err.Error() != cw.lastErr {
+			log.Errorf("[INIT] Invalid config file, keeping current settings: %v", err)
+			cw.lastErr = err.Error()
+		}
+		return
+	}
+	cw.lastErr = ""
+
+	for _, name := range changedOptions(cw.options, options) {
+		reloadable := false
+		for _, option := range reloadableOptions {
+			reloadable = reloadable || option == name
+		}
+		if !reloadable && !cmdLineOptions[name] {
+			log.Warningf("[INIT] Option %v changed in %v, restart the controller to apply it",
+				name, cw.path)
+		}
+	}
+	cw.options = options
+	if settings == cw.current {
+		return
+	}
+	cw.current = settings
+	log.Infof("[INIT] Reloaded settings from %v", cw.path)
+	for _, listener := range cw.listeners {
+		listener(settings)
+	}
+}
+
+// changedOptions returns the sorted names of the options that differ
+func changedOptions(old, cur map[string][]string) []string {
+	var names []string
+	for name, values := range old {
+		if !reflect.DeepEqual(values, cur[name]) {
+			names = append(names, name)
+		}
+	}
+	for name := range cur {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// watch re-reads the config file every interval. Like the credentials
+// directory, a file mounted from a ConfigMap is replaced atomically, so
+// polling picks up changes without following symlink swaps.
+func (cw *configFileWatcher) watch(interval time.Duration, stopCh <-chan struct{}) {
+	log.Infof("[INIT] Watching config file %v every %v", cw.path, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			cw.reload()
+		}
+	}
+}
+
+// setupConfigFileWatcher reloads the config file, changes the log levels
+// and passes the changes of the reloadable settings to listener
+func setupConfigFileWatcher(
+	stopCh <-chan struct{},
+	lc *logLevelsController,
+	listener func(reloadableSettings),
+) {
+	if len(*configFile) == 0 || *configReload <= 0 {
+		return
+	}
+	cw, err := newConfigFileWatcher(*configFile)
+	if err != nil {
+		log.Errorf("[INIT] Not watching config file: %v", err)
+		return
+	}
+	cw.RegisterListener(func(settings reloadableSettings) {
+		levels, err := parseComponentLogLevels(*componentLevels, *log.NewLogLevel(settings.LogLevel))
+		if err != nil {
+			log.Errorf("[INIT] Failed to change log levels: %v", err)
+			return
+		}
+		lc.setFlagLevels(levels, "config file "+*configFile)
+	})
+	cw.RegisterListener(listener)
+	go cw.watch(time.Duration(*configReload)*time.Second, stopCh)
+}
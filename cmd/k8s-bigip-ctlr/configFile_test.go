@@ -0,0 +1,163 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config File Tests", func() {
+	var dir, path string
+
+	writeConfig := func(content string) {
+		Expect(ioutil.WriteFile(path, []byte(content), 0644)).To(BeNil())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "config-file")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "config.yaml")
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+		_init()
+	})
+
+	const config = `
+global:
+  log-level: debug
+  verify-interval: 60
+bigip:
+  bigip-url: bigip.example.com
+  bigip-username: admin
+  bigip-password: admin
+  bigip-partition: [velcro1, velcro2]
+  as3-retry-multiplier: 1.5
+kubernetes:
+  namespace:
+  - default
+  - prod
+  pool-member-type: cluster
+  use-node-internal: false
+  vs-snat-pool-name: file-snat-pool
+openshift-routes:
+  default-client-ssl: /Common/clientssl
+`
+
+	It("loads the options from the config file", func() {
+		writeConfig(config)
+		flags.Parse([]string{
+			"./bin/k8s-bigip-ctlr",
+			"--config-file=" + path,
+			"--verify-interval=45",
+			"--bigip-partition=cli",
+		})
+		Expect(verifyArgs()).To(BeNil())
+		Expect(*logLevel).To(Equal("DEBUG"))
+		Expect(*verifyInterval).To(Equal(45))
+		Expect(*bigIPURL).To(Equal("bigip.example.com"))
+		Expect(*bigIPPartitions).To(Equal([]string{"cli"}))
+		Expect(*as3RetryMultiplier).To(Equal(1.5))
+		Expect(*namespaces).To(Equal([]string{"default", "prod"}))
+		Expect(isNodePort).To(BeFalse())
+		Expect(*useNodeInternal).To(BeFalse())
+		Expect(*vsSnatPoolName).To(Equal("file-snat-pool"))
+		Expect(*clientSSL).To(Equal("/Common/clientssl"))
+		Expect(flags.Changed("bigip-url")).To(BeTrue())
+	})
+
+	It("rejects invalid config files", func() {
+		for _, content := range []string{
+			"global: [log-level]",
+			"bigip-ip:\n  bigip-url: bigip.example.com",
+			"bigip:\n  log-level: debug",
+			"global:\n  config-file: other.yaml",
+			"bigip:\n  bigip-url: [a, b]",
+			"bigip:\n  bigip-url:",
+			"global:\n  verify-interval: often",
+		} {
+			writeConfig(content)
+			_init()
+			flags.Parse([]string{"./bin/k8s-bigip-ctlr", "--config-file=" + path})
+			Expect(verifyArgs()).NotTo(BeNil(), content)
+		}
+		_init()
+		flags.Parse([]string{"./bin/k8s-bigip-ctlr", "--config-file=" + path + ".missing"})
+		Expect(verifyArgs()).NotTo(BeNil())
+	})
+
+	It("reloads the reloadable settings", func() {
+		writeConfig(config)
+		flags.Parse([]string{
+			"./bin/k8s-bigip-ctlr",
+			"--config-file=" + path,
+			"--default-server-ssl=/Common/serverssl",
+		})
+		Expect(verifyArgs()).To(BeNil())
+
+		cw, err := newConfigFileWatcher(path)
+		Expect(err).To(BeNil())
+		Expect(cw.current).To(Equal(reloadableSettings{
+			LogLevel:       "DEBUG",
+			VerifyInterval: 60,
+			ClientSSL:      "/Common/clientssl",
+			ServerSSL:      "/Common/serverssl",
+			VsSnatPoolName: "file-snat-pool",
+		}))
+		var reloaded []reloadableSettings
+		cw.RegisterListener(func(settings reloadableSettings) {
+			reloaded = append(reloaded, settings)
+		})
+
+		cw.reload()
+		Expect(reloaded).To(BeEmpty())
+
+		writeConfig(`
+global:
+  log-level: warning
+bigip:
+  bigip-url: other.example.com
+kubernetes:
+  node-label-selector: f5role=worker
+openshift-routes:
+  default-server-ssl: /Common/other
+`)
+		cw.reload()
+		Expect(reloaded).To(Equal([]reloadableSettings{{
+			LogLevel:          "WARNING",
+			VerifyInterval:    30,
+			NodeLabelSelector: "f5role=worker",
+			ServerSSL:         "/Common/serverssl",
+		}}))
+
+		for _, content := range []string{
+			"global:\n  log-level: verbose",
+			"kubernetes:\n  node-label-selector: '!!'",
+			"global: [",
+		} {
+			writeConfig(content)
+			cw.reload()
+		}
+		Expect(reloaded).To(HaveLen(1))
+	})
+})
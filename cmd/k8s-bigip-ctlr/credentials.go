@@ -0,0 +1,253 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+type bigIPCredentials struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// credentialsWatcher tracks the BIG-IP credentials and notifies listeners
+// whenever they change in the credentials directory or Secret.
+type credentialsWatcher struct {
+	sync.Mutex
+	current   bigIPCredentials
+	listeners []func(bigIPCredentials)
+}
+
+func newCredentialsWatcher(initial bigIPCredentials) *credentialsWatcher {
+	return &credentialsWatcher{current: initial}
+}
+
+// RegisterListener adds a function called with the new credentials on every
+// change
+func (cw *credentialsWatcher) RegisterListener(listener func(bigIPCredentials)) {
+	cw.Lock()
+	defer cw.Unlock()
+	cw.listeners = append(cw.listeners, listener)
+}
+
+// update validates creds and hands them to the listeners if they differ
+// from the current ones. Invalid credentials are logged and ignored.
+func (cw *credentialsWatcher) update(creds bigIPCredentials, source string) {
+	u, err := normalizeBigIPURL(creds.URL)
+	if err != nil {
+		log.Errorf("[INIT] Ignoring BIG-IP credentials from %v: %v", source, err)
+		return
+	}
+	creds.URL = u
+	if creds.Username == "" || creds.Password == "" {
+		log.Errorf("[INIT] Ignoring BIG-IP credentials from %v: missing username or password", source)
+		return
+	}
+
+	cw.Lock()
+	defer cw.Unlock()
+	if creds == cw.current {
+		return
+	}
+	cw.current = creds
+	log.Infof("[INIT] Reloaded BIG-IP credentials from %v", source)
+	for _, listener := range cw.listeners {
+		listener(creds)
+	}
+}
+
+// watchDirectory re-reads the credentials directory every interval. Files
+// mounted from a Secret are replaced atomically by the kubelet, so polling
+// picks up rotated credentials without following symlink swaps.
+func (cw *credentialsWatcher) watchDirectory(
+	dir string,
+	interval time.Duration,
+	stopCh <-chan struct{},
+) {
+	log.Infof("[INIT] Watching credentials directory %v every %v", dir, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			cw.Lock()
+			fallback := cw.current
+			cw.Unlock()
+			creds, err := readCredentialsDir(dir, fallback)
+			if err != nil {
+				log.Errorf("[INIT] Failed to read credentials directory %v: %v", dir, err)
+				continue
+			}
+			cw.update(creds, dir)
+		}
+	}
+}
+
+// watchSecret watches the Secret namespace/name for username, password and
+// url keys. Missing keys keep their current value.
+func (cw *credentialsWatcher) watchSecret(
+	kubeClient kubernetes.Interface,
+	namespace string,
+	name string,
+	stopCh <-chan struct{},
+) {
+	source := namespace + "/" + name
+	handle := func(obj interface{}) {
+		secret, ok := obj.(*v1.Secret)
+		if !ok {
+			return
+		}
+		cw.Lock()
+		creds := cw.current
+		cw.Unlock()
+		if data, ok := secret.Data["username"]; ok {
+			creds.Username = strings.TrimSpace(string(data))
+		}
+		if data, ok := secret.Data["password"]; ok {
+			creds.Password = strings.TrimSpace(string(data))
+		}
+		if data, ok := secret.Data["url"]; ok {
+			creds.URL = strings.TrimSpace(string(data))
+		}
+		cw.update(creds, "Secret "+source)
+	}
+
+	secrets := kubeClient.CoreV1().Secrets(namespace)
+	log.Infof("[INIT] Watching credentials Secret %v", source)
+	watchNamedObject(
+		name,
+		&v1.Secret{},
+		func(options metav1.ListOptions) (runtime.Object, error) { return secrets.List(options) },
+		secrets.Watch,
+		&cache.ResourceEventHandlerFuncs{
+			AddFunc:    handle,
+			UpdateFunc: func(old, cur interface{}) { handle(cur) },
+			DeleteFunc: func(obj interface{}) {
+				log.Warningf("[INIT] Credentials Secret %v deleted, keeping current credentials", source)
+			},
+		},
+		stopCh,
+	)
+}
+
+// watchNamedObject runs an informer of the single object name, of type
+// objType, listed and watched by list and watchFunc with a field selector on
+// its name, until stopCh is closed
+func watchNamedObject(
+	name string,
+	objType runtime.Object,
+	list func(metav1.ListOptions) (runtime.Object, error),
+	watchFunc func(metav1.ListOptions) (watch.Interface, error),
+	handler cache.ResourceEventHandler,
+	stopCh <-chan struct{},
+) {
+	fieldSelector := fields.OneTermEqualSelector("metadata.name", name).String()
+	informer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.FieldSelector = fieldSelector
+				return list(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.FieldSelector = fieldSelector
+				return watchFunc(options)
+			},
+		},
+		objType,
+		0,
+		cache.Indexers{},
+	)
+	informer.AddEventHandler(handler)
+	go informer.Run(stopCh)
+}
+
+// readCredentialsDir reads username, password and url files from dir.
+// Missing files fall back to the values in fallback.
+func readCredentialsDir(dir string, fallback bigIPCredentials) (bigIPCredentials, error) {
+	creds := fallback
+	setField := func(field *string, fileName, fieldType string) error {
+		fileBytes, readErr := ioutil.ReadFile(filepath.Join(dir, fileName))
+		if readErr != nil {
+			log.Debug(fmt.Sprintf(
+				"No %s in credentials directory, falling back to CLI argument", fieldType))
+			if len(*field) == 0 {
+				return fmt.Errorf(fmt.Sprintf("BIG-IP %s not specified", fieldType))
+			}
+		} else {
+			*field = string(fileBytes)
+		}
+		return nil
+	}
+
+	if err := setField(&creds.Username, "username", "username"); err != nil {
+		return creds, err
+	}
+	if err := setField(&creds.Password, "password", "password"); err != nil {
+		return creds, err
+	}
+	if err := setField(&creds.URL, "url", "url"); err != nil {
+		return creds, err
+	}
+	return creds, nil
+}
+
+// normalizeBigIPURL verifies the BIG-IP URL and defaults the scheme to https
+func normalizeBigIPURL(bigipURL string) (string, error) {
+	u, err := url.Parse(bigipURL)
+	if nil != err {
+		return "", fmt.Errorf("Error parsing url: %s", err)
+	}
+
+	if len(u.Scheme) == 0 {
+		bigipURL = "https://" + bigipURL
+		u, err = url.Parse(bigipURL)
+		if nil != err {
+			return "", fmt.Errorf("Error parsing url: %s", err)
+		}
+	}
+
+	if u.Scheme != "https" {
+		return "", fmt.Errorf("Invalid BIGIP-URL protocol: '%s' - Must be 'https'",
+			u.Scheme)
+	}
+
+	if len(u.Path) > 0 && u.Path != "/" {
+		return "", fmt.Errorf("BIGIP-URL path must be empty or '/'; check URL formatting and/or remove %s from path",
+			u.Path)
+	}
+	return bigipURL, nil
+}
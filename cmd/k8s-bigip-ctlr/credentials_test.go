@@ -0,0 +1,111 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Credentials Watcher Tests", func() {
+	var cw *credentialsWatcher
+	var mutex sync.Mutex
+	var received []bigIPCredentials
+
+	initial := bigIPCredentials{
+		URL:      "https://bigip.example.com",
+		Username: "admin",
+		Password: "pass",
+	}
+	getReceived := func() []bigIPCredentials {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]bigIPCredentials{}, received...)
+	}
+
+	BeforeEach(func() {
+		received = nil
+		cw = newCredentialsWatcher(initial)
+		cw.RegisterListener(func(creds bigIPCredentials) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			received = append(received, creds)
+		})
+	})
+
+	It("notifies listeners only on valid changes", func() {
+		cw.update(initial, "test")
+		Expect(getReceived()).To(BeEmpty())
+
+		cw.update(bigIPCredentials{URL: "ftp://bigip", Username: "a", Password: "b"}, "test")
+		Expect(getReceived()).To(BeEmpty())
+		cw.update(bigIPCredentials{URL: "bigip.example.com", Username: "admin"}, "test")
+		Expect(getReceived()).To(BeEmpty())
+
+		cw.update(bigIPCredentials{URL: "bigip.example.com", Username: "admin", Password: "new"}, "test")
+		Expect(getReceived()).To(Equal([]bigIPCredentials{{
+			URL:      "https://bigip.example.com",
+			Username: "admin",
+			Password: "new",
+		}}))
+	})
+
+	It("reloads rotated credentials from the directory", func() {
+		dir, err := ioutil.TempDir("", "k8s-test-creds")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		ioutil.WriteFile(filepath.Join(dir, "username"), []byte("admin"), 0600)
+		ioutil.WriteFile(filepath.Join(dir, "password"), []byte("pass"), 0600)
+
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		go cw.watchDirectory(dir, 10*time.Millisecond, stopCh)
+
+		Consistently(getReceived, 50*time.Millisecond).Should(BeEmpty())
+		ioutil.WriteFile(filepath.Join(dir, "password"), []byte("rotated"), 0600)
+		Eventually(getReceived).Should(HaveLen(1))
+		Expect(getReceived()[0].Password).To(Equal("rotated"))
+		Expect(getReceived()[0].URL).To(Equal(initial.URL))
+	})
+
+	It("reloads credentials from a Secret", func() {
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "bigip-login", Namespace: "kube-system"},
+			Data: map[string][]byte{
+				"username": []byte("admin"),
+				"password": []byte("from-secret\n"),
+			},
+		}
+		client := fake.NewSimpleClientset(secret)
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		cw.watchSecret(client, "kube-system", "bigip-login", stopCh)
+
+		Eventually(getReceived).Should(HaveLen(1))
+		Expect(getReceived()[0].Password).To(Equal("from-secret"))
+	})
+})
@@ -0,0 +1,180 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent/as3"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/debug"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// historyCommand inspects the AS3 declaration history instead of running
+// the controller:
+//
+//	k8s-bigip-ctlr history list|show <id>|diff <id> <id> --as3-history-dir=<dir>
+const historyCommand = "history"
+
+const historyUsage = "Usage: %s list | show <id> | diff <from-id> <to-id> " +
+	"(--as3-history-dir=<dir> | --as3-history-secret=<namespace>/<name>)"
+
+// getHistorySize returns the size of the AS3 declaration history, which is
+// only kept when persisted or needed for rollbacks
+func getHistorySize() int {
+	if *as3HistoryDir == "" && *as3HistorySecret == "" && !*as3Rollback {
+		return 0
+	}
+	return *as3HistorySize
+}
+
+// getHistoryStore returns the store of the AS3 declaration history, nil
+// when the history is kept in memory only
+func getHistoryStore(kubeClient kubernetes.Interface) as3.HistoryStore {
+	switch {
+	case *as3HistoryDir != "":
+		return as3.FileHistoryStore{Dir: *as3HistoryDir}
+	case *as3HistorySecret != "" && kubeClient != nil:
+		secret := strings.Split(*as3HistorySecret, "/")
+		return as3.SecretHistoryStore{
+			KubeClient: kubeClient,
+			Namespace:  secret[0],
+			Name:       secret[1],
+		}
+	}
+	return nil
+}
+
+// runHistoryCommand lists, shows or diffs the entries of the AS3
+// declaration history given by the flags in args
+func runHistoryCommand(args []string, out io.Writer) error {
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	cmdArgs := flags.Args()
+	if len(cmdArgs) == 0 {
+		return fmt.Errorf(historyUsage, historyCommand)
+	}
+	if err := loadConfigFile(*configFile); err != nil {
+		return err
+	}
+	if err := initLogger(strings.ToUpper(*logLevel), *logFormat, *componentLevels); err != nil {
+		return err
+	}
+	if *as3HistorySecret != "" && len(strings.Split(*as3HistorySecret, "/")) != 2 {
+		return fmt.Errorf(historyUsage, historyCommand)
+	}
+	if *as3HistorySecret != "" && kubeClient == nil {
+		config, err := getKubeConfig()
+		if err != nil {
+			return err
+		}
+		if kubeClient, err = kubernetes.NewForConfig(config); err != nil {
+			return err
+		}
+	}
+	store := getHistoryStore(kubeClient)
+	if store == nil {
+		return fmt.Errorf(historyUsage, historyCommand)
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return fmt.Errorf("Failed to load the declaration history: %v", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	for i := range entries {
+		if entries[i].Declaration, err = redactDeclaration(entries[i].Declaration); err != nil {
+			return fmt.Errorf("Invalid declaration in history entry %v: %v", entries[i].ID, err)
+		}
+	}
+
+	getEntry := func(arg string) (as3.HistoryEntry, error) {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return as3.HistoryEntry{}, fmt.Errorf("Invalid history entry id %q", arg)
+		}
+		for _, entry := range entries {
+			if entry.ID == id {
+				return entry, nil
+			}
+		}
+		return as3.HistoryEntry{}, fmt.Errorf("History entry %v not found", id)
+	}
+
+	switch {
+	case cmdArgs[0] == "list" && len(cmdArgs) == 1:
+		w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tTIME\tTENANTS\tTRIGGERS")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", entry.ID, entry.Time.Format(time.RFC3339),
+				strings.Join(entry.Tenants, ","), strings.Join(entry.Triggers, ","))
+		}
+		return w.Flush()
+	case cmdArgs[0] == "show" && len(cmdArgs) == 2:
+		entry, err := getEntry(cmdArgs[1])
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entry)
+	case cmdArgs[0] == "diff" && len(cmdArgs) == 3:
+		from, err := getEntry(cmdArgs[1])
+		if err != nil {
+			return err
+		}
+		to, err := getEntry(cmdArgs[2])
+		if err != nil {
+			return err
+		}
+		diff, err := as3.DiffDeclarations(from.Declaration, to.Declaration)
+		if err != nil {
+			return err
+		}
+		for _, line := range diff {
+			fmt.Fprintln(out, line)
+		}
+		return nil
+	}
+	return fmt.Errorf(historyUsage, historyCommand)
+}
+
+// redactDeclaration replaces the private keys, passphrases and passwords of
+// decl, so that they are not printed
+func redactDeclaration(decl json.RawMessage) (json.RawMessage, error) {
+	var obj interface{}
+	if err := json.Unmarshal(decl, &obj); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(debug.Redact(obj)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSpace(buf.Bytes()), nil
+}
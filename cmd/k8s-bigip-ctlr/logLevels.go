@@ -0,0 +1,284 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/debug"
+	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// logLevelsPath is where the log levels are served and changed
+const logLevelsPath = "/log-levels"
+
+// logLevelsController changes the log levels at runtime from the
+// /log-levels endpoint, the log levels ConfigMap and SIGUSR1/SIGUSR2.
+// Every change replaces the levels at once, see vlogger.SetLevels.
+type logLevelsController struct {
+	sync.Mutex
+	// Levels from the command line flags
+	flags log.Levels
+	// Levels from the flags overridden by the ConfigMap, restored by SIGUSR2
+	configured log.Levels
+	// Data of the log levels ConfigMap, nil when there is none
+	configMap map[string]string
+	// Bearer token required to change the levels over http
+	token string
+}
+
+func newLogLevelsController(flags log.Levels, token string) *logLevelsController {
+	return &logLevelsController{
+		flags:      flags,
+		configured: flags,
+		token:      token,
+	}
+}
+
+// validateLevels checks that l only sets known components
+func validateLevels(l log.Levels) error {
+	for component := range l.Components {
+		known := false
+		for _, c := range log.Components {
+			known = known || c == strings.ToLower(component)
+		}
+		if !known {
+			return fmt.Errorf("Unknown log component %q, valid components are: %s",
+				component, strings.Join(log.Components, ", "))
+		}
+	}
+	return nil
+}
+
+// parseComponentLogLevels parses the --component-log-levels flag value
+func parseComponentLogLevels(spec string, global log.LogLevel) (log.Levels, error) {
+	l, err := log.ParseLevels(spec, global)
+	if err != nil {
+		return l, err
+	}
+	return l, validateLevels(l)
+}
+
+// ServeHTTP returns the current levels on GET. PUT takes a JSON object like
+// {"global": "info", "components": {"as3": "debug"}} and changes the given
+// levels, keeping the others.
+func (lc *logLevelsController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !debug.Authorized(r, lc.token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var update log.Levels
+		if err = json.Unmarshal(body, &update); err == nil {
+			err = validateLevels(update)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// Unset fields keep their level
+		levels := log.GetLevels()
+		if err = json.Unmarshal(body, &levels); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		lc.apply(levels, "http request")
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, _ := json.Marshal(log.GetLevels())
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+func (lc *logLevelsController) apply(levels log.Levels, source string) {
+	log.SetLevels(levels)
+	body, _ := json.Marshal(levels)
+	log.Infof("[INIT] Log levels changed by %v: %s", source, body)
+}
+
+// handleSignals sets all levels to DEBUG on SIGUSR1 and restores the
+// configured levels on SIGUSR2
+func (lc *logLevelsController) handleSignals(stopCh <-chan struct{}) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(sigs)
+	for {
+		select {
+		case sig := <-sigs:
+			lc.handleSignal(sig)
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (lc *logLevelsController) handleSignal(sig os.Signal) {
+	switch sig {
+	case syscall.SIGUSR1:
+		levels := log.Levels{
+			Global:     log.LL_DEBUG,
+			Components: make(map[string]log.LogLevel),
+		}
+		for _, component := range log.Components {
+			levels.Components[component] = log.LL_DEBUG
+		}
+		lc.apply(levels, "signal "+sig.String())
+	case syscall.SIGUSR2:
+		lc.Lock()
+		levels := lc.configured
+		lc.Unlock()
+		lc.apply(levels, "signal "+sig.String())
+	}
+}
+
+// levelsFromConfigMap overrides the flag levels with the ConfigMap data,
+// keyed by "global" or a component name
+func levelsFromConfigMap(flags log.Levels, data map[string]string) (log.Levels, error) {
+	var pairs []string
+	for key, value := range data {
+		pairs = append(pairs, strings.TrimSpace(key)+"="+strings.TrimSpace(value))
+	}
+	levels, err := parseComponentLogLevels(strings.Join(pairs, ","), flags.Global)
+	if err != nil {
+		return levels, err
+	}
+	for component, level := range flags.Components {
+		if _, ok := levels.Components[component]; !ok {
+			levels.Components[component] = level
+		}
+	}
+	return levels, nil
+}
+
+func (lc *logLevelsController) setConfigured(levels log.Levels, source string) {
+	lc.Lock()
+	lc.configured = levels
+	lc.Unlock()
+	lc.apply(levels, source)
+}
+
+// setFlagLevels replaces the flag levels, e.g. on changes of the config
+// file, keeping the overrides of the ConfigMap
+func (lc *logLevelsController) setFlagLevels(flags log.Levels, source string) {
+	lc.Lock()
+	if reflect.DeepEqual(flags, lc.flags) {
+		lc.Unlock()
+		return
+	}
+	lc.flags = flags
+	data := lc.configMap
+	lc.Unlock()
+	levels := flags
+	if data != nil {
+		var err error
+		if levels, err = levelsFromConfigMap(flags, data); err != nil {
+			log.Errorf("[INIT] Invalid log levels in the ConfigMap, keeping current levels: %v", err)
+			return
+		}
+	}
+	lc.setConfigured(levels, source)
+}
+
+// watchConfigMap applies the levels of the ConfigMap whenever it changes
+// and restores the flag levels when it is deleted
+func (lc *logLevelsController) watchConfigMap(
+	kubeClient kubernetes.Interface,
+	namespace string,
+	name string,
+	stopCh <-chan struct{},
+) {
+	source := "ConfigMap " + namespace + "/" + name
+	handle := func(obj interface{}) {
+		cm, ok := obj.(*v1.ConfigMap)
+		if !ok {
+			return
+		}
+		lc.Lock()
+		flags := lc.flags
+		lc.Unlock()
+		levels, err := levelsFromConfigMap(flags, cm.Data)
+		if err != nil {
+			log.Errorf("[INIT] Invalid log levels in %v, keeping current levels: %v", source, err)
+			return
+		}
+		lc.Lock()
+		lc.configMap = cm.Data
+		lc.Unlock()
+		lc.setConfigured(levels, source)
+	}
+
+	configMaps := kubeClient.CoreV1().ConfigMaps(namespace)
+	log.Infof("[INIT] Watching log levels %v", source)
+	watchNamedObject(
+		name,
+		&v1.ConfigMap{},
+		func(options metav1.ListOptions) (runtime.Object, error) { return configMaps.List(options) },
+		configMaps.Watch,
+		&cache.ResourceEventHandlerFuncs{
+			AddFunc:    handle,
+			UpdateFunc: func(old, cur interface{}) { handle(cur) },
+			DeleteFunc: func(obj interface{}) {
+				lc.Lock()
+				lc.configMap = nil
+				flags := lc.flags
+				lc.Unlock()
+				lc.setConfigured(flags, source+" deleted")
+			},
+		},
+		stopCh,
+	)
+}
+
+// setupLogLevels serves the log levels at /log-levels when the debug
+// endpoints are enabled, and changes them on signals and ConfigMap updates
+func setupLogLevels(stopCh <-chan struct{}) *logLevelsController {
+	lc := newLogLevelsController(flagLevels, *debugToken)
+	if *debugEndpoints {
+		http.Handle(logLevelsPath, lc)
+	}
+	go lc.handleSignals(stopCh)
+	if len(*logLevelsCM) > 0 && kubeClient != nil {
+		cm := strings.Split(*logLevelsCM, "/")
+		lc.watchConfigMap(kubeClient, cm[0], cm[1], stopCh)
+	}
+	return lc
+}
@@ -0,0 +1,151 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Log Levels Tests", func() {
+	var lc *logLevelsController
+	var saved log.Levels
+
+	flagsLevels := log.Levels{
+		Global:     log.LL_INFO,
+		Components: map[string]log.LogLevel{"as3": log.LL_DEBUG},
+	}
+
+	BeforeEach(func() {
+		saved = log.GetLevels()
+		log.SetLevels(flagsLevels)
+		lc = newLogLevelsController(flagsLevels, "secret")
+	})
+	AfterEach(func() {
+		log.SetLevels(saved)
+	})
+
+	request := func(method, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, logLevelsPath, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		lc.ServeHTTP(rec, req)
+		return rec
+	}
+
+	It("serves and changes the levels over http", func() {
+		Expect(request(http.MethodGet, "", "").Code).To(Equal(http.StatusUnauthorized))
+		Expect(request(http.MethodGet, "", "wrong").Code).To(Equal(http.StatusUnauthorized))
+
+		rec := request(http.MethodGet, "", "secret")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(MatchJSON(
+			`{"global": "info", "components": {"as3": "debug"}}`))
+
+		rec = request(http.MethodPut, `{"components": {"vxlan": "error"}}`, "secret")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(MatchJSON(
+			`{"global": "info", "components": {"as3": "debug", "vxlan": "error"}}`))
+
+		rec = request(http.MethodPut, `{"global": "warning"}`, "secret")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(log.GetLogLevel()).To(Equal(log.LogLevel(log.LL_WARNING)))
+
+		Expect(request(http.MethodPut, `{"components": {"python": "debug"}}`, "secret").Code).
+			To(Equal(http.StatusBadRequest))
+		Expect(request(http.MethodPut, `{"global": "verbose"}`, "secret").Code).
+			To(Equal(http.StatusBadRequest))
+		Expect(request(http.MethodDelete, "", "secret").Code).
+			To(Equal(http.StatusMethodNotAllowed))
+		Expect(log.GetLogLevel()).To(Equal(log.LogLevel(log.LL_WARNING)))
+	})
+
+	It("raises the levels on SIGUSR1 and restores them on SIGUSR2", func() {
+		lc.handleSignal(syscall.SIGUSR1)
+		levels := log.GetLevels()
+		Expect(levels.Global).To(Equal(log.LogLevel(log.LL_DEBUG)))
+		for _, component := range log.Components {
+			Expect(levels.Components[component]).To(Equal(log.LogLevel(log.LL_DEBUG)))
+		}
+
+		lc.handleSignal(syscall.SIGUSR2)
+		Expect(log.GetLevels()).To(Equal(flagsLevels))
+	})
+
+	It("applies the levels of the ConfigMap", func() {
+		kubeClient := fake.NewSimpleClientset()
+		stopCh := make(chan struct{})
+		defer close(stopCh)
+		lc.watchConfigMap(kubeClient, "kube-system", "cis-log-levels", stopCh)
+
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cis-log-levels", Namespace: "kube-system"},
+			Data:       map[string]string{"global": "error", "vxlan": "debug"},
+		}
+		_, err := kubeClient.CoreV1().ConfigMaps("kube-system").Create(cm)
+		Expect(err).To(BeNil())
+		expected := log.Levels{
+			Global: log.LL_ERROR,
+			Components: map[string]log.LogLevel{
+				"as3":   log.LL_DEBUG,
+				"vxlan": log.LL_DEBUG,
+			},
+		}
+		Eventually(log.GetLevels, 5*time.Second).Should(Equal(expected))
+
+		// Invalid levels keep the current ones
+		cm.Data = map[string]string{"as3": "verbose"}
+		_, err = kubeClient.CoreV1().ConfigMaps("kube-system").Update(cm)
+		Expect(err).To(BeNil())
+		Consistently(log.GetLevels, 500*time.Millisecond).Should(Equal(expected))
+
+		// SIGUSR2 restores the ConfigMap levels, not the flags
+		lc.handleSignal(syscall.SIGUSR1)
+		lc.handleSignal(syscall.SIGUSR2)
+		Expect(log.GetLevels()).To(Equal(expected))
+
+		err = kubeClient.CoreV1().ConfigMaps("kube-system").Delete("cis-log-levels", nil)
+		Expect(err).To(BeNil())
+		Eventually(log.GetLevels, 5*time.Second).Should(Equal(flagsLevels))
+	})
+
+	It("keeps the ConfigMap levels when the flag levels change", func() {
+		lc.configMap = map[string]string{"vxlan": "debug"}
+		lc.setFlagLevels(log.Levels{Global: log.LL_WARNING}, "config file")
+		Expect(log.GetLevels()).To(Equal(log.Levels{
+			Global:     log.LL_WARNING,
+			Components: map[string]log.LogLevel{"vxlan": log.LL_DEBUG},
+		}))
+
+		lc.configMap = nil
+		lc.setFlagLevels(flagsLevels, "config file")
+		Expect(log.GetLevels()).To(Equal(flagsLevels))
+	})
+})
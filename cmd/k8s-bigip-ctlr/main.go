@@ -17,25 +17,27 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/crmanager"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/debug"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/health"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/icontrol"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/l2l3"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/pollers"
-	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/staticroute"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vxlan"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/writer"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	//"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent/cccl"
-	"io/ioutil"
 
 	v1 "k8s.io/api/core/v1"
 
 	//"net/http"
-	"net/url"
 	"os"
 	"os/signal"
 	"strings"
@@ -47,9 +49,11 @@ import (
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent/cccl"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/appmanager"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
 
 	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 	clog "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger/console"
+	jlog "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger/jsonlog"
 
 	//"github.com/prometheus/client_golang/prometheus/promhttp"
 
@@ -57,6 +61,7 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -110,10 +115,22 @@ var (
 
 	pythonBaseDir    *string
 	logLevel         *string
+	logFormat        *string
+	componentLevels  *string
+	logLevelsCM      *string
 	verifyInterval   *int
 	nodePollInterval *int
+	watchNodes       *bool
 	printVersion     *bool
 	httpAddress      *string
+	readyPostTimeout *int
+	debugEndpoints   *bool
+	debugToken       *string
+	tracingExporter  *string
+	otlpEndpoint     *string
+	otlpHeaders      *[]string
+	configFile       *string
+	configReload     *int
 	dgPath           string
 
 	namespaces             *[]string
@@ -133,12 +150,28 @@ var (
 	schemaLocal            *string
 	manageIngressClassOnly *bool
 	ingressClass           *string
+	ingressClassController *string
+	endpointSlices         *bool
+	manageGateways         *bool
+	gatewayControllerName  *string
+	manageLoadBalancers    *bool
+	loadBalancerClass      *string
+	lbAddressRangeStr      *string
+	memberDrainPeriod      *int
+	nodePortHealthMonitor  *bool
 
 	bigIPURL                  *string
 	bigIPUsername             *string
 	bigIPPassword             *string
 	bigIPPartitions           *[]string
 	credsDir                  *string
+	credsSecret               *string
+	credsReloadInterval       *int
+	bigIPZone                 *string
+	tokenAuth                 *bool
+	driftCheckInterval        *int
+	driftCheckTenants         *[]string
+	loginProvider             *string
 	as3Validation             *bool
 	sslInsecure               *bool
 	enableTLS                 *string
@@ -146,6 +179,15 @@ var (
 	ciphers                   *string
 	trustedCerts              *string
 	as3PostDelay              *int
+	as3RetryInitialDelay      *int
+	as3RetryMaxDelay          *int
+	as3RetryMultiplier        *float64
+	as3RetryJitter            *float64
+	as3BreakerThreshold       *int
+	as3HistorySize            *int
+	as3HistoryDir             *string
+	as3HistorySecret          *string
+	as3Rollback               *bool
 
 	trustedCertsCfgmap *string
 	agent              *string
@@ -154,9 +196,11 @@ var (
 	userDefinedAS3Decl *string
 	filterTenants      *bool
 
-	vxlanMode        string
-	openshiftSDNName *string
-	flannelName      *string
+	vxlanMode         string
+	openshiftSDNName  *string
+	flannelName       *string
+	ovnK8sName        *string
+	staticRoutingMode *bool
 
 	routeVserverAddr *string
 	routeLabel       *string
@@ -168,12 +212,16 @@ var (
 	// package variables
 	isNodePort         bool
 	watchAllNamespaces bool
+	lbAddressRange     *resource.AddressRange
 	vxlanName          string
+	tunnelType         string
 	kubeClient         kubernetes.Interface
 	agRspChan          chan interface{}
 	eventChan          chan interface{}
 	configWriter       writer.Writer
+	bigIPClient        *icontrol.Client
 	k8sVersion         string
+	flagLevels         log.Levels
 )
 
 func _init() {
@@ -200,14 +248,47 @@ func _init() {
 		"DEPRECATED: Optional, directory location of python utilities")
 	logLevel = globalFlags.String("log-level", "INFO",
 		"Optional, logging level")
+	logFormat = globalFlags.String("log-format", "console",
+		"Optional, format of the log output, 'console' or 'json'.")
+	componentLevels = globalFlags.String("component-log-levels", "",
+		"Optional, comma separated logging levels of components overriding --log-level, "+
+			"e.g. as3=DEBUG,vxlan=WARNING. Components are as3, cccl, crmanager, appmanager, "+
+			"vxlan and pollers.")
+	logLevelsCM = globalFlags.String("log-levels-configmap", "",
+		"Optional, <namespace>/<configmap-name> of a ConfigMap watched for logging levels, "+
+			"keyed by 'global' or a component name. Its levels override the flags.")
 	verifyInterval = globalFlags.Int("verify-interval", 30,
 		"Optional, interval (in seconds) at which to verify the BIG-IP configuration.")
 	nodePollInterval = globalFlags.Int("node-poll-interval", 30,
 		"Optional, interval (in seconds) at which to poll for cluster nodes.")
+	watchNodes = globalFlags.Bool("watch-nodes", false,
+		"Optional, default `false`. Watch the cluster nodes and process their changes right "+
+			"away, instead of polling them every --node-poll-interval seconds.")
 	printVersion = globalFlags.Bool("version", false,
 		"Optional, print version and exit.")
 	httpAddress = globalFlags.String("http-listen-address", "0.0.0.0:8080",
 		"Optional, address to serve http based informations (/metrics and /health).")
+	readyPostTimeout = globalFlags.Int("ready-post-timeout", 10,
+		"Optional, time (in minutes) after which /health/ready reports failure while "+
+			"posts to BIG-IP keep failing. 0 disables the check.")
+	debugEndpoints = globalFlags.Bool("debug-endpoints", false,
+		"Optional, serve the internal state of the controller at /debug/ on the http-listen-address.")
+	debugToken = globalFlags.String("debug-token", "",
+		"Optional, bearer token required to access the /debug/ endpoints.")
+	tracingExporter = globalFlags.String("tracing-exporter", "none",
+		"Optional, exporter of the traces from resource events to BIG-IP posts, 'none' or 'otlp'.")
+	otlpEndpoint = globalFlags.String("otlp-endpoint", tracing.DefaultOTLPEndpoint,
+		"Optional, OTLP/HTTP endpoint receiving the traces with --tracing-exporter=otlp.")
+	otlpHeaders = globalFlags.StringArray("otlp-header", []string{},
+		"Optional, <name>=<value> header added to the OTLP requests, can be repeated.")
+
+	configFile = globalFlags.String("config-file", "",
+		"Optional, YAML file of the options, keyed by flag name in the sections global, bigip, "+
+			"kubernetes, vxlan and openshift-routes. Command line flags override the file.")
+	configReload = globalFlags.Int("config-reload-interval", 10,
+		"Optional, interval (in seconds) at which to reload log-level, verify-interval, "+
+			"node-label-selector, default-client-ssl, default-server-ssl and vs-snat-pool-name "+
+			"from the config-file. 0 disables the reload.")
 
 	// Custom Resource
 	customResourceMode = globalFlags.Bool("custom-resource-mode", false,
@@ -229,12 +310,53 @@ func _init() {
 	credsDir = bigIPFlags.String("credentials-directory", "",
 		"Optional, directory that contains the BIG-IP username, password, and/or "+
 			"url files. To be used instead of username, password, and/or url arguments.")
+	credsSecret = bigIPFlags.String("credentials-secret", "",
+		"Optional, provide Namespace and Name of a Secret as <namespace>/<secret-name> holding "+
+			"BIG-IP username, password and/or url keys. Changes to the Secret are applied without restart.")
+	credsReloadInterval = bigIPFlags.Int("credentials-reload-interval", 10,
+		"Optional, interval (in seconds) at which to re-read the credentials directory. "+
+			"0 disables reloading.")
+	bigIPZone = bigIPFlags.String("bigip-zone", "",
+		"Optional, zone of the BIG-IP, as in the topology.kubernetes.io/zone label of the nodes. "+
+			"The pool members in this zone take precedence in the zone aware pools. Requires the AS3 agent.")
+	tokenAuth = bigIPFlags.Bool("token-auth", false,
+		"Optional, when set to true, CIS logs in to BIG-IP and authenticates requests with an "+
+			"auth token instead of basic auth.")
+	loginProvider = bigIPFlags.String("login-provider", "tmos",
+		"Optional, BIG-IP login provider (e.g. a TACACS+ or LDAP provider) used for token authentication.")
 	as3Validation = bigIPFlags.Bool("as3-validation", true,
 		"Optional, when set to false, disables as3 template validation on the controller.")
 	sslInsecure = bigIPFlags.Bool("insecure", false,
 		"Optional, when set to true, enable insecure SSL communication to BIGIP.")
 	as3PostDelay = bigIPFlags.Int("as3-post-delay", 0,
 		"Optional, time (in seconds) that CIS waits to post the available AS3 declaration.")
+	as3RetryInitialDelay = bigIPFlags.Int("as3-retry-initial-delay", 3,
+		"Optional, time (in seconds) that CIS waits before re-posting a failed AS3 declaration.")
+	as3RetryMaxDelay = bigIPFlags.Int("as3-retry-max-delay", 60,
+		"Optional, maximum time (in seconds) that CIS waits between re-posts of a failed AS3 declaration.")
+	as3RetryMultiplier = bigIPFlags.Float64("as3-retry-multiplier", 2,
+		"Optional, factor by which the wait between re-posts of a failed AS3 declaration grows.")
+	as3RetryJitter = bigIPFlags.Float64("as3-retry-jitter", 0.2,
+		"Optional, fraction (0-1) by which the wait between re-posts is randomized.")
+	as3BreakerThreshold = bigIPFlags.Int("as3-circuit-breaker-threshold", 5,
+		"Optional, consecutive AS3 posts without response or with a 5xx or 404 response after which "+
+			"CIS stops posting and only probes BIG-IP until it is available. 0 disables the circuit breaker.")
+	driftCheckInterval = bigIPFlags.Int("drift-check-interval", 0,
+		"Optional, interval (in seconds) at which CIS compares the posted AS3 tenants with the "+
+			"declaration stored by AS3 and re-posts tenants re-declared outside of CIS. 0 disables "+
+			"drift detection.")
+	driftCheckTenants = bigIPFlags.StringArray("drift-check-tenant", []string{},
+		"Optional, AS3 tenant(s) to check for drift. If left blank all posted tenants are checked.")
+	as3HistorySize = bigIPFlags.Int("as3-history-size", as3.DefaultHistorySize,
+		"Optional, number of AS3 declarations accepted by BIG-IP kept in the declaration history.")
+	as3HistoryDir = bigIPFlags.String("as3-history-dir", "",
+		"Optional, directory in which the AS3 declaration history is kept.")
+	as3HistorySecret = bigIPFlags.String("as3-history-secret", "",
+		"Optional, <namespace>/<name> of the AS3 declaration history kept in Secrets, one Secret "+
+			"named <name>-<id> per declaration.")
+	as3Rollback = bigIPFlags.Bool("as3-rollback", false,
+		"Optional, when set to true, CIS re-posts the last AS3 declaration accepted by BIG-IP "+
+			"when BIG-IP rejects a declaration, instead of retrying it.")
 	logAS3Response = bigIPFlags.Bool("log-as3-response", false,
 		"Optional, when set to true, add the body of AS3 API response in Controller logs.")
 	enableTLS = bigIPFlags.String("tls-version", "1.2",
@@ -308,6 +430,36 @@ func _init() {
 			"resources that belong to its class - i.e. have the annotation `kubernetes.io/ingress.class` equal to the class."+
 			"Additionally, the Ingress controller processes Ingress resources that do not have that annotation,"+
 			"which can be disabled by setting the `-manage-ingress-class-only` flag")
+	ingressClassController = kubeFlags.String("ingress-class-controller", "f5.com/cntr-ingress-svcs",
+		"Optional, default `f5.com/cntr-ingress-svcs`. The controller of the networking.k8s.io/v1 "+
+			"IngressClasses whose Ingresses the controller processes.")
+
+	endpointSlices = kubeFlags.Bool("endpoint-slices", false,
+		"Optional, discover the pool members of cluster mode from discovery.k8s.io/v1 "+
+			"EndpointSlices instead of Endpoints. Requires Kubernetes 1.21 or later.")
+	manageGateways = kubeFlags.Bool("manage-gateways", false,
+		"Optional, default `false`. Process the Gateways and HTTPRoutes, TLSRoutes and TCPRoutes of "+
+			"the Kubernetes Gateway API. Requires the AS3 agent.")
+	gatewayControllerName = kubeFlags.String("gateway-controller-name", "f5.com/k8s-bigip-ctlr",
+		"Optional, default `f5.com/k8s-bigip-ctlr`. The controller name of the GatewayClasses "+
+			"whose Gateways the controller processes.")
+	manageLoadBalancers = kubeFlags.Bool("manage-load-balancers", false,
+		"Optional, default `false`. Create a layer 4 virtual server for each port of the "+
+			"Services of type LoadBalancer and write its address to their status.")
+	loadBalancerClass = kubeFlags.String("load-balancer-class", "",
+		"Optional, only process the LoadBalancer Services with this spec.loadBalancerClass. "+
+			"By default only the LoadBalancer Services without class are processed. "+
+			"Requires Kubernetes 1.21 or later.")
+	lbAddressRangeStr = kubeFlags.String("load-balancer-address-range", "",
+		"Optional, range <first>-<last> of the addresses allocated to the LoadBalancer "+
+			"Services without spec.loadBalancerIP or virtual-server.f5.com/ip annotation.")
+	memberDrainPeriod = kubeFlags.Int("pool-member-drain-period", 0,
+		"Optional, default `0` (disabled). Seconds during which the pool members of cluster mode "+
+			"whose pod is no longer ready or is terminating are kept disabled at most, "+
+			"so that their connections drain.")
+	nodePortHealthMonitor = kubeFlags.Bool("node-port-health-monitor", false,
+		"Optional, default `false`. In nodeport mode, monitor the pool members of the Services "+
+			"with externalTrafficPolicy Local on their healthCheckNodePort. Requires the AS3 agent.")
 
 	// If the flag is specified with no argument, default to LOOKUP
 	kubeFlags.Lookup("resolve-ingress-names").NoOptDefVal = "LOOKUP"
@@ -329,6 +481,12 @@ func _init() {
 	flannelName = vxlanFlags.String("flannel-name", "",
 		"Must be provided for BigIP Flannel integration, "+
 			"full path of BigIP Flannel VxLAN Tunnel")
+	ovnK8sName = vxlanFlags.String("ovn-kubernetes-name", "",
+		"Must be provided for BigIP OVN-Kubernetes integration, "+
+			"full path of BigIP OVN-Kubernetes hybrid overlay VxLAN Tunnel")
+	staticRoutingMode = vxlanFlags.Bool("static-routing-mode", false,
+		"Optional, default `false`. In cluster mode without VXLAN tunnel, create a static route "+
+			"on BIG-IP to the pod CIDR of each node via the node address.")
 
 	vxlanFlags.Usage = func() {
 		fmt.Fprintf(os.Stderr, "  Openshift SDN:\n%s\n", vxlanFlags.FlagUsagesWrapped(width))
@@ -370,16 +528,30 @@ func _init() {
 	}
 }
 
-func initLogger(logLevel string) error {
-	log.RegisterLogger(
-		log.LL_MIN_LEVEL, log.LL_MAX_LEVEL, clog.NewConsoleLogger())
-
-	if ll := log.NewLogLevel(logLevel); nil != ll {
-		log.SetLogLevel(*ll)
-	} else {
+func initLogger(logLevel, logFormat, componentLevels string) error {
+	switch strings.ToLower(logFormat) {
+	case "console":
+		log.RegisterLogger(
+			log.LL_MIN_LEVEL, log.LL_MAX_LEVEL, clog.NewConsoleLogger())
+	case "json":
+		log.RegisterLogger(
+			log.LL_MIN_LEVEL, log.LL_MAX_LEVEL, jlog.NewJSONLogger())
+	default:
+		return fmt.Errorf("Unknown log format requested: %s\n"+
+			"    Valid log formats are: console, json", logFormat)
+	}
+
+	ll := log.NewLogLevel(logLevel)
+	if nil == ll {
 		return fmt.Errorf("Unknown log level requested: %s\n"+
 			"    Valid log levels are: DEBUG, INFO, WARNING, ERROR, CRITICAL", logLevel)
 	}
+	levels, err := parseComponentLogLevels(componentLevels, *ll)
+	if nil != err {
+		return fmt.Errorf("Invalid value provided for --component-log-levels: %v", err)
+	}
+	flagLevels = levels
+	log.SetLevels(flagLevels)
 	return nil
 }
 
@@ -398,8 +570,11 @@ func hasCommonPartition(partitions []string) bool {
 }
 
 func verifyArgs() error {
+	if err := loadConfigFile(*configFile); err != nil {
+		return err
+	}
 	*logLevel = strings.ToUpper(*logLevel)
-	logErr := initLogger(*logLevel)
+	logErr := initLogger(*logLevel, *logFormat, *componentLevels)
 	if nil != logErr {
 		return logErr
 	}
@@ -443,6 +618,10 @@ func verifyArgs() error {
 	if len(*openshiftSDNName) > 0 && len(*flannelName) > 0 {
 		return fmt.Errorf("Cannot have both openshift-sdn-name and flannel-name specified.")
 	}
+	if len(*ovnK8sName) > 0 && (len(*openshiftSDNName) > 0 || len(*flannelName) > 0) {
+		return fmt.Errorf("Cannot have ovn-kubernetes-name with openshift-sdn-name " +
+			"or flannel-name specified.")
+	}
 
 	if flags.Changed("openshift-sdn-name") {
 		if len(*openshiftSDNName) == 0 {
@@ -454,6 +633,7 @@ func verifyArgs() error {
 		}
 		vxlanMode = "maintain"
 		vxlanName = *openshiftSDNName
+		tunnelType = vxlan.OpenShiftSDN
 	} else if flags.Changed("flannel-name") {
 		if len(*flannelName) == 0 {
 			return fmt.Errorf("Missing required parameter flannel-name")
@@ -464,6 +644,28 @@ func verifyArgs() error {
 		}
 		vxlanMode = "maintain"
 		vxlanName = *flannelName
+		tunnelType = vxlan.Flannel
+	} else if flags.Changed("ovn-kubernetes-name") {
+		if len(*ovnK8sName) == 0 {
+			return fmt.Errorf("Missing required parameter ovn-kubernetes-name")
+		}
+		if isNodePort {
+			return fmt.Errorf("Cannot run NodePort mode while supplying ovn-kubernetes-name. " +
+				"Must be in Cluster mode if using VxLAN.")
+		}
+		vxlanMode = "maintain"
+		vxlanName = *ovnK8sName
+		tunnelType = vxlan.OVNKubernetes
+	}
+	if *staticRoutingMode {
+		if len(vxlanName) > 0 {
+			return fmt.Errorf("Cannot use --static-routing-mode with openshift-sdn-name, " +
+				"flannel-name or ovn-kubernetes-name.")
+		}
+		if isNodePort {
+			return fmt.Errorf("Cannot run NodePort mode with --static-routing-mode. " +
+				"Must be in Cluster mode if using static routes.")
+		}
 	}
 	if *manageRoutes {
 		if len(*routeVserverAddr) == 0 {
@@ -476,79 +678,88 @@ func verifyArgs() error {
 				"Usage: --override-as3-declaration=<namespace>/<configmap-name>")
 		}
 	}
+	if *credsSecret != "" {
+		if len(strings.Split(*credsSecret, "/")) != 2 {
+			return fmt.Errorf("Invalid value provided for --credentials-secret" +
+				"Usage: --credentials-secret=<namespace>/<secret-name>")
+		}
+	}
+	switch *tracingExporter {
+	case "none", "otlp":
+	default:
+		return fmt.Errorf("Unknown tracing exporter requested: %s\n"+
+			"    Valid tracing exporters are: none, otlp", *tracingExporter)
+	}
+	if *as3HistorySecret != "" {
+		if len(strings.Split(*as3HistorySecret, "/")) != 2 {
+			return fmt.Errorf("Invalid value provided for --as3-history-secret" +
+				"Usage: --as3-history-secret=<namespace>/<name>")
+		}
+		if *as3HistoryDir != "" {
+			return fmt.Errorf("Cannot use both --as3-history-dir and --as3-history-secret")
+		}
+	}
+	for _, header := range *otlpHeaders {
+		if !strings.Contains(header, "=") {
+			return fmt.Errorf("Invalid value provided for --otlp-header" +
+				"Usage: --otlp-header=<name>=<value>")
+		}
+	}
+	if *logLevelsCM != "" {
+		if len(strings.Split(*logLevelsCM, "/")) != 2 {
+			return fmt.Errorf("Invalid value provided for --log-levels-configmap" +
+				"Usage: --log-levels-configmap=<namespace>/<configmap-name>")
+		}
+	}
 	if *userDefinedAS3Decl != "" {
 		if len(strings.Split(*userDefinedAS3Decl, "/")) != 2 {
 			return fmt.Errorf("Invalid value provided for --userdefined-as3-declaration" +
 				"Usage: --userdefined-as3-declaration=<namespace>/<configmap-name>")
 		}
 	}
+	if *manageGateways && strings.ToLower(*agent) != "as3" {
+		return fmt.Errorf("Cannot use --manage-gateways without --agent=as3")
+	}
+	if *bigIPZone != "" && strings.ToLower(*agent) != "as3" {
+		return fmt.Errorf("Cannot use --bigip-zone without --agent=as3")
+	}
+	if *nodePortHealthMonitor && strings.ToLower(*agent) != "as3" {
+		return fmt.Errorf("Cannot use --node-port-health-monitor without --agent=as3")
+	}
+	if *lbAddressRangeStr != "" {
+		var err error
+		lbAddressRange, err = resource.ParseAddressRange(*lbAddressRangeStr)
+		if nil != err {
+			return fmt.Errorf("Invalid value provided for --load-balancer-address-range: %v", err)
+		}
+	}
+	if *memberDrainPeriod < 0 {
+		return fmt.Errorf("Invalid value provided for --pool-member-drain-period, " +
+			"must be 0 or more seconds")
+	}
 	return nil
 }
 
 func getCredentials() error {
 	if len(*credsDir) > 0 {
-		var usr, pass, bigipURL string
-		var err error
-		if strings.HasSuffix(*credsDir, "/") {
-			usr = *credsDir + "username"
-			pass = *credsDir + "password"
-			bigipURL = *credsDir + "url"
-		} else {
-			usr = *credsDir + "/username"
-			pass = *credsDir + "/password"
-			bigipURL = *credsDir + "/url"
-		}
-
-		setField := func(field *string, filename, fieldType string) error {
-			fileBytes, readErr := ioutil.ReadFile(filename)
-			if readErr != nil {
-				log.Debug(fmt.Sprintf(
-					"No %s in credentials directory, falling back to CLI argument", fieldType))
-				if len(*field) == 0 {
-					return fmt.Errorf(fmt.Sprintf("BIG-IP %s not specified", fieldType))
-				}
-			} else {
-				*field = string(fileBytes)
-			}
-			return nil
-		}
-
-		err = setField(bigIPUsername, usr, "username")
-		if err != nil {
-			return err
-		}
-		err = setField(bigIPPassword, pass, "password")
-		if err != nil {
-			return err
-		}
-		err = setField(bigIPURL, bigipURL, "url")
+		creds, err := readCredentialsDir(*credsDir, bigIPCredentials{
+			URL:      *bigIPURL,
+			Username: *bigIPUsername,
+			Password: *bigIPPassword,
+		})
 		if err != nil {
 			return err
 		}
+		*bigIPURL = creds.URL
+		*bigIPUsername = creds.Username
+		*bigIPPassword = creds.Password
 	}
 	// Verify URL is valid
-	u, err := url.Parse(*bigIPURL)
-	if nil != err {
-		return fmt.Errorf("Error parsing url: %s", err)
-	}
-
-	if len(u.Scheme) == 0 {
-		*bigIPURL = "https://" + *bigIPURL
-		u, err = url.Parse(*bigIPURL)
-		if nil != err {
-			return fmt.Errorf("Error parsing url: %s", err)
-		}
-	}
-
-	if u.Scheme != "https" {
-		return fmt.Errorf("Invalid BIGIP-URL protocol: '%s' - Must be 'https'",
-			u.Scheme)
-	}
-
-	if len(u.Path) > 0 && u.Path != "/" {
-		return fmt.Errorf("BIGIP-URL path must be empty or '/'; check URL formatting and/or remove %s from path",
-			u.Path)
+	u, err := normalizeBigIPURL(*bigIPURL)
+	if err != nil {
+		return err
 	}
+	*bigIPURL = u
 	return nil
 }
 
@@ -573,9 +784,14 @@ func setupNodePolling(
 		if slashPos != -1 {
 			tunnelName = cleanPath[slashPos+1:]
 		}
+		discovery, err := vxlan.NewTunnelDiscovery(tunnelType)
+		if nil != err {
+			return fmt.Errorf("error creating vxlan manager: %v", err)
+		}
 		vxMgr, err := vxlan.NewVxlanMgr(
 			vxlanMode,
 			tunnelName,
+			discovery,
 			appMgr.UseNodeInternal(),
 			getConfigWriter(),
 			eventChanl,
@@ -595,6 +811,15 @@ func setupNodePolling(
 		}
 	}
 
+	if routeMgr := getStaticRouteMgr(); nil != routeMgr {
+		// Register routeMgr to watch for node updates to route pod CIDRs
+		err = np.RegisterListener(routeMgr.ProcessNodeUpdate)
+		if nil != err {
+			return fmt.Errorf("error registering node update listener for static routes: %v",
+				err)
+		}
+	}
+
 	return nil
 }
 
@@ -671,6 +896,8 @@ func initCustomResourceManager(
 		SSLInsecure:   true,
 		AS3PostDelay:  *as3PostDelay,
 		LogResponse:   *logAS3Response,
+		TokenAuth:     *tokenAuth,
+		LoginProvider: *loginProvider,
 	}
 
 	agentParams := crmanager.AgentParams{
@@ -679,7 +906,7 @@ func initCustomResourceManager(
 		LogLevel:       *logLevel,
 		VerifyInterval: *verifyInterval,
 		VXLANName:      vxlanName,
-		PythonBaseDir:  *pythonBaseDir,
+		ConfigWriter:   l2l3.NewWriter(getBigIPClient()),
 	}
 	agent := crmanager.NewAgent(agentParams)
 
@@ -692,16 +919,63 @@ func initCustomResourceManager(
 			ControllerMode:    *poolMemberType,
 			VXLANName:         vxlanName,
 			VXLANMode:         vxlanMode,
+			TunnelType:        tunnelType,
 			UseNodeInternal:   *useNodeInternal,
 			NodePollInterval:  *nodePollInterval,
+			WatchNodes:        *watchNodes,
 			NodeLabelSelector: *nodeLabelSelector,
+			UseEndpointSlices: *endpointSlices,
+			LocalZone:         *bigIPZone,
+			StaticRouteMgr:    getStaticRouteMgr(),
 		},
 	)
 
 	return crMgr
 }
 
+// as3CheckTTL keeps the result of the AS3 endpoint check for the default
+// probe period of Kubernetes, so that readiness probes do not load BIG-IP
+const as3CheckTTL = 10 * time.Second
+
+// setupHealthChecks registers the liveness and readiness checks and serves
+// them at /health/live and /health/ready. The BIG-IP checks are added when
+// the agent posts AS3 declarations.
+func setupHealthChecks(
+	hc *health.HealthChecker,
+	subPid int,
+	informersSynced func() bool,
+	agent interface{},
+) {
+	if subPid != 0 {
+		hc.AddLivenessCheck("python-driver", health.ProcessCheck(subPid))
+	}
+	hc.AddReadinessCheck("informers-synced", health.InformersSyncedCheck(informersSynced))
+	if kubeClient != nil {
+		hc.AddReadinessCheck("kubernetes-api", health.KubernetesAPICheck(kubeClient.Discovery()))
+	}
+	if as3Agent, ok := agent.(interface {
+		GetBigipAS3Version() (string, error)
+	}); ok {
+		hc.AddReadinessCheck("bigip-as3",
+			health.AS3Check(as3Agent.GetBigipAS3Version, as3CheckTTL))
+	}
+	if postAgent, ok := agent.(interface{ UnsyncedSince() time.Time }); ok && *readyPostTimeout > 0 {
+		hc.AddReadinessCheck("last-post", health.LastPostCheck(postAgent.UnsyncedSince,
+			time.Duration(*readyPostTimeout)*time.Minute))
+	}
+	http.Handle("/health/live", hc.LiveHandler())
+	http.Handle("/health/ready", hc.ReadyHandler())
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == historyCommand {
+		if err := runHistoryCommand(os.Args[2:], os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	err := flags.Parse(os.Args)
 	if nil != err {
 		os.Exit(1)
@@ -726,6 +1000,7 @@ func main() {
 	}
 
 	log.Infof("[INIT] Starting: Container Ingress Services - Version: %s, BuildInfo: %s", version, buildInfo)
+	defer setupTracing()()
 
 	resource.DEFAULT_PARTITION = (*bigIPPartitions)[0]
 	dgPath = resource.DEFAULT_PARTITION
@@ -736,9 +1011,9 @@ func main() {
 	}
 	appmanager.RegisterBigIPSchemaTypes()
 
-	// If running with Flannel, create an event channel that the appManager
-	// uses to send endpoints to the VxlanManager
-	if len(*flannelName) > 0 {
+	// If running with Flannel or OVN-Kubernetes, create an event channel that
+	// the appManager uses to send endpoints to the VxlanManager
+	if len(*flannelName) > 0 || len(*ovnK8sName) > 0 {
 		eventChan = make(chan interface{})
 	}
 
@@ -763,10 +1038,39 @@ func main() {
 	}
 
 	if *customResourceMode {
+		if kubeClient, err = kubernetes.NewForConfig(config); err != nil {
+			log.Errorf("[INIT] error connecting to the client: %v", err)
+		}
 		crMgr := initCustomResourceManager(config)
+		crStopCh := make(chan struct{})
+		// Expose Prometheus metrics
+		http.Handle("/metrics", promhttp.Handler())
+		hc := &health.HealthChecker{}
+		http.Handle("/health", hc.HealthCheckHandler())
+		setupHealthChecks(hc, 0, crMgr.InformersSynced, crMgr.Agent)
+		if *debugEndpoints {
+			dbg := debug.NewServer(*debugToken)
+			crMgr.RegisterDebugState(dbg)
+			http.Handle(debug.PathPrefix, dbg)
+		}
+		lc := setupLogLevels(crStopCh)
+		go func() {
+			log.Fatal(http.ListenAndServe(*httpAddress, nil).Error())
+		}()
+		setupCredentialsWatcher(crStopCh, func(creds bigIPCredentials) {
+			crMgr.Agent.UpdateCredentials(creds.URL, creds.Username, creds.Password)
+			if nil != bigIPClient {
+				bigIPClient.UpdateCredentials(creds.URL, creds.Username, creds.Password)
+			}
+		})
+		setupConfigFileWatcher(crStopCh, lc, func(settings reloadableSettings) {
+			crMgr.Agent.UpdateGlobalSettings(settings.LogLevel, settings.VerifyInterval)
+			crMgr.SetNodeLabelSelector(settings.NodeLabelSelector)
+		})
 		sigs := make(chan os.Signal, 1)
 		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 		sig := <-sigs
+		close(crStopCh)
 		crMgr.Stop()
 		log.Infof("Exiting - signal %v\n", sig)
 		return
@@ -784,24 +1088,37 @@ func main() {
 		BigIPPartitions: *bigIPPartitions,
 	}
 
-	subPidCh, err := startPythonDriver(getConfigWriter(), gs, bs, *pythonBaseDir)
-	if nil != err {
-		log.Fatalf("Could not initialize subprocess configuration: %v", err)
-	}
-	subPid := <-subPidCh
-	defer func(pid int) {
-		if 0 != pid {
-			var proc *os.Process
-			proc, err = os.FindProcess(pid)
-			if nil != err {
-				log.Warningf("Failed to find sub-process on exit: %v", err)
-			}
-			err = proc.Signal(os.Interrupt)
-			if nil != err {
-				log.Warningf("Could not stop sub-process on exit: %d - %v", pid, err)
-			}
+	var subPid int
+	if *agent == cisAgent.AS3Agent {
+		// AS3 declares the LTM objects, the FDB records and ARP entries are
+		// applied with iControl REST without the python driver
+		configWriter = l2l3.NewWriter(getBigIPClient())
+		if err = initializeDriverConfig(configWriter, gs, bs); nil != err {
+			log.Fatalf("Could not initialize L2-L3 configuration: %v", err)
 		}
-	}(subPid)
+		go cleanupCCCLPartitions(getBigIPClient(), *bigIPPartitions,
+			time.Duration(*verifyInterval)*time.Second)
+	} else {
+		var subPidCh <-chan int
+		subPidCh, err = startPythonDriver(getConfigWriter(), gs, bs, *pythonBaseDir)
+		if nil != err {
+			log.Fatalf("Could not initialize subprocess configuration: %v", err)
+		}
+		subPid = <-subPidCh
+		defer func(pid int) {
+			if 0 != pid {
+				var proc *os.Process
+				proc, err = os.FindProcess(pid)
+				if nil != err {
+					log.Warningf("Failed to find sub-process on exit: %v", err)
+				}
+				err = proc.Signal(os.Interrupt)
+				if nil != err {
+					log.Warningf("Could not stop sub-process on exit: %d - %v", pid, err)
+				}
+			}
+		}(subPid)
+	}
 
 	if _, isSet := os.LookupEnv("SCALE_PERF_ENABLE"); isSet {
 		now := time.Now()
@@ -823,6 +1140,12 @@ func main() {
 
 	// creates the clientset
 	appMgrParms.KubeClient = kubeClient
+	if *endpointSlices || *manageGateways || *manageIngress || *manageLoadBalancers {
+		appMgrParms.DynamicClient, err = dynamic.NewForConfig(config)
+		if nil != err {
+			log.Fatalf("[INIT] unable to create dynamic client: err: %+v\n", err)
+		}
+	}
 	if *manageRoutes {
 		var rclient *routeclient.RouteV1Client
 		rclient, err = routeclient.NewForConfig(config)
@@ -854,8 +1177,9 @@ func main() {
 		os.Exit(1)
 	}
 	defer appMgr.AgentCIS.DeInit()
-	// Initlize CCCL for L2-L3 if agent is AS3
-	// TODO: this will be removed when L2-L3 support is added in AS3
+	// Initlize CCCL for L2-L3 if agent is AS3, it hands the pool members
+	// to the VxlanMgr, whose ARP entries and FDB records the config writer
+	// applies
 	if *agent == cisAgent.AS3Agent {
 		appMgr.AgentCCCL, err = cisAgent.CreateAgent(cisAgent.CCCLAgent)
 		if err = appMgr.AgentCCCL.Init(getAgentParams(cisAgent.CCCLAgent)); err != nil {
@@ -866,8 +1190,13 @@ func main() {
 	}
 
 	GetNamespaces(appMgr)
-	intervalFactor := time.Duration(*nodePollInterval)
-	np := pollers.NewNodePoller(appMgrParms.KubeClient, intervalFactor*time.Second, *nodeLabelSelector)
+	var np pollers.Poller
+	if *watchNodes {
+		np = pollers.NewNodeWatcher(appMgrParms.KubeClient, *nodeLabelSelector)
+	} else {
+		intervalFactor := time.Duration(*nodePollInterval)
+		np = pollers.NewNodePoller(appMgrParms.KubeClient, intervalFactor*time.Second, *nodeLabelSelector)
+	}
 	err = setupNodePolling(appMgr, np, eventChan, appMgrParms.KubeClient)
 	if nil != err {
 		log.Fatalf("Required polling utility for node updates failed setup: %v",
@@ -884,13 +1213,76 @@ func main() {
 	hc := &health.HealthChecker{
 		SubPID: subPid,
 	}
+	if as3Agent, ok := appMgr.AgentCIS.(interface{ CircuitBreakerState() string }); ok {
+		hc.AS3BreakerState = as3Agent.CircuitBreakerState
+	}
 	http.Handle("/health", hc.HealthCheckHandler())
-	bigIPPrometheus.RegisterMetrics()
+	setupHealthChecks(hc, subPid, appMgr.InformersSynced, appMgr.AgentCIS)
+	if *debugEndpoints {
+		dbg := debug.NewServer(*debugToken)
+		appMgr.RegisterDebugState(dbg)
+		if as3Agent, ok := appMgr.AgentCIS.(interface {
+			Declarations() as3.DeclarationStatus
+		}); ok {
+			dbg.Register("declarations", func() (interface{}, error) {
+				return as3Agent.Declarations(), nil
+			})
+		}
+		if as3Agent, ok := appMgr.AgentCIS.(interface {
+			History() []as3.HistoryEntry
+		}); ok {
+			dbg.Register("declaration-history", func() (interface{}, error) {
+				return as3Agent.History(), nil
+			})
+		}
+		if cw, ok := getConfigWriter().(interface{ Sections() json.RawMessage }); ok {
+			dbg.Register("cccl-sections", func() (interface{}, error) {
+				return cw.Sections(), nil
+			})
+		}
+		http.Handle(debug.PathPrefix, dbg)
+	}
+	stopCh := make(chan struct{})
+	lc := setupLogLevels(stopCh)
 	go func() {
 		log.Fatal(http.ListenAndServe(*httpAddress, nil).Error())
 	}()
 
-	stopCh := make(chan struct{})
+	setupCredentialsWatcher(stopCh, func(creds bigIPCredentials) {
+		type credentialsUpdater interface {
+			UpdateCredentials(bigIPURL, username, password string)
+		}
+		if ag, ok := appMgr.AgentCIS.(credentialsUpdater); ok {
+			ag.UpdateCredentials(creds.URL, creds.Username, creds.Password)
+		}
+		if nil != bigIPClient {
+			bigIPClient.UpdateCredentials(creds.URL, creds.Username, creds.Password)
+		}
+		// The python driver reads BIG-IP credentials from the bigip section
+		bs.BigIPUsername = creds.Username
+		bs.BigIPPassword = creds.Password
+		bs.BigIPURL = creds.URL
+		if _, _, err := getConfigWriter().SendSection("bigip", bs); err != nil {
+			log.Errorf("[INIT] Failed to write updated BIG-IP credentials: %v", err)
+		}
+	})
+	setupConfigFileWatcher(stopCh, lc, func(settings reloadableSettings) {
+		appMgr.UpdateSettings(appmanager.Settings{
+			ClientSSL:      settings.ClientSSL,
+			ServerSSL:      settings.ServerSSL,
+			VsSnatPoolName: settings.VsSnatPoolName,
+		})
+		if labeled, ok := np.(interface{ SetNodeLabel(string) }); ok {
+			labeled.SetNodeLabel(settings.NodeLabelSelector)
+		}
+		// The python driver reads the log level and verify interval from
+		// the global section
+		gs.LogLevel = settings.LogLevel
+		gs.VerifyInterval = settings.VerifyInterval
+		if _, _, err := getConfigWriter().SendSection("global", gs); err != nil {
+			log.Errorf("[INIT] Failed to write updated global settings: %v", err)
+		}
+	})
 
 	appMgr.Run(stopCh)
 
@@ -901,6 +1293,49 @@ func main() {
 	log.Infof("[INIT] Exiting - signal %v\n", sig)
 }
 
+// setupTracing sets the tracer provider of the spans and returns a function
+// sending the spans still queued, to be called on exit
+func setupTracing() func() {
+	if *tracingExporter != "otlp" {
+		return func() {}
+	}
+	headers := make(map[string]string)
+	for _, header := range *otlpHeaders {
+		kv := strings.SplitN(header, "=", 2)
+		headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	tp := tracing.NewTracerProvider(tracing.NewOTLPExporter(*otlpEndpoint, headers), "k8s-bigip-ctlr")
+	tracing.SetTracerProvider(tp)
+	log.Infof("[INIT] Exporting traces to %v", *otlpEndpoint)
+	return func() {
+		if err := tp.Shutdown(context.Background()); err != nil {
+			log.Warningf("[TRACING] Failed to export the queued spans: %v", err)
+		}
+	}
+}
+
+// setupCredentialsWatcher reloads BIG-IP credentials from the credentials
+// directory and/or Secret and passes changes to listener
+func setupCredentialsWatcher(stopCh <-chan struct{}, listener func(bigIPCredentials)) {
+	if len(*credsDir) == 0 && len(*credsSecret) == 0 {
+		return
+	}
+	cw := newCredentialsWatcher(bigIPCredentials{
+		URL:      *bigIPURL,
+		Username: *bigIPUsername,
+		Password: *bigIPPassword,
+	})
+	cw.RegisterListener(listener)
+
+	if len(*credsDir) > 0 && *credsReloadInterval > 0 {
+		go cw.watchDirectory(*credsDir, time.Duration(*credsReloadInterval)*time.Second, stopCh)
+	}
+	if len(*credsSecret) > 0 && kubeClient != nil {
+		secret := strings.Split(*credsSecret, "/")
+		cw.watchSecret(kubeClient, secret[0], secret[1], stopCh)
+	}
+}
+
 func cleanupOtherAgents(exclAgent, partition string) error {
 	var agentList []string
 	agentList = append(agentList, cisAgent.AS3Agent) // This can include cisAgent.CCCLAgent etc.
@@ -920,6 +1355,24 @@ func cleanupOtherAgents(exclAgent, partition string) error {
 	return nil
 }
 
+// cleanupCCCLPartitions deletes the LTM objects left in partitions by the
+// CCCL agent, as the python driver did in AS3 mode, retrying every interval
+// until BIG-IP accepts the deletes
+func cleanupCCCLPartitions(client *icontrol.Client, partitions []string, interval time.Duration) {
+	for _, partition := range partitions {
+		for {
+			err := client.DeleteLTMObjects(partition)
+			if nil == err {
+				log.Infof("[INIT] Removed the CCCL objects of partition %v", partition)
+				break
+			}
+			log.Warningf("[INIT] Failed to remove the CCCL objects of partition %v: %v",
+				partition, err)
+			time.Sleep(interval)
+		}
+	}
+}
+
 func getConfigWriter() writer.Writer {
 	if configWriter == nil {
 		var err error
@@ -932,6 +1385,37 @@ func getConfigWriter() writer.Writer {
 	return configWriter
 }
 
+// getStaticRouteMgr returns the manager of the static routes to the pod CIDRs
+// of the nodes, nil without --static-routing-mode
+func getStaticRouteMgr() *staticroute.RouteMgr {
+	if !*staticRoutingMode {
+		return nil
+	}
+	routeMgr, err := staticroute.NewRouteMgr(getBigIPClient(), (*bigIPPartitions)[0], *useNodeInternal)
+	if nil != err {
+		log.Fatalf("[INIT] Failed creating static route manager: %v", err)
+	}
+	return routeMgr
+}
+
+// getBigIPClient returns the iControl REST client of the BIG-IP objects
+// that AS3 does not declare, such as static routes, FDB records and ARP
+// entries
+func getBigIPClient() *icontrol.Client {
+	if nil == bigIPClient {
+		bigIPClient = icontrol.NewClient(icontrol.Params{
+			BIGIPURL:      *bigIPURL,
+			BIGIPUsername: *bigIPUsername,
+			BIGIPPassword: *bigIPPassword,
+			TrustedCerts:  getBIGIPTrustedCerts(),
+			SSLInsecure:   *sslInsecure,
+			TokenAuth:     *tokenAuth,
+			LoginProvider: *loginProvider,
+		})
+	}
+	return bigIPClient
+}
+
 func getRouteConfig() appmanager.RouteConfig {
 	return appmanager.RouteConfig{
 		RouteVSAddr: *routeVserverAddr,
@@ -957,6 +1441,16 @@ func getAppManagerParams() appmanager.Params {
 		ManageIngress:          *manageIngress,
 		ManageIngressClassOnly: *manageIngressClassOnly,
 		IngressClass:           *ingressClass,
+		IngressClassController: *ingressClassController,
+		UseEndpointSlices:      *endpointSlices,
+		ManageGateways:         *manageGateways,
+		GatewayControllerName:  *gatewayControllerName,
+		ManageLoadBalancers:    *manageLoadBalancers,
+		LoadBalancerClass:      *loadBalancerClass,
+		LBAddressRange:         lbAddressRange,
+		MemberDrainPeriod:      time.Duration(*memberDrainPeriod) * time.Second,
+		LocalZone:              *bigIPZone,
+		NodePortHealthMonitor:  *nodePortHealthMonitor,
 		TrustedCertsCfgmap:     *trustedCertsCfgmap,
 		DgPath:                 dgPath,
 		AgRspChan:              agRspChan,
@@ -992,9 +1486,23 @@ func getAS3Params() *as3.Params {
 		TrustedCerts:              getBIGIPTrustedCerts(),
 		SSLInsecure:               *sslInsecure,
 		AS3PostDelay:              *as3PostDelay,
+		TokenAuth:                 *tokenAuth,
+		LoginProvider:             *loginProvider,
 		LogResponse:               *logAS3Response,
 		RspChan:                   agRspChan,
 		UserAgent:                 getUserAgentInfo(),
+		RetryPolicy: as3.NewExponentialBackoff(
+			time.Duration(*as3RetryInitialDelay)*time.Second,
+			time.Duration(*as3RetryMaxDelay)*time.Second,
+			*as3RetryMultiplier,
+			*as3RetryJitter,
+		),
+		CircuitBreakerThreshold: *as3BreakerThreshold,
+		DriftCheckInterval:      *driftCheckInterval,
+		DriftCheckTenants:       *driftCheckTenants,
+		HistoryStore:            getHistoryStore(kubeClient),
+		HistorySize:             getHistorySize(),
+		RollbackOnFailure:       *as3Rollback,
 	}
 }
 
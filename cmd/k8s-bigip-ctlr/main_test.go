@@ -27,6 +27,8 @@ import (
 
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/appmanager"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/test"
+	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vxlan"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	. "github.com/onsi/gomega/gexec"
@@ -285,6 +287,58 @@ var _ = Describe("Main Tests", func() {
 			Expect(hasCommon).To(BeTrue())
 		})
 
+		It("verifies log format", func() {
+			defer _init()
+			defer initLogger("INFO", "console", "")
+			args := []string{
+				"./bin/k8s-bigip-ctlr",
+				"--namespace=testing",
+				"--bigip-partition=velcro1",
+				"--bigip-password=admin",
+				"--bigip-url=bigip.example.com",
+				"--bigip-username=admin",
+			}
+			os.Args = append(args, "--log-format=json")
+			flags.Parse(os.Args)
+			Expect(verifyArgs()).To(BeNil())
+
+			os.Args = append(args, "--log-format=xml")
+			flags.Parse(os.Args)
+			Expect(verifyArgs()).ToNot(BeNil())
+		})
+
+		It("verifies component log levels", func() {
+			defer _init()
+			defer initLogger("INFO", "console", "")
+			args := []string{
+				"./bin/k8s-bigip-ctlr",
+				"--namespace=testing",
+				"--bigip-partition=velcro1",
+				"--bigip-password=admin",
+				"--bigip-url=bigip.example.com",
+				"--bigip-username=admin",
+			}
+			os.Args = append(args, "--component-log-levels=as3=DEBUG,vxlan=warning")
+			flags.Parse(os.Args)
+			Expect(verifyArgs()).To(BeNil())
+			levels := log.GetLevels()
+			Expect(levels.Global).To(Equal(log.LogLevel(log.LL_INFO)))
+			Expect(levels.Components).To(Equal(map[string]log.LogLevel{
+				"as3":   log.LL_DEBUG,
+				"vxlan": log.LL_WARNING,
+			}))
+
+			os.Args = append(args, "--component-log-levels=python=DEBUG")
+			flags.Parse(os.Args)
+			Expect(verifyArgs()).ToNot(BeNil())
+			os.Args = append(args, "--component-log-levels=as3=verbose")
+			flags.Parse(os.Args)
+			Expect(verifyArgs()).ToNot(BeNil())
+			os.Args = append(args, "--log-levels-configmap=levels")
+			flags.Parse(os.Args)
+			Expect(verifyArgs()).ToNot(BeNil())
+		})
+
 		It("verifies args labels", func() {
 			defer _init()
 			os.Args = []string{
@@ -572,6 +626,47 @@ var _ = Describe("Main Tests", func() {
 			Expect(err.Error()).To(Equal("Cannot have both openshift-sdn-name and flannel-name specified."))
 		})
 
+		It("handles the ovn-kubernetes vxlan flag", func() {
+			defer _init()
+			defer func() {
+				vxlanMode, vxlanName, tunnelType = "", "", ""
+			}()
+			os.Args = []string{
+				"./bin/k8s-bigip-ctlr",
+				"--namespace=testing",
+				"--bigip-partition=velcro1",
+				"--bigip-password=admin",
+				"--bigip-url=bigip.example.com",
+				"--bigip-username=admin",
+				"--ovn-kubernetes-name=vxlan-ovn",
+				"--pool-member-type=cluster",
+			}
+
+			flags.Parse(os.Args)
+			err := verifyArgs()
+			Expect(err).To(BeNil())
+			Expect(vxlanMode).To(Equal("maintain"))
+			Expect(vxlanName).To(Equal("vxlan-ovn"))
+			Expect(tunnelType).To(Equal(vxlan.OVNKubernetes))
+
+			os.Args = []string{
+				"./bin/k8s-bigip-ctlr",
+				"--namespace=testing",
+				"--bigip-partition=velcro1",
+				"--bigip-password=admin",
+				"--bigip-url=bigip.example.com",
+				"--bigip-username=admin",
+				"--ovn-kubernetes-name=vxlan-ovn",
+				"--flannel-name=vxlan500",
+			}
+
+			flags.Parse(os.Args)
+			err = verifyArgs()
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(Equal("Cannot have ovn-kubernetes-name with openshift-sdn-name " +
+				"or flannel-name specified."))
+		})
+
 		It("handles empty vxlan flags", func() {
 			defer _init()
 			os.Args = []string{
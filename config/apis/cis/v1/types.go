@@ -20,8 +20,16 @@ type VirtualServer struct {
 type VirtualServerSpec struct {
 	Host                 string `json:"host"`
 	VirtualServerAddress string `json:"virtualServerAddress"`
-	Pools                []Pool `json:"pools"`
-	TLS                  bool   `json:"tls"`
+	// SecondaryVirtualServerAddress makes a dual-stack virtual server. It
+	// must be of the other IP family than VirtualServerAddress.
+	SecondaryVirtualServerAddress string `json:"secondaryVirtualServerAddress,omitempty"`
+	Pools                         []Pool `json:"pools"`
+	TLS                           bool   `json:"tls"`
+	// ZoneAware gives the pool members in the zone of the BIG-IP precedence
+	// while at least MinimumMembersActive of them are available, 1 by
+	// default.
+	ZoneAware            bool  `json:"zoneAware,omitempty"`
+	MinimumMembersActive int32 `json:"minimumMembersActive,omitempty"`
 }
 
 // Pool defines a pool object in BIG-IP.
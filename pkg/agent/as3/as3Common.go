@@ -23,7 +23,6 @@ import (
 	"strings"
 
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 	"github.com/xeipuuv/gojsonschema"
 )
 
@@ -86,6 +85,16 @@ func (am *AS3Manager) processIRulesForAS3(sharedApp as3Application) {
 	}
 }
 
+// formatDGRecordValue formats the value of a data group record. The A/B
+// deployment records keep their weights, whatever the resource type of
+// their pools.
+func formatDGRecordValue(dgName, data string) string {
+	if dgName == AbDeploymentDgName {
+		return as3FormatedString(data, ResourceTypeRoute)
+	}
+	return as3FormatedString(data, deriveResourceTypeFromAS3Value(data))
+}
+
 func (am *AS3Manager) processDataGroupForAS3(sharedApp as3Application) {
 	for idk, idg := range am.IntDgMap {
 		for _, dg := range idg {
@@ -101,7 +110,7 @@ func (am *AS3Manager) processDataGroupForAS3(sharedApp as3Application) {
 					if val, ok := getDGRecordValueForAS3(idk.Name, sharedApp); ok {
 						rec.Value = val
 					} else {
-						rec.Value = as3FormatedString(record.Data, deriveResourceTypeFromAS3Value(record.Data))
+						rec.Value = formatDGRecordValue(idk.Name, record.Data)
 					}
 					dgMap.Records = append(dgMap.Records, rec)
 				}
@@ -116,7 +125,7 @@ func (am *AS3Manager) processDataGroupForAS3(sharedApp as3Application) {
 					if val, ok := getDGRecordValueForAS3(idk.Name, sharedApp); ok {
 						rec.Value = val
 					} else {
-						rec.Value = as3FormatedString(record.Data, deriveResourceTypeFromAS3Value(record.Data))
+						rec.Value = formatDGRecordValue(idk.Name, record.Data)
 					}
 					sharedApp[as3FormatedString(dg.Name, "")].(*as3DataGroup).Records = append(dataGroupRecord.(*as3DataGroup).Records, rec)
 				}
@@ -256,12 +265,18 @@ func createPoolDecl(cfg *ResourceConfig, sharedApp as3Application) {
 	for _, v := range cfg.Pools {
 		pool := &as3Pool{}
 		pool.LoadBalancingMode = v.Balance
+		pool.MinimumMembersActive = v.MinimumMembersActive
 		pool.Class = "Pool"
 		for _, val := range v.Members {
 			var member as3PoolMember
 			member.AddressDiscovery = "static"
 			member.ServicePort = val.Port
 			member.ServerAddresses = append(member.ServerAddresses, val.Address)
+			member.PriorityGroup = val.PriorityGroup
+			if val.Session == MemberSessionDisabled {
+				// Draining, existing connections are kept
+				member.AdminState = "disable"
+			}
 			pool.Members = append(pool.Members, member)
 		}
 		for _, val := range v.MonitorNames {
@@ -328,14 +343,25 @@ func createServiceDecl(cfg *ResourceConfig, sharedApp as3Application) {
 	}
 
 	svc.Layer4 = cfg.Virtual.IpProtocol
-	svc.Source = "0.0.0.0/0"
 	svc.TranslateServerAddress = true
 	svc.TranslateServerPort = true
 
 	svc.Class = "Service_HTTP"
+	// Gateway TLS passthrough and TCP listeners have no HTTP profile
+	if cfg.MetaData.ResourceType == ResourceTypeGateway && !hasHTTPProfile(&cfg.Virtual) {
+		svc.Class = "Service_TCP"
+	}
+	// LoadBalancer Services are forwarded at layer 4
+	if cfg.MetaData.ResourceType == ResourceTypeLoadBalancer {
+		svc.Class = "Service_TCP"
+		if cfg.Virtual.IpProtocol == "udp" {
+			svc.Class = "Service_UDP"
+		}
+	}
 	virtualAddress, port := ExtractVirtualAddressAndPort(cfg.Virtual.Destination)
 	// verify that ip address and port exists.
 	if virtualAddress != "" && port != 0 {
+		svc.Source = AnySourceAddress(virtualAddress)
 		va := append(svc.VirtualAddresses, virtualAddress)
 		svc.VirtualAddresses = va
 		svc.VirtualPort = port
@@ -359,6 +385,15 @@ func createServiceDecl(cfg *ResourceConfig, sharedApp as3Application) {
 	sharedApp[as3FormatedString(cfg.Virtual.Name, cfg.MetaData.ResourceType)] = svc
 }
 
+func hasHTTPProfile(v *Virtual) bool {
+	for _, prof := range v.Profiles {
+		if prof.Name == "http" {
+			return true
+		}
+	}
+	return false
+}
+
 // Create AS3 Rule Condition for Route
 func createAS3RuleCondition(rl *Rule, rulesData *as3Rule, port int) {
 	for _, c := range rl.Conditions {
@@ -387,6 +422,27 @@ func createAS3RuleCondition(rl *Rule, rulesData *as3Rule, port int) {
 			if c.Equals {
 				condition.All.Operand = "equals"
 			}
+			if c.EndsWith {
+				condition.All.Operand = "ends-with"
+			}
+		} else if c.HTTPHeader {
+			condition.Type = "httpHeader"
+			condition.Name = c.Tmname
+			condition.All = &as3PolicyCompareString{
+				Values: c.Values,
+			}
+			if c.Equals {
+				condition.All.Operand = "equals"
+			}
+		} else if c.QueryParameter {
+			condition.Type = "httpUri"
+			condition.Name = c.Tmname
+			condition.QueryParameter = &as3PolicyCompareString{
+				Values: c.Values,
+			}
+			if c.Equals {
+				condition.QueryParameter.Operand = "equals"
+			}
 		} else if c.PathSegment {
 			condition.PathSegment = &as3PolicyCompareString{
 				Values: c.Values,
@@ -437,7 +493,7 @@ func createAS3RuleAction(rl *Rule, rulesData *as3Rule, resourceType string) {
 		if v.Redirect {
 			action.Type = "httpRedirect"
 		}
-		if v.HTTPHost {
+		if v.HTTPHost || v.HTTPHeader {
 			action.Type = "httpHeader"
 		}
 		if v.HTTPURI {
@@ -457,6 +513,23 @@ func createAS3RuleAction(rl *Rule, rulesData *as3Rule, resourceType string) {
 		if v.Replace && v.HTTPURI {
 			action.Replace = &as3ActionReplaceMap{
 				Value: v.Value,
+				Path:  v.Path,
+			}
+		}
+		// Handle header modifiers.
+		if v.HTTPHeader {
+			header := &as3ActionReplaceMap{
+				Value: v.Value,
+				Name:  v.Tmname,
+			}
+			switch {
+			case v.Replace:
+				action.Replace = header
+			case v.Insert:
+				action.Insert = header
+			case v.Remove:
+				header.Value = ""
+				action.Remove = header
 			}
 		}
 		p := strings.Split(v.Pool, "/")
@@ -482,6 +555,10 @@ func createMonitorDecl(cfg *ResourceConfig, sharedApp as3Application) {
 		monitor.Timeout = v.Timeout
 		val := 0
 		monitor.TargetPort = &val
+		if v.TargetPort != 0 {
+			targetPort := int(v.TargetPort)
+			monitor.TargetPort = &targetPort
+		}
 		targetAddressStr := ""
 		monitor.TargetAddress = &targetAddressStr
 		//Monitor type
@@ -0,0 +1,165 @@
+package as3
+
+import (
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AS3 Policy Rules", func() {
+	It("Converts header conditions and actions", func() {
+		rl := &Rule{
+			Conditions: []*Condition{
+				{Name: "0", Host: true, HTTPHost: true, EndsWith: true, Request: true,
+					Values: []string{".example.com"}},
+				{Name: "1", HTTPHeader: true, Equals: true, Request: true,
+					Tmname: "x-env", Values: []string{"canary"}},
+			},
+			Actions: []*Action{
+				{Name: "0", HTTPHeader: true, Replace: true, Request: true, Tmname: "x-a", Value: "a"},
+				{Name: "1", HTTPHeader: true, Insert: true, Request: true, Tmname: "x-b", Value: "b"},
+				{Name: "2", HTTPHeader: true, Remove: true, Request: true, Tmname: "x-c"},
+				{Name: "3", HTTPURI: true, Replace: true, Request: true, Path: "/new"},
+			},
+		}
+		rulesData := &as3Rule{}
+		createAS3RuleCondition(rl, rulesData, 80)
+		createAS3RuleAction(rl, rulesData, ResourceTypeGateway)
+
+		Expect(rulesData.Conditions).To(HaveLen(2))
+		Expect(*rulesData.Conditions[0].All).To(Equal(as3PolicyCompareString{
+			Values: []string{".example.com"}, Operand: "ends-with"}))
+		Expect(rulesData.Conditions[1].Type).To(Equal("httpHeader"))
+		Expect(rulesData.Conditions[1].Name).To(Equal("x-env"))
+		Expect(*rulesData.Conditions[1].All).To(Equal(as3PolicyCompareString{
+			Values: []string{"canary"}, Operand: "equals"}))
+
+		Expect(rulesData.Actions).To(HaveLen(4))
+		Expect(*rulesData.Actions[0].Replace).To(Equal(as3ActionReplaceMap{Name: "x-a", Value: "a"}))
+		Expect(*rulesData.Actions[1].Insert).To(Equal(as3ActionReplaceMap{Name: "x-b", Value: "b"}))
+		Expect(*rulesData.Actions[2].Remove).To(Equal(as3ActionReplaceMap{Name: "x-c"}))
+		Expect(rulesData.Actions[3].Type).To(Equal("httpUri"))
+		Expect(*rulesData.Actions[3].Replace).To(Equal(as3ActionReplaceMap{Path: "/new"}))
+	})
+
+	It("Converts query parameter conditions", func() {
+		rl := &Rule{
+			Conditions: []*Condition{
+				{Name: "0", HTTPURI: true, QueryParameter: true, Equals: true, Request: true,
+					Tmname: "debug", Values: []string{"1"}},
+			},
+		}
+		rulesData := &as3Rule{}
+		createAS3RuleCondition(rl, rulesData, 80)
+
+		Expect(rulesData.Conditions).To(HaveLen(1))
+		Expect(rulesData.Conditions[0].Type).To(Equal("httpUri"))
+		Expect(rulesData.Conditions[0].Event).To(Equal("request"))
+		Expect(rulesData.Conditions[0].Name).To(Equal("debug"))
+		Expect(*rulesData.Conditions[0].QueryParameter).To(Equal(as3PolicyCompareString{
+			Values: []string{"1"}, Operand: "equals"}))
+	})
+
+	It("Keeps the weights of A/B deployment records", func() {
+		Expect(formatDGRecordValue(AbDeploymentDgName,
+			"gateway_default_foo-v1_80,0.250;gateway_default_foo-v2_80,1.000")).To(Equal(
+			"gateway_default_foo_v1_80,0.250;gateway_default_foo_v2_80,1.000"))
+	})
+	It("Creates the services of IPv4 and IPv6 virtual servers", func() {
+		sharedApp := as3Application{}
+		v4 := &ResourceConfig{}
+		v4.Virtual.Name = "ingress_10-1-1-1_80"
+		v4.MetaData.ResourceType = ResourceTypeIngress
+		v4.Virtual.Partition = "test"
+		v4.Virtual.SetVirtualAddress("10.1.1.1%2", 80)
+		createServiceDecl(v4, sharedApp)
+		svc := sharedApp["ingress_10_1_1_1_80"].(*as3Service)
+		Expect(svc.VirtualAddresses).To(Equal([]string{"10.1.1.1%2"}))
+		Expect(svc.VirtualPort).To(Equal(80))
+		Expect(svc.Source).To(Equal("0.0.0.0/0"))
+
+		v6 := &ResourceConfig{}
+		v6.Virtual.Name = "ingress_2001-db8--1_443"
+		v6.MetaData.ResourceType = ResourceTypeIngress
+		v6.Virtual.Partition = "test"
+		v6.Virtual.SetVirtualAddress("[2001:db8::1]", 443)
+		createServiceDecl(v6, sharedApp)
+		svc = sharedApp["ingress_2001_db8__1_443"].(*as3Service)
+		Expect(svc.VirtualAddresses).To(Equal([]string{"2001:db8::1"}))
+		Expect(svc.VirtualPort).To(Equal(443))
+		Expect(svc.Source).To(Equal("::/0"))
+	})
+	It("Creates layer 4 services for LoadBalancer Services", func() {
+		sharedApp := as3Application{}
+		for _, mode := range []string{"tcp", "udp"} {
+			cfg := &ResourceConfig{}
+			cfg.MetaData.ResourceType = ResourceTypeLoadBalancer
+			cfg.Virtual.Name = FormatLoadBalancerVSName("default", "dns", mode, 53)
+			cfg.Virtual.Partition = "test"
+			cfg.Virtual.SetVirtualAddress("10.1.1.1", 53)
+			SetProfilesForMode(mode, cfg)
+			createServiceDecl(cfg, sharedApp)
+		}
+		svc := sharedApp["loadbalancer_default_dns_tcp_53"].(*as3Service)
+		Expect(svc.Class).To(Equal("Service_TCP"))
+		Expect(svc.Layer4).To(Equal("tcp"))
+		svc = sharedApp["loadbalancer_default_dns_udp_53"].(*as3Service)
+		Expect(svc.Class).To(Equal("Service_UDP"))
+		Expect(svc.Layer4).To(Equal("udp"))
+	})
+	It("Disables the draining pool members", func() {
+		sharedApp := as3Application{}
+		cfg := &ResourceConfig{}
+		cfg.MetaData.ResourceType = ResourceTypeIngress
+		cfg.Pools = Pools{{
+			Name:    "foo_80",
+			Balance: "round-robin",
+			Members: []Member{
+				{Address: "10.2.96.0", Port: 8080, Session: MemberSessionEnabled},
+				{Address: "10.2.96.1", Port: 8080, Session: MemberSessionDisabled},
+			},
+		}}
+		createPoolDecl(cfg, sharedApp)
+		pool := sharedApp["foo_80"].(*as3Pool)
+		Expect(pool.Members).To(Equal([]as3PoolMember{
+			{AddressDiscovery: "static", ServerAddresses: []string{"10.2.96.0"}, ServicePort: 8080},
+			{AddressDiscovery: "static", ServerAddresses: []string{"10.2.96.1"}, ServicePort: 8080,
+				AdminState: "disable"},
+		}))
+	})
+	It("Declares the priority groups of zone aware pools", func() {
+		sharedApp := as3Application{}
+		cfg := &ResourceConfig{}
+		cfg.MetaData.ResourceType = ResourceTypeIngress
+		cfg.Pools = Pools{{
+			Name:                 "foo_80",
+			Balance:              "round-robin",
+			MinimumMembersActive: 2,
+			Members: []Member{
+				{Address: "10.2.96.0", Port: 8080, PriorityGroup: LocalZonePriorityGroup},
+				{Address: "10.2.96.1", Port: 8080},
+			},
+		}}
+		createPoolDecl(cfg, sharedApp)
+		pool := sharedApp["foo_80"].(*as3Pool)
+		Expect(pool.MinimumMembersActive).To(Equal(int32(2)))
+		Expect(pool.Members[0].PriorityGroup).To(Equal(LocalZonePriorityGroup))
+		Expect(pool.Members[1].PriorityGroup).To(BeZero())
+	})
+
+	It("Declares the target port of monitors", func() {
+		sharedApp := as3Application{}
+		cfg := &ResourceConfig{}
+		cfg.MetaData.ResourceType = ResourceTypeIngress
+		cfg.Monitors = Monitors{
+			HealthCheckNodePortMonitor(Pool{Name: "foo_80"}, 32000),
+			{Name: "foo_80_0_http", Type: "http", Interval: 5, Timeout: 16},
+		}
+		createMonitorDecl(cfg, sharedApp)
+		monitor := sharedApp[as3FormatedString(cfg.Monitors[0].Name, ResourceTypeIngress)].(*as3Monitor)
+		Expect(*monitor.TargetPort).To(Equal(32000))
+		monitor = sharedApp["foo_80_0_http"].(*as3Monitor)
+		Expect(*monitor.TargetPort).To(BeZero())
+		Expect(*monitor.Dscp).To(BeZero())
+	})
+})
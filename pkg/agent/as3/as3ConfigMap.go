@@ -5,7 +5,6 @@ import (
 	"strings"
 
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 )
 
 // cfgMap States
@@ -0,0 +1,214 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package as3
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+)
+
+// reconcileDrift compares the tenants of the active declaration with the
+// ones on BIG-IP. Tenants modified outside of CIS are reported to the
+// response handler and re-posted.
+// The tenants on BIG-IP are read from the AS3 declare endpoint, which
+// returns the declaration AS3 last stored rather than the live LTM
+// configuration. Drift is therefore only detected when another AS3 client
+// declares the tenants, not when their objects are edited in the GUI or
+// tmsh.
+func (am *AS3Manager) reconcileDrift() {
+	decl := am.as3ActiveConfig.unifiedDeclaration
+	if decl == "" {
+		return
+	}
+	// BIG-IP has not accepted the declaration yet, the deployer retries it
+	if am.isFailingDeclaration(decl) {
+		log.Debugf("[AS3] Skipping drift detection while the declaration fails to post")
+		return
+	}
+	desired := getDeclaredTenants(decl)
+	tenants := am.getDriftCheckTenants(desired)
+	if len(tenants) == 0 {
+		return
+	}
+
+	actual, err := am.PostManager.GetAS3Declaration(tenants)
+	if err != nil {
+		log.Warningf("[AS3] Unable to fetch tenants %v for drift detection: %v", tenants, err)
+		return
+	}
+
+	var drifted, paths []string
+	for _, tenant := range tenants {
+		if !isTenantInSync(desired[tenant], actual[tenant]) {
+			bigIPPrometheus.AS3DriftDetected.WithLabelValues(tenant).Inc()
+			drifted = append(drifted, tenant)
+			paths = append(paths, getDriftedPaths(tenant, desired[tenant], actual[tenant])...)
+		}
+	}
+	if len(drifted) == 0 {
+		log.Debugf("[AS3] No drift detected in tenants %v", tenants)
+		return
+	}
+
+	log.Warningf("[AS3] Configuration of tenants %v on BIG-IP differs from the declaration "+
+		"in %v, re-posting", drifted, paths)
+	am.postAgentResponse(MessageResponse{ResourceResponse: ResourceResponse{
+		DriftedTenants: drifted,
+		DriftedPaths:   paths,
+	}})
+	if posted, event := am.PostManager.postConfig(string(decl), drifted, postOrigin{}); !posted {
+		log.Errorf("[AS3] Failed to re-post drifted tenants %v: %v", drifted, event)
+	}
+}
+
+// isFailingDeclaration reports whether decl is the declaration BIG-IP
+// rejected in the last post
+func (am *AS3Manager) isFailingDeclaration(decl as3Declaration) bool {
+	if am.PostManager.UnsyncedSince().IsZero() {
+		return false
+	}
+	failed := am.PostManager.Declarations().LastFailed
+	if failed == nil {
+		return false
+	}
+	switch d := failed.Declaration.(type) {
+	case json.RawMessage:
+		return DeepEqualJSON(as3Declaration(d), decl)
+	case string:
+		return DeepEqualJSON(as3Declaration(d), decl)
+	}
+	return false
+}
+
+// getDriftCheckTenants returns the declared tenants that are configured
+// for drift detection
+func (am *AS3Manager) getDriftCheckTenants(declared map[string]interface{}) []string {
+	var tenants []string
+	if len(am.driftCheckTenants) == 0 {
+		for tenant := range declared {
+			tenants = append(tenants, tenant)
+		}
+	} else {
+		for _, tenant := range am.driftCheckTenants {
+			if _, ok := declared[tenant]; ok {
+				tenants = append(tenants, tenant)
+			}
+		}
+	}
+	sort.Strings(tenants)
+	return tenants
+}
+
+// getDeclaredTenants returns the Tenant objects of an AS3 declaration keyed
+// by tenant name
+func getDeclaredTenants(decl as3Declaration) map[string]interface{} {
+	tenants := make(map[string]interface{})
+	var as3Obj map[string]interface{}
+	if err := json.Unmarshal([]byte(decl), &as3Obj); err != nil {
+		return tenants
+	}
+	adc, ok := as3Obj["declaration"].(map[string]interface{})
+	if !ok {
+		return tenants
+	}
+	for name, obj := range adc {
+		if tnt, ok := obj.(map[string]interface{}); ok && tnt[as3class] == as3tenant {
+			tenants[name] = tnt
+		}
+	}
+	return tenants
+}
+
+// isTenantInSync reports whether the tenant on BIG-IP matches the declared
+// one. A tenant declared without applications is a delete request and is in
+// sync when it does not exist on BIG-IP.
+func isTenantInSync(desired, actual interface{}) bool {
+	if actual == nil {
+		tnt, _ := desired.(map[string]interface{})
+		for key := range tnt {
+			if key != as3class && key != "label" && key != "remark" {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(desired, actual)
+}
+
+// getDriftedPaths returns the AS3 paths of the objects of tenant that differ
+// between the declared and the actual tenant: /tenant/application/object
+// for the objects of an application, /tenant/application for a missing or
+// extra application and /tenant for the other properties of the tenant
+func getDriftedPaths(tenant string, desired, actual interface{}) []string {
+	tntPath := "/" + tenant
+	desiredTnt, _ := desired.(map[string]interface{})
+	actualTnt, _ := actual.(map[string]interface{})
+	if actualTnt == nil {
+		return []string{tntPath}
+	}
+
+	var paths []string
+	tenantDrifted := false
+	for _, name := range unionKeys(desiredTnt, actualTnt) {
+		desiredApp, dIsApp := getAS3Application(desiredTnt[name])
+		actualApp, aIsApp := getAS3Application(actualTnt[name])
+		switch {
+		case dIsApp && aIsApp:
+			appPath := tntPath + "/" + name
+			for _, obj := range unionKeys(desiredApp, actualApp) {
+				if !reflect.DeepEqual(desiredApp[obj], actualApp[obj]) {
+					paths = append(paths, appPath+"/"+obj)
+				}
+			}
+		case dIsApp || aIsApp:
+			paths = append(paths, tntPath+"/"+name)
+		default:
+			if !reflect.DeepEqual(desiredTnt[name], actualTnt[name]) {
+				tenantDrifted = true
+			}
+		}
+	}
+	if tenantDrifted {
+		paths = append([]string{tntPath}, paths...)
+	}
+	return paths
+}
+
+// getAS3Application returns obj when it is an AS3 Application
+func getAS3Application(obj interface{}) (map[string]interface{}, bool) {
+	app, ok := obj.(map[string]interface{})
+	return app, ok && app[as3class] == as3application
+}
+
+// unionKeys returns the sorted keys of both maps
+func unionKeys(a, b map[string]interface{}) []string {
+	var keys []string
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
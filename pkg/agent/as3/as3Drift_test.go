@@ -0,0 +1,196 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package as3
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const driftDeclaration = `{
+  "class": "AS3",
+  "declaration": {
+    "class": "ADC",
+    "schemaVersion": "3.18.0",
+    "app1": {"class": "Tenant", "svc": {"class": "Application", "template": "shared"}},
+    "app2": {"class": "Tenant", "svc": {"class": "Application", "template": "generic"}},
+    "old": {"class": "Tenant"}
+  }
+}`
+
+var _ = Describe("AS3 Drift Detection Tests", func() {
+	var am *AS3Manager
+	var server *httptest.Server
+	var onBigIP string
+	var posted []string
+
+	BeforeEach(func() {
+		posted = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				fmt.Fprint(w, onBigIP)
+				return
+			}
+			posted = append(posted, r.URL.Path)
+			fmt.Fprint(w, `{"results": [{"code": 200, "tenant": "app2", "message": "success"}]}`)
+		}))
+		am = NewAS3Manager(&Params{
+			BIGIPURL: server.URL,
+			RspChan:  make(chan interface{}, 1),
+		})
+		am.as3ActiveConfig.unifiedDeclaration = as3Declaration(driftDeclaration)
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Extracts declared tenants", func() {
+		tenants := getDeclaredTenants(as3Declaration(driftDeclaration))
+		Expect(tenants).To(HaveLen(3))
+		Expect(am.getDriftCheckTenants(tenants)).To(Equal([]string{"app1", "app2", "old"}))
+		am.driftCheckTenants = []string{"app2", "missing"}
+		Expect(am.getDriftCheckTenants(tenants)).To(Equal([]string{"app2"}))
+	})
+
+	It("Re-posts only drifted tenants", func() {
+		onBigIP = `{
+		  "class": "ADC",
+		  "app1": {"class": "Tenant", "svc": {"class": "Application", "template": "shared"}},
+		  "app2": {"class": "Tenant", "svc": {"class": "Application", "template": "http"}}
+		}`
+		am.reconcileDrift()
+		Expect(posted).To(Equal([]string{"/mgmt/shared/appsvcs/declare/app2"}))
+		rsp := (<-am.RspChan).(MessageResponse)
+		Expect(rsp.DriftedTenants).To(Equal([]string{"app2"}))
+		Expect(rsp.DriftedPaths).To(Equal([]string{"/app2/svc/template"}))
+	})
+
+	It("Does not re-post a failing declaration", func() {
+		onBigIP = `{"class": "ADC"}`
+		am.PostManager.recordPostResult(driftDeclaration, []string{"app1"}, nil, false, 422)
+		am.reconcileDrift()
+		Expect(posted).To(BeEmpty())
+		Expect(am.RspChan).To(BeEmpty())
+	})
+
+	It("Finds the drifted objects of a tenant", func() {
+		desired := map[string]interface{}{
+			"class": "Tenant",
+			"label": "cis",
+			"svc": map[string]interface{}{
+				"class": "Application",
+				"pool":  map[string]interface{}{"class": "Pool"},
+				"vs":    map[string]interface{}{"class": "Service_HTTP"},
+			},
+			"old": map[string]interface{}{"class": "Application"},
+		}
+		actual := map[string]interface{}{
+			"class": "Tenant",
+			"label": "edited",
+			"svc": map[string]interface{}{
+				"class": "Application",
+				"pool":  map[string]interface{}{"class": "Pool", "remark": "edited"},
+				"vs":    map[string]interface{}{"class": "Service_HTTP"},
+				"extra": map[string]interface{}{"class": "Pool"},
+			},
+		}
+		Expect(getDriftedPaths("tnt", desired, actual)).To(Equal([]string{
+			"/tnt", "/tnt/old", "/tnt/svc/extra", "/tnt/svc/pool"}))
+		Expect(getDriftedPaths("tnt", desired, nil)).To(Equal([]string{"/tnt"}))
+	})
+
+	It("Does nothing when BIG-IP is in sync", func() {
+		onBigIP = `{
+		  "class": "ADC",
+		  "app1": {"class": "Tenant", "svc": {"class": "Application", "template": "shared"}},
+		  "app2": {"class": "Tenant", "svc": {"class": "Application", "template": "generic"}}
+		}`
+		am.reconcileDrift()
+		Expect(posted).To(BeEmpty())
+		Expect(am.RspChan).To(BeEmpty())
+	})
+})
+
+var _ = Describe("AS3 Tenant Error Tests", func() {
+	It("Reports tenant errors once per change", func() {
+		am := NewAS3Manager(&Params{RspChan: make(chan interface{}, 1)})
+		am.PostManager.tenantErrors = map[string]string{"app1": "failed"}
+		am.reportTenantErrors()
+		rsp := (<-am.RspChan).(MessageResponse)
+		Expect(rsp.TenantErrors).To(Equal(map[string]string{"app1": "failed"}))
+		am.reportTenantErrors()
+		Expect(am.RspChan).To(BeEmpty())
+	})
+})
+
+var _ = Describe("AS3 Post Tracking Tests", func() {
+	It("Tracks since when posts fail", func() {
+		code := http.StatusUnprocessableEntity
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+			fmt.Fprintf(w, `{"code": %d, "results": [{"code": %d, "tenant": "app1"}]}`, code, code)
+		}))
+		defer server.Close()
+		am := NewAS3Manager(&Params{
+			BIGIPURL: server.URL,
+			RspChan:  make(chan interface{}, 1),
+		})
+		Expect(am.UnsyncedSince().IsZero()).To(BeTrue())
+
+		am.PostManager.postConfig(driftDeclaration, []string{"app1"}, postOrigin{})
+		since := am.UnsyncedSince()
+		Expect(since.IsZero()).To(BeFalse())
+		am.PostManager.postConfig(driftDeclaration, []string{"app1"}, postOrigin{})
+		Expect(am.UnsyncedSince()).To(Equal(since))
+
+		code = http.StatusOK
+		am.PostManager.postConfig(driftDeclaration, []string{"app1"}, postOrigin{})
+		Expect(am.UnsyncedSince().IsZero()).To(BeTrue())
+	})
+
+	It("Traces the posts", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			fmt.Fprint(w, `{"code": 422, "results": [{"code": 422, "tenant": "app1"}]}`)
+		}))
+		defer server.Close()
+		exporter := tracing.NewInMemoryExporter()
+		defer tracing.SetTracerProvider(tracing.SetTracerProvider(exporter.TracerProvider()))
+		am := NewAS3Manager(&Params{
+			BIGIPURL: server.URL,
+			RspChan:  make(chan interface{}, 1),
+		})
+		parent := tracing.Start(tracing.SpanContext{}, tracing.SpanDeployResource)
+		am.PostManager.postConfig(driftDeclaration, []string{"app1"}, postOrigin{trace: parent.SpanContext()})
+
+		posts := exporter.Named(tracing.SpanPost)
+		Expect(posts).To(HaveLen(1))
+		Expect(posts[0].SpanKind).To(Equal(tracing.SpanKindClient))
+		Expect(posts[0].Parent).To(Equal(parent.SpanContext()))
+		Expect(posts[0].Attributes).To(ConsistOf(
+			tracing.Int("declaration.size", len(driftDeclaration)),
+			tracing.String("tenants", "app1"),
+			tracing.Int("http.status_code", http.StatusUnprocessableEntity),
+		))
+		Expect(posts[0].Status.Code).To(Equal(tracing.StatusError))
+	})
+})
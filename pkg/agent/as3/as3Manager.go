@@ -19,13 +19,18 @@ package as3
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"strconv"
 	"time"
 
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 )
 
+var log = vlogger.NewComponentLogger(vlogger.ComponentAS3)
+
 const (
 	svcTenantLabel = "cis.f5.com/as3-tenant="
 	svcAppLabel    = "cis.f5.com/as3-app="
@@ -99,6 +104,19 @@ type AS3Manager struct {
 	ReqChan          chan MessageRequest
 	RspChan          chan interface{}
 	userAgent        string
+	// Decides the wait before re-posting a failed declaration
+	retryPolicy RetryPolicy
+	// Stops posting while BIG-IP keeps failing
+	breaker *CircuitBreaker
+	// Interval at which posted tenants are compared with BIG-IP, 0 disables
+	driftCheckInterval time.Duration
+	// Tenants to check for drift, all posted tenants when empty
+	driftCheckTenants []string
+	// Tenant errors last reported to the response handler
+	tenantErrors map[string]string
+	// Re-post the last declaration accepted by BIG-IP when BIG-IP rejects
+	// a declaration
+	rollbackOnFailure bool
 	ResourceRequest
 	ResourceResponse
 }
@@ -122,10 +140,28 @@ type Params struct {
 	BIGIPURL           string
 	TrustedCerts       string
 	AS3PostDelay       int
+	TokenAuth          bool
+	LoginProvider      string
 	//Log the AS3 response body in Controller logs
 	LogResponse bool
 	RspChan     chan interface{}
 	UserAgent   string
+	// Retry policy for failed posts, DefaultRetryPolicy when nil
+	RetryPolicy RetryPolicy
+	// Consecutive failures after which the circuit breaker opens, 0 disables
+	CircuitBreakerThreshold int
+	// Interval (in seconds) at which to check BIG-IP for drift, 0 disables
+	DriftCheckInterval int
+	// Tenants to check for drift, all posted tenants when empty
+	DriftCheckTenants []string
+	// Persists the declaration history, which is kept in memory only when
+	// nil
+	HistoryStore HistoryStore
+	// Number of declarations kept in the history, 0 disables the history
+	HistorySize int
+	// Re-post the last declaration of the history when BIG-IP rejects a
+	// declaration
+	RollbackOnFailure bool
 }
 
 // Create and return a new app manager that meets the Manager interface
@@ -140,6 +176,11 @@ func NewAS3Manager(params *Params) *AS3Manager {
 		FilterTenants:             params.FilterTenants,
 		RspChan:                   params.RspChan,
 		userAgent:                 params.UserAgent,
+		retryPolicy:               params.RetryPolicy,
+		breaker:                   NewCircuitBreaker(params.CircuitBreakerThreshold),
+		driftCheckInterval:        time.Duration(params.DriftCheckInterval) * time.Second,
+		driftCheckTenants:         params.DriftCheckTenants,
+		rollbackOnFailure:         params.RollbackOnFailure,
 		as3ActiveConfig: AS3Config{
 			configmap:         AS3ConfigMap{cfg: params.UserDefinedAS3Decl},
 			overrideConfigmap: AS3ConfigMap{cfg: params.OverrideAS3Decl},
@@ -151,9 +192,23 @@ func NewAS3Manager(params *Params) *AS3Manager {
 			TrustedCerts:  params.TrustedCerts,
 			SSLInsecure:   params.SSLInsecure,
 			AS3PostDelay:  params.AS3PostDelay,
+			TokenAuth:     params.TokenAuth,
+			LoginProvider: params.LoginProvider,
 			LogResponse:   params.LogResponse}),
 	}
 
+	if as3Manager.retryPolicy == nil {
+		as3Manager.retryPolicy = DefaultRetryPolicy()
+	}
+
+	if params.HistorySize > 0 {
+		history, err := NewDeclarationHistory(params.HistoryStore, params.HistorySize)
+		if err != nil {
+			log.Errorf("[AS3] Failed to load the declaration history: %v", err)
+		}
+		as3Manager.PostManager.history = history
+	}
+
 	as3Manager.as3ActiveConfig.overrideConfigmap.Init()
 	as3Manager.as3ActiveConfig.configmap.Init()
 
@@ -162,9 +217,10 @@ func NewAS3Manager(params *Params) *AS3Manager {
 	return &as3Manager
 }
 
-func (am *AS3Manager) postAS3Declaration(rsReq ResourceRequest) (bool, string) {
+func (am *AS3Manager) postAS3Declaration(rsReq ResourceRequest, origin postOrigin) (bool, string) {
 
 	am.ResourceRequest = rsReq
+	span := tracing.Start(origin.trace, tracing.SpanBuildDeclaration)
 
 	as3Config := am.as3ActiveConfig
 	// Process cfgMap if present in Resource Request
@@ -176,6 +232,8 @@ func (am *AS3Manager) postAS3Declaration(rsReq ResourceRequest) (bool, string) {
 				if ok, event := am.processAS3CfgMapDelete(cfgMap.Name, cfgMap.Namespace, &as3Config); !ok {
 					log.Errorf("[AS3] Failed to perform delete cfgMap with name: %s and namespace %s",
 						cfgMap.Name, cfgMap.Namespace)
+					span.SetStatus(tracing.StatusError, event)
+					span.End()
 					return ok, event
 				}
 				continue
@@ -187,10 +245,17 @@ func (am *AS3Manager) postAS3Declaration(rsReq ResourceRequest) (bool, string) {
 	// Process Route or Ingress
 	as3ConfigReq := am.prepareAS3ResourceConfig(as3Config)
 
-	return am.postAS3Config(as3ConfigReq)
+	return am.postAS3Config(as3ConfigReq, span, origin)
 }
 
-func (am *AS3Manager) postAS3Config(tempAS3Config AS3Config) (bool, string) {
+// postAS3Config posts the declaration of tempAS3Config, if changed, ending
+// buildSpan once the declaration is built
+func (am *AS3Manager) postAS3Config(
+	tempAS3Config AS3Config,
+	buildSpan tracing.Span,
+	origin postOrigin,
+) (bool, string) {
+	defer buildSpan.End()
 	unifiedDecl := am.getUnifiedDeclaration(&tempAS3Config)
 	if unifiedDecl == "" {
 		return true, ""
@@ -202,9 +267,12 @@ func (am *AS3Manager) postAS3Config(tempAS3Config AS3Config) (bool, string) {
 
 	if am.as3Validation == true {
 		if ok := am.validateAS3Template(string(unifiedDecl)); !ok {
+			buildSpan.SetStatus(tracing.StatusError, "invalid AS3 declaration")
 			return true, ""
 		}
 	}
+	buildSpan.SetAttributes(tracing.Bool("changed", true))
+	buildSpan.End()
 
 	log.Debugf("[AS3] Posting AS3 Declaration")
 
@@ -218,7 +286,7 @@ func (am *AS3Manager) postAS3Config(tempAS3Config AS3Config) (bool, string) {
 		tenants = getTenants(unifiedDecl)
 	}
 
-	return am.PostManager.postConfig(string(unifiedDecl), tenants)
+	return am.PostManager.postConfig(string(unifiedDecl), tenants, origin)
 }
 
 func (cfg *AS3Config) updateConfig(newAS3Cfg AS3Config) {
@@ -300,7 +368,7 @@ func (am *AS3Manager) DeleteAS3Partition(partition string) (bool, string) {
 		return true, ""
 	}
 
-	return am.PostManager.postConfig(string(nilDecl), nil)
+	return am.PostManager.postConfig(string(nilDecl), nil, postOrigin{})
 }
 
 func (c AS3Config) Init(partition string) {
@@ -322,40 +390,172 @@ func (am *AS3Manager) fetchAS3Schema() {
 func (am *AS3Manager) ConfigDeployer() {
 	// For the very first post after starting controller, need not wait to post
 	firstPost := true
-	for msgReq := range am.ReqChan {
 
-		if !firstPost && am.PostManager.AS3PostDelay != 0 {
-			// Time (in seconds) that CIS waits to post the AS3 declaration to BIG-IP.
-			_ = <-time.After(time.Duration(am.PostManager.AS3PostDelay) * time.Second)
-		}
+	// Periodically verify the posted tenants against BIG-IP, if enabled
+	var driftCheck <-chan time.Time
+	if am.driftCheckInterval > 0 {
+		ticker := time.NewTicker(am.driftCheckInterval)
+		defer ticker.Stop()
+		driftCheck = ticker.C
+	}
 
-		// After postDelay expires pick up latest declaration, if available
+	for {
 		select {
-		case msgReq = <-am.ReqChan:
-		case <-time.After(1 * time.Microsecond):
+		case msgReq, ok := <-am.ReqChan:
+			if !ok {
+				return
+			}
+			am.deployRequest(msgReq, firstPost)
+			firstPost = false
+		case <-driftCheck:
+			am.reconcileDrift()
 		}
+	}
+}
+
+// deployRequest posts the declaration for msgReq and keeps re-posting until
+// BIG-IP accepts it
+func (am *AS3Manager) deployRequest(msgReq MessageRequest, firstPost bool) {
+	if !firstPost && am.PostManager.AS3PostDelay != 0 {
+		// Time (in seconds) that CIS waits to post the AS3 declaration to BIG-IP.
+		_ = <-time.After(time.Duration(am.PostManager.AS3PostDelay) * time.Second)
+	}
 
-		posted, event := am.postAS3Declaration(msgReq.ResourceRequest)
-		// To handle general errors
-		for !posted {
-			timeout := getTimeDurationForErrorResponse(event)
-			log.Debugf("[AS3] Error handling for event %v", event)
-			posted, event = am.postOnEventOrTimeout(timeout)
+	// After postDelay expires pick up latest declaration, if available
+	select {
+	case msgReq = <-am.ReqChan:
+	case <-time.After(1 * time.Microsecond):
+	}
+	origin := postOrigin{trace: msgReq.Trace, triggers: msgReq.Triggers}
+
+	posted, event := am.postAS3Declaration(msgReq.ResourceRequest, origin)
+	if !posted {
+		event = am.retryPost(event, origin)
+	}
+	am.breaker.RecordSuccess()
+	am.tenantErrors = nil
+	if event == responseStatusOk {
+		log.Debugf("[AS3] Preparing response message to response handler")
+		am.sendARPRequest()
+		log.Debugf("[AS3] Sent response message to response handler")
+	}
+}
+
+// retryPost re-posts the declaration that failed with event, or a newer
+// one, until BIG-IP accepts it, and returns the event of the accepted post
+func (am *AS3Manager) retryPost(event string, origin postOrigin) string {
+	// To handle general errors
+	attempt := 0
+	for posted := false; !posted; {
+		am.reportTenantErrors()
+		if am.rollback(origin) {
+			return responseStatusRolledBack
+		}
+		// Only count the failures of BIG-IP, retrying an invalid declaration
+		// must not open the breaker
+		if isBigIPUnavailable(event) {
+			am.breaker.RecordFailure()
 		}
-		firstPost = false
-		if event == responseStatusOk {
-			log.Debugf("[AS3] Preparing response message to response handler")
-			am.sendARPRequest()
-			log.Debugf("[AS3] Sent response message to response handler")
+		timeout := am.retryPolicy.NextDelay(event, attempt)
+		attempt++
+		if am.breaker.IsOpen() {
+			// BIG-IP answered the probe, re-post right away. The delays keep
+			// growing should the declaration still fail.
+			am.waitForBigIP()
+			timeout = timeoutNill
 		}
+		log.Debugf("[AS3] Error handling for event %v, retrying in %v", event, timeout)
+		posted, event = am.postOnEventOrTimeout(timeout, &origin)
 	}
+	return event
 }
 
-// Helper method used by configDeployer to handle error responses received from BIG-IP
-func (am *AS3Manager) postOnEventOrTimeout(timeout time.Duration) (bool, string) {
+// rollback re-posts the last declaration accepted by BIG-IP when BIG-IP
+// rejected the active declaration, instead of retrying it. The rolled back
+// declaration becomes the active one, so the next change is posted.
+func (am *AS3Manager) rollback(origin postOrigin) bool {
+	history := am.PostManager.history
+	if !am.rollbackOnFailure || history == nil || len(am.PostManager.tenantErrors) == 0 {
+		return false
+	}
+	lastGood, ok := history.Last()
+	if !ok || DeepEqualJSON(as3Declaration(lastGood.Declaration), am.as3ActiveConfig.unifiedDeclaration) {
+		return false
+	}
+	log.Warningf("[AS3] BIG-IP rejected the declaration, rolling back to declaration %v of %v",
+		lastGood.ID, lastGood.Time.Format(time.RFC3339))
+	var tenants []string
+	if am.FilterTenants {
+		tenants = lastGood.Tenants
+	}
+	// The history keeps the triggers of the rolled back declaration
+	origin.triggers = lastGood.Triggers
+	if posted, event := am.PostManager.postConfig(string(lastGood.Declaration), tenants, origin); !posted {
+		log.Errorf("[AS3] Failed to roll back to declaration %v: %v", lastGood.ID, event)
+		return false
+	}
+	bigIPPrometheus.AS3Rollbacks.Inc()
+	am.as3ActiveConfig.unifiedDeclaration = as3Declaration(lastGood.Declaration)
+	return true
+}
+
+// History returns the declarations accepted by BIG-IP, oldest first, nil
+// when the history is disabled
+func (am *AS3Manager) History() []HistoryEntry {
+	if am.PostManager.history == nil {
+		return nil
+	}
+	return am.PostManager.history.Entries()
+}
+
+// waitForBigIP blocks while the circuit breaker is open, probing BIG-IP with
+// an AS3 version request until it answers, then half-opens the breaker.
+// Declarations received meanwhile stay queued on ReqChan.
+func (am *AS3Manager) waitForBigIP() {
+	for attempt := 0; ; attempt++ {
+		delay := am.retryPolicy.NextDelay(responseStatusCommon, attempt)
+		log.Debugf("[AS3] Circuit breaker open, probing BIG-IP in %v", delay)
+		<-time.After(delay)
+		if _, err := am.PostManager.GetBigipAS3Version(); err == nil {
+			am.breaker.HalfOpen()
+			return
+		}
+	}
+}
+
+// CircuitBreakerState returns the state of the breaker guarding AS3 posts
+func (am *AS3Manager) CircuitBreakerState() string {
+	return am.breaker.State()
+}
+
+// UpdateCredentials swaps the BIG-IP credentials used to post declarations
+func (am *AS3Manager) UpdateCredentials(bigIPURL, username, password string) {
+	am.PostManager.UpdateCredentials(bigIPURL, username, password)
+}
+
+// GetBigipAS3Version returns the version of AS3 installed on BIG-IP
+func (am *AS3Manager) GetBigipAS3Version() (string, error) {
+	return am.PostManager.GetBigipAS3Version()
+}
+
+// UnsyncedSince returns the time of the first failed post since the last
+// successful one, and the zero time when the last post succeeded
+func (am *AS3Manager) UnsyncedSince() time.Time {
+	return am.PostManager.UnsyncedSince()
+}
+
+// Declarations returns the last posted and the last failed declarations
+func (am *AS3Manager) Declarations() DeclarationStatus {
+	return am.PostManager.Declarations()
+}
+
+// Helper method used by configDeployer to handle error responses received from BIG-IP.
+// A new request replaces origin.
+func (am *AS3Manager) postOnEventOrTimeout(timeout time.Duration, origin *postOrigin) (bool, string) {
 	select {
 	case msgReq := <-am.ReqChan:
-		return am.postAS3Declaration(msgReq.ResourceRequest)
+		*origin = postOrigin{trace: msgReq.Trace, triggers: msgReq.Triggers}
+		return am.postAS3Declaration(msgReq.ResourceRequest, *origin)
 	case <-time.After(timeout):
 		if am.as3ActiveConfig.configmap.isDeletePending() {
 			return am.processAS3CfgMapDelete(am.as3ActiveConfig.configmap.Name,
@@ -364,8 +564,21 @@ func (am *AS3Manager) postOnEventOrTimeout(timeout time.Duration) (bool, string)
 		}
 		tenants := getTenants(am.as3ActiveConfig.unifiedDeclaration)
 		unifiedDeclaration := string(am.as3ActiveConfig.unifiedDeclaration)
-		return am.PostManager.postConfig(unifiedDeclaration, tenants)
+		return am.PostManager.postConfig(unifiedDeclaration, tenants, *origin)
+	}
+}
+
+// reportTenantErrors hands the tenants rejected by the last post to the
+// response handler, unless they were already reported by a previous retry
+func (am *AS3Manager) reportTenantErrors() {
+	tenantErrors := am.PostManager.tenantErrors
+	if len(tenantErrors) == 0 || reflect.DeepEqual(tenantErrors, am.tenantErrors) {
+		return
 	}
+	am.tenantErrors = tenantErrors
+	am.postAgentResponse(MessageResponse{ResourceResponse: ResourceResponse{
+		TenantErrors: tenantErrors,
+	}})
 }
 
 // Post FDB records on response channel
@@ -20,7 +20,6 @@ import (
 	"fmt"
 
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 )
 
 func (am *AS3Manager) prepareAS3ResourceConfig(routeCfg AS3Config) AS3Config {
@@ -73,8 +72,10 @@ func (am *AS3Manager) processProfilesForAS3(sharedApp as3Application) {
 			switch cfg.MetaData.ResourceType {
 			case ResourceTypeRoute:
 				processRouteTLSProfilesForAS3(&cfg.MetaData, svc)
-			case ResourceTypeIngress:
+			case ResourceTypeIngress, ResourceTypeGateway:
 				processIngressTLSProfilesForAS3(&cfg.Virtual, svc)
+			case ResourceTypeLoadBalancer:
+				// Layer 4 virtual servers have no TLS profiles
 			default:
 				log.Warningf("Unsupported resource type: %v", cfg.MetaData.ResourceType)
 			}
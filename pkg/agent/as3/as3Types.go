@@ -70,6 +70,8 @@ type (
 		Enabled  *bool                   `json:"enabled,omitempty"`
 		Location string                  `json:"location,omitempty"`
 		Replace  *as3ActionReplaceMap    `json:"replace,omitempty"`
+		Insert   *as3ActionReplaceMap    `json:"insert,omitempty"`
+		Remove   *as3ActionReplaceMap    `json:"remove,omitempty"`
 	}
 
 	as3ActionReplaceMap struct {
@@ -80,14 +82,15 @@ type (
 
 	// as3Condition maps to Policy_Condition in AS3 Resources
 	as3Condition struct {
-		Type        string                  `json:"type,omitempty"`
-		Name        string                  `json:"name,omitempty"`
-		Event       string                  `json:"event,omitempty"`
-		All         *as3PolicyCompareString `json:"all,omitempty"`
-		Index       int                     `json:"index,omitempty"`
-		Host        *as3PolicyCompareString `json:"host,omitempty"`
-		PathSegment *as3PolicyCompareString `json:"pathSegment,omitempty"`
-		Path        *as3PolicyCompareString `json:"path,omitempty"`
+		Type           string                  `json:"type,omitempty"`
+		Name           string                  `json:"name,omitempty"`
+		Event          string                  `json:"event,omitempty"`
+		All            *as3PolicyCompareString `json:"all,omitempty"`
+		Index          int                     `json:"index,omitempty"`
+		Host           *as3PolicyCompareString `json:"host,omitempty"`
+		PathSegment    *as3PolicyCompareString `json:"pathSegment,omitempty"`
+		Path           *as3PolicyCompareString `json:"path,omitempty"`
+		QueryParameter *as3PolicyCompareString `json:"queryParameter,omitempty"`
 	}
 
 	// as3ActionForwardSelect maps to Policy_Action_Forward_Select in AS3 Resources
@@ -109,10 +112,11 @@ type (
 
 	// as3Pool maps to Pool in AS3 Resources
 	as3Pool struct {
-		Class             string               `json:"class,omitempty"`
-		LoadBalancingMode string               `json:"loadBalancingMode,omitempty"`
-		Members           []as3PoolMember      `json:"members,omitempty"`
-		Monitors          []as3ResourcePointer `json:"monitors,omitempty"`
+		Class                string               `json:"class,omitempty"`
+		LoadBalancingMode    string               `json:"loadBalancingMode,omitempty"`
+		Members              []as3PoolMember      `json:"members,omitempty"`
+		Monitors             []as3ResourcePointer `json:"monitors,omitempty"`
+		MinimumMembersActive int32                `json:"minimumMembersActive,omitempty"`
 	}
 
 	// as3PoolMember maps to Pool_Member in AS3 Resources
@@ -120,6 +124,8 @@ type (
 		AddressDiscovery string   `json:"addressDiscovery,omitempty"`
 		ServerAddresses  []string `json:"serverAddresses,omitempty"`
 		ServicePort      int32    `json:"servicePort,omitempty"`
+		AdminState       string   `json:"adminState,omitempty"`
+		PriorityGroup    int32    `json:"priorityGroup,omitempty"`
 	}
 
 	// as3ResourcePointer maps to following in AS3 Resources
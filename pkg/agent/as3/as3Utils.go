@@ -19,11 +19,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
 
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 )
 
 func ValidateJSONStringAndFetchObject(jsonData string, jsonObj *map[string]interface{}) error {
@@ -142,17 +140,13 @@ func DeepEqualJSON(decl1, decl2 as3Declaration) bool {
 }
 
 func ExtractVirtualAddressAndPort(str string) (string, int) {
-	destination := strings.Split(str, "/")
-	ipPort := strings.Split(destination[len(destination)-1], ":")
+	address, port, err := ParseVirtualDestination(str)
 	// verify that ip address and port exists else log error.
-	if len(ipPort) == 2 {
-		port, _ := strconv.Atoi(ipPort[1])
-		return ipPort[0], port
-	} else {
-		log.Error("[AS3] Invalid Virtual Server Destination IP address/Port.")
+	if nil != err {
+		log.Errorf("[AS3] Invalid Virtual Server Destination IP address/Port: %v", err)
 		return "", 0
 	}
-
+	return address, port
 }
 
 func DeepEqualAS3ArbitaryJsonObject(obj1, obj2 map[string]interface{}) bool {
@@ -386,7 +380,7 @@ func (a as3Application) initDefault() {
 func as3FormatedString(str string, resourceType string) string {
 	var formattedString string
 	switch resourceType {
-	case ResourceTypeIngress:
+	case ResourceTypeIngress, ResourceTypeGateway:
 		formattedString = strings.Replace(str, ".", "_", -1)
 		formattedString = strings.Replace(formattedString, "-", "_", -1)
 	default:
@@ -0,0 +1,405 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package as3
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// DefaultHistorySize is the number of declarations kept by default
+	DefaultHistorySize = 10
+
+	historyKeyPrefix = "declaration-"
+	historyKeySuffix = ".json"
+
+	// historyLabel labels the Secrets of a SecretHistoryStore with its name
+	historyLabel     = "cis.f5.com/as3-history"
+	historySecretKey = "entry.json"
+	// maxSecretSize is the limit of the data of a Secret
+	maxSecretSize = 1 << 20
+)
+
+// HistoryEntry is a declaration that BIG-IP accepted
+type HistoryEntry struct {
+	ID      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	Tenants []string  `json:"tenants"`
+	// Resources whose changes produced the declaration
+	Triggers    []string        `json:"triggers,omitempty"`
+	Declaration json.RawMessage `json:"declaration"`
+}
+
+// HistoryStore persists the declaration history
+type HistoryStore interface {
+	Load() ([]HistoryEntry, error)
+	// Store replaces the stored entries with entries
+	Store(entries []HistoryEntry) error
+}
+
+// DeclarationHistory keeps the last declarations accepted by BIG-IP, oldest
+// first, in memory and in an optional HistoryStore
+type DeclarationHistory struct {
+	mutex   sync.Mutex
+	store   HistoryStore
+	size    int
+	entries []HistoryEntry
+}
+
+// NewDeclarationHistory creates a history of size entries, loaded from
+// store when not nil. The history is usable, though empty, when the store
+// fails to load.
+func NewDeclarationHistory(store HistoryStore, size int) (*DeclarationHistory, error) {
+	h := &DeclarationHistory{
+		store: store,
+		size:  size,
+	}
+	if store == nil {
+		return h, nil
+	}
+	entries, err := store.Load()
+	if err != nil {
+		return h, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+	h.entries = h.trim(entries)
+	return h, nil
+}
+
+func (h *DeclarationHistory) trim(entries []HistoryEntry) []HistoryEntry {
+	if len(entries) > h.size {
+		entries = entries[len(entries)-h.size:]
+	}
+	return entries
+}
+
+// Add records decl, unless it is the same as the last recorded declaration
+func (h *DeclarationHistory) Add(decl string, triggers []string) {
+	if !json.Valid([]byte(decl)) {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	id := 1
+	if len(h.entries) != 0 {
+		last := h.entries[len(h.entries)-1]
+		if DeepEqualJSON(as3Declaration(last.Declaration), as3Declaration(decl)) {
+			return
+		}
+		id = last.ID + 1
+	}
+	var tenants []string
+	for tenant := range getDeclaredTenants(as3Declaration(decl)) {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+	entry := HistoryEntry{
+		ID:          id,
+		Time:        time.Now(),
+		Tenants:     tenants,
+		Triggers:    triggers,
+		Declaration: json.RawMessage(decl),
+	}
+	h.entries = h.trim(append(h.entries, entry))
+
+	if h.store != nil {
+		if err := h.store.Store(append([]HistoryEntry{}, h.entries...)); err != nil {
+			log.Warningf("[AS3] Failed to store the declaration history: %v", err)
+		}
+	}
+}
+
+// Entries returns the recorded declarations, oldest first
+func (h *DeclarationHistory) Entries() []HistoryEntry {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return append([]HistoryEntry{}, h.entries...)
+}
+
+// Get returns the entry id
+func (h *DeclarationHistory) Get(id int) (HistoryEntry, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, entry := range h.entries {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return HistoryEntry{}, false
+}
+
+// Last returns the last declaration accepted by BIG-IP
+func (h *DeclarationHistory) Last() (HistoryEntry, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.entries) == 0 {
+		return HistoryEntry{}, false
+	}
+	return h.entries[len(h.entries)-1], true
+}
+
+// historyKey names the file of an entry
+func historyKey(id int) string {
+	return fmt.Sprintf("%s%d%s", historyKeyPrefix, id, historyKeySuffix)
+}
+
+func isHistoryKey(name string) bool {
+	return strings.HasPrefix(name, historyKeyPrefix) && strings.HasSuffix(name, historyKeySuffix)
+}
+
+// FileHistoryStore keeps each entry in a JSON file of a directory. The
+// files are only readable by the controller, as declarations hold private
+// keys and passphrases.
+type FileHistoryStore struct {
+	Dir string
+}
+
+func (s FileHistoryStore) Load() ([]HistoryEntry, error) {
+	files, err := ioutil.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []HistoryEntry
+	for _, file := range files {
+		if file.IsDir() || !isHistoryKey(file.Name()) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.Dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var entry HistoryEntry
+		if err = json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("%v: %v", file.Name(), err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Store writes the files of new entries and removes the files of the
+// entries no longer kept. Entries never change once written.
+func (s FileHistoryStore) Store(entries []HistoryEntry) error {
+	if err := os.MkdirAll(s.Dir, 0700); err != nil {
+		return err
+	}
+	keep := make(map[string]bool)
+	for _, entry := range entries {
+		name := historyKey(entry.ID)
+		keep[name] = true
+		path := filepath.Join(s.Dir, name)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		// Write to a temporary file first, so that a file is never partial
+		if err = ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+			return err
+		}
+		if err = os.Rename(path+".tmp", path); err != nil {
+			return err
+		}
+	}
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		if isHistoryKey(file.Name()) && !keep[file.Name()] {
+			if err = os.Remove(filepath.Join(s.Dir, file.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SecretHistoryStore keeps each entry in a Secret named <Name>-<id>, as
+// declarations hold private keys and passphrases. A Secret per entry keeps
+// the entries within the 1MiB limit of Secrets; larger entries are not
+// stored.
+type SecretHistoryStore struct {
+	KubeClient kubernetes.Interface
+	Namespace  string
+	Name       string
+}
+
+func (s SecretHistoryStore) secretName(id int) string {
+	return fmt.Sprintf("%s-%d", s.Name, id)
+}
+
+func (s SecretHistoryStore) list() ([]v1.Secret, error) {
+	secrets, err := s.KubeClient.CoreV1().Secrets(s.Namespace).List(metav1.ListOptions{
+		LabelSelector: historyLabel + "=" + s.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return secrets.Items, nil
+}
+
+func (s SecretHistoryStore) Load() ([]HistoryEntry, error) {
+	secrets, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	var entries []HistoryEntry
+	for _, secret := range secrets {
+		var entry HistoryEntry
+		if err = json.Unmarshal(secret.Data[historySecretKey], &entry); err != nil {
+			return nil, fmt.Errorf("%v: %v", secret.Name, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Store creates the Secrets of new entries and deletes the Secrets of the
+// entries no longer kept. Entries never change once written.
+func (s SecretHistoryStore) Store(entries []HistoryEntry) error {
+	secrets, err := s.list()
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]bool)
+	for _, secret := range secrets {
+		stored[secret.Name] = true
+	}
+	keep := make(map[string]bool)
+	var tooLarge []int
+	for _, entry := range entries {
+		name := s.secretName(entry.ID)
+		keep[name] = true
+		if stored[name] {
+			continue
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		if len(data) > maxSecretSize {
+			tooLarge = append(tooLarge, entry.ID)
+			continue
+		}
+		_, err = s.KubeClient.CoreV1().Secrets(s.Namespace).Create(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: s.Namespace,
+				Labels:    map[string]string{historyLabel: s.Name},
+			},
+			Type: v1.SecretTypeOpaque,
+			Data: map[string][]byte{historySecretKey: data},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for name := range stored {
+		if keep[name] {
+			continue
+		}
+		err = s.KubeClient.CoreV1().Secrets(s.Namespace).Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	if len(tooLarge) != 0 {
+		return fmt.Errorf("entries %v exceed the %v bytes limit of Secrets and are not stored",
+			tooLarge, maxSecretSize)
+	}
+	return nil
+}
+
+// DiffDeclarations lists the differences between two declarations, one per
+// line: "+ path: value" for added properties, "- path: value" for removed
+// ones and "~ path: old -> new" for changed ones. Paths are JSON pointers.
+func DiffDeclarations(from, to json.RawMessage) ([]string, error) {
+	var o1, o2 interface{}
+	if err := json.Unmarshal(from, &o1); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(to, &o2); err != nil {
+		return nil, err
+	}
+	var diff []string
+	diffValues("", o1, o2, &diff)
+	return diff, nil
+}
+
+func diffValues(path string, from, to interface{}, diff *[]string) {
+	m1, ok1 := from.(map[string]interface{})
+	m2, ok2 := to.(map[string]interface{})
+	if !ok1 || !ok2 {
+		if !reflect.DeepEqual(from, to) {
+			if path == "" {
+				path = "/"
+			}
+			*diff = append(*diff, fmt.Sprintf("~ %s: %s -> %s", path, toJSON(from), toJSON(to)))
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(m1)+len(m2))
+	for key := range m1 {
+		keys = append(keys, key)
+	}
+	for key := range m2 {
+		if _, ok := m1[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		keyPath := path + "/" + strings.Replace(strings.Replace(key, "~", "~0", -1), "/", "~1", -1)
+		v1, in1 := m1[key]
+		v2, in2 := m2[key]
+		switch {
+		case !in1:
+			*diff = append(*diff, fmt.Sprintf("+ %s: %s", keyPath, toJSON(v2)))
+		case !in2:
+			*diff = append(*diff, fmt.Sprintf("- %s: %s", keyPath, toJSON(v1)))
+		default:
+			diffValues(keyPath, v1, v2, diff)
+		}
+	}
+}
+
+func toJSON(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
@@ -0,0 +1,201 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package as3
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func historyDeclaration(tenant, template string) string {
+	return fmt.Sprintf(`{"class": "AS3", "declaration": {"class": "ADC", "schemaVersion": "3.18.0",
+	  "%s": {"class": "Tenant", "svc": {"class": "Application", "template": "%s"}}}}`, tenant, template)
+}
+
+var _ = Describe("AS3 Declaration History Tests", func() {
+	It("Keeps the last accepted declarations", func() {
+		h, err := NewDeclarationHistory(nil, 2)
+		Expect(err).To(BeNil())
+		_, ok := h.Last()
+		Expect(ok).To(BeFalse())
+
+		h.Add(historyDeclaration("app1", "http"), []string{"default/foo"})
+		h.Add(historyDeclaration("app1", "http"), nil)
+		h.Add("not json", nil)
+		Expect(h.Entries()).To(HaveLen(1))
+		h.Add(historyDeclaration("app1", "https"), nil)
+		h.Add(historyDeclaration("app2", "https"), nil)
+
+		entries := h.Entries()
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].ID).To(Equal(2))
+		Expect(entries[1].ID).To(Equal(3))
+		Expect(entries[1].Tenants).To(Equal([]string{"app2"}))
+		_, ok = h.Get(1)
+		Expect(ok).To(BeFalse())
+		last, ok := h.Last()
+		Expect(ok).To(BeTrue())
+		Expect(last.ID).To(Equal(3))
+	})
+
+	It("Persists the history in a directory", func() {
+		dir, err := ioutil.TempDir("", "as3-history")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		store := FileHistoryStore{Dir: dir + "/history"}
+
+		h, err := NewDeclarationHistory(store, 2)
+		Expect(err).To(BeNil())
+		for _, template := range []string{"http", "https", "tcp"} {
+			h.Add(historyDeclaration("app1", template), []string{"default/foo"})
+		}
+		files, _ := ioutil.ReadDir(dir + "/history")
+		Expect(files).To(HaveLen(2))
+		Expect(files[0].Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		h, err = NewDeclarationHistory(store, 2)
+		Expect(err).To(BeNil())
+		entries := h.Entries()
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[1].ID).To(Equal(3))
+		Expect(entries[1].Triggers).To(Equal([]string{"default/foo"}))
+		Expect(DeepEqualJSON(as3Declaration(entries[1].Declaration),
+			as3Declaration(historyDeclaration("app1", "tcp")))).To(BeTrue())
+	})
+
+	It("Persists the history in Secrets", func() {
+		client := fake.NewSimpleClientset(&v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "history-1", Namespace: "kube-system"},
+		})
+		store := SecretHistoryStore{KubeClient: client, Namespace: "kube-system", Name: "history"}
+		h, err := NewDeclarationHistory(store, 1)
+		Expect(err).To(BeNil())
+		h.Add(historyDeclaration("app1", "http"), nil)
+		h.Add(historyDeclaration("app1", "https"), nil)
+
+		secrets, err := client.CoreV1().Secrets("kube-system").List(metav1.ListOptions{})
+		Expect(err).To(BeNil())
+		Expect(secrets.Items).To(HaveLen(2))
+		secret, err := client.CoreV1().Secrets("kube-system").Get("history-2", metav1.GetOptions{})
+		Expect(err).To(BeNil())
+		Expect(secret.Labels).To(Equal(map[string]string{"cis.f5.com/as3-history": "history"}))
+		Expect(secret.Data).To(HaveKey("entry.json"))
+
+		entries, err := store.Load()
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].ID).To(Equal(2))
+
+		large := HistoryEntry{ID: 3, Declaration: json.RawMessage(
+			fmt.Sprintf(`{"remark": "%s"}`, strings.Repeat("x", maxSecretSize)))}
+		Expect(store.Store([]HistoryEntry{entries[0], large})).NotTo(BeNil())
+		entries, err = store.Load()
+		Expect(err).To(BeNil())
+		Expect(entries).To(HaveLen(1))
+
+		entries, err = SecretHistoryStore{KubeClient: client, Namespace: "default", Name: "history"}.Load()
+		Expect(err).To(BeNil())
+		Expect(entries).To(BeEmpty())
+	})
+
+	It("Diffs declarations", func() {
+		diff, err := DiffDeclarations(
+			json.RawMessage(`{"a": 1, "b": {"c": "x", "d/e": [1]}, "f": true}`),
+			json.RawMessage(`{"a": 1, "b": {"c": "y", "d/e": [1, 2]}, "g": null}`),
+		)
+		Expect(err).To(BeNil())
+		Expect(diff).To(Equal([]string{
+			`~ /b/c: "x" -> "y"`,
+			`~ /b/d~1e: [1] -> [1,2]`,
+			`- /f: true`,
+			`+ /g: null`,
+		}))
+		diff, err = DiffDeclarations(json.RawMessage(`[1]`), json.RawMessage(`[1]`))
+		Expect(err).To(BeNil())
+		Expect(diff).To(BeEmpty())
+		_, err = DiffDeclarations(json.RawMessage(`{`), json.RawMessage(`{}`))
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("Rolls back to the last accepted declaration", func() {
+		var posted []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			posted = append(posted, string(body))
+			if DeepEqualJSON(as3Declaration(body), as3Declaration(historyDeclaration("app1", "bad"))) {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				fmt.Fprint(w, `{"code": 422, "results": [{"code": 422, "tenant": "app1", "message": "invalid"}]}`)
+				return
+			}
+			fmt.Fprint(w, `{"results": [{"code": 200, "tenant": "app1", "message": "success"}]}`)
+		}))
+		defer server.Close()
+		am := NewAS3Manager(&Params{
+			BIGIPURL:          server.URL,
+			RspChan:           make(chan interface{}, 1),
+			HistorySize:       DefaultHistorySize,
+			RollbackOnFailure: true,
+		})
+		failing := postOrigin{triggers: []string{"default/bad"}}
+		Expect(am.rollback(failing)).To(BeFalse())
+
+		good := historyDeclaration("app1", "http")
+		ok, _ := am.PostManager.postConfig(good, nil, postOrigin{triggers: []string{"default/good"}})
+		Expect(ok).To(BeTrue())
+		Expect(am.History()).To(HaveLen(1))
+
+		am.as3ActiveConfig.unifiedDeclaration = as3Declaration(historyDeclaration("app1", "bad"))
+		ok, _ = am.PostManager.postConfig(string(am.as3ActiveConfig.unifiedDeclaration), nil, failing)
+		Expect(ok).To(BeFalse())
+		Expect(am.rollback(failing)).To(BeTrue())
+		Expect(DeepEqualJSON(as3Declaration(posted[len(posted)-1]), as3Declaration(good))).To(BeTrue())
+		Expect(DeepEqualJSON(am.as3ActiveConfig.unifiedDeclaration, as3Declaration(good))).To(BeTrue())
+		Expect(am.History()).To(HaveLen(1))
+		Expect(am.History()[0].Triggers).To(Equal([]string{"default/good"}))
+
+		// Nothing to roll back to once the active declaration is the last good one
+		Expect(am.rollback(failing)).To(BeFalse())
+		Expect(am.RspChan).To(BeEmpty())
+	})
+
+	It("Records the triggers of the deployed requests", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"results": [{"code": 200, "tenant": "app1", "message": "success"}]}`)
+		}))
+		defer server.Close()
+		am := NewAS3Manager(&Params{
+			BIGIPURL:    server.URL,
+			RspChan:     make(chan interface{}, 1),
+			HistorySize: DefaultHistorySize,
+		})
+		am.PostManager.postConfig(historyDeclaration("app1", "http"), nil,
+			postOrigin{triggers: []string{"default/foo"}})
+		Expect(am.History()[0].Triggers).To(Equal([]string{"default/foo"}))
+		Expect(NewAS3Manager(&Params{}).History()).To(BeNil())
+	})
+})
@@ -25,9 +25,13 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tokenmanager"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
 	routeclient "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
 )
 
@@ -43,15 +47,42 @@ const (
 	responseStatusCommon             = "statusCommonResponse"
 	responseStatusNotFound           = "statusNotFound"
 	responseStatusServiceUnavailable = "statusServiceUnavailable"
+	// BIG-IP did not respond, or not with an AS3 response
+	responseStatusUnreachable = "statusUnreachable"
+	// BIG-IP responded with a server error other than 503
+	responseStatusServerError = "statusServerError"
+	// The declaration was rejected and the last accepted one re-posted
+	responseStatusRolledBack = "statusRolledBack"
 )
 
 type PostManager struct {
 	postChan   chan config
 	httpClient *http.Client
+	// Authenticates requests with X-F5-Auth-Token when token auth is enabled
+	tokenManager *tokenmanager.TokenManager
+	// Guards BIG-IP credentials in PostParams, which can be swapped at runtime
+	credsMutex sync.RWMutex
 	activeCfg  config
+	// AS3 error of each tenant rejected by BIG-IP in the last post
+	tenantErrors map[string]string
+	// Time of the first failed post since the last successful one
+	unsyncedSince time.Time
+	// Last posted and last failed declarations
+	declarations DeclarationStatus
+	syncMutex    sync.Mutex
+	// Declarations accepted by BIG-IP, nil when not kept
+	history *DeclarationHistory
 	PostParams
 }
 
+// postOrigin is the request a declaration is posted for
+type postOrigin struct {
+	// Parent of the post span
+	trace tracing.SpanContext
+	// Resources that triggered the request, recorded in the history
+	triggers []string
+}
+
 type PostParams struct {
 	BIGIPUsername string
 	BIGIPPassword string
@@ -62,6 +93,10 @@ type PostParams struct {
 	//Log the AS3 response body in Controller logs
 	LogResponse   bool
 	RouteClientV1 routeclient.RouteV1Interface
+	// Use token based authentication instead of basic auth
+	TokenAuth bool
+	// BIG-IP login provider used for token based authentication
+	LoginProvider string
 }
 
 type config struct {
@@ -104,30 +139,76 @@ func (postMgr *PostManager) setupBIGIPRESTClient() {
 		Transport: tr,
 		Timeout:   timeoutLarge,
 	}
-}
 
-func (postMgr *PostManager) getAS3APIURL(tenants []string) string {
-	apiURL := postMgr.BIGIPURL + "/mgmt/shared/appsvcs/declare/" + strings.Join(tenants, ",")
-	return apiURL
+	if postMgr.TokenAuth {
+		postMgr.tokenManager = tokenmanager.NewTokenManager(
+			postMgr.BIGIPURL,
+			postMgr.BIGIPUsername,
+			postMgr.BIGIPPassword,
+			postMgr.LoginProvider,
+			postMgr.httpClient,
+		)
+	}
 }
 
-func (postMgr *PostManager) getAS3VersionURL() string {
-	apiURL := postMgr.BIGIPURL + "/mgmt/shared/appsvcs/info"
-	return apiURL
+// doRequest sends request to BIG-IP authenticated with either an auth token
+// or basic auth
+func (postMgr *PostManager) doRequest(request *http.Request) (*http.Response, error) {
+	if postMgr.tokenManager != nil {
+		return postMgr.tokenManager.Do(request)
+	}
+	_, username, password := postMgr.getCredentials()
+	request.SetBasicAuth(username, password)
+	return postMgr.httpClient.Do(request)
 }
 
-func getTimeDurationForErrorResponse(errRsp string) time.Duration {
-	duration := timeoutNill
-	switch errRsp {
-	case responseStatusCommon:
-		duration = timeoutMedium
-	case responseStatusServiceUnavailable:
-		duration = timeoutSmall
+// UpdateCredentials swaps the BIG-IP URL and credentials used by subsequent
+// requests. Configuration waiting to be posted is kept.
+func (postMgr *PostManager) UpdateCredentials(bigIPURL, username, password string) {
+	postMgr.credsMutex.Lock()
+	postMgr.BIGIPURL = bigIPURL
+	postMgr.BIGIPUsername = username
+	postMgr.BIGIPPassword = password
+	postMgr.credsMutex.Unlock()
+
+	if postMgr.tokenManager != nil {
+		postMgr.tokenManager.SetCredentials(bigIPURL, username, password)
 	}
-	return duration
 }
 
-func (postMgr *PostManager) postConfig(data string, tenants []string) (bool, string) {
+// getCredentials returns BIG-IP URL, username and password
+func (postMgr *PostManager) getCredentials() (string, string, string) {
+	postMgr.credsMutex.RLock()
+	defer postMgr.credsMutex.RUnlock()
+	return postMgr.BIGIPURL, postMgr.BIGIPUsername, postMgr.BIGIPPassword
+}
+
+func (postMgr *PostManager) getAS3APIURL(tenants []string) string {
+	bigIPURL, _, _ := postMgr.getCredentials()
+	apiURL := bigIPURL + "/mgmt/shared/appsvcs/declare/" + strings.Join(tenants, ",")
+	return apiURL
+}
+
+func (postMgr *PostManager) postConfig(
+	data string,
+	tenants []string,
+	origin postOrigin,
+) (posted bool, status string) {
+	postMgr.tenantErrors = nil
+	statusCode := 0
+	span := tracing.StartKind(origin.trace, tracing.SpanPost, tracing.SpanKindClient,
+		tracing.Int("declaration.size", len(data)))
+	defer func() {
+		span.SetAttributes(
+			tracing.String("tenants", strings.Join(tenants, ",")),
+			tracing.Int("http.status_code", statusCode),
+		)
+		if !posted {
+			span.SetStatus(tracing.StatusError, status)
+		}
+		span.End()
+		postMgr.recordPostResult(data, tenants, origin.triggers, posted, statusCode)
+	}()
 	cfg := config{
 		data:      data,
 		as3APIURL: postMgr.getAS3APIURL(tenants),
@@ -140,12 +221,19 @@ func (postMgr *PostManager) postConfig(data string, tenants []string) (bool, str
 		return false, responseStatusCommon
 	}
 	log.Debugf("[AS3] posting request to %v", cfg.as3APIURL)
-	req.SetBasicAuth(postMgr.BIGIPUsername, postMgr.BIGIPPassword)
 
+	if len(tenants) == 0 {
+		tenants = getTenants(as3Declaration(data))
+	}
+	start := time.Now()
 	httpResp, responseMap := postMgr.httpReq(req)
+	bigIPPrometheus.ObservePost("as3", start, len(data))
 	if httpResp == nil || responseMap == nil {
-		return false, responseStatusCommon
+		bigIPPrometheus.RecordAS3Results("as3", 0, nil, tenants)
+		return false, responseStatusUnreachable
 	}
+	bigIPPrometheus.RecordAS3Results("as3", httpResp.StatusCode, responseMap, tenants)
+	statusCode = httpResp.StatusCode
 
 	switch httpResp.StatusCode {
 	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
@@ -155,47 +243,122 @@ func (postMgr *PostManager) postConfig(data string, tenants []string) (bool, str
 	case http.StatusNotFound:
 		return postMgr.handleResponseStatusNotFound(responseMap)
 	default:
-		return postMgr.handleResponseOthers(responseMap, cfg)
+		return postMgr.handleResponseOthers(responseMap, cfg, httpResp.StatusCode)
 	}
 }
 
+// PostedDeclaration is a declaration posted to BIG-IP
+type PostedDeclaration struct {
+	Time    time.Time `json:"time"`
+	Tenants []string  `json:"tenants"`
+	// HTTP status of the response, 0 when BIG-IP did not respond, and AS3
+	// error of each rejected tenant
+	StatusCode   int               `json:"statusCode"`
+	TenantErrors map[string]string `json:"tenantErrors,omitempty"`
+	Declaration  interface{}       `json:"declaration"`
+}
+
+// DeclarationStatus holds the last posted and the last failed declarations
+type DeclarationStatus struct {
+	LastPosted *PostedDeclaration `json:"lastPosted"`
+	LastFailed *PostedDeclaration `json:"lastFailed"`
+}
+
+// recordPostResult keeps the posted declaration, records it in the history
+// when accepted and tracks since when the declaration failed to post
+func (postMgr *PostManager) recordPostResult(
+	data string,
+	tenants []string,
+	triggers []string,
+	posted bool,
+	statusCode int,
+) {
+	decl := &PostedDeclaration{
+		Time:         time.Now(),
+		Tenants:      tenants,
+		StatusCode:   statusCode,
+		TenantErrors: postMgr.tenantErrors,
+		Declaration:  data,
+	}
+	if json.Valid([]byte(data)) {
+		decl.Declaration = json.RawMessage(data)
+	}
+
+	postMgr.syncMutex.Lock()
+	defer postMgr.syncMutex.Unlock()
+	if posted {
+		postMgr.unsyncedSince = time.Time{}
+		postMgr.declarations.LastPosted = decl
+		if postMgr.history != nil {
+			postMgr.history.Add(data, triggers)
+		}
+	} else {
+		if postMgr.unsyncedSince.IsZero() {
+			postMgr.unsyncedSince = decl.Time
+		}
+		postMgr.declarations.LastFailed = decl
+	}
+}
+
+// Declarations returns the last posted and the last failed declarations
+func (postMgr *PostManager) Declarations() DeclarationStatus {
+	postMgr.syncMutex.Lock()
+	defer postMgr.syncMutex.Unlock()
+	return postMgr.declarations
+}
+
+// UnsyncedSince returns the time of the first failed post since the last
+// successful one, and the zero time when the last post succeeded
+func (postMgr *PostManager) UnsyncedSince() time.Time {
+	postMgr.syncMutex.Lock()
+	defer postMgr.syncMutex.Unlock()
+	return postMgr.unsyncedSince
+}
+
+// GetBigipAS3Version returns the version of AS3 installed on BIG-IP
 func (postMgr *PostManager) GetBigipAS3Version() (string, error) {
-	url := postMgr.getAS3VersionURL()
+	bigIPURL, _, _ := postMgr.getCredentials()
+	log.Debugf("[AS3] posting GET BIGIP AS3 Version request on %v", bigIPURL+AS3InfoPath)
+	return GetBigipAS3Version(bigIPURL, postMgr.httpReq)
+}
+
+// GetAS3Declaration fetches the declared tenants from BIG-IP and returns them
+// keyed by tenant name. Tenants that are not on BIG-IP are absent.
+func (postMgr *PostManager) GetAS3Declaration(tenants []string) (map[string]interface{}, error) {
+	url := postMgr.getAS3APIURL(tenants)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		log.Errorf("[AS3] Creating new HTTP request error: %v ", err)
-		return "", err
+		return nil, err
 	}
 
-	log.Debugf("[AS3] posting GET BIGIP AS3 Version request on %v", url)
-	req.SetBasicAuth(postMgr.BIGIPUsername, postMgr.BIGIPPassword)
-
-	httpResp, responseMap := postMgr.httpReq(req)
-	if httpResp == nil || responseMap == nil {
-		return "", fmt.Errorf("Internal Error")
+	log.Debugf("[AS3] posting GET AS3 declaration request on %v", url)
+	httpResp, err := postMgr.doRequest(req)
+	if err != nil {
+		return nil, err
 	}
+	defer httpResp.Body.Close()
 
+	declaration := make(map[string]interface{})
 	switch httpResp.StatusCode {
 	case http.StatusOK:
-		if responseMap["version"] != nil {
-			versionField := responseMap["version"].(string)
-			as3VersionStr := versionField[:strings.LastIndex(versionField, ".")]
-			return as3VersionStr, nil
+		body, err := ioutil.ReadAll(httpResp.Body)
+		if err != nil {
+			return nil, err
 		}
-	case http.StatusNotFound:
-		responseMap["code"] = int(responseMap["code"].(float64))
-		if responseMap["code"] == http.StatusNotFound {
-			return "", fmt.Errorf("App services are not installed on BIGIP,"+
-				" Error response from BIGIP with status code %v", httpResp.StatusCode)
+		if err = json.Unmarshal(body, &declaration); err != nil {
+			return nil, fmt.Errorf("Response body unmarshal failed: %v", err)
 		}
-		// In case of 503 status code : CIS will exit and auto restart of the
-		// controller might fetch the BIGIP version once BIGIP is available.
+		return declaration, nil
+	case http.StatusNoContent, http.StatusNotFound:
+		// None of the requested tenants exist on BIG-IP
+		return declaration, nil
 	}
-	return "", fmt.Errorf("Error response from BIGIP with status code %v", httpResp.StatusCode)
+	return nil, fmt.Errorf("Error response from BIGIP with status code %v", httpResp.StatusCode)
 }
 
 func (postMgr *PostManager) httpReq(request *http.Request) (*http.Response, map[string]interface{}) {
-	httpResp, err := postMgr.httpClient.Do(request)
+	httpResp, err := postMgr.doRequest(request)
 	if err != nil {
 		log.Errorf("[AS3] REST call error: %v ", err)
 		return nil, nil
@@ -232,8 +395,7 @@ func (postMgr *PostManager) handleResponseStatusOK(responseMap map[string]interf
 
 func (postMgr *PostManager) handleResponseStatusServiceUnavailable(responseMap map[string]interface{}, cfg config) (bool, string) {
 	log.Errorf("[AS3] Big-IP Responded with error code: %v", responseMap["code"])
-	log.Debugf("[AS3] Response from BIG-IP: BIG-IP is busy, waiting %v seconds and re-posting the declaration", timeoutSmall)
-	//return postMgr.postOnEventOrTimeout(timeoutSmall, cfg)
+	log.Debugf("[AS3] Response from BIG-IP: BIG-IP is busy, re-posting the declaration")
 	return false, responseStatusServiceUnavailable
 }
 
@@ -247,10 +409,12 @@ func (postMgr *PostManager) handleResponseStatusNotFound(responseMap map[string]
 	if postMgr.LogResponse {
 		log.Errorf("[AS3] Raw response from Big-IP: %v ", responseMap)
 	}
-	return true, responseStatusNotFound
+	// AS3 is unavailable while BIG-IP restarts, keep retrying
+	return false, responseStatusNotFound
 }
 
-func (postMgr *PostManager) handleResponseOthers(responseMap map[string]interface{}, cfg config) (bool, string) {
+func (postMgr *PostManager) handleResponseOthers(responseMap map[string]interface{}, cfg config, statusCode int) (bool, string) {
+	postMgr.tenantErrors = GetAS3TenantErrors(responseMap, getTenants(as3Declaration(cfg.data)))
 	if results, ok := (responseMap["results"]).([]interface{}); ok {
 		for _, value := range results {
 			v := value.(map[string]interface{})
@@ -266,6 +430,8 @@ func (postMgr *PostManager) handleResponseOthers(responseMap map[string]interfac
 	if postMgr.LogResponse {
 		log.Errorf("[AS3] Raw response from Big-IP: %v ", responseMap)
 	}
-	//return postMgr.postOnEventOrTimeout(timeoutMedium, cfg)
+	if statusCode >= http.StatusInternalServerError {
+		return false, responseStatusServerError
+	}
 	return false, responseStatusCommon
 }
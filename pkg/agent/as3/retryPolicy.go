@@ -0,0 +1,199 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package as3
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+)
+
+const (
+	defaultRetryInitialDelay = timeoutSmall
+	defaultRetryMaxDelay     = timeoutLarge
+	defaultRetryMultiplier   = 2.0
+	defaultRetryJitter       = 0.2
+)
+
+// RetryPolicy decides how long the AS3 agent waits before re-posting a
+// declaration that BIG-IP did not accept. attempt starts at 0 for the first
+// retry and event is the response status returned by the PostManager.
+type RetryPolicy interface {
+	NextDelay(event string, attempt int) time.Duration
+}
+
+// ExponentialBackoff is a RetryPolicy that grows the delay by Multiplier on
+// every attempt, caps it at MaxDelay and spreads it by +/- Jitter.
+type ExponentialBackoff struct {
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+	// Fraction (0-1) of the computed delay to randomize
+	Jitter float64
+}
+
+// NewExponentialBackoff returns an ExponentialBackoff, replacing invalid
+// values with the defaults.
+func NewExponentialBackoff(
+	initialDelay, maxDelay time.Duration,
+	multiplier, jitter float64,
+) *ExponentialBackoff {
+	if initialDelay <= 0 {
+		initialDelay = defaultRetryInitialDelay
+	}
+	if maxDelay < initialDelay {
+		maxDelay = initialDelay
+	}
+	if multiplier < 1 {
+		multiplier = defaultRetryMultiplier
+	}
+	if jitter < 0 || jitter > 1 {
+		jitter = defaultRetryJitter
+	}
+	return &ExponentialBackoff{
+		InitialDelay: initialDelay,
+		MaxDelay:     maxDelay,
+		Multiplier:   multiplier,
+		Jitter:       jitter,
+	}
+}
+
+// DefaultRetryPolicy is used when no policy is configured in Params
+func DefaultRetryPolicy() RetryPolicy {
+	return NewExponentialBackoff(
+		defaultRetryInitialDelay,
+		defaultRetryMaxDelay,
+		defaultRetryMultiplier,
+		defaultRetryJitter,
+	)
+}
+
+func (eb *ExponentialBackoff) NextDelay(event string, attempt int) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+	delay := float64(eb.InitialDelay) * math.Pow(eb.Multiplier, float64(attempt))
+	if delay > float64(eb.MaxDelay) {
+		delay = float64(eb.MaxDelay)
+	}
+	if eb.Jitter > 0 {
+		// Spread the delay uniformly over [delay*(1-jitter), delay*(1+jitter)]
+		delay = delay * (1 + eb.Jitter*(2*rand.Float64()-1))
+	}
+	return time.Duration(delay)
+}
+
+// isBigIPUnavailable reports whether a failed post is a failure of BIG-IP
+// itself, the failures counted by the CircuitBreaker. Declarations rejected
+// by AS3 are not, BIG-IP would answer the probes right away.
+func isBigIPUnavailable(event string) bool {
+	switch event {
+	case responseStatusUnreachable, responseStatusServerError,
+		responseStatusServiceUnavailable, responseStatusNotFound:
+		return true
+	}
+	return false
+}
+
+// States of the CircuitBreaker
+const (
+	BreakerClosed   = "closed"
+	BreakerHalfOpen = "half-open"
+	BreakerOpen     = "open"
+)
+
+// CircuitBreaker stops the AS3 agent from posting to a BIG-IP that keeps
+// failing. After Threshold consecutive failures it opens and the agent only
+// probes BIG-IP until it answers again.
+type CircuitBreaker struct {
+	sync.Mutex
+	// Consecutive failures needed to open the breaker, 0 disables it
+	Threshold int
+	failures  int
+	state     string
+}
+
+func NewCircuitBreaker(threshold int) *CircuitBreaker {
+	cb := &CircuitBreaker{
+		Threshold: threshold,
+	}
+	cb.setState(BreakerClosed)
+	return cb
+}
+
+// RecordSuccess closes the breaker and resets the failure count
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.Lock()
+	defer cb.Unlock()
+	cb.failures = 0
+	bigIPPrometheus.AS3ConsecutiveFailures.Set(0)
+	if cb.state != BreakerClosed {
+		log.Infof("[AS3] Circuit breaker closed, BIG-IP accepted the declaration")
+		cb.setState(BreakerClosed)
+	}
+}
+
+// RecordFailure counts a failed post and opens the breaker once the
+// threshold is reached. A failure while half-open re-opens it immediately.
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.Lock()
+	defer cb.Unlock()
+	cb.failures++
+	bigIPPrometheus.AS3ConsecutiveFailures.Set(float64(cb.failures))
+	if cb.Threshold <= 0 || cb.state == BreakerOpen {
+		return
+	}
+	if cb.state == BreakerHalfOpen || cb.failures >= cb.Threshold {
+		log.Warningf("[AS3] Circuit breaker opened after %v consecutive failures", cb.failures)
+		cb.setState(BreakerOpen)
+	}
+}
+
+// HalfOpen allows a single trial post after a successful probe
+func (cb *CircuitBreaker) HalfOpen() {
+	cb.Lock()
+	defer cb.Unlock()
+	if cb.state == BreakerOpen {
+		log.Infof("[AS3] Circuit breaker half-open, BIG-IP is reachable again")
+		cb.setState(BreakerHalfOpen)
+	}
+}
+
+func (cb *CircuitBreaker) IsOpen() bool {
+	return cb.State() == BreakerOpen
+}
+
+func (cb *CircuitBreaker) State() string {
+	cb.Lock()
+	defer cb.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) setState(state string) {
+	cb.state = state
+	switch state {
+	case BreakerClosed:
+		bigIPPrometheus.AS3CircuitBreakerState.Set(0)
+	case BreakerHalfOpen:
+		bigIPPrometheus.AS3CircuitBreakerState.Set(1)
+	case BreakerOpen:
+		bigIPPrometheus.AS3CircuitBreakerState.Set(2)
+	}
+}
@@ -0,0 +1,141 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package as3
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recordingPolicy records the delays of an ExponentialBackoff
+type recordingPolicy struct {
+	*ExponentialBackoff
+	delays []time.Duration
+}
+
+func (rp *recordingPolicy) NextDelay(event string, attempt int) time.Duration {
+	delay := rp.ExponentialBackoff.NextDelay(event, attempt)
+	rp.delays = append(rp.delays, delay)
+	return delay
+}
+
+var _ = Describe("Retry Policy and Circuit Breaker", func() {
+	Describe("Exponential backoff", func() {
+		It("Grows the delay up to the maximum", func() {
+			eb := NewExponentialBackoff(time.Second, 10*time.Second, 2, 0)
+			Expect(eb.NextDelay(responseStatusCommon, 0)).To(Equal(time.Second))
+			Expect(eb.NextDelay(responseStatusCommon, 1)).To(Equal(2 * time.Second))
+			Expect(eb.NextDelay(responseStatusCommon, 3)).To(Equal(8 * time.Second))
+			Expect(eb.NextDelay(responseStatusCommon, 4)).To(Equal(10 * time.Second))
+			Expect(eb.NextDelay(responseStatusCommon, 40)).To(Equal(10 * time.Second))
+		})
+		It("Keeps jittered delays within bounds", func() {
+			eb := NewExponentialBackoff(10*time.Second, time.Minute, 2, 0.5)
+			for i := 0; i < 50; i++ {
+				delay := eb.NextDelay(responseStatusServiceUnavailable, 0)
+				Expect(delay).To(BeNumerically(">=", 5*time.Second))
+				Expect(delay).To(BeNumerically("<=", 15*time.Second))
+			}
+		})
+		It("Replaces invalid values with defaults", func() {
+			eb := NewExponentialBackoff(0, 0, 0, 3)
+			Expect(eb.InitialDelay).To(Equal(defaultRetryInitialDelay))
+			Expect(eb.MaxDelay).To(Equal(defaultRetryInitialDelay))
+			Expect(eb.Multiplier).To(Equal(defaultRetryMultiplier))
+			Expect(eb.Jitter).To(Equal(defaultRetryJitter))
+		})
+	})
+
+	Describe("Circuit breaker", func() {
+		It("Opens after consecutive failures and closes on success", func() {
+			cb := NewCircuitBreaker(3)
+			Expect(cb.State()).To(Equal(BreakerClosed))
+			cb.RecordFailure()
+			cb.RecordFailure()
+			Expect(cb.IsOpen()).To(BeFalse())
+			cb.RecordFailure()
+			Expect(cb.IsOpen()).To(BeTrue())
+
+			cb.HalfOpen()
+			Expect(cb.State()).To(Equal(BreakerHalfOpen))
+			cb.RecordFailure()
+			Expect(cb.IsOpen()).To(BeTrue())
+
+			cb.HalfOpen()
+			cb.RecordSuccess()
+			Expect(cb.State()).To(Equal(BreakerClosed))
+			cb.RecordFailure()
+			Expect(cb.IsOpen()).To(BeFalse())
+		})
+		It("Never opens when disabled", func() {
+			cb := NewCircuitBreaker(0)
+			for i := 0; i < 10; i++ {
+				cb.RecordFailure()
+			}
+			Expect(cb.State()).To(Equal(BreakerClosed))
+		})
+	})
+
+	Describe("Retrying posts", func() {
+		It("Backs off rejected declarations without opening the breaker", func() {
+			rejections := 6
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if rejections > 0 {
+					rejections--
+					w.WriteHeader(http.StatusUnprocessableEntity)
+					fmt.Fprint(w, `{"code": 422, "results": [{"code": 422, "tenant": "app1", "message": "invalid"}]}`)
+					return
+				}
+				fmt.Fprint(w, `{"results": [{"code": 200, "tenant": "app1", "message": "success"}]}`)
+			}))
+			defer server.Close()
+			policy := &recordingPolicy{ExponentialBackoff: NewExponentialBackoff(
+				time.Millisecond, 8*time.Millisecond, 2, 0)}
+			am := NewAS3Manager(&Params{
+				BIGIPURL:                server.URL,
+				RspChan:                 make(chan interface{}, 1),
+				RetryPolicy:             policy,
+				CircuitBreakerThreshold: 2,
+			})
+			am.as3ActiveConfig.unifiedDeclaration = as3Declaration(driftDeclaration)
+
+			posted, event := am.PostManager.postConfig(driftDeclaration, nil, postOrigin{})
+			Expect(posted).To(BeFalse())
+			Expect(am.retryPost(event, postOrigin{})).To(Equal(responseStatusOk))
+			Expect(policy.delays).To(Equal([]time.Duration{
+				time.Millisecond,
+				2 * time.Millisecond,
+				4 * time.Millisecond,
+				8 * time.Millisecond,
+				8 * time.Millisecond,
+				8 * time.Millisecond,
+			}))
+			Expect(am.CircuitBreakerState()).To(Equal(BreakerClosed))
+		})
+		It("Only counts the failures of BIG-IP", func() {
+			Expect(isBigIPUnavailable(responseStatusUnreachable)).To(BeTrue())
+			Expect(isBigIPUnavailable(responseStatusServerError)).To(BeTrue())
+			Expect(isBigIPUnavailable(responseStatusServiceUnavailable)).To(BeTrue())
+			Expect(isBigIPUnavailable(responseStatusNotFound)).To(BeTrue())
+			Expect(isBigIPUnavailable(responseStatusCommon)).To(BeFalse())
+		})
+	})
+})
@@ -21,10 +21,13 @@ import (
 	"strings"
 	"time"
 
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 )
 
+var log = vlogger.NewComponentLogger(vlogger.ComponentCCCL)
+
 // Dump out the Virtual Server configs to a file
 // This function MUST be called with the virtualServers
 // lock held.
@@ -142,12 +145,19 @@ func (cm *CCCLManager) OutputConfigLocked() {
 		}
 	}
 
+	start := time.Now()
 	doneCh, errCh, err := cm.ConfigWriter().SendSection("resources", resources)
 	if nil != err {
 		log.Warningf("[CCCL] Failed to write Big-IP config data: %v", err)
 	} else {
 		select {
 		case <-doneCh:
+			if output, err := json.Marshal(resources); nil == err {
+				bigIPPrometheus.ObservePost("cccl", start, len(output))
+			}
+			for partition := range resources {
+				bigIPPrometheus.SetSynced(partition)
+			}
 			virtualCount := 0
 			iappCount := 0
 			for _, partitionConfig := range resources {
@@ -156,7 +166,7 @@ func (cm *CCCLManager) OutputConfigLocked() {
 			}
 			log.Infof("[CCCL] Wrote %v Virtual Server and %v IApp configs",
 				virtualCount, iappCount)
-			if log.LL_DEBUG == log.GetLogLevel() {
+			if log.Enabled(vlogger.LL_DEBUG) {
 				// Copy everything from resources except CustomProfiles
 				// to be used for debug logging
 				resourceLog := copyResourceData(resources)
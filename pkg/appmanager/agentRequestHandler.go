@@ -3,11 +3,13 @@ package appmanager
 import (
 	cisAgent "github.com/F5Networks/k8s-bigip-ctlr/pkg/agent"
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
 )
 
 // Method to deploy resources on configured agent
 func (appMgr *Manager) deployResource() error {
+	span := tracing.Start(appMgr.traces.Take(deployTraceKey{}), tracing.SpanDeployResource)
+	defer span.End()
 	// Generate Agent Request
 
 	// Prepare Custom Profiles Copy
@@ -27,7 +29,8 @@ func (appMgr *Manager) deployResource() error {
 		RsCfgs: appMgr.resources.GetAllResources()}, Profs: Profs,
 		IrulesMap: appMgr.irulesMap, IntDgMap: appMgr.intDgMap, IntF5Res: appMgr.intF5Res,
 		AgentCfgmap: agentCfgMapLst}
-	agentReq := MessageRequest{MsgType: cisAgent.MsgTypeSendDecl, ResourceRequest: deployCfg}
+	agentReq := MessageRequest{MsgType: cisAgent.MsgTypeSendDecl, ResourceRequest: deployCfg,
+		Trace: span.SpanContext(), Triggers: appMgr.takeDeployTriggers()}
 	// Handle resources to agent and deploy to BIG-IP
 	appMgr.AgentCIS.Deploy(agentReq)
 	// Initialize cfgMap context if CfgMaps are removed
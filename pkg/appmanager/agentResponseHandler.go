@@ -17,11 +17,14 @@
 package appmanager
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 	routeapi "github.com/openshift/api/route/v1"
 	v1 "k8s.io/api/core/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 const (
@@ -145,5 +148,53 @@ func (appMgr *Manager) agentResponseWorker() {
 		if rspMsg.FdbRecords {
 			appMgr.deployFDB()
 		}
+
+		if len(rspMsg.DriftedPaths) > 0 {
+			appMgr.recordDriftEvents(rspMsg.DriftedPaths)
+		}
+
+		// Mark the resources of rejected tenants, and unmark them once
+		// BIG-IP accepts the declaration
+		if len(rspMsg.TenantErrors) > 0 {
+			appMgr.recordTenantErrors(rspMsg.TenantErrors)
+		} else if rspMsg.AdmitStatus {
+			appMgr.clearTenantErrors()
+		}
+	}
+}
+
+// recordDriftEvents adds a Warning event to the Ingresses, Routes and AS3
+// ConfigMaps that declare the objects modified outside of CIS
+func (appMgr *Manager) recordDriftEvents(paths []string) {
+	for _, owner := range appMgr.getAllTenantOwners() {
+		var drifted []string
+		for _, path := range paths {
+			for _, ownerPath := range owner.paths {
+				if overlapsAS3Path(path, ownerPath) {
+					drifted = append(drifted, path)
+					break
+				}
+			}
+		}
+		if len(drifted) == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("Configuration of BIG-IP object(s) %v was modified outside of "+
+			"the controller, restoring the declared configuration.", strings.Join(drifted, ","))
+		appMgr.recordWarningEvent(owner.obj, owner.namespace, "ConfigurationDrift", msg)
+	}
+}
+
+func (appMgr *Manager) recordWarningEvent(
+	obj runtime.Object,
+	namespace,
+	reason,
+	message string,
+) {
+	if appMgr.kubeClient == nil {
+		return
 	}
+	evNotifier := appMgr.eventNotifier.createNotifierForNamespace(
+		namespace, appMgr.kubeClient.CoreV1())
+	evNotifier.recordEvent(obj, v1.EventTypeWarning, reason, message)
 }
@@ -25,15 +25,20 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	cisAgent "github.com/F5Networks/k8s-bigip-ctlr/pkg/agent"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/debug"
 	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -50,12 +55,17 @@ import (
 	routeclient "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
 )
 
+var log = vlogger.NewComponentLogger(vlogger.ComponentAppManager)
+
 type ResourceMap map[int32][]*ResourceConfig
 
 // RoutesMap consists of List of route names indexed by namespace
 type RoutesMap map[string][]string
 
 type Manager struct {
+	// Number of virtual server syncs, identifies each sync in the logs.
+	// First field to keep it 64-bit aligned for atomic access.
+	syncCycles        uint64
 	resources         *Resources
 	customProfiles    *CustomProfileStore
 	irulesMap         IRulesMap
@@ -65,6 +75,11 @@ type Manager struct {
 	restClientv1      rest.Interface
 	restClientv1beta1 rest.Interface
 	routeClientV1     routeclient.RouteV1Interface
+	// Client of the resources newer than the Kubernetes client libraries,
+	// such as the networking.k8s.io/v1 Ingresses and the EndpointSlices,
+	// watched instead of the Endpoints when useEndpointSlices is set
+	dynamicClient     dynamic.Interface
+	useEndpointSlices bool
 	steadyState       bool
 	queueLen          int
 	processedItems    int
@@ -85,9 +100,15 @@ type Manager struct {
 	// Mutex for intDgMap
 	intDgMutex sync.Mutex
 	// App informer support
-	vsQueue      workqueue.RateLimitingInterface
-	appInformers map[string]*appInformer
-	as3Informer  *appInformer
+	vsQueue workqueue.RateLimitingInterface
+	// Span contexts of the queued keys, and of the first sync since the
+	// last deployment under deployTraceKey
+	traces *tracing.Pending
+	// Services synced since the last deployment, as namespace/name
+	deployTriggers map[string]bool
+	triggersMutex  sync.Mutex
+	appInformers   map[string]*appInformer
+	as3Informer    *appInformer
 	// Namespace informer support (namespace labels)
 	nsQueue    workqueue.RateLimitingInterface
 	nsInformer cache.SharedIndexInformer
@@ -103,6 +124,8 @@ type Manager struct {
 	defaultIngIP string
 	// Optional SNAT pool name to be referenced by virtual servers
 	vsSnatPoolName string
+	// Guards the settings changed at runtime, see UpdateSettings
+	settingsMutex sync.RWMutex
 	// Use Secrets for SSL Profiles
 	useSecrets bool
 	// Channel for emitting events
@@ -116,6 +139,40 @@ type Manager struct {
 	manageIngress          bool
 	manageIngressClassOnly bool
 	ingressClass           string
+	// Whether the Ingresses are networking.k8s.io/v1 ones, watched with the
+	// dynamic client, and the controller of the IngressClasses of CIS
+	useIngressV1           bool
+	ingressClassController string
+	// Informer of the cluster-scoped IngressClasses, and the ones of CIS by
+	// name
+	ingClassInformer    cache.SharedIndexInformer
+	ingressClasses      map[string]*managedIngressClass
+	ingressClassesMutex sync.RWMutex
+	// Whether to watch the Gateway API resources, and the controller name
+	// of the GatewayClasses of CIS
+	manageGateways        bool
+	gatewayControllerName string
+	// Gateway API resources served by the cluster
+	gatewayResources map[schema.GroupVersionResource]bool
+	// Informer of the cluster-scoped GatewayClasses
+	gwClassInformer cache.SharedIndexInformer
+	// Whether to configure the Services of type LoadBalancer, of
+	// loadBalancerClass only, or without class when empty
+	manageLoadBalancers bool
+	loadBalancerClass   string
+	// Range of the addresses allocated to LoadBalancer Services, and the
+	// allocated addresses by <namespace>/<name> of their Service
+	lbAddressRange   *AddressRange
+	lbAddresses      map[string]string
+	lbAddressesMutex sync.Mutex
+	// Keeps the pool members of cluster mode that are no longer ready for
+	// the drain period, nil when draining is disabled
+	memberDrainer *MemberDrainer
+	// Zone of the BIG-IP, whose members take precedence in zone aware pools
+	localZone string
+	// Whether to monitor the members of NodePort mode with the
+	// healthCheckNodePort of their Service
+	nodePortHealthMonitor bool
 	// Ingress SSL security Context
 	rsrcSSLCtxt     map[string]*v1.Secret
 	WatchedNS       WatchedNamespaces
@@ -142,6 +199,8 @@ type WatchedNamespaces struct {
 type Params struct {
 	KubeClient        kubernetes.Interface
 	RouteClientV1     routeclient.RouteV1Interface
+	DynamicClient     dynamic.Interface
+	UseEndpointSlices bool
 	UseNodeInternal   bool
 	IsNodePort        bool
 	RouteConfig       RouteConfig
@@ -160,9 +219,20 @@ type Params struct {
 	ManageIngress          bool
 	ManageIngressClassOnly bool
 	IngressClass           string
-	Agent                  string
-	SchemaLocalPath        string
-	TrustedCertsCfgmap     string
+	IngressClassController string
+	ManageGateways         bool
+	GatewayControllerName  string
+	ManageLoadBalancers    bool
+	LoadBalancerClass      string
+	LBAddressRange         *AddressRange
+	MemberDrainPeriod      time.Duration
+	LocalZone              string
+	// Monitor the members of NodePort mode with the healthCheckNodePort of
+	// their Service
+	NodePortHealthMonitor bool
+	Agent                 string
+	SchemaLocalPath       string
+	TrustedCertsCfgmap    string
 	// Data group path
 	DgPath             string
 	AgRspChan          chan interface{}
@@ -183,10 +253,12 @@ var RoutesProcessed []*routeapi.Route
 
 // Create and return a new app manager that meets the Manager interface
 func NewManager(params *Params) *Manager {
-	vsQueue := workqueue.NewNamedRateLimitingQueue(
-		workqueue.DefaultControllerRateLimiter(), "virtual-server-controller")
-	nsQueue := workqueue.NewNamedRateLimitingQueue(
-		workqueue.DefaultControllerRateLimiter(), "namespace-controller")
+	// Metrics must be registered before the work queues are created
+	bigIPPrometheus.RegisterMetrics()
+	vsQueue := debug.NewInspectableQueue(workqueue.NewNamedRateLimitingQueue(
+		workqueue.DefaultControllerRateLimiter(), "virtual-server-controller"))
+	nsQueue := debug.NewInspectableQueue(workqueue.NewNamedRateLimitingQueue(
+		workqueue.DefaultControllerRateLimiter(), "namespace-controller"))
 	manager := Manager{
 		resources:              NewResources(),
 		customProfiles:         NewCustomProfiles(),
@@ -196,6 +268,8 @@ func NewManager(params *Params) *Manager {
 		restClientv1:           params.restClient,
 		restClientv1beta1:      params.restClient,
 		routeClientV1:          params.RouteClientV1,
+		dynamicClient:          params.DynamicClient,
+		useEndpointSlices:      params.UseEndpointSlices,
 		useNodeInternal:        params.UseNodeInternal,
 		isNodePort:             params.IsNodePort,
 		steadyState:            params.steadyState,
@@ -210,6 +284,8 @@ func NewManager(params *Params) *Manager {
 		useSecrets:             params.UseSecrets,
 		eventChan:              params.EventChan,
 		vsQueue:                vsQueue,
+		traces:                 tracing.NewPending(),
+		deployTriggers:         make(map[string]bool),
 		nsQueue:                nsQueue,
 		appInformers:           make(map[string]*appInformer),
 		eventNotifier:          NewEventNotifier(params.broadcasterFunc),
@@ -218,6 +294,16 @@ func NewManager(params *Params) *Manager {
 		manageIngress:          params.ManageIngress,
 		manageIngressClassOnly: params.ManageIngressClassOnly,
 		ingressClass:           params.IngressClass,
+		ingressClassController: params.IngressClassController,
+		ingressClasses:         make(map[string]*managedIngressClass),
+		manageGateways:         params.ManageGateways,
+		gatewayControllerName:  params.GatewayControllerName,
+		manageLoadBalancers:    params.ManageLoadBalancers,
+		loadBalancerClass:      params.LoadBalancerClass,
+		lbAddressRange:         params.LBAddressRange,
+		lbAddresses:            make(map[string]string),
+		localZone:              params.LocalZone,
+		nodePortHealthMonitor:  params.NodePortHealthMonitor,
 		rsrcSSLCtxt:            make(map[string]*v1.Secret),
 		trustedCertsCfgmap:     params.TrustedCertsCfgmap,
 		intF5Res:               make(map[string]InternalF5Resources),
@@ -228,6 +314,10 @@ func NewManager(params *Params) *Manager {
 		agentCfgMap:            make(map[string]*AgentCfgMap),
 	}
 
+	if params.MemberDrainPeriod > 0 {
+		manager.memberDrainer = NewMemberDrainer(params.MemberDrainPeriod)
+	}
+
 	// Initialize agent response worker
 	go manager.agentResponseWorker()
 
@@ -239,6 +329,19 @@ func NewManager(params *Params) *Manager {
 		// This is the normal production case, but need the checks for unit tests.
 		manager.restClientv1beta1 = manager.kubeClient.ExtensionsV1beta1().RESTClient()
 	}
+	if manager.manageIngress && nil != manager.kubeClient && nil != manager.dynamicClient {
+		served := servedResources(manager.kubeClient, IngressV1Resource, IngressClassResource)
+		manager.useIngressV1 = served[IngressV1Resource]
+		if served[IngressClassResource] {
+			manager.newIngressClassInformer()
+		}
+	}
+	if manager.manageGateways && nil != manager.kubeClient {
+		manager.gatewayResources = servedGatewayAPIResources(manager.kubeClient)
+		if manager.gatewayResources[GatewayClassResource] {
+			manager.newGatewayClassInformer()
+		}
+	}
 	return &manager
 }
 
@@ -328,11 +431,11 @@ func (appMgr *Manager) AddNamespaceLabelInformer(
 		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
 	)
 	appMgr.nsInformer.AddEventHandlerWithResyncPeriod(
-		&cache.ResourceEventHandlerFuncs{
+		bigIPPrometheus.CountInformerEvents("Namespace", &cache.ResourceEventHandlerFuncs{
 			AddFunc:    func(obj interface{}) { appMgr.enqueueNamespace(obj) },
 			UpdateFunc: func(old, cur interface{}) { appMgr.enqueueNamespace(cur) },
 			DeleteFunc: func(obj interface{}) { appMgr.enqueueNamespace(obj) },
-		},
+		}),
 		resyncPeriod,
 	)
 
@@ -462,10 +565,20 @@ type appInformer struct {
 	cfgMapInformer cache.SharedIndexInformer
 	svcInformer    cache.SharedIndexInformer
 	endptInformer  cache.SharedIndexInformer
-	ingInformer    cache.SharedIndexInformer
-	routeInformer  cache.SharedIndexInformer
-	nodeInformer   cache.SharedIndexInformer
-	stopCh         chan struct{}
+	// Informer of the EndpointSlices, see resource.NewEndpointSliceInformer
+	endptSliceInformer cache.SharedIndexInformer
+	ingInformer        cache.SharedIndexInformer
+	routeInformer      cache.SharedIndexInformer
+	nodeInformer       cache.SharedIndexInformer
+	// Informers of the Gateway API resources, see resource.NewGatewayAPIInformer
+	gatewayInformer   cache.SharedIndexInformer
+	httpRouteInformer cache.SharedIndexInformer
+	tlsRouteInformer  cache.SharedIndexInformer
+	tcpRouteInformer  cache.SharedIndexInformer
+	// Informer of the Services, to read their loadBalancerClass, see
+	// resource.NewLoadBalancerClassInformer
+	lbClassInformer cache.SharedIndexInformer
+	stopCh          chan struct{}
 }
 
 func (appMgr *Manager) newAppInformer(
@@ -491,7 +604,17 @@ func (appMgr *Manager) newAppInformer(
 			resyncPeriod,
 			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
 		),
-		endptInformer: cache.NewSharedIndexInformer(
+	}
+
+	if appMgr.useEndpointSlices {
+		log.Infof("[CORE] Watching EndpointSlice resources.")
+		appInf.endptSliceInformer = NewEndpointSliceInformer(
+			appMgr.dynamicClient,
+			namespace,
+			resyncPeriod,
+		)
+	} else {
+		appInf.endptInformer = cache.NewSharedIndexInformer(
 			cache.NewFilteredListWatchFromClient(
 				appMgr.restClientv1,
 				"endpoints",
@@ -501,10 +624,40 @@ func (appMgr *Manager) newAppInformer(
 			&v1.Endpoints{},
 			resyncPeriod,
 			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
-		),
+		)
 	}
 
-	if true == appMgr.manageIngress {
+	if appMgr.manageGateways {
+		log.Infof("[CORE] Watching Gateway API resources.")
+		for _, inf := range []struct {
+			informer *cache.SharedIndexInformer
+			resource schema.GroupVersionResource
+		}{
+			{&appInf.gatewayInformer, GatewayResource},
+			{&appInf.httpRouteInformer, HTTPRouteResource},
+			{&appInf.tlsRouteInformer, TLSRouteResource},
+			{&appInf.tcpRouteInformer, TCPRouteResource},
+		} {
+			if appMgr.gatewayResources[inf.resource] {
+				*inf.informer = NewGatewayAPIInformer(
+					appMgr.dynamicClient, inf.resource, namespace, resyncPeriod)
+			}
+		}
+	}
+
+	if appMgr.manageLoadBalancers && nil != appMgr.dynamicClient {
+		appInf.lbClassInformer = NewLoadBalancerClassInformer(
+			appMgr.dynamicClient, namespace, resyncPeriod)
+	}
+
+	if true == appMgr.manageIngress && appMgr.useIngressV1 {
+		log.Infof("[CORE] Watching networking.k8s.io/v1 Ingress resources.")
+		appInf.ingInformer = NewIngressV1Informer(
+			appMgr.dynamicClient,
+			namespace,
+			resyncPeriod,
+		)
+	} else if true == appMgr.manageIngress {
 		log.Infof("[CORE] Watching Ingress resources.")
 		appInf.ingInformer = cache.NewSharedIndexInformer(
 			cache.NewFilteredListWatchFromClient(
@@ -564,11 +717,11 @@ func (appMgr *Manager) newAppInformer(
 	if false != appMgr.manageConfigMaps {
 		log.Infof("[CORE] Handling ConfigMap resource events.")
 		appInf.cfgMapInformer.AddEventHandlerWithResyncPeriod(
-			&cache.ResourceEventHandlerFuncs{
+			bigIPPrometheus.CountInformerEvents("ConfigMap", &cache.ResourceEventHandlerFuncs{
 				AddFunc:    func(obj interface{}) { appMgr.enqueueCreatedConfigMap(obj) },
 				UpdateFunc: func(old, cur interface{}) { appMgr.enqueueUpdatedConfigMap(cur) },
 				DeleteFunc: func(obj interface{}) { appMgr.enqueueDeletedConfigMap(obj) },
-			},
+			}),
 			resyncPeriod,
 		)
 	} else {
@@ -576,31 +729,74 @@ func (appMgr *Manager) newAppInformer(
 	}
 
 	appInf.svcInformer.AddEventHandlerWithResyncPeriod(
-		&cache.ResourceEventHandlerFuncs{
+		bigIPPrometheus.CountInformerEvents("Service", &cache.ResourceEventHandlerFuncs{
 			AddFunc:    func(obj interface{}) { appMgr.enqueueService(obj) },
 			UpdateFunc: func(old, cur interface{}) { appMgr.enqueueService(cur) },
 			DeleteFunc: func(obj interface{}) { appMgr.enqueueService(obj) },
-		},
+		}),
 		resyncPeriod,
 	)
 
-	appInf.endptInformer.AddEventHandlerWithResyncPeriod(
-		&cache.ResourceEventHandlerFuncs{
-			AddFunc:    func(obj interface{}) { appMgr.enqueueEndpoints(obj) },
-			UpdateFunc: func(old, cur interface{}) { appMgr.enqueueEndpoints(cur) },
-			DeleteFunc: func(obj interface{}) { appMgr.enqueueEndpoints(obj) },
-		},
-		resyncPeriod,
-	)
+	if nil != appInf.endptSliceInformer {
+		appInf.endptSliceInformer.AddEventHandlerWithResyncPeriod(
+			bigIPPrometheus.CountInformerEvents("EndpointSlice", &cache.ResourceEventHandlerFuncs{
+				AddFunc:    func(obj interface{}) { appMgr.enqueueEndpointSlice(obj) },
+				UpdateFunc: func(old, cur interface{}) { appMgr.enqueueEndpointSlice(cur) },
+				DeleteFunc: func(obj interface{}) { appMgr.enqueueEndpointSlice(obj) },
+			}),
+			resyncPeriod,
+		)
+	} else {
+		appInf.endptInformer.AddEventHandlerWithResyncPeriod(
+			bigIPPrometheus.CountInformerEvents("Endpoints", &cache.ResourceEventHandlerFuncs{
+				AddFunc:    func(obj interface{}) { appMgr.enqueueEndpoints(obj) },
+				UpdateFunc: func(old, cur interface{}) { appMgr.enqueueEndpoints(cur) },
+				DeleteFunc: func(obj interface{}) { appMgr.enqueueEndpoints(obj) },
+			}),
+			resyncPeriod,
+		)
+	}
+
+	if nil != appInf.gatewayInformer {
+		appInf.gatewayInformer.AddEventHandlerWithResyncPeriod(
+			bigIPPrometheus.CountInformerEvents(GatewayKind, &cache.ResourceEventHandlerFuncs{
+				AddFunc:    func(obj interface{}) { appMgr.enqueueGatewayAPIObject(GatewayKind, obj) },
+				UpdateFunc: func(old, cur interface{}) { appMgr.enqueueGatewayAPIObject(GatewayKind, cur) },
+				DeleteFunc: func(obj interface{}) { appMgr.enqueueGatewayAPIObject(GatewayKind, obj) },
+			}),
+			resyncPeriod,
+		)
+	}
+	if nil != appInf.lbClassInformer {
+		appInf.lbClassInformer.AddEventHandlerWithResyncPeriod(
+			bigIPPrometheus.CountInformerEvents("LoadBalancerClass", &cache.ResourceEventHandlerFuncs{
+				AddFunc:    func(obj interface{}) { appMgr.enqueueLoadBalancerClass(obj) },
+				UpdateFunc: func(old, cur interface{}) { appMgr.enqueueLoadBalancerClass(cur) },
+				DeleteFunc: func(obj interface{}) { appMgr.enqueueLoadBalancerClass(obj) },
+			}),
+			resyncPeriod,
+		)
+	}
+	for _, ri := range appInf.gatewayRouteInformers() {
+		kind := ri.kind
+		ri.informer.AddEventHandlerWithResyncPeriod(
+			bigIPPrometheus.CountInformerEvents(kind, &cache.ResourceEventHandlerFuncs{
+				AddFunc:    func(obj interface{}) { appMgr.enqueueGatewayAPIObject(kind, obj) },
+				UpdateFunc: func(old, cur interface{}) { appMgr.enqueueGatewayAPIObject(kind, cur) },
+				DeleteFunc: func(obj interface{}) { appMgr.enqueueGatewayAPIObject(kind, obj) },
+			}),
+			resyncPeriod,
+		)
+	}
 
 	if true == appMgr.manageIngress {
 		log.Infof("[CORE] Handling Ingress resource events.")
 		appInf.ingInformer.AddEventHandlerWithResyncPeriod(
-			&cache.ResourceEventHandlerFuncs{
+			bigIPPrometheus.CountInformerEvents("Ingress", &cache.ResourceEventHandlerFuncs{
 				AddFunc:    func(obj interface{}) { appMgr.enqueueIngress(obj) },
 				UpdateFunc: func(old, cur interface{}) { appMgr.enqueueIngress(cur) },
 				DeleteFunc: func(obj interface{}) { appMgr.enqueueIngress(obj) },
-			},
+			}),
 			resyncPeriod,
 		)
 	} else {
@@ -609,11 +805,11 @@ func (appMgr *Manager) newAppInformer(
 
 	if nil != appMgr.routeClientV1 {
 		appInf.routeInformer.AddEventHandlerWithResyncPeriod(
-			&cache.ResourceEventHandlerFuncs{
+			bigIPPrometheus.CountInformerEvents("Route", &cache.ResourceEventHandlerFuncs{
 				AddFunc:    func(obj interface{}) { appMgr.enqueueRoute(obj) },
 				UpdateFunc: func(old, cur interface{}) { appMgr.enqueueRoute(cur) },
 				DeleteFunc: func(obj interface{}) { appMgr.enqueueRoute(obj) },
-			},
+			}),
 			resyncPeriod,
 		)
 	}
@@ -621,59 +817,98 @@ func (appMgr *Manager) newAppInformer(
 	return &appInf
 }
 
+// deployTraceKey keeps the span of the first sync since the last deployment
+type deployTraceKey struct{}
+
+// addDeployTrigger records the service of sKey as a trigger of the next
+// deployment
+func (appMgr *Manager) addDeployTrigger(sKey serviceQueueKey) {
+	appMgr.triggersMutex.Lock()
+	defer appMgr.triggersMutex.Unlock()
+	appMgr.deployTriggers[sKey.Namespace+"/"+sKey.ServiceName] = true
+}
+
+// takeDeployTriggers returns and forgets the triggers of the deployment
+func (appMgr *Manager) takeDeployTriggers() []string {
+	appMgr.triggersMutex.Lock()
+	defer appMgr.triggersMutex.Unlock()
+	var triggers []string
+	for trigger := range appMgr.deployTriggers {
+		triggers = append(triggers, trigger)
+	}
+	sort.Strings(triggers)
+	appMgr.deployTriggers = make(map[string]bool)
+	return triggers
+}
+
+// enqueueKeys queues the keys produced by an informer event on a resource
+// of kind, with the spans tracing the event and each enqueue
+func (appMgr *Manager) enqueueKeys(kind string, keys []*serviceQueueKey) {
+	span := tracing.Start(tracing.SpanContext{}, tracing.SpanInformerEvent,
+		tracing.String("kind", kind))
+	defer span.End()
+	for _, key := range keys {
+		enqueueSpan := tracing.Start(span.SpanContext(), tracing.SpanEnqueue,
+			tracing.String("namespace", key.Namespace),
+			tracing.String("service", key.ServiceName),
+		)
+		appMgr.traces.Add(*key, enqueueSpan.SpanContext())
+		appMgr.vsQueue.Add(*key)
+		enqueueSpan.End()
+	}
+}
+
 func (appMgr *Manager) enqueueCreatedConfigMap(obj interface{}) {
 	if ok, keys := appMgr.checkValidConfigMap(obj, OprTypeCreate); ok {
-		for _, key := range keys {
-			appMgr.vsQueue.Add(*key)
-		}
+		appMgr.enqueueKeys("ConfigMap", keys)
 	}
 }
 
 func (appMgr *Manager) enqueueUpdatedConfigMap(obj interface{}) {
 	if ok, keys := appMgr.checkValidConfigMap(obj, OprTypeModify); ok {
-		for _, key := range keys {
-			appMgr.vsQueue.Add(*key)
-		}
+		appMgr.enqueueKeys("ConfigMap", keys)
 	}
 }
 
 func (appMgr *Manager) enqueueDeletedConfigMap(obj interface{}) {
 	if ok, keys := appMgr.checkValidConfigMap(obj, OprTypeDelete); ok {
-		for _, key := range keys {
-			appMgr.vsQueue.Add(*key)
-		}
+		appMgr.enqueueKeys("ConfigMap", keys)
 	}
 }
 
 func (appMgr *Manager) enqueueService(obj interface{}) {
 	if ok, keys := appMgr.checkValidService(obj); ok {
-		for _, key := range keys {
-			appMgr.vsQueue.Add(*key)
-		}
+		appMgr.enqueueKeys("Service", keys)
 	}
 }
 
 func (appMgr *Manager) enqueueEndpoints(obj interface{}) {
 	if ok, keys := appMgr.checkValidEndpoints(obj); ok {
-		for _, key := range keys {
-			appMgr.vsQueue.Add(*key)
-		}
+		appMgr.enqueueKeys("Endpoints", keys)
+	}
+}
+
+func (appMgr *Manager) enqueueEndpointSlice(obj interface{}) {
+	if ok, keys := appMgr.checkValidEndpointSlice(obj); ok {
+		appMgr.enqueueKeys("EndpointSlice", keys)
+	}
+}
+
+func (appMgr *Manager) enqueueLoadBalancerClass(obj interface{}) {
+	if ok, keys := appMgr.checkValidLoadBalancerClass(obj); ok {
+		appMgr.enqueueKeys("Service", keys)
 	}
 }
 
 func (appMgr *Manager) enqueueIngress(obj interface{}) {
 	if ok, keys := appMgr.checkValidIngress(obj); ok {
-		for _, key := range keys {
-			appMgr.vsQueue.Add(*key)
-		}
+		appMgr.enqueueKeys("Ingress", keys)
 	}
 }
 
 func (appMgr *Manager) enqueueRoute(obj interface{}) {
 	if ok, keys := appMgr.checkValidRoute(obj); ok {
-		for _, key := range keys {
-			appMgr.vsQueue.Add(*key)
-		}
+		appMgr.enqueueKeys("Route", keys)
 	}
 }
 
@@ -704,6 +939,9 @@ func (appInf *appInformer) start() {
 	if nil != appInf.endptInformer {
 		go appInf.endptInformer.Run(appInf.stopCh)
 	}
+	if nil != appInf.endptSliceInformer {
+		go appInf.endptSliceInformer.Run(appInf.stopCh)
+	}
 	if nil != appInf.ingInformer {
 		go appInf.ingInformer.Run(appInf.stopCh)
 	}
@@ -716,9 +954,26 @@ func (appInf *appInformer) start() {
 	if nil != appInf.nodeInformer {
 		go appInf.nodeInformer.Run(appInf.stopCh)
 	}
+	if nil != appInf.gatewayInformer {
+		go appInf.gatewayInformer.Run(appInf.stopCh)
+	}
+	for _, ri := range appInf.gatewayRouteInformers() {
+		go ri.informer.Run(appInf.stopCh)
+	}
+	if nil != appInf.lbClassInformer {
+		go appInf.lbClassInformer.Run(appInf.stopCh)
+	}
 }
 
 func (appInf *appInformer) waitForCacheSync() {
+	cache.WaitForCacheSync(
+		appInf.stopCh,
+		appInf.cacheSyncs()...,
+	)
+}
+
+// cacheSyncs returns the HasSynced functions of the running informers
+func (appInf *appInformer) cacheSyncs() []cache.InformerSynced {
 	cacheSyncs := []cache.InformerSynced{}
 
 	if nil != appInf.svcInformer {
@@ -727,6 +982,9 @@ func (appInf *appInformer) waitForCacheSync() {
 	if nil != appInf.endptInformer {
 		cacheSyncs = append(cacheSyncs, appInf.endptInformer.HasSynced)
 	}
+	if nil != appInf.endptSliceInformer {
+		cacheSyncs = append(cacheSyncs, appInf.endptSliceInformer.HasSynced)
+	}
 	if nil != appInf.ingInformer {
 		cacheSyncs = append(cacheSyncs, appInf.ingInformer.HasSynced)
 	}
@@ -739,10 +997,16 @@ func (appInf *appInformer) waitForCacheSync() {
 	if nil != appInf.nodeInformer {
 		cacheSyncs = append(cacheSyncs, appInf.nodeInformer.HasSynced)
 	}
-	cache.WaitForCacheSync(
-		appInf.stopCh,
-		cacheSyncs...,
-	)
+	if nil != appInf.gatewayInformer {
+		cacheSyncs = append(cacheSyncs, appInf.gatewayInformer.HasSynced)
+	}
+	for _, ri := range appInf.gatewayRouteInformers() {
+		cacheSyncs = append(cacheSyncs, ri.informer.HasSynced)
+	}
+	if nil != appInf.lbClassInformer {
+		cacheSyncs = append(cacheSyncs, appInf.lbClassInformer.HasSynced)
+	}
+	return cacheSyncs
 }
 
 func (appInf *appInformer) stopInformers() {
@@ -772,6 +1036,15 @@ func (appMgr *Manager) runImpl(stopCh <-chan struct{}) {
 		go wait.Until(appMgr.namespaceWorker, time.Second, stopCh)
 	}
 
+	if nil != appMgr.gwClassInformer {
+		go appMgr.gwClassInformer.Run(stopCh)
+		cache.WaitForCacheSync(stopCh, appMgr.gwClassInformer.HasSynced)
+	}
+	if nil != appMgr.ingClassInformer {
+		go appMgr.ingClassInformer.Run(stopCh)
+		cache.WaitForCacheSync(stopCh, appMgr.ingClassInformer.HasSynced)
+	}
+
 	appMgr.startAndSyncAppInformers()
 
 	// Using only one virtual server worker currently.
@@ -810,6 +1083,36 @@ func (appMgr *Manager) waitForCacheSync() {
 	appMgr.waitForCacheSyncLocked()
 }
 
+// InformersSynced reports whether the caches of all informers hold the
+// initial state of the watched resources
+func (appMgr *Manager) InformersSynced() bool {
+	appMgr.informersMutex.Lock()
+	defer appMgr.informersMutex.Unlock()
+
+	cacheSyncs := []cache.InformerSynced{}
+	if nil != appMgr.nsInformer {
+		cacheSyncs = append(cacheSyncs, appMgr.nsInformer.HasSynced)
+	}
+	if nil != appMgr.gwClassInformer {
+		cacheSyncs = append(cacheSyncs, appMgr.gwClassInformer.HasSynced)
+	}
+	if nil != appMgr.ingClassInformer {
+		cacheSyncs = append(cacheSyncs, appMgr.ingClassInformer.HasSynced)
+	}
+	for _, appInf := range appMgr.appInformers {
+		cacheSyncs = append(cacheSyncs, appInf.cacheSyncs()...)
+	}
+	if nil != appMgr.as3Informer {
+		cacheSyncs = append(cacheSyncs, appMgr.as3Informer.cacheSyncs()...)
+	}
+	for _, synced := range cacheSyncs {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
 func (appMgr *Manager) waitForCacheSyncLocked() {
 	for _, appInf := range appMgr.appInformers {
 		appInf.waitForCacheSync()
@@ -885,7 +1188,16 @@ func (appMgr *Manager) processNextVirtualServer() bool {
 
 	defer appMgr.vsQueue.Done(key)
 
-	err := appMgr.syncVirtualServer(key.(serviceQueueKey))
+	sKey := key.(serviceQueueKey)
+	span := tracing.Start(appMgr.traces.Take(key), tracing.SpanSyncVirtual,
+		tracing.String("namespace", sKey.Namespace),
+		tracing.String("service", sKey.ServiceName),
+	)
+	appMgr.traces.Add(deployTraceKey{}, span.SpanContext())
+	appMgr.addDeployTrigger(sKey)
+	err := appMgr.syncVirtualServer(sKey)
+	span.RecordError(err)
+	span.End()
 	if err == nil {
 		if !appMgr.steadyState {
 			appMgr.processedItems++
@@ -924,9 +1236,15 @@ type vsSyncStats struct {
 
 func (appMgr *Manager) syncVirtualServer(sKey serviceQueueKey) error {
 	startTime := time.Now()
+	syncLog := log.WithFields(vlogger.Fields{
+		Component: "core",
+		Namespace: sKey.Namespace,
+		Name:      sKey.ServiceName,
+		SyncID:    strconv.FormatUint(atomic.AddUint64(&appMgr.syncCycles, 1), 10),
+	})
 	defer func() {
 		endTime := time.Now()
-		log.Debugf("[CORE] Finished syncing virtual servers %+v %+v (%v)",
+		syncLog.Debugf("Finished syncing virtual servers %+v %+v (%v)",
 			sKey.Name, sKey.Namespace, endTime.Sub(startTime))
 	}()
 	// Get the informers for the namespace. This will tell us if we care about
@@ -935,7 +1253,7 @@ func (appMgr *Manager) syncVirtualServer(sKey serviceQueueKey) error {
 	if !haveNamespace {
 		// This shouldn't happen as the namespace is checked for every item before
 		// it is added to the queue, but issue a warning if it does.
-		log.Warningf(
+		syncLog.Warningf(
 			"Received an update for an item from an un-watched namespace %v",
 			sKey.Namespace)
 		return nil
@@ -946,7 +1264,7 @@ func (appMgr *Manager) syncVirtualServer(sKey serviceQueueKey) error {
 	obj, svcFound, err := appInf.svcInformer.GetIndexer().GetByKey(svcKey)
 	if nil != err {
 		// Returning non-nil err will re-queue this item with rate-limiting.
-		log.Warningf("[CORE] Error looking up service '%v': %v\n", svcKey, err)
+		syncLog.Warningf("Error looking up service '%v': %v", svcKey, err)
 		return err
 	}
 
@@ -959,6 +1277,9 @@ func (appMgr *Manager) syncVirtualServer(sKey serviceQueueKey) error {
 		for _, portSpec := range svc.Spec.Ports {
 			svcPortMap[portSpec.Port] = false
 		}
+	} else if nil != appMgr.memberDrainer {
+		// The pools of a deleted service are deleted with their members
+		appMgr.memberDrainer.Forget(sKey.Namespace, sKey.ServiceName)
 	}
 
 	// rsMap stores all resources currently in Resources matching sKey, indexed by port.
@@ -987,6 +1308,15 @@ func (appMgr *Manager) syncVirtualServer(sKey serviceQueueKey) error {
 			return err
 		}
 	}
+	if nil != appInf.gatewayInformer {
+		err = appMgr.syncGateways(&stats, sKey, rsMap, appInf, dgMap)
+		if nil != err {
+			return err
+		}
+	}
+	if appMgr.manageLoadBalancers {
+		appMgr.syncLoadBalancerService(&stats, sKey, rsMap, svc, appInf)
+	}
 	// Update internal data groups if changed
 	appMgr.syncDataGroups(&stats, dgMap, sKey.Namespace)
 	// Delete IRules if necessary
@@ -1070,7 +1400,7 @@ func (appMgr *Manager) syncConfigMaps(
 			continue
 		}
 
-		rsCfg, err := ParseConfigMap(cm, appMgr.schemaLocal, appMgr.vsSnatPoolName)
+		rsCfg, err := ParseConfigMap(cm, appMgr.schemaLocal, appMgr.getVsSnatPoolName())
 		if nil != err {
 			bigIPPrometheus.MonitoredServices.WithLabelValues(cm.ObjectMeta.Namespace, cm.ObjectMeta.Name, "parse-error").Set(1)
 			// Ignore this config map for the time being. When the user updates it
@@ -1134,7 +1464,7 @@ func (appMgr *Manager) syncConfigMaps(
 	return nil
 }
 
-func prepareIngressSSLContext(appMgr *Manager, ing *v1beta1.Ingress) {
+func prepareIngressSSLContext(appMgr *Manager, ing *Ingress) {
 	// Prepare Ingress SSL Transient Context
 	for _, tls := range ing.Spec.TLS {
 		// Check if TLS Secret already exists
@@ -1172,7 +1502,11 @@ func (appMgr *Manager) syncIngresses(
 	for _, obj := range ingByIndex {
 		// We need to look at all ingresses in the store, parse the data blob,
 		// and see if it belongs to the service that has changed.
-		ing := obj.(*v1beta1.Ingress)
+		ing, err := ToIngress(obj)
+		if nil != err {
+			log.Warningf("[CORE] Unable to parse Ingress: %v", err)
+			continue
+		}
 		// TODO: Each ingress resource must be processed for its associated service
 		//  only, existing implementation processes all services available in k8s
 		//  and this approach degrades the performance of processing Ingress resources
@@ -1187,11 +1521,11 @@ func (appMgr *Manager) syncIngresses(
 		// Resolve first Ingress Host name (if required)
 		_, exists := ing.ObjectMeta.Annotations[F5VsBindAddrAnnotation]
 		if !exists && appMgr.resolveIng != "" {
-			appMgr.resolveIngressHost(ing, sKey.Namespace)
+			appMgr.resolveIngressHost(ing)
 		}
 
 		// Get a list of dependencies removed so their pools can be removed.
-		objKey, objDeps := NewObjectDependencies(ing)
+		objKey, objDeps := NewObjectDependencies(ing.Ingress)
 		svcDepKey := ObjectDependency{
 			Kind:      ServiceDep,
 			Namespace: sKey.Namespace,
@@ -1216,8 +1550,8 @@ func (appMgr *Manager) syncIngresses(
 				sKey.Namespace,
 				appInf.svcInformer.GetIndexer(),
 				portStruct,
-				appMgr.defaultIngIP,
-				appMgr.vsSnatPoolName,
+				appMgr.ingressDefaultIP(ing),
+				appMgr.getVsSnatPoolName(),
 			)
 			if rsCfg == nil {
 				// Currently, an error is returned only if the Ingress is one we
@@ -1421,8 +1755,8 @@ func (appMgr *Manager) syncRoutes(
 			{protocol: "https", port: DEFAULT_HTTPS_PORT}}
 		for _, ps := range pStructs {
 			rsCfg, err, pool := appMgr.createRSConfigFromRoute(
-				route, svcName, appMgr.resources, appMgr.routeConfig, ps,
-				appInf.svcInformer.GetIndexer(), svcFwdRulesMap, appMgr.vsSnatPoolName)
+				route, svcName, appMgr.resources, appMgr.getRouteConfig(), ps,
+				appInf.svcInformer.GetIndexer(), svcFwdRulesMap, appMgr.getVsSnatPoolName())
 			if err != nil {
 				log.Warningf("[CORE] %v", err)
 				continue
@@ -1639,7 +1973,7 @@ type portStruct struct {
 }
 
 // Return the required ports for Ingress VS (depending on sslRedirect/allowHttp vals)
-func (appMgr *Manager) virtualPorts(ing *v1beta1.Ingress) []portStruct {
+func (appMgr *Manager) virtualPorts(ing *Ingress) []portStruct {
 	var httpPort int32
 	var httpsPort int32
 	if port, ok := ing.ObjectMeta.Annotations[F5VsHttpPortAnnotation]; ok == true {
@@ -1722,7 +2056,7 @@ func (appMgr *Manager) handleConfigForType(
 	svc *v1.Service,
 	appInf *appInformer,
 	currResourceSvcs []string, // Used for Ingress/Routes
-	ing *v1beta1.Ingress, // Used for writing events
+	ing *Ingress, // Used for writing events
 ) (bool, int, int) {
 	vsFound := 0
 	vsUpdated := 0
@@ -1873,8 +2207,15 @@ func (appMgr *Manager) updatePoolMembersForNodePort(
 				log.Debugf("[CORE] Service backend matched %+v: using node port %v",
 					svcKey, portSpec.NodePort)
 				rsCfg.MetaData.Active = true
-				rsCfg.Pools[index].Members =
-					appMgr.getEndpointsForNodePort(portSpec.NodePort)
+				if HasLocalTrafficPolicy(svc) {
+					rsCfg.Pools[index].Members = appMgr.getLocalEndpointsForNodePort(
+						svc, portSpec.NodePort)
+				} else {
+					rsCfg.Pools[index].Members =
+						appMgr.getEndpointsForNodePort(portSpec.NodePort)
+				}
+				appMgr.setZonePriorityGroups(&rsCfg.Pools[index], nil)
+				appMgr.setHealthCheckNodePortMonitor(svc, rsCfg, index)
 			}
 		}
 		return true, "", ""
@@ -1893,6 +2234,9 @@ func (appMgr *Manager) updatePoolMembersForCluster(
 	appInf *appInformer,
 	index int,
 ) (bool, string, string) {
+	if nil != appInf.endptSliceInformer {
+		return appMgr.updatePoolMembersForEndpointSlices(svc, sKey, rsCfg, appInf, index)
+	}
 	svcKey := sKey.Namespace + "/" + sKey.ServiceName
 	item, found, _ := appInf.endptInformer.GetStore().GetByKey(svcKey)
 	if !found {
@@ -1903,15 +2247,91 @@ func (appMgr *Manager) updatePoolMembersForCluster(
 	eps, _ := item.(*v1.Endpoints)
 	for _, portSpec := range svc.Spec.Ports {
 		if portSpec.Port == sKey.ServicePort {
-			ipPorts := appMgr.getEndpointsForCluster(portSpec.Name, eps)
+			ipPorts := appMgr.drainPoolMembers(sKey,
+				appMgr.getEndpointsForCluster(portSpec.Name, eps),
+				appMgr.getNotReadyEndpointsForCluster(portSpec.Name, eps))
+			log.Debugf("[CORE] Found endpoints for backend %+v: %v", sKey, ipPorts)
+			rsCfg.MetaData.Active = true
+			rsCfg.Pools[index].Members = ipPorts
+			appMgr.setZonePriorityGroups(&rsCfg.Pools[index], EndpointsNodes(eps))
+		}
+	}
+	return true, "", ""
+}
+
+func (appMgr *Manager) updatePoolMembersForEndpointSlices(
+	svc *v1.Service,
+	sKey ServiceKey,
+	rsCfg *ResourceConfig,
+	appInf *appInformer,
+	index int,
+) (bool, string, string) {
+	svcKey := sKey.Namespace + "/" + sKey.ServiceName
+	slices, err := GetServiceEndpointSlices(
+		appInf.endptSliceInformer.GetIndexer(), sKey.Namespace, sKey.ServiceName)
+	if err != nil || len(slices) == 0 {
+		msg := fmt.Sprintf("EndpointSlices for service '%v' not found!", svcKey)
+		log.Debug(msg)
+		return false, "EndpointsNotFound", msg
+	}
+	nodes := appMgr.getNodesFromCache()
+	onNode := func(nodeName string) bool {
+		return containsNode(nodes, nodeName)
+	}
+	for _, portSpec := range svc.Spec.Ports {
+		if portSpec.Port == sKey.ServicePort {
+			ipPorts := appMgr.drainPoolMembers(sKey,
+				EndpointSliceMembers(slices, portSpec.Name, onNode),
+				EndpointSliceDrainingMembers(slices, portSpec.Name, onNode))
 			log.Debugf("[CORE] Found endpoints for backend %+v: %v", sKey, ipPorts)
 			rsCfg.MetaData.Active = true
 			rsCfg.Pools[index].Members = ipPorts
+			appMgr.setZonePriorityGroups(&rsCfg.Pools[index], EndpointSliceNodes(slices))
 		}
 	}
 	return true, "", ""
 }
 
+// setZonePriorityGroups gives the members of a zone aware pool in the local
+// zone of the BIG-IP precedence over the others. memberNodes maps the
+// addresses of pod members to their node, the members of NodePort mode are
+// nodes.
+func (appMgr *Manager) setZonePriorityGroups(pool *Pool, memberNodes map[string]string) {
+	if 0 == pool.MinimumMembersActive || "" == appMgr.localZone {
+		return
+	}
+	zonesByName := make(map[string]string)
+	zonesByAddr := make(map[string]string)
+	for _, node := range appMgr.getNodesFromCache() {
+		zonesByName[node.Name] = node.Zone
+		zonesByAddr[node.Addr] = node.Zone
+	}
+	for i, member := range pool.Members {
+		zone := zonesByAddr[member.Address]
+		if nil != memberNodes {
+			zone = zonesByName[memberNodes[member.Address]]
+		}
+		pool.Members[i].PriorityGroup = ZonePriorityGroup(zone, appMgr.localZone)
+	}
+}
+
+// drainPoolMembers adds the members of the not ready or terminating
+// endpoints of the service port of sKey to its ready members, disabled, and
+// syncs the service again once the first of them expires
+func (appMgr *Manager) drainPoolMembers(sKey ServiceKey, members, notReady []Member) []Member {
+	if nil == appMgr.memberDrainer {
+		return members
+	}
+	members, expiry := appMgr.memberDrainer.Drain(sKey, members, notReady)
+	if expiry > 0 {
+		appMgr.vsQueue.AddAfter(serviceQueueKey{
+			Namespace:   sKey.Namespace,
+			ServiceName: sKey.ServiceName,
+		}, expiry)
+	}
+	return members
+}
+
 func (appMgr *Manager) deactivateVirtualServer(
 	sKey ServiceKey,
 	rsName string,
@@ -2041,8 +2461,10 @@ func (appMgr *Manager) deleteUnusedResources(
 	namespace := sKey.Namespace
 	svcName := sKey.ServiceName
 	for _, cfg := range appMgr.resources.GetAllResources() {
+		// The pools of Gateways are reconciled by syncGateways
 		if cfg.MetaData.ResourceType == "configmap" ||
-			cfg.MetaData.ResourceType == "iapp" {
+			cfg.MetaData.ResourceType == "iapp" ||
+			cfg.MetaData.ResourceType == ResourceTypeGateway {
 			continue
 		}
 		for _, pool := range cfg.Pools {
@@ -2095,7 +2517,7 @@ func (appMgr *Manager) setBindAddrAnnotation(
 }
 
 func (appMgr *Manager) setIngressStatus(
-	ing *v1beta1.Ingress,
+	ing *Ingress,
 	rsCfg *ResourceConfig,
 ) {
 	// Set the ingress status to include the virtual IP
@@ -2106,8 +2528,7 @@ func (appMgr *Manager) setIngressStatus(
 	} else if ing.Status.LoadBalancer.Ingress[0].IP != ip {
 		ing.Status.LoadBalancer.Ingress[0] = lbIngress
 	}
-	_, updateErr := appMgr.kubeClient.ExtensionsV1beta1().
-		Ingresses(ing.ObjectMeta.Namespace).UpdateStatus(ing)
+	updateErr := appMgr.updateIngressStatus(ing)
 	if nil != updateErr {
 		// Multi-service causes the controller to try to update the status multiple times
 		// at once. Ignore this error.
@@ -2123,7 +2544,7 @@ func (appMgr *Manager) setIngressStatus(
 }
 
 // Resolve the first host name in an Ingress and use the IP address as the VS address
-func (appMgr *Manager) resolveIngressHost(ing *v1beta1.Ingress, namespace string) {
+func (appMgr *Manager) resolveIngressHost(ing *Ingress) {
 	var host, ipAddress string
 	var err error
 	var netIPs []net.IP
@@ -2163,40 +2584,23 @@ func (appMgr *Manager) resolveIngressHost(ing *v1beta1.Ingress, namespace string
 		}
 	} else {
 		// Use custom DNS server
-		port := "53"
-		customDNS := appMgr.resolveIng
-		// Grab the port if it exists
-		slice := strings.Split(customDNS, ":")
-		if _, err = strconv.Atoi(slice[len(slice)-1]); err == nil {
-			port = slice[len(slice)-1]
-		}
-		isIP := net.ParseIP(customDNS)
-		if isIP == nil {
-			// customDNS is not an IPAddress, it is a hostname that we need to resolve first
-			netIPs, err = net.LookupIP(customDNS)
-			if nil != err {
-				logDNSError(fmt.Sprintf("Error while resolving host '%s': %s",
-					appMgr.resolveIng, err))
-				return
-			}
-			customDNS = netIPs[0].String()
+		var server string
+		server, err = customDNSServer(appMgr.resolveIng)
+		if nil != err {
+			logDNSError(fmt.Sprintf("Error while resolving host '%s': %s",
+				appMgr.resolveIng, err))
+			return
 		}
-		client := dns.Client{}
-		msg := dns.Msg{}
-		msg.SetQuestion(host+".", dns.TypeA)
-		var res *dns.Msg
-		res, _, err = client.Exchange(&msg, customDNS+":"+port)
+		ipAddress, err = resolveWithDNSServer(host, server)
 		if nil != err {
 			logDNSError(fmt.Sprintf("Error while resolving host '%s' "+
 				"using DNS server '%s': %s", host, appMgr.resolveIng, err))
 			return
-		} else if len(res.Answer) == 0 {
+		} else if ipAddress == "" {
 			logDNSError(fmt.Sprintf("No results for host '%s' "+
 				"using DNS server '%s'", host, appMgr.resolveIng))
 			return
 		}
-		Arecord := res.Answer[0].(*dns.A)
-		ipAddress = Arecord.A.String()
 	}
 
 	// Update the virtual-server annotation with the resolved IP Address
@@ -2204,7 +2608,7 @@ func (appMgr *Manager) resolveIngressHost(ing *v1beta1.Ingress, namespace string
 		ing.ObjectMeta.Annotations = make(map[string]string)
 	}
 	ing.ObjectMeta.Annotations[F5VsBindAddrAnnotation] = ipAddress
-	_, err = appMgr.kubeClient.ExtensionsV1beta1().Ingresses(namespace).Update(ing)
+	err = appMgr.updateIngress(ing)
 	if nil != err {
 		msg := fmt.Sprintf("Error while setting virtual-server IP for Ingress '%s': %s",
 			ing.ObjectMeta.Name, err)
@@ -2218,9 +2622,71 @@ func (appMgr *Manager) resolveIngressHost(ing *v1beta1.Ingress, namespace string
 	}
 }
 
+// customDNSServer returns the address of the DNS server given as the
+// resolve-ingress-names setting, an IP address or a host name with an
+// optional port. IPv6 addresses with a port are bracketed.
+func customDNSServer(server string) (string, error) {
+	host, port, err := net.SplitHostPort(server)
+	if nil != err {
+		// No port, use the default one
+		host, port = strings.Trim(server, "[]"), "53"
+	}
+	if nil == net.ParseIP(host) {
+		// host is not an IPAddress, it is a hostname that we need to resolve first
+		netIPs, err := net.LookupIP(host)
+		if nil != err {
+			return "", err
+		}
+		host = netIPs[0].String()
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// resolveWithDNSServer returns the first IPv4 address of host, or its first
+// IPv6 address if it has no IPv4 address
+func resolveWithDNSServer(host, server string) (string, error) {
+	client := dns.Client{}
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		msg := dns.Msg{}
+		msg.SetQuestion(host+".", qtype)
+		res, _, err := client.Exchange(&msg, server)
+		if nil != err {
+			return "", err
+		}
+		for _, rr := range res.Answer {
+			switch record := rr.(type) {
+			case *dns.A:
+				return record.A.String(), nil
+			case *dns.AAAA:
+				return record.AAAA.String(), nil
+			}
+		}
+	}
+	return "", nil
+}
+
 func (appMgr *Manager) getEndpointsForCluster(
 	portName string,
 	eps *v1.Endpoints,
+) []Member {
+	return appMgr.getEndpointAddressesForCluster(portName, eps,
+		func(subset v1.EndpointSubset) []v1.EndpointAddress { return subset.Addresses })
+}
+
+// getNotReadyEndpointsForCluster returns the members of the endpoints whose
+// pod is not ready, which are drained rather than removed
+func (appMgr *Manager) getNotReadyEndpointsForCluster(
+	portName string,
+	eps *v1.Endpoints,
+) []Member {
+	return appMgr.getEndpointAddressesForCluster(portName, eps,
+		func(subset v1.EndpointSubset) []v1.EndpointAddress { return subset.NotReadyAddresses })
+}
+
+func (appMgr *Manager) getEndpointAddressesForCluster(
+	portName string,
+	eps *v1.Endpoints,
+	addresses func(subset v1.EndpointSubset) []v1.EndpointAddress,
 ) []Member {
 	nodes := appMgr.getNodesFromCache()
 	var members []Member
@@ -2232,12 +2698,12 @@ func (appMgr *Manager) getEndpointsForCluster(
 	for _, subset := range eps.Subsets {
 		for _, p := range subset.Ports {
 			if portName == p.Name {
-				for _, addr := range subset.Addresses {
-					if containsNode(nodes, *addr.NodeName) {
+				for _, addr := range addresses(subset) {
+					if nil != addr.NodeName && containsNode(nodes, *addr.NodeName) {
 						member := Member{
 							Address: addr.IP,
 							Port:    p.Port,
-							Session: "user-enabled",
+							Session: MemberSessionEnabled,
 						}
 						members = append(members, member)
 					}
@@ -2265,6 +2731,55 @@ func (appMgr *Manager) getEndpointsForNodePort(
 	return members
 }
 
+// getLocalEndpointsForNodePort returns the members of the node port of a
+// Service with the Local external traffic policy: the nodes hosting a ready
+// endpoint of the Service, as the other nodes drop its traffic
+func (appMgr *Manager) getLocalEndpointsForNodePort(
+	svc *v1.Service,
+	nodePort int32,
+) []Member {
+	var readyNodes map[string]bool
+	if appInf, ok := appMgr.getNamespaceInformer(svc.ObjectMeta.Namespace); ok {
+		if nil != appInf.endptSliceInformer {
+			slices, _ := GetServiceEndpointSlices(appInf.endptSliceInformer.GetIndexer(),
+				svc.ObjectMeta.Namespace, svc.ObjectMeta.Name)
+			readyNodes = EndpointSliceReadyNodes(slices)
+		} else if nil != appInf.endptInformer {
+			item, _, _ := appInf.endptInformer.GetStore().GetByKey(
+				svc.ObjectMeta.Namespace + "/" + svc.ObjectMeta.Name)
+			eps, _ := item.(*v1.Endpoints)
+			readyNodes = EndpointsReadyNodes(eps)
+		}
+	}
+	var members []Member
+	for _, member := range appMgr.getEndpointsForNodePort(nodePort) {
+		for _, node := range appMgr.getNodesFromCache() {
+			if node.Addr == member.Address && readyNodes[node.Name] {
+				members = append(members, member)
+				break
+			}
+		}
+	}
+	return members
+}
+
+// setHealthCheckNodePortMonitor monitors the members of a pool with the
+// healthCheckNodePort of its Service, when enabled, and removes the monitor
+// when the Service has none
+func (appMgr *Manager) setHealthCheckNodePortMonitor(
+	svc *v1.Service,
+	rsCfg *ResourceConfig,
+	index int,
+) {
+	pool := &rsCfg.Pools[index]
+	monitor := HealthCheckNodePortMonitor(*pool, svc.Spec.HealthCheckNodePort)
+	if appMgr.nodePortHealthMonitor && 0 != svc.Spec.HealthCheckNodePort {
+		rsCfg.SetMonitor(pool, monitor)
+	} else {
+		rsCfg.UnsetMonitor(pool, monitor)
+	}
+}
+
 func handleConfigMapParseFailure(
 	appMgr *Manager,
 	cm *v1.ConfigMap,
@@ -2303,6 +2818,62 @@ func handleConfigMapParseFailure(
 type Node struct {
 	Name string
 	Addr string
+	// Zone of the node, from its topology.kubernetes.io/zone label
+	Zone string
+}
+
+// Settings of the Manager that can be changed while it runs
+type Settings struct {
+	// Default client and server SSL profiles of the Route virtual servers
+	ClientSSL string
+	ServerSSL string
+	// SNAT pool name referenced by virtual servers
+	VsSnatPoolName string
+}
+
+// UpdateSettings changes the settings and re-syncs the services of the
+// existing resources, for their virtual servers to use the new settings
+func (appMgr *Manager) UpdateSettings(settings Settings) {
+	appMgr.settingsMutex.Lock()
+	changed := settings.ClientSSL != appMgr.routeConfig.ClientSSL ||
+		settings.ServerSSL != appMgr.routeConfig.ServerSSL ||
+		settings.VsSnatPoolName != appMgr.vsSnatPoolName
+	appMgr.routeConfig.ClientSSL = settings.ClientSSL
+	appMgr.routeConfig.ServerSSL = settings.ServerSSL
+	appMgr.vsSnatPoolName = settings.VsSnatPoolName
+	appMgr.settingsMutex.Unlock()
+	if !changed {
+		return
+	}
+
+	items := make(map[serviceQueueKey]bool)
+	appMgr.resources.Lock()
+	appMgr.resources.ForEach(func(key ServiceKey, cfg *ResourceConfig) {
+		items[serviceQueueKey{
+			Namespace:   key.Namespace,
+			ServiceName: key.ServiceName,
+		}] = true
+	})
+	appMgr.resources.Unlock()
+	var keys []*serviceQueueKey
+	for queueKey := range items {
+		key := queueKey
+		keys = append(keys, &key)
+	}
+	log.Infof("[CORE] Settings changed, re-syncing %v services", len(keys))
+	appMgr.enqueueKeys("Settings", keys)
+}
+
+func (appMgr *Manager) getRouteConfig() RouteConfig {
+	appMgr.settingsMutex.RLock()
+	defer appMgr.settingsMutex.RUnlock()
+	return appMgr.routeConfig
+}
+
+func (appMgr *Manager) getVsSnatPoolName() string {
+	appMgr.settingsMutex.RLock()
+	defer appMgr.settingsMutex.RUnlock()
+	return appMgr.vsSnatPoolName
 }
 
 // Check for a change in Node state
@@ -2391,6 +2962,7 @@ func (appMgr *Manager) getNodes(
 				n := Node{
 					Name: node.ObjectMeta.Name,
 					Addr: addr.Address,
+					Zone: NodeZone(&node),
 				}
 				watchedNodes = append(watchedNodes, n)
 			}
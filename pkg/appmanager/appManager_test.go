@@ -22,6 +22,7 @@ import (
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent"
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/test"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"io/ioutil"
@@ -35,6 +36,7 @@ import (
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes/fake"
@@ -316,6 +318,21 @@ func (m *mockAppManager) deleteEndpoints(ep *v1.Endpoints) bool {
 	return ok
 }
 
+func (m *mockAppManager) updateEndpointSlice(slice *unstructured.Unstructured) bool {
+	ok, keys := m.appMgr.checkValidEndpointSlice(slice)
+	if ok {
+		appInf, _ := m.appMgr.getNamespaceInformer(slice.GetNamespace())
+		appInf.endptSliceInformer.GetStore().Update(slice)
+		for _, vsKey := range keys {
+			mtx := m.getVsMutex(*vsKey)
+			mtx.Lock()
+			defer mtx.Unlock()
+			m.appMgr.syncVirtualServer(*vsKey)
+		}
+	}
+	return ok
+}
+
 func (m *mockAppManager) addIngress(ing *v1beta1.Ingress) bool {
 	ok, keys := m.appMgr.checkValidIngress(ing)
 	if ok {
@@ -373,6 +390,14 @@ func (m *mockAppManager) deleteIngress(ing *v1beta1.Ingress) bool {
 	return ok
 }
 
+// legacyIngress returns an extensions/v1beta1 Ingress as read from the
+// Ingress informer
+func legacyIngress(ing *v1beta1.Ingress) *Ingress {
+	wrapped, err := ToIngress(ing)
+	Expect(err).To(BeNil())
+	return wrapped
+}
+
 func (m *mockAppManager) addRoute(route *routeapi.Route) bool {
 	ok, keys := m.appMgr.checkValidRoute(route)
 	if ok {
@@ -984,6 +1009,71 @@ var _ = Describe("AppManager Tests", func() {
 				testOverwriteAddImpl(false)
 			})
 
+			It("traces informer events to the deployment", func() {
+				exporter := tracing.NewInMemoryExporter()
+				defer tracing.SetTracerProvider(tracing.SetTracerProvider(exporter.TracerProvider()))
+
+				cfgFoo := test.NewConfigMap("foomap", "1", namespace, map[string]string{
+					"schema": schemaUrl,
+					"data":   configmapFoo})
+				appInf, _ := mockMgr.appMgr.getNamespaceInformer(namespace)
+				appInf.cfgMapInformer.GetStore().Add(cfgFoo)
+				mockMgr.appMgr.enqueueCreatedConfigMap(cfgFoo)
+				Expect(mockMgr.appMgr.vsQueue.Len()).To(Equal(1))
+				Expect(mockMgr.appMgr.processNextVirtualServer()).To(BeTrue())
+
+				events := exporter.Named(tracing.SpanInformerEvent)
+				Expect(events).To(HaveLen(1))
+				Expect(events[0].Attributes).To(ContainElement(tracing.String("kind", "ConfigMap")))
+				Expect(events[0].Parent.IsValid()).To(BeFalse())
+				enqueues := exporter.Named(tracing.SpanEnqueue)
+				Expect(enqueues).To(HaveLen(1))
+				Expect(enqueues[0].Parent).To(Equal(events[0].SpanContext))
+				Expect(enqueues[0].Attributes).To(ContainElement(tracing.String("service", "foo")))
+				syncs := exporter.Named(tracing.SpanSyncVirtual)
+				Expect(syncs).To(HaveLen(1))
+				Expect(syncs[0].Parent).To(Equal(enqueues[0].SpanContext))
+				Expect(syncs[0].Status.Code).To(Equal(tracing.StatusUnset))
+				deploys := exporter.Named(tracing.SpanDeployResource)
+				Expect(deploys).To(HaveLen(1))
+				Expect(deploys[0].Parent).To(Equal(syncs[0].SpanContext))
+				Expect(deploys[0].SpanContext.TraceID()).To(Equal(events[0].SpanContext.TraceID()))
+			})
+
+			It("records the services synced since the last deployment", func() {
+				mockMgr.appMgr.addDeployTrigger(serviceQueueKey{Namespace: namespace, ServiceName: "foo"})
+				mockMgr.appMgr.addDeployTrigger(serviceQueueKey{Namespace: namespace, ServiceName: "bar"})
+				mockMgr.appMgr.addDeployTrigger(serviceQueueKey{Namespace: namespace, ServiceName: "foo"})
+				Expect(mockMgr.appMgr.takeDeployTriggers()).To(Equal([]string{"default/bar", "default/foo"}))
+				Expect(mockMgr.appMgr.takeDeployTriggers()).To(BeEmpty())
+			})
+
+			It("re-syncs the virtual servers when the settings change", func() {
+				cfgFoo := test.NewConfigMap("foomap", "1", namespace, map[string]string{
+					"schema": schemaUrl,
+					"data":   configmapFoo})
+				Expect(mockMgr.addConfigMap(cfgFoo)).To(BeTrue())
+				rs, ok := mockMgr.resources().Get(
+					ServiceKey{"foo", 80, namespace}, FormatConfigMapVSName(cfgFoo))
+				Expect(ok).To(BeTrue())
+				Expect(rs.Virtual.SourceAddrTranslation.Pool).To(BeEmpty())
+
+				mockMgr.appMgr.UpdateSettings(Settings{})
+				Expect(mockMgr.appMgr.vsQueue.Len()).To(Equal(0))
+
+				mockMgr.appMgr.UpdateSettings(Settings{
+					ClientSSL:      "/Common/clientssl",
+					VsSnatPoolName: "snat-pool",
+				})
+				Expect(mockMgr.appMgr.getRouteConfig().ClientSSL).To(Equal("/Common/clientssl"))
+				Expect(mockMgr.appMgr.vsQueue.Len()).To(Equal(1))
+				Expect(mockMgr.appMgr.processNextVirtualServer()).To(BeTrue())
+				rs, ok = mockMgr.resources().Get(
+					ServiceKey{"foo", 80, namespace}, FormatConfigMapVSName(cfgFoo))
+				Expect(ok).To(BeTrue())
+				Expect(rs.Virtual.SourceAddrTranslation.Pool).To(Equal("snat-pool"))
+			})
+
 			testServiceChangeUpdateImpl := func(isNodePort bool) {
 				mockMgr.appMgr.isNodePort = isNodePort
 				cfgFoo := test.NewConfigMap("foomap", "1", namespace, map[string]string{
@@ -2268,6 +2358,54 @@ var _ = Describe("AppManager Tests", func() {
 				validateServiceIps(svcName, namespace, svcPorts, readyIps, resources)
 			})
 
+			It("drains the members whose endpoints are no longer ready", func() {
+				mockMgr.appMgr.isNodePort = false
+				mockMgr.appMgr.memberDrainer = NewMemberDrainer(time.Minute)
+				svcPorts := []v1.ServicePort{newServicePort("port0", 80)}
+				endptPorts := convertSvcPortsToEndpointPorts(svcPorts)
+				member := func(ip, session string) Member {
+					return Member{Address: ip, Port: 80, Session: session}
+				}
+				members := func() []Member {
+					cfgs := mockMgr.resources().GetAll(ServiceKey{"foo", 80, namespace})
+					Expect(cfgs).To(HaveLen(1))
+					return cfgs[0].Pools[0].Members
+				}
+
+				node := test.NewNode("node0", "0", false, []v1.NodeAddress{
+					{Type: "ExternalIP", Address: "127.0.0.0"}}, []v1.Taint{})
+				mockMgr.processNodeUpdate([]v1.Node{*node}, nil)
+				cfgFoo := test.NewConfigMap("foomap", "1", namespace, map[string]string{
+					"schema": schemaUrl,
+					"data":   configmapFoo})
+				Expect(mockMgr.addConfigMap(cfgFoo)).To(BeTrue())
+				foo := test.NewService("foo", "1", namespace, v1.ServiceTypeClusterIP, svcPorts)
+				Expect(mockMgr.addService(foo)).To(BeTrue())
+				Expect(mockMgr.addEndpoints(test.NewEndpoints("foo", "1", "node0", namespace,
+					[]string{"10.2.96.0", "10.2.96.1", "10.2.96.2"}, nil, endptPorts))).To(BeTrue())
+				Expect(members()).To(Equal([]Member{
+					member("10.2.96.0", "user-enabled"),
+					member("10.2.96.1", "user-enabled"),
+					member("10.2.96.2", "user-enabled"),
+				}))
+
+				// One endpoint is not ready, another one is gone
+				Expect(mockMgr.updateEndpoints(test.NewEndpoints("foo", "2", "node0", namespace,
+					[]string{"10.2.96.0"}, []string{"10.2.96.1"}, endptPorts))).To(BeTrue())
+				Expect(members()).To(Equal([]Member{
+					member("10.2.96.0", "user-enabled"),
+					member("10.2.96.1", "user-disabled"),
+				}))
+
+				// Ready again, new endpoints which are not ready yet are left out
+				Expect(mockMgr.updateEndpoints(test.NewEndpoints("foo", "3", "node0", namespace,
+					[]string{"10.2.96.0", "10.2.96.1"}, []string{"10.2.96.3"}, endptPorts))).To(BeTrue())
+				Expect(members()).To(Equal([]Member{
+					member("10.2.96.0", "user-enabled"),
+					member("10.2.96.1", "user-enabled"),
+				}))
+			})
+
 			It("configures virtual servers when service changes", func() {
 				mockMgr.appMgr.isNodePort = false
 				svcName := "foo"
@@ -2335,6 +2473,73 @@ var _ = Describe("AppManager Tests", func() {
 				validateServiceIps(svcName, namespace, svcPorts, svcPodIps, resources)
 			})
 
+			It("configures pool members from EndpointSlices", func() {
+				mockMgr.appMgr.isNodePort = false
+				mockMgr.appMgr.useEndpointSlices = true
+				sliceNamespace := "slices"
+				ls, _ := labels.Parse(DefaultConfigMapLabel)
+				Expect(mockMgr.appMgr.AddNamespace(sliceNamespace, ls, 0)).To(BeNil())
+				appInf, _ := mockMgr.appMgr.getNamespaceInformer(sliceNamespace)
+				Expect(appInf.endptInformer).To(BeNil())
+
+				svcName := "foo"
+				svcPorts := []v1.ServicePort{newServicePort("port0", 80)}
+				node := test.NewNode("node0", "0", false, []v1.NodeAddress{
+					{Type: "ExternalIP", Address: "127.0.0.0"}}, []v1.Taint{})
+				mockMgr.processNodeUpdate([]v1.Node{*node}, nil)
+
+				endpoint := func(addr string, ready, terminating bool) interface{} {
+					return map[string]interface{}{
+						"addresses": []interface{}{addr},
+						"nodeName":  "node0",
+						"conditions": map[string]interface{}{
+							"ready":       ready,
+							"serving":     true,
+							"terminating": terminating,
+						},
+					}
+				}
+				slice := &unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "discovery.k8s.io/v1",
+					"kind":       "EndpointSlice",
+					"metadata": map[string]interface{}{
+						"name":      "foo-abc",
+						"namespace": sliceNamespace,
+						"labels":    map[string]interface{}{EndpointSliceServiceLabel: svcName},
+					},
+					"addressType": "IPv4",
+					"endpoints": []interface{}{
+						endpoint("10.2.96.1", true, false),
+						endpoint("10.2.96.0", true, false),
+						endpoint("10.2.96.2", false, true),
+					},
+					"ports": []interface{}{
+						map[string]interface{}{"name": "port0", "port": int64(80)},
+					},
+				}}
+				Expect(mockMgr.updateEndpointSlice(slice)).To(BeTrue(), "EndpointSlice should be processed.")
+				Expect(mockMgr.addService(test.NewService(svcName, "1", sliceNamespace,
+					v1.ServiceTypeClusterIP, svcPorts))).To(BeTrue(), "Service should be processed.")
+				cfgFoo := test.NewConfigMap("foomap", "1", sliceNamespace, map[string]string{
+					"schema": schemaUrl,
+					"data":   configmapFoo})
+				Expect(mockMgr.addConfigMap(cfgFoo)).To(BeTrue(), "ConfigMap should be processed.")
+
+				resources := mockMgr.resources()
+				Expect(resources.PoolCount()).To(Equal(1))
+				validateServiceIps(svcName, sliceNamespace, svcPorts,
+					[]string{"10.2.96.0", "10.2.96.1"}, resources)
+
+				// Terminating endpoints still serving are used when none is ready
+				slice.Object["endpoints"] = []interface{}{endpoint("10.2.96.2", false, true)}
+				Expect(mockMgr.updateEndpointSlice(slice)).To(BeTrue(), "EndpointSlice should be processed.")
+				validateServiceIps(svcName, sliceNamespace, svcPorts, []string{"10.2.96.2"}, resources)
+
+				unlabeled := slice.DeepCopy()
+				unlabeled.SetLabels(nil)
+				Expect(mockMgr.updateEndpointSlice(unlabeled)).To(BeFalse())
+			})
+
 			It("configures virtual servers when ConfigMap changes", func() {
 				mockMgr.appMgr.isNodePort = false
 				svcName := "foo"
@@ -2495,6 +2700,100 @@ var _ = Describe("AppManager Tests", func() {
 				Expect(resources.PoolCount()).To(Equal(2))
 			})
 
+			It("configures zone aware pools via Ingress", func() {
+				mockMgr.appMgr.localZone = "zone-a"
+				var nodes []v1.Node
+				for i, zone := range []string{"zone-a", "zone-b"} {
+					node := test.NewNode(fmt.Sprintf("node%d", i), "1", false, []v1.NodeAddress{
+						{Type: "ExternalIP", Address: fmt.Sprintf("127.0.0.%d", i)}}, []v1.Taint{})
+					node.ObjectMeta.Labels = map[string]string{ZoneLabel: zone}
+					nodes = append(nodes, *node)
+				}
+				mockMgr.processNodeUpdate(nodes, nil)
+				fooSvc := test.NewService("foo", "1", namespace, "NodePort",
+					[]v1.ServicePort{{Port: 80, NodePort: 37001}})
+				Expect(mockMgr.addService(fooSvc)).To(BeTrue())
+
+				ingressConfig := v1beta1.IngressSpec{
+					Backend: &v1beta1.IngressBackend{
+						ServiceName: "foo",
+						ServicePort: intstr.IntOrString{IntVal: 80},
+					},
+				}
+				ingress := test.NewIngress("ingress", "1", namespace, ingressConfig,
+					map[string]string{
+						F5VsBindAddrAnnotation:         "1.2.3.4",
+						F5VsZoneAwareAnnotation:        "true",
+						F5VsMinActiveMembersAnnotation: "2",
+					})
+				Expect(mockMgr.addIngress(ingress)).To(BeTrue())
+				rs, ok := mockMgr.resources().Get(
+					ServiceKey{"foo", 80, namespace}, FormatIngressVSName("1.2.3.4", 80))
+				Expect(ok).To(BeTrue())
+				Expect(rs.Pools[0].MinimumMembersActive).To(Equal(int32(2)))
+				Expect(rs.Pools[0].Members).To(Equal([]Member{
+					{Address: "127.0.0.0", Port: 37001, Session: "user-enabled", PriorityGroup: 1},
+					{Address: "127.0.0.1", Port: 37001, Session: "user-enabled"},
+				}))
+
+				// Not zone aware anymore
+				ingress = test.NewIngress("ingress", "2", namespace, ingressConfig,
+					map[string]string{F5VsBindAddrAnnotation: "1.2.3.4"})
+				Expect(mockMgr.updateIngress(ingress)).To(BeTrue())
+				rs, _ = mockMgr.resources().Get(
+					ServiceKey{"foo", 80, namespace}, FormatIngressVSName("1.2.3.4", 80))
+				Expect(rs.Pools[0].MinimumMembersActive).To(BeZero())
+				Expect(rs.Pools[0].Members[0].PriorityGroup).To(BeZero())
+			})
+
+			It("only uses the nodes with ready endpoints for the Local traffic policy", func() {
+				mockMgr.appMgr.nodePortHealthMonitor = true
+				var nodes []v1.Node
+				for i := 0; i < 2; i++ {
+					node := test.NewNode(fmt.Sprintf("node%d", i), "1", false, []v1.NodeAddress{
+						{Type: "ExternalIP", Address: fmt.Sprintf("127.0.0.%d", i)}}, []v1.Taint{})
+					nodes = append(nodes, *node)
+				}
+				mockMgr.processNodeUpdate(nodes, nil)
+				fooPorts := []v1.ServicePort{{Port: 80, NodePort: 37001}}
+				fooSvc := test.NewService("foo", "1", namespace, "NodePort", fooPorts)
+				fooSvc.Spec.ExternalTrafficPolicy = v1.ServiceExternalTrafficPolicyTypeLocal
+				fooSvc.Spec.HealthCheckNodePort = 32000
+				Expect(mockMgr.addService(fooSvc)).To(BeTrue())
+				fooEndpts := test.NewEndpoints("foo", "1", "node1", namespace,
+					[]string{"10.2.96.0"}, nil, convertSvcPortsToEndpointPorts(fooPorts))
+				Expect(mockMgr.addEndpoints(fooEndpts)).To(BeTrue())
+
+				ingressConfig := v1beta1.IngressSpec{
+					Backend: &v1beta1.IngressBackend{
+						ServiceName: "foo",
+						ServicePort: intstr.IntOrString{IntVal: 80},
+					},
+				}
+				ingress := test.NewIngress("ingress", "1", namespace, ingressConfig,
+					map[string]string{F5VsBindAddrAnnotation: "1.2.3.4"})
+				Expect(mockMgr.addIngress(ingress)).To(BeTrue())
+				rs, ok := mockMgr.resources().Get(
+					ServiceKey{"foo", 80, namespace}, FormatIngressVSName("1.2.3.4", 80))
+				Expect(ok).To(BeTrue())
+				Expect(rs.Pools[0].Members).To(Equal([]Member{
+					{Address: "127.0.0.1", Port: 37001, Session: "user-enabled"},
+				}))
+				monitor := HealthCheckNodePortMonitor(rs.Pools[0], 32000)
+				Expect(rs.Monitors).To(ContainElement(monitor))
+				Expect(rs.Pools[0].MonitorNames).To(ContainElement(
+					JoinBigipPath(monitor.Partition, monitor.Name)))
+
+				// Back to the Cluster traffic policy
+				fooSvc = test.NewService("foo", "2", namespace, "NodePort", fooPorts)
+				Expect(mockMgr.updateService(fooSvc)).To(BeTrue())
+				rs, _ = mockMgr.resources().Get(
+					ServiceKey{"foo", 80, namespace}, FormatIngressVSName("1.2.3.4", 80))
+				Expect(rs.Pools[0].Members).To(HaveLen(2))
+				Expect(rs.Monitors).ToNot(ContainElement(monitor))
+				Expect(rs.Pools[0].MonitorNames).To(BeEmpty())
+			})
+
 			It("configures virtual servers via Ingress", func() {
 				// Add a service
 				fooSvc := test.NewService("foo", "1", namespace, "NodePort",
@@ -4090,6 +4389,20 @@ var _ = Describe("AppManager Tests", func() {
 				Expect(events[0].Reason).To(Equal("DNSResolutionError"))
 				Expect(events[1].Reason).To(Equal("ResourceConfigured"))
 			})
+
+			It("parses the address of custom DNS servers", func() {
+				for server, expected := range map[string]string{
+					"10.1.1.1":            "10.1.1.1:53",
+					"10.1.1.1:5353":       "10.1.1.1:5353",
+					"2001:db8::53":        "[2001:db8::53]:53",
+					"[2001:db8::53]":      "[2001:db8::53]:53",
+					"[2001:db8::53]:5353": "[2001:db8::53]:5353",
+				} {
+					address, err := customDNSServer(server)
+					Expect(err).To(BeNil(), server)
+					Expect(address).To(Equal(expected), server)
+				}
+			})
 		})
 
 		Context("namespace related", func() {
@@ -0,0 +1,52 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package appmanager
+
+import (
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/debug"
+)
+
+// RegisterDebugState serves the resources, merged rules, work queues,
+// watched namespaces and node cache of the manager on dbg
+func (appMgr *Manager) RegisterDebugState(dbg *debug.Server) {
+	dbg.Register("resources", func() (interface{}, error) {
+		appMgr.resources.Lock()
+		defer appMgr.resources.Unlock()
+		return debug.MarshalState(appMgr.resources.GetAllResources())
+	})
+	dbg.Register("merged-rules", func() (interface{}, error) {
+		appMgr.resources.Lock()
+		defer appMgr.resources.Unlock()
+		return debug.MarshalState(appMgr.mergedRulesMap)
+	})
+	dbg.Register("queues", func() (interface{}, error) {
+		queues := make(map[string]interface{})
+		if q, ok := appMgr.vsQueue.(*debug.InspectableQueue); ok {
+			queues["virtual-server-controller"] = q.Contents()
+		}
+		if q, ok := appMgr.nsQueue.(*debug.InspectableQueue); ok {
+			queues["namespace-controller"] = q.Contents()
+		}
+		return queues, nil
+	})
+	dbg.Register("namespaces", func() (interface{}, error) {
+		return appMgr.GetWatchedNamespaces(), nil
+	})
+	dbg.Register("nodes", func() (interface{}, error) {
+		return appMgr.getNodesFromCache(), nil
+	})
+}
@@ -19,8 +19,9 @@ package appmanager
 import (
 	"sync"
 
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+
 	"k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes/scheme"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -114,7 +115,7 @@ func (nen *NamespaceEventNotifier) recordEvent(
 // This function expects either an Ingress resource or the name of a VS for
 // an Ingress.
 func (appMgr *Manager) recordIngressEvent(
-	ing *v1beta1.Ingress,
+	ing *Ingress,
 	reason,
 	message string,
 ) {
@@ -122,5 +123,5 @@ func (appMgr *Manager) recordIngressEvent(
 	// Create the event
 	evNotifier := appMgr.eventNotifier.createNotifierForNamespace(
 		namespace, appMgr.kubeClient.CoreV1())
-	evNotifier.recordEvent(ing, v1.EventTypeNormal, reason, message)
+	evNotifier.recordEvent(ing.Obj, v1.EventTypeNormal, reason, message)
 }
@@ -0,0 +1,1272 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package appmanager
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Route kinds accepted by the listeners of each protocol
+var gatewayListenerRouteKinds = map[string]string{
+	HTTPProtocolType:  HTTPRouteKind,
+	HTTPSProtocolType: HTTPRouteKind,
+	TLSProtocolType:   TLSRouteKind,
+	TCPProtocolType:   TCPRouteKind,
+}
+
+// Resources of the route kinds
+var gatewayRouteResources = map[string]schema.GroupVersionResource{
+	HTTPRouteKind: HTTPRouteResource,
+	TLSRouteKind:  TLSRouteResource,
+	TCPRouteKind:  TCPRouteResource,
+}
+
+// servedGatewayAPIResources returns the Gateway API resources served by the
+// cluster. Informers of resources without CRDs would never sync.
+func servedGatewayAPIResources(
+	kubeClient kubernetes.Interface,
+) map[schema.GroupVersionResource]bool {
+	gvrs := []schema.GroupVersionResource{
+		GatewayClassResource,
+		GatewayResource,
+		HTTPRouteResource,
+		TLSRouteResource,
+		TCPRouteResource,
+	}
+	served := servedResources(kubeClient, gvrs...)
+	for _, gvr := range gvrs {
+		if !served[gvr] {
+			log.Warningf("[CORE] Gateway API resource %v is not served, not watching it.", gvr)
+		}
+	}
+	return served
+}
+
+// gatewayRouteInformer is the informer of a route kind
+type gatewayRouteInformer struct {
+	kind     string
+	informer cache.SharedIndexInformer
+}
+
+// gatewayRouteInformers returns the informers of the route kinds served
+func (appInf *appInformer) gatewayRouteInformers() []gatewayRouteInformer {
+	var informers []gatewayRouteInformer
+	for _, ri := range []gatewayRouteInformer{
+		{HTTPRouteKind, appInf.httpRouteInformer},
+		{TLSRouteKind, appInf.tlsRouteInformer},
+		{TCPRouteKind, appInf.tcpRouteInformer},
+	} {
+		if nil != ri.informer {
+			informers = append(informers, ri)
+		}
+	}
+	return informers
+}
+
+// gatewayRoute is an HTTPRoute, TLSRoute or TCPRoute
+type gatewayRoute struct {
+	metav1.ObjectMeta
+	kind       string
+	obj        *unstructured.Unstructured
+	parentRefs []ParentReference
+	hostnames  []string
+	status     RouteStatus
+	// Set for HTTPRoutes
+	httpRules []HTTPRouteRule
+	// Set for TLSRoutes and TCPRoutes
+	l4Rules []L4RouteRule
+	// Why the route is not supported, empty when it is
+	unsupported string
+	// False ResolvedRefs condition, nil when all backends resolve
+	unresolved *GatewayCondition
+	// Accepted conditions of the parentRefs of our Gateways, by index
+	parents map[int]*GatewayCondition
+}
+
+func newGatewayRoute(kind string, obj interface{}) (*gatewayRoute, error) {
+	rt := &gatewayRoute{kind: kind, parents: make(map[int]*GatewayCondition)}
+	switch kind {
+	case HTTPRouteKind:
+		var route HTTPRoute
+		if err := FromGatewayAPIObject(obj, &route); nil != err {
+			return nil, err
+		}
+		rt.ObjectMeta = route.ObjectMeta
+		rt.parentRefs = route.Spec.ParentRefs
+		rt.hostnames = route.Spec.Hostnames
+		rt.status = route.Status
+		rt.httpRules = route.Spec.Rules
+	case TLSRouteKind:
+		var route TLSRoute
+		if err := FromGatewayAPIObject(obj, &route); nil != err {
+			return nil, err
+		}
+		rt.ObjectMeta = route.ObjectMeta
+		rt.parentRefs = route.Spec.ParentRefs
+		rt.hostnames = route.Spec.Hostnames
+		rt.status = route.Status
+		rt.l4Rules = route.Spec.Rules
+	case TCPRouteKind:
+		var route TCPRoute
+		if err := FromGatewayAPIObject(obj, &route); nil != err {
+			return nil, err
+		}
+		rt.ObjectMeta = route.ObjectMeta
+		rt.parentRefs = route.Spec.ParentRefs
+		rt.status = route.Status
+		rt.l4Rules = route.Spec.Rules
+	}
+	rt.obj, _ = obj.(*unstructured.Unstructured)
+	return rt, nil
+}
+
+// backendRefs returns the backends of all rules of the route
+func (rt *gatewayRoute) backendRefs() []BackendRef {
+	var refs []BackendRef
+	for _, rl := range rt.httpRules {
+		for _, ref := range rl.BackendRefs {
+			refs = append(refs, ref.BackendRef)
+		}
+	}
+	for _, rl := range rt.l4Rules {
+		refs = append(refs, rl.BackendRefs...)
+	}
+	return refs
+}
+
+// listGatewayRoutes returns the routes of the namespace, oldest first
+func (appInf *appInformer) listGatewayRoutes(namespace string) ([]*gatewayRoute, error) {
+	var routes []*gatewayRoute
+	for _, ri := range appInf.gatewayRouteInformers() {
+		objs, err := ri.informer.GetIndexer().ByIndex("namespace", namespace)
+		if nil != err {
+			return nil, err
+		}
+		for _, obj := range objs {
+			rt, err := newGatewayRoute(ri.kind, obj)
+			if nil != err {
+				log.Warningf("[CORE] Unable to parse %v: %v", ri.kind, err)
+				continue
+			}
+			routes = append(routes, rt)
+		}
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		a, b := routes[i], routes[j]
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		return a.kind+"/"+a.Name < b.kind+"/"+b.Name
+	})
+	return routes, nil
+}
+
+// gatewayRouteUnsupported returns why CIS does not support the route, empty
+// when it does
+func gatewayRouteUnsupported(rt *gatewayRoute) string {
+	for _, rl := range rt.httpRules {
+		for _, m := range rl.Matches {
+			if nil != m.Method {
+				return "method matches are not supported"
+			}
+			if nil != m.Path && nil != m.Path.Type &&
+				*m.Path.Type != PathMatchExact && *m.Path.Type != PathMatchPathPrefix {
+				return fmt.Sprintf("%v path matches are not supported", *m.Path.Type)
+			}
+			for _, h := range m.Headers {
+				if nil != h.Type && *h.Type != HeaderMatchExact {
+					return fmt.Sprintf("%v header matches are not supported", *h.Type)
+				}
+			}
+			for _, q := range m.QueryParams {
+				if nil != q.Type && *q.Type != QueryMatchExact {
+					return fmt.Sprintf("%v query parameter matches are not supported", *q.Type)
+				}
+			}
+		}
+		for _, f := range rl.Filters {
+			switch f.Type {
+			case RequestHeaderModifierFilter:
+			case RequestRedirectFilter:
+				if r := f.RequestRedirect; nil != r {
+					if nil != r.StatusCode && *r.StatusCode != 302 {
+						return "redirect status codes other than 302 are not supported"
+					}
+					if nil != r.Path && r.Path.Type != FullPathHTTPPathModifier {
+						return fmt.Sprintf("%v path modifiers are not supported", r.Path.Type)
+					}
+				}
+			case URLRewriteFilter:
+				if r := f.URLRewrite; nil != r && nil != r.Path &&
+					r.Path.Type != FullPathHTTPPathModifier {
+					return fmt.Sprintf("%v path modifiers are not supported", r.Path.Type)
+				}
+			default:
+				return fmt.Sprintf("%v filters are not supported", f.Type)
+			}
+		}
+		for _, ref := range rl.BackendRefs {
+			if 0 != len(ref.Filters) {
+				return "backend filters are not supported"
+			}
+		}
+		if len(rl.BackendRefs) < 2 {
+			continue
+		}
+		// Weighted backends are selected by the A/B deployment iRule, which
+		// only looks at the host and the path prefix
+		if 0 != len(rl.Filters) {
+			return "filters of weighted backends are not supported"
+		}
+		for _, m := range rl.Matches {
+			if 0 != len(m.Headers) || 0 != len(m.QueryParams) ||
+				(nil != m.Path && nil != m.Path.Type && *m.Path.Type == PathMatchExact) {
+				return "weighted backends only support path prefix matches"
+			}
+		}
+		if 0 == len(rt.hostnames) {
+			return "weighted backends need route hostnames"
+		}
+		for _, h := range rt.hostnames {
+			if strings.HasPrefix(h, "*.") {
+				return "weighted backends do not support wildcard hostnames"
+			}
+		}
+	}
+	if rt.kind != HTTPRouteKind &&
+		(1 != len(rt.l4Rules) || 1 != len(rt.l4Rules[0].BackendRefs)) {
+		return fmt.Sprintf("a %v needs exactly one rule with one backend", rt.kind)
+	}
+	return ""
+}
+
+// backendRefError returns the reason and message of the ResolvedRefs
+// condition of a backend of a route in namespace, empty when it resolves
+func backendRefError(namespace string, ref BackendRef, svcIndexer cache.Indexer) (string, string) {
+	if (nil != ref.Group && *ref.Group != "") || (nil != ref.Kind && *ref.Kind != "Service") {
+		return ReasonInvalidKind, fmt.Sprintf("Backend '%v' is not a Service.", ref.Name)
+	}
+	if nil != ref.Namespace && *ref.Namespace != namespace {
+		return ReasonRefNotPermitted, fmt.Sprintf(
+			"Backend '%v/%v' is in another namespace.", *ref.Namespace, ref.Name)
+	}
+	if nil == ref.Port {
+		return ReasonBackendNotFound, fmt.Sprintf("Backend '%v' has no port.", ref.Name)
+	}
+	if _, found, _ := svcIndexer.GetByKey(namespace + "/" + ref.Name); !found {
+		return ReasonBackendNotFound, fmt.Sprintf("Service '%v' has not been found.", ref.Name)
+	}
+	return "", ""
+}
+
+// gatewayListener is a listener of a Gateway being processed
+type gatewayListener struct {
+	Listener
+	status ListenerStatus
+	// Whether the listener is accepted, resolved and not conflicted
+	ok bool
+	// Certificates of HTTPS listeners
+	secrets []*v1.Secret
+	// Routes attached to the listener
+	routes []gatewayAttachment
+}
+
+// gatewayAttachment is a route attached to a listener, with its hostnames
+// matching the listener
+type gatewayAttachment struct {
+	route     *gatewayRoute
+	hostnames []string
+}
+
+func (appMgr *Manager) syncGateways(
+	stats *vsSyncStats,
+	sKey serviceQueueKey,
+	rsMap ResourceMap,
+	appInf *appInformer,
+	dgMap InternalDataGroupMap,
+) error {
+	gwByIndex, err := appInf.gatewayInformer.GetIndexer().ByIndex(
+		"namespace", sKey.Namespace)
+	if nil != err {
+		log.Warningf("[CORE] Unable to list gateways for namespace '%v': %v",
+			sKey.Namespace, err)
+		return err
+	}
+	routes, err := appInf.listGatewayRoutes(sKey.Namespace)
+	if nil != err {
+		log.Warningf("[CORE] Unable to list gateway routes for namespace '%v': %v",
+			sKey.Namespace, err)
+		return err
+	}
+	svcIndexer := appInf.svcInformer.GetIndexer()
+	for _, rt := range routes {
+		rt.unsupported = gatewayRouteUnsupported(rt)
+		for _, ref := range rt.backendRefs() {
+			if reason, msg := backendRefError(rt.Namespace, ref, svcIndexer); reason != "" {
+				cond := NewGatewayCondition(
+					ConditionResolvedRefs, false, reason, msg, rt.Generation)
+				rt.unresolved = &cond
+				break
+			}
+		}
+	}
+
+	// Virtual servers of the namespace by name. As the Gateways of a namespace
+	// and their routes are all processed on each sync, they are rebuilt here.
+	cfgs := make(map[string]*ResourceConfig)
+	sort.Slice(gwByIndex, func(i, j int) bool {
+		return gwByIndex[i].(*unstructured.Unstructured).GetName() <
+			gwByIndex[j].(*unstructured.Unstructured).GetName()
+	})
+	for _, obj := range gwByIndex {
+		var gw Gateway
+		if err := FromGatewayAPIObject(obj, &gw); nil != err {
+			log.Warningf("[CORE] Unable to parse Gateway: %v", err)
+			continue
+		}
+		if !appMgr.isManagedGatewayClass(gw.Spec.GatewayClassName) {
+			continue
+		}
+		status := appMgr.processGateway(&gw, routes, svcIndexer, cfgs, dgMap, stats)
+		appMgr.updateGatewayAPIStatus(
+			GatewayResource, obj.(*unstructured.Unstructured), status)
+	}
+	for _, rt := range routes {
+		if nil != rt.obj {
+			appMgr.updateGatewayAPIStatus(
+				gatewayRouteResources[rt.kind], rt.obj, appMgr.gatewayRouteStatus(rt))
+		}
+	}
+
+	found, updated := appMgr.saveGatewayConfigs(sKey.Namespace, cfgs, appInf)
+	stats.vsFound += found
+	stats.vsUpdated += updated
+
+	// The Gateway configs are all saved above, none is left to delete
+	for port, cfgList := range rsMap {
+		var others []*ResourceConfig
+		for _, cfg := range cfgList {
+			if cfg.MetaData.ResourceType != ResourceTypeGateway {
+				others = append(others, cfg)
+			}
+		}
+		if 0 == len(others) {
+			delete(rsMap, port)
+		} else {
+			rsMap[port] = others
+		}
+	}
+	return nil
+}
+
+// isManagedGatewayClass tells whether CIS is the controller of the
+// GatewayClass name
+func (appMgr *Manager) isManagedGatewayClass(name string) bool {
+	if nil == appMgr.gwClassInformer {
+		return false
+	}
+	obj, found, err := appMgr.gwClassInformer.GetIndexer().GetByKey(name)
+	if nil != err || !found {
+		return false
+	}
+	var class GatewayClass
+	if err := FromGatewayAPIObject(obj, &class); nil != err {
+		return false
+	}
+	return class.Spec.ControllerName == appMgr.gatewayControllerName
+}
+
+// processGateway creates the virtual servers of the listeners of a Gateway
+// in cfgs, one for each port, and returns the status of the Gateway
+func (appMgr *Manager) processGateway(
+	gw *Gateway,
+	routes []*gatewayRoute,
+	svcIndexer cache.Indexer,
+	cfgs map[string]*ResourceConfig,
+	dgMap InternalDataGroupMap,
+	stats *vsSyncStats,
+) *GatewayStatus {
+	gen := gw.Generation
+	status := &GatewayStatus{}
+	addr, addrErr := appMgr.gatewayAddress(gw)
+	switch {
+	case addrErr != "":
+		status.Conditions = []GatewayCondition{
+			NewGatewayCondition(ConditionAccepted, false, ReasonUnsupportedAddress, addrErr, gen),
+			NewGatewayCondition(ConditionProgrammed, false, ReasonInvalid, addrErr, gen),
+		}
+	case addr == "":
+		msg := "The Gateway has no address and no default-ingress-ip is configured."
+		status.Conditions = []GatewayCondition{
+			NewGatewayCondition(ConditionAccepted, true, ReasonAccepted, "", gen),
+			NewGatewayCondition(ConditionProgrammed, false, ReasonAddressNotAssigned, msg, gen),
+		}
+	default:
+		addrType := IPAddressType
+		status.Addresses = []GatewayStatusAddress{{Type: &addrType, Value: addr}}
+		status.Conditions = []GatewayCondition{
+			NewGatewayCondition(ConditionAccepted, true, ReasonAccepted, "", gen),
+			NewGatewayCondition(ConditionProgrammed, true, ReasonProgrammed, "", gen),
+		}
+	}
+	programmed := addr != ""
+
+	listeners := appMgr.gatewayListeners(gw)
+	attachGatewayRoutes(gw, listeners, routes)
+	byPort := make(map[int32][]*gatewayListener)
+	var ports []int32
+	for _, l := range listeners {
+		reason := ReasonProgrammed
+		if !programmed || !l.ok {
+			reason = ReasonInvalid
+		}
+		l.status.Conditions = append(l.status.Conditions, NewGatewayCondition(
+			ConditionProgrammed, reason == ReasonProgrammed, reason, "", gen))
+		status.Listeners = append(status.Listeners, l.status)
+		if !programmed || !l.ok {
+			continue
+		}
+		if _, ok := byPort[l.Port]; !ok {
+			ports = append(ports, l.Port)
+		}
+		byPort[l.Port] = append(byPort[l.Port], l)
+	}
+
+	for _, port := range ports {
+		portListeners := byPort[port]
+		var rsCfg ResourceConfig
+		rsCfg.MetaData.ResourceType = ResourceTypeGateway
+		rsCfg.MetaData.Active = true
+		rsCfg.Virtual.Name = FormatGatewayVSName(gw.Namespace, gw.Name, port)
+		rsCfg.Virtual.Partition = DEFAULT_PARTITION
+		rsCfg.Virtual.Enabled = true
+		rsCfg.Virtual.SourceAddrTranslation = SetSourceAddrTranslation(appMgr.getVsSnatPoolName())
+		rsCfg.Virtual.SetVirtualAddress(addr, port)
+		switch portListeners[0].Protocol {
+		case HTTPProtocolType, HTTPSProtocolType:
+			SetProfilesForMode("http", &rsCfg)
+			for _, l := range portListeners {
+				for _, secret := range l.secrets {
+					appMgr.setGatewayListenerProfile(&rsCfg, secret, stats)
+				}
+			}
+			appMgr.setGatewayHTTPRules(&rsCfg, gw.Namespace, portListeners, svcIndexer, dgMap)
+		default:
+			SetProfilesForMode("tcp", &rsCfg)
+			if 0 != len(portListeners[0].routes) {
+				rt := portListeners[0].routes[0].route
+				ref := rt.l4Rules[0].BackendRefs[0]
+				if poolName := addGatewayPool(&rsCfg, rt.Namespace, ref, svcIndexer); poolName != "" {
+					rsCfg.Virtual.PoolName = JoinBigipPath(rsCfg.Virtual.Partition, poolName)
+				}
+			}
+		}
+		cfgs[rsCfg.GetName()] = &rsCfg
+	}
+	return status
+}
+
+// gatewayAddress returns the address of the virtual servers of a Gateway,
+// the default ingress IP when it has none, and why its address is not
+// supported
+func (appMgr *Manager) gatewayAddress(gw *Gateway) (string, string) {
+	if 0 != len(gw.Spec.Addresses) {
+		addr := gw.Spec.Addresses[0]
+		if (nil != addr.Type && *addr.Type != IPAddressType) || nil == net.ParseIP(addr.Value) {
+			return "", fmt.Sprintf("Address '%v' is not an IP address.", addr.Value)
+		}
+		return addr.Value, ""
+	}
+	if appMgr.defaultIngIP != "" && appMgr.defaultIngIP != "0.0.0.0" {
+		return appMgr.defaultIngIP, ""
+	}
+	return "", ""
+}
+
+// gatewayListeners validates the listeners of a Gateway. Listeners sharing
+// a port become one virtual server, so they need the same HTTP or HTTPS
+// protocol and different hostnames.
+func (appMgr *Manager) gatewayListeners(gw *Gateway) []*gatewayListener {
+	gen := gw.Generation
+	var listeners []*gatewayListener
+	okByPort := make(map[int32][]*gatewayListener)
+	for _, spec := range gw.Spec.Listeners {
+		l := &gatewayListener{
+			Listener: spec,
+			status:   ListenerStatus{Name: spec.Name},
+		}
+		listeners = append(listeners, l)
+
+		var reason, msg, refReason, refMsg, conflict, conflictMsg string
+		if kind, ok := gatewayListenerRouteKinds[spec.Protocol]; ok {
+			group := GatewayAPIGroup
+			l.status.SupportedKinds = []RouteGroupKind{{Group: &group, Kind: kind}}
+			reason, msg = gatewayListenerTLSError(spec)
+		} else {
+			reason = ReasonUnsupportedProtocol
+			msg = fmt.Sprintf("Protocol '%v' is not supported.", spec.Protocol)
+		}
+		if reason == "" && spec.Protocol == HTTPSProtocolType {
+			l.secrets, refMsg = appMgr.gatewayListenerSecrets(gw.Namespace, spec)
+			if refMsg != "" {
+				refReason = ReasonInvalidCertificateRef
+			}
+		}
+		if reason == "" && refReason == "" {
+			conflict, conflictMsg = gatewayListenerConflict(l, okByPort[spec.Port])
+			if conflict == "" {
+				okByPort[spec.Port] = append(okByPort[spec.Port], l)
+			}
+		}
+		l.ok = reason == "" && refReason == "" && conflict == ""
+
+		if reason == "" {
+			reason = ReasonAccepted
+		}
+		if refReason == "" {
+			refReason = ReasonResolvedRefs
+		}
+		conflicted := conflict != ""
+		if !conflicted {
+			conflict = ReasonNoConflicts
+		}
+		l.status.Conditions = []GatewayCondition{
+			NewGatewayCondition(ConditionAccepted, reason == ReasonAccepted, reason, msg, gen),
+			NewGatewayCondition(ConditionResolvedRefs, refReason == ReasonResolvedRefs,
+				refReason, refMsg, gen),
+			NewGatewayCondition(ConditionConflicted, conflicted, conflict, conflictMsg, gen),
+		}
+	}
+	return listeners
+}
+
+// gatewayListenerTLSError returns why the TLS mode of a listener is not
+// supported, empty when it is
+func gatewayListenerTLSError(l Listener) (string, string) {
+	mode := TLSModeTerminate
+	if nil != l.TLS && nil != l.TLS.Mode {
+		mode = *l.TLS.Mode
+	}
+	switch {
+	case l.Protocol == HTTPSProtocolType && mode != TLSModeTerminate:
+		return ReasonUnsupportedValue, "HTTPS listeners only support the Terminate TLS mode."
+	case l.Protocol == TLSProtocolType && mode != TLSModePassthrough:
+		return ReasonUnsupportedValue, "TLS listeners only support the Passthrough TLS mode."
+	}
+	return "", ""
+}
+
+// gatewayListenerSecrets returns the certificates of an HTTPS listener, and
+// why they do not resolve
+func (appMgr *Manager) gatewayListenerSecrets(
+	namespace string,
+	l Listener,
+) ([]*v1.Secret, string) {
+	if nil == l.TLS || 0 == len(l.TLS.CertificateRefs) {
+		return nil, "HTTPS listeners need a certificate."
+	}
+	var secrets []*v1.Secret
+	for _, ref := range l.TLS.CertificateRefs {
+		if (nil != ref.Group && *ref.Group != "") || (nil != ref.Kind && *ref.Kind != "Secret") {
+			return nil, fmt.Sprintf("Certificate '%v' is not a Secret.", ref.Name)
+		}
+		if nil != ref.Namespace && *ref.Namespace != namespace {
+			return nil, fmt.Sprintf("Certificate '%v/%v' is in another namespace.",
+				*ref.Namespace, ref.Name)
+		}
+		secret, err := appMgr.kubeClient.CoreV1().Secrets(namespace).
+			Get(ref.Name, metav1.GetOptions{})
+		if nil != err {
+			return nil, fmt.Sprintf("Secret '%v' has not been found.", ref.Name)
+		}
+		_, hasCert := secret.Data["tls.crt"]
+		_, hasKey := secret.Data["tls.key"]
+		if !hasCert || !hasKey {
+			return nil, fmt.Sprintf("Secret '%v' has no 'tls.crt' or 'tls.key'.", ref.Name)
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, ""
+}
+
+// gatewayListenerConflict returns the reason and message of the Conflicted
+// condition of a listener with the listeners before it on its port, empty
+// when there is no conflict
+func gatewayListenerConflict(l *gatewayListener, others []*gatewayListener) (string, string) {
+	hostname := func(l *gatewayListener) string {
+		if nil == l.Hostname {
+			return ""
+		}
+		return *l.Hostname
+	}
+	for _, other := range others {
+		switch {
+		case other.Protocol != l.Protocol:
+			return ReasonProtocolConflict, fmt.Sprintf(
+				"Listener '%v' uses port %d with protocol '%v'.", other.Name, l.Port, other.Protocol)
+		// There is no routing by SNI for TLS listeners
+		case l.Protocol == TLSProtocolType || l.Protocol == TCPProtocolType ||
+			hostname(other) == hostname(l):
+			return ReasonHostnameConflict, fmt.Sprintf(
+				"Listener '%v' uses port %d with the same hostname.", other.Name, l.Port)
+		}
+	}
+	return "", ""
+}
+
+// attachGatewayRoutes attaches the routes to the listeners of a Gateway
+// their parentRefs select, and records the Accepted condition of each
+// parentRef of the Gateway in the routes. Routes only attach to the
+// Gateways of their namespace.
+func attachGatewayRoutes(gw *Gateway, listeners []*gatewayListener, routes []*gatewayRoute) {
+	for _, rt := range routes {
+		for i, ref := range rt.parentRefs {
+			if (nil != ref.Group && *ref.Group != GatewayAPIGroup) ||
+				(nil != ref.Kind && *ref.Kind != GatewayKind) ||
+				(nil != ref.Namespace && *ref.Namespace != gw.Namespace) ||
+				ref.Name != gw.Name || rt.Namespace != gw.Namespace {
+				continue
+			}
+			reason, msg := attachGatewayRoute(rt, ref, listeners)
+			if reason == "" {
+				reason = ReasonAccepted
+			}
+			cond := NewGatewayCondition(
+				ConditionAccepted, reason == ReasonAccepted, reason, msg, rt.Generation)
+			rt.parents[i] = &cond
+		}
+	}
+}
+
+// attachGatewayRoute attaches a route to the listeners of a parentRef, and
+// returns why it does not attach to any
+func attachGatewayRoute(
+	rt *gatewayRoute,
+	ref ParentReference,
+	listeners []*gatewayListener,
+) (string, string) {
+	if rt.unsupported != "" {
+		return ReasonUnsupportedValue, rt.unsupported
+	}
+	reason := ReasonNoMatchingParent
+	msg := "No listener matches the parent reference."
+	attached := false
+	for _, l := range listeners {
+		if (nil != ref.SectionName && *ref.SectionName != l.Name) ||
+			(nil != ref.Port && *ref.Port != l.Port) || !l.ok {
+			continue
+		}
+		if gatewayListenerRouteKinds[l.Protocol] != rt.kind {
+			reason = ReasonNotAllowedByListeners
+			msg = fmt.Sprintf("Listener '%v' does not allow %v.", l.Name, rt.kind)
+			continue
+		}
+		hostnames, ok := GatewayListenerHostnames(l.Hostname, rt.hostnames)
+		if !ok {
+			reason = ReasonNoMatchingHostname
+			msg = fmt.Sprintf("No hostname matches listener '%v'.", l.Name)
+			continue
+		}
+		// The virtual server of a TLS or TCP listener has a single pool
+		if rt.kind != HTTPRouteKind && 0 != len(l.routes) {
+			reason = ReasonNotAllowedByListeners
+			msg = fmt.Sprintf("Listener '%v' already has a route.", l.Name)
+			continue
+		}
+		l.routes = append(l.routes, gatewayAttachment{route: rt, hostnames: hostnames})
+		l.status.AttachedRoutes++
+		attached = true
+	}
+	if attached {
+		return "", ""
+	}
+	return reason, msg
+}
+
+// gatewayRuleEntry is a match of an HTTPRoute rule for a hostname
+type gatewayRuleEntry struct {
+	route    *gatewayRoute
+	rule     *HTTPRouteRule
+	ruleIdx  int
+	matchIdx int
+	match    HTTPRouteMatch
+	host     string
+	// Scheme of the listener
+	scheme string
+}
+
+func (e *gatewayRuleEntry) path() (string, string) {
+	pathType, path := PathMatchPathPrefix, "/"
+	if nil != e.match.Path {
+		if nil != e.match.Path.Type {
+			pathType = *e.match.Path.Type
+		}
+		if nil != e.match.Path.Value {
+			path = *e.match.Path.Value
+		}
+	}
+	return pathType, path
+}
+
+// gatewayRuleEntryLess orders the entries by precedence: exact hostnames,
+// then longest wildcard hostnames, exact paths, then longest prefixes, most
+// header matches, most query parameter matches, then oldest routes
+func gatewayRuleEntryLess(a, b *gatewayRuleEntry) bool {
+	hostRank := func(host string) int {
+		switch {
+		case host == "":
+			return 2
+		case strings.HasPrefix(host, "*."):
+			return 1
+		}
+		return 0
+	}
+	if ra, rb := hostRank(a.host), hostRank(b.host); ra != rb {
+		return ra < rb
+	}
+	if len(a.host) != len(b.host) {
+		return len(a.host) > len(b.host)
+	}
+	aType, aPath := a.path()
+	bType, bPath := b.path()
+	if aType != bType {
+		return aType == PathMatchExact
+	}
+	if len(aPath) != len(bPath) {
+		return len(aPath) > len(bPath)
+	}
+	if len(a.match.Headers) != len(b.match.Headers) {
+		return len(a.match.Headers) > len(b.match.Headers)
+	}
+	if len(a.match.QueryParams) != len(b.match.QueryParams) {
+		return len(a.match.QueryParams) > len(b.match.QueryParams)
+	}
+	if !a.route.CreationTimestamp.Equal(&b.route.CreationTimestamp) {
+		return a.route.CreationTimestamp.Before(&b.route.CreationTimestamp)
+	}
+	if a.route.Name != b.route.Name {
+		return a.route.Name < b.route.Name
+	}
+	if a.ruleIdx != b.ruleIdx {
+		return a.ruleIdx < b.ruleIdx
+	}
+	return a.matchIdx < b.matchIdx
+}
+
+// setGatewayHTTPRules creates the policy of the HTTPRoutes attached to the
+// listeners of a virtual server. Rules with weighted backends are selected
+// by the A/B deployment iRule instead.
+func (appMgr *Manager) setGatewayHTTPRules(
+	rsCfg *ResourceConfig,
+	namespace string,
+	listeners []*gatewayListener,
+	svcIndexer cache.Indexer,
+	dgMap InternalDataGroupMap,
+) {
+	var entries []*gatewayRuleEntry
+	seen := make(map[string]bool)
+	for _, l := range listeners {
+		scheme := "http"
+		if l.Protocol == HTTPSProtocolType {
+			scheme = "https"
+		}
+		for _, att := range l.routes {
+			hosts := att.hostnames
+			if 0 == len(hosts) {
+				hosts = []string{""}
+			}
+			for ri := range att.route.httpRules {
+				rule := &att.route.httpRules[ri]
+				matches := rule.Matches
+				if 0 == len(matches) {
+					matches = []HTTPRouteMatch{{}}
+				}
+				for mi, match := range matches {
+					for _, host := range hosts {
+						// Routes attach to each listener they match
+						key := fmt.Sprintf("%s/%s/%d/%d/%s",
+							att.route.kind, att.route.Name, ri, mi, host)
+						if seen[key] {
+							continue
+						}
+						seen[key] = true
+						entries = append(entries, &gatewayRuleEntry{
+							route:    att.route,
+							rule:     rule,
+							ruleIdx:  ri,
+							matchIdx: mi,
+							match:    match,
+							host:     host,
+							scheme:   scheme,
+						})
+					}
+				}
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return gatewayRuleEntryLess(entries[i], entries[j])
+	})
+
+	var rules Rules
+	weighted := false
+	for _, e := range entries {
+		pathType, path := e.path()
+		if len(e.rule.BackendRefs) > 1 {
+			var pools []string
+			var weights []int
+			for _, ref := range e.rule.BackendRefs {
+				poolName := addGatewayPool(rsCfg, namespace, ref.BackendRef, svcIndexer)
+				if poolName == "" {
+					continue
+				}
+				weight := 1
+				if nil != ref.Weight {
+					weight = int(*ref.Weight)
+				}
+				pools = append(pools, poolName)
+				weights = append(weights, weight)
+			}
+			key := strings.ToLower(e.host) + strings.TrimRight(path, "/")
+			updateDataGroup(dgMap, AbDeploymentDgName,
+				DEFAULT_PARTITION, namespace, key, abDeploymentDgValue(pools, weights))
+			weighted = true
+			continue
+		}
+
+		poolName := ""
+		if 1 == len(e.rule.BackendRefs) {
+			poolName = addGatewayPool(rsCfg, namespace, e.rule.BackendRefs[0].BackendRef, svcIndexer)
+		}
+		uri := e.host + path
+		if pathType == PathMatchExact {
+			uri = e.host
+		}
+		ruleName := fmt.Sprintf("%s_%s_%d", strings.ToLower(e.route.kind), e.route.Name, len(rules))
+		rl, err := CreateRule(uri, poolName, rsCfg.Virtual.Partition, ruleName)
+		if nil != err {
+			log.Warningf("[CORE] Error configuring rule for %v '%v': %v",
+				e.route.kind, e.route.Name, err)
+			continue
+		}
+		if pathType == PathMatchExact {
+			rl.Conditions = append(rl.Conditions, &Condition{
+				Name:    strconv.Itoa(len(rl.Conditions)),
+				Equals:  true,
+				HTTPURI: true,
+				Path:    true,
+				Request: true,
+				Values:  []string{path},
+			})
+		}
+		for _, h := range e.match.Headers {
+			rl.Conditions = append(rl.Conditions, &Condition{
+				Name:       strconv.Itoa(len(rl.Conditions)),
+				Equals:     true,
+				HTTPHeader: true,
+				Request:    true,
+				Tmname:     h.Name,
+				Values:     []string{h.Value},
+			})
+		}
+		for _, q := range e.match.QueryParams {
+			rl.Conditions = append(rl.Conditions, &Condition{
+				Name:           strconv.Itoa(len(rl.Conditions)),
+				Equals:         true,
+				HTTPURI:        true,
+				QueryParameter: true,
+				Request:        true,
+				Tmname:         q.Name,
+				Values:         []string{q.Value},
+			})
+		}
+		// Requests matching rules without backend are rejected
+		if poolName == "" {
+			rl.Actions = nil
+		}
+		for _, f := range e.rule.Filters {
+			if f.Type == RequestRedirectFilter && nil != f.RequestRedirect {
+				rl.Actions = []*Action{{
+					HttpReply: true,
+					Location:  gatewayRedirectLocation(f.RequestRedirect, e.scheme),
+					Redirect:  true,
+					Request:   true,
+				}}
+				break
+			}
+			rl.Actions = append(rl.Actions, gatewayFilterActions(f)...)
+		}
+		for i, action := range rl.Actions {
+			action.Name = strconv.Itoa(i)
+		}
+		rl.Ordinal = len(rules)
+		rules = append(rules, rl)
+	}
+
+	if weighted {
+		appMgr.addIRule(
+			AbDeploymentPathIRuleName, DEFAULT_PARTITION, appMgr.abDeploymentPathIRule())
+		appMgr.addInternalDataGroup(AbDeploymentDgName, DEFAULT_PARTITION)
+		rsCfg.Virtual.AddIRule(JoinBigipPath(DEFAULT_PARTITION, AbDeploymentPathIRuleName))
+	}
+	if 0 != len(rules) {
+		policy := CreatePolicy(rules, rsCfg.Virtual.Name, rsCfg.Virtual.Partition)
+		rsCfg.SetPolicy(*policy)
+	}
+}
+
+// gatewayRedirectLocation returns the location of a redirect filter, a Tcl
+// expression keeping the parts of the request the filter does not change
+func gatewayRedirectLocation(r *HTTPRequestRedirectFilter, scheme string) string {
+	// Keep the port of the request unless the filter changes it
+	host := "[HTTP::host]"
+	if nil != r.Scheme || nil != r.Port {
+		host = `[getfield [HTTP::host] ":" 1]`
+	}
+	if nil != r.Scheme {
+		scheme = *r.Scheme
+	}
+	if nil != r.Hostname {
+		host = *r.Hostname
+	}
+	if nil != r.Port {
+		host = fmt.Sprintf("%s:%d", host, *r.Port)
+	}
+	path := "[HTTP::uri]"
+	if nil != r.Path && nil != r.Path.ReplaceFullPath {
+		path = *r.Path.ReplaceFullPath
+	}
+	return fmt.Sprintf("tcl:%s://%s%s", scheme, host, path)
+}
+
+// gatewayFilterActions returns the policy actions of a header modifier or
+// URL rewrite filter
+func gatewayFilterActions(f HTTPRouteFilter) []*Action {
+	var actions []*Action
+	if m := f.RequestHeaderModifier; f.Type == RequestHeaderModifierFilter && nil != m {
+		for _, h := range m.Set {
+			actions = append(actions, &Action{
+				HTTPHeader: true, Replace: true, Request: true, Tmname: h.Name, Value: h.Value,
+			})
+		}
+		for _, h := range m.Add {
+			actions = append(actions, &Action{
+				HTTPHeader: true, Insert: true, Request: true, Tmname: h.Name, Value: h.Value,
+			})
+		}
+		for _, name := range m.Remove {
+			actions = append(actions, &Action{
+				HTTPHeader: true, Remove: true, Request: true, Tmname: name,
+			})
+		}
+	}
+	if r := f.URLRewrite; f.Type == URLRewriteFilter && nil != r {
+		if nil != r.Hostname {
+			actions = append(actions, &Action{
+				HTTPHost: true, Replace: true, Request: true, Value: *r.Hostname,
+			})
+		}
+		if nil != r.Path && nil != r.Path.ReplaceFullPath {
+			actions = append(actions, &Action{
+				HTTPURI: true, Replace: true, Request: true, Path: *r.Path.ReplaceFullPath,
+			})
+		}
+	}
+	return actions
+}
+
+// addGatewayPool adds the pool of a route backend to a virtual server, and
+// returns its name, empty when the backend does not resolve
+func addGatewayPool(
+	rsCfg *ResourceConfig,
+	namespace string,
+	ref BackendRef,
+	svcIndexer cache.Indexer,
+) string {
+	if reason, _ := backendRefError(namespace, ref, svcIndexer); reason != "" {
+		return ""
+	}
+	poolName := FormatGatewayPoolName(namespace, ref.Name, *ref.Port)
+	for _, pool := range rsCfg.Pools {
+		if pool.Name == poolName {
+			return poolName
+		}
+	}
+	rsCfg.Pools = append(rsCfg.Pools, Pool{
+		Name:        poolName,
+		Partition:   rsCfg.Virtual.Partition,
+		Balance:     DEFAULT_BALANCE,
+		ServiceName: ref.Name,
+		ServicePort: *ref.Port,
+	})
+	return poolName
+}
+
+// setGatewayListenerProfile adds the client SSL profile of a certificate of
+// an HTTPS listener to a virtual server
+func (appMgr *Manager) setGatewayListenerProfile(
+	rsCfg *ResourceConfig,
+	secret *v1.Secret,
+	stats *vsSyncStats,
+) {
+	err, updated := appMgr.createSecretSslProfile(rsCfg, secret)
+	if nil != err {
+		log.Warningf("[CORE] %v", err)
+		return
+	}
+	if updated {
+		stats.cpUpdated += 1
+	}
+	rsCfg.Virtual.AddOrUpdateProfile(ProfileRef{
+		Name:      secret.ObjectMeta.Name,
+		Partition: rsCfg.Virtual.Partition,
+		Context:   CustomProfileClient,
+		Namespace: secret.ObjectMeta.Namespace,
+	})
+}
+
+// saveGatewayConfigs replaces the virtual servers of the Gateways of the
+// namespace with cfgs, and returns the number of virtual servers found and
+// updated. Each is saved under the keys of the services of its pools, or of
+// the namespace when it has none.
+func (appMgr *Manager) saveGatewayConfigs(
+	namespace string,
+	cfgs map[string]*ResourceConfig,
+	appInf *appInformer,
+) (int, int) {
+	found := 0
+	for _, rsCfg := range cfgs {
+		for i, pool := range rsCfg.Pools {
+			obj, svcFound, _ := appInf.svcInformer.GetIndexer().GetByKey(
+				namespace + "/" + pool.ServiceName)
+			if !svcFound {
+				continue
+			}
+			svcKey := ServiceKey{
+				Namespace:   namespace,
+				ServiceName: pool.ServiceName,
+				ServicePort: pool.ServicePort,
+			}
+			if appMgr.IsNodePort() {
+				appMgr.updatePoolMembersForNodePort(obj.(*v1.Service), svcKey, rsCfg, i)
+			} else {
+				appMgr.updatePoolMembersForCluster(obj.(*v1.Service), svcKey, rsCfg, appInf, i)
+			}
+			found += 1
+		}
+	}
+
+	appMgr.resources.Lock()
+	defer appMgr.resources.Unlock()
+	oldKeys := make(map[string][]ServiceKey)
+	appMgr.resources.ForEach(func(key ServiceKey, cfg *ResourceConfig) {
+		if key.Namespace == namespace && cfg.MetaData.ResourceType == ResourceTypeGateway {
+			oldKeys[cfg.GetName()] = append(oldKeys[cfg.GetName()], key)
+		}
+	})
+	updated := 0
+	for name, keys := range oldKeys {
+		if _, ok := cfgs[name]; ok {
+			continue
+		}
+		for _, key := range keys {
+			appMgr.resources.Delete(key, name)
+		}
+		updated += 1
+	}
+	for name, rsCfg := range cfgs {
+		keys := []ServiceKey{{Namespace: namespace}}
+		if 0 != len(rsCfg.Pools) {
+			keys = nil
+			for _, pool := range rsCfg.Pools {
+				keys = append(keys, ServiceKey{
+					Namespace:   namespace,
+					ServiceName: pool.ServiceName,
+					ServicePort: pool.ServicePort,
+				})
+			}
+		}
+		oldCfg, exists := appMgr.resources.GetByName(name)
+		if !exists || !reflect.DeepEqual(oldCfg, rsCfg) {
+			updated += 1
+		}
+		for _, key := range oldKeys[name] {
+			if !containsServiceKey(keys, key) {
+				appMgr.resources.Delete(key, name)
+			}
+		}
+		for _, key := range keys {
+			appMgr.resources.Assign(key, name, rsCfg)
+		}
+	}
+	return found, updated
+}
+
+func containsServiceKey(keys []ServiceKey, key ServiceKey) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// gatewayRouteStatus returns the status of a route with the parents of our
+// Gateways, keeping those of the other controllers
+func (appMgr *Manager) gatewayRouteStatus(rt *gatewayRoute) *RouteStatus {
+	status := &RouteStatus{}
+	for _, parent := range rt.status.Parents {
+		if parent.ControllerName != appMgr.gatewayControllerName {
+			status.Parents = append(status.Parents, parent)
+		}
+	}
+	for i, ref := range rt.parentRefs {
+		accepted, ok := rt.parents[i]
+		if !ok {
+			continue
+		}
+		resolved := NewGatewayCondition(
+			ConditionResolvedRefs, true, ReasonResolvedRefs, "", rt.Generation)
+		if nil != rt.unresolved {
+			resolved = *rt.unresolved
+		}
+		status.Parents = append(status.Parents, RouteParentStatus{
+			ParentRef:      ref,
+			ControllerName: appMgr.gatewayControllerName,
+			Conditions:     []GatewayCondition{*accepted, resolved},
+		})
+	}
+	return status
+}
+
+// updateGatewayAPIStatus writes the status of a Gateway API object when it
+// changes
+func (appMgr *Manager) updateGatewayAPIStatus(
+	resource schema.GroupVersionResource,
+	obj *unstructured.Unstructured,
+	status interface{},
+) {
+	updated, changed, err := SetGatewayAPIStatus(obj, status)
+	if nil != err {
+		log.Warningf("[CORE] Unable to set the status of %v '%v': %v",
+			obj.GetKind(), obj.GetName(), err)
+		return
+	}
+	if !changed {
+		return
+	}
+	_, err = appMgr.dynamicClient.Resource(resource).Namespace(obj.GetNamespace()).
+		UpdateStatus(updated, metav1.UpdateOptions{})
+	if nil != err {
+		log.Warningf("[CORE] Error when setting the status of %v '%v': %v",
+			obj.GetKind(), obj.GetName(), err)
+	}
+}
+
+// acceptGatewayClass sets the Accepted condition of the GatewayClasses of
+// CIS
+func (appMgr *Manager) acceptGatewayClass(obj interface{}) {
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	var class GatewayClass
+	if err := FromGatewayAPIObject(u, &class); nil != err {
+		log.Warningf("[CORE] Unable to parse GatewayClass: %v", err)
+		return
+	}
+	if class.Spec.ControllerName != appMgr.gatewayControllerName {
+		return
+	}
+	appMgr.updateGatewayAPIStatus(GatewayClassResource, u, &GatewayClassStatus{
+		Conditions: []GatewayCondition{NewGatewayCondition(
+			ConditionAccepted, true, ReasonAccepted, "", class.Generation)},
+	})
+}
+
+// enqueueGatewayClass queues the namespaces of the Gateways of a
+// GatewayClass
+func (appMgr *Manager) enqueueGatewayClass(obj interface{}) {
+	var class GatewayClass
+	if err := FromGatewayAPIObject(obj, &class); nil != err {
+		log.Warningf("[CORE] Unable to parse GatewayClass: %v", err)
+		return
+	}
+	appMgr.informersMutex.Lock()
+	namespaces := make(map[string]bool)
+	for _, appInf := range appMgr.appInformers {
+		if nil == appInf.gatewayInformer {
+			continue
+		}
+		for _, gwObj := range appInf.gatewayInformer.GetIndexer().List() {
+			var gw Gateway
+			if err := FromGatewayAPIObject(gwObj, &gw); nil != err {
+				continue
+			}
+			if gw.Spec.GatewayClassName == class.Name {
+				namespaces[gw.Namespace] = true
+			}
+		}
+	}
+	appMgr.informersMutex.Unlock()
+	var keys []*serviceQueueKey
+	for namespace := range namespaces {
+		keys = append(keys, &serviceQueueKey{Namespace: namespace, Name: class.Name})
+	}
+	appMgr.enqueueKeys("GatewayClass", keys)
+}
+
+func (appMgr *Manager) enqueueGatewayAPIObject(kind string, obj interface{}) {
+	if ok, keys := appMgr.checkValidGatewayAPIObject(obj); ok {
+		appMgr.enqueueKeys(kind, keys)
+	}
+}
+
+// newGatewayClassInformer creates the informer of the GatewayClasses. The
+// Gateways of a GatewayClass are synced when it changes.
+func (appMgr *Manager) newGatewayClassInformer() {
+	appMgr.gwClassInformer = NewGatewayAPIInformer(
+		appMgr.dynamicClient, GatewayClassResource, "", 0)
+	appMgr.gwClassInformer.AddEventHandler(
+		bigIPPrometheus.CountInformerEvents("GatewayClass", &cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) {
+				appMgr.acceptGatewayClass(obj)
+				appMgr.enqueueGatewayClass(obj)
+			},
+			UpdateFunc: func(old, cur interface{}) {
+				appMgr.acceptGatewayClass(cur)
+				appMgr.enqueueGatewayClass(cur)
+			},
+			DeleteFunc: func(obj interface{}) { appMgr.enqueueGatewayClass(obj) },
+		}),
+	)
+}
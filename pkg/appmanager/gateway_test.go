@@ -0,0 +1,348 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package appmanager
+
+import (
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent/cccl"
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/test"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newGatewayAPIObject(kind, namespace, name string, spec map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name, "generation": int64(1)}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": GatewayAPIGroup + "/v1",
+		"kind":       kind,
+		"metadata":   metadata,
+		"spec":       spec,
+	}}
+}
+
+func newGatewayService(name string, port, nodePort int32) *v1.Service {
+	return test.NewService(name, "1", "default", v1.ServiceTypeNodePort,
+		[]v1.ServicePort{{
+			Port:       port,
+			NodePort:   nodePort,
+			TargetPort: intstr.FromInt(int(port)),
+		}})
+}
+
+// gatewayConditions returns the status of the conditions of a status
+// object by type
+func gatewayConditions(conditions interface{}) map[string]string {
+	statuses := make(map[string]string)
+	list, _ := conditions.([]interface{})
+	for _, c := range list {
+		cond := c.(map[string]interface{})
+		statuses[cond["type"].(string)] = cond["status"].(string) + "/" + cond["reason"].(string)
+	}
+	return statuses
+}
+
+var _ = Describe("Gateway API Tests", func() {
+	var mockMgr *mockAppManager
+	var dynamicClient *dynamicfake.FakeDynamicClient
+	var gwClass, gw, fooRoute, unsupportedRoute, redirectRoute *unstructured.Unstructured
+
+	syncGatewayAPIObject := func(informer cache.SharedIndexInformer, obj *unstructured.Unstructured) {
+		Expect(informer.GetStore().Update(obj)).To(BeNil())
+		ok, keys := mockMgr.appMgr.checkValidGatewayAPIObject(obj)
+		Expect(ok).To(BeTrue())
+		for _, key := range keys {
+			Expect(mockMgr.appMgr.syncVirtualServer(*key)).To(BeNil())
+		}
+	}
+	getStatus := func(gvr schema.GroupVersionResource, name string) map[string]interface{} {
+		obj, err := dynamicClient.Resource(gvr).Namespace("default").Get(name, metav1.GetOptions{})
+		Expect(err).To(BeNil())
+		status, _ := obj.Object["status"].(map[string]interface{})
+		return status
+	}
+	routeParentConditions := func(name string) map[string]string {
+		status := getStatus(HTTPRouteResource, name)
+		Expect(status).NotTo(BeNil())
+		parents := status["parents"].([]interface{})
+		Expect(parents).To(HaveLen(1))
+		return gatewayConditions(parents[0].(map[string]interface{})["conditions"])
+	}
+
+	BeforeEach(func() {
+		RegisterBigIPSchemaTypes()
+		gwClass = newGatewayAPIObject("GatewayClass", "", "f5", map[string]interface{}{
+			"controllerName": "f5.com/k8s-bigip-ctlr",
+		})
+		gw = newGatewayAPIObject("Gateway", "default", "gw", map[string]interface{}{
+			"gatewayClassName": "f5",
+			"addresses": []interface{}{
+				map[string]interface{}{"type": "IPAddress", "value": "10.1.1.1"},
+			},
+			"listeners": []interface{}{
+				map[string]interface{}{
+					"name": "http", "port": int64(80), "protocol": "HTTP", "hostname": "*.example.com",
+				},
+				map[string]interface{}{"name": "tcp", "port": int64(80), "protocol": "TCP"},
+				map[string]interface{}{"name": "udp", "port": int64(53), "protocol": "UDP"},
+			},
+		})
+		parentRefs := []interface{}{map[string]interface{}{"name": "gw"}}
+		fooRoute = newGatewayAPIObject("HTTPRoute", "default", "foo", map[string]interface{}{
+			"parentRefs": parentRefs,
+			"hostnames":  []interface{}{"foo.example.com"},
+			"rules": []interface{}{
+				map[string]interface{}{
+					"matches": []interface{}{map[string]interface{}{
+						"path": map[string]interface{}{"type": "Exact", "value": "/login"},
+						"headers": []interface{}{
+							map[string]interface{}{"name": "x-env", "value": "canary"},
+						},
+						"queryParams": []interface{}{
+							map[string]interface{}{"name": "debug", "value": "1"},
+						},
+					}},
+					"filters": []interface{}{map[string]interface{}{
+						"type": "RequestHeaderModifier",
+						"requestHeaderModifier": map[string]interface{}{
+							"set": []interface{}{map[string]interface{}{"name": "x-gw", "value": "f5"}},
+						},
+					}},
+					"backendRefs": []interface{}{
+						map[string]interface{}{"name": "foo", "port": int64(80)},
+					},
+				},
+				map[string]interface{}{
+					"backendRefs": []interface{}{
+						map[string]interface{}{"name": "foo", "port": int64(80), "weight": int64(1)},
+						map[string]interface{}{"name": "bar", "port": int64(80), "weight": int64(3)},
+					},
+				},
+			},
+		})
+		unsupportedRoute = newGatewayAPIObject("HTTPRoute", "default", "unsupported", map[string]interface{}{
+			"parentRefs": parentRefs,
+			"rules": []interface{}{map[string]interface{}{
+				"matches": []interface{}{map[string]interface{}{"method": "GET"}},
+			}},
+		})
+		redirectRoute = newGatewayAPIObject("HTTPRoute", "default", "redirect", map[string]interface{}{
+			"parentRefs": parentRefs,
+			"hostnames":  []interface{}{"old.example.com"},
+			"rules": []interface{}{map[string]interface{}{
+				"filters": []interface{}{map[string]interface{}{
+					"type": "RequestRedirect",
+					"requestRedirect": map[string]interface{}{
+						"hostname": "new.example.com",
+					},
+				}},
+				"backendRefs": []interface{}{
+					map[string]interface{}{"name": "missing", "port": int64(80)},
+				},
+			}},
+		})
+
+		fakeClient := fake.NewSimpleClientset()
+		var resources []metav1.APIResource
+		for _, name := range []string{"gatewayclasses", "gateways", "httproutes"} {
+			resources = append(resources, metav1.APIResource{Name: name})
+		}
+		fakeClient.Resources = []*metav1.APIResourceList{{
+			GroupVersion: GatewayAPIGroup + "/v1",
+			APIResources: resources,
+		}}
+		dynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+			gwClass, fooRoute, unsupportedRoute, redirectRoute)
+		// The fake client would guess the resource of Gateways wrong
+		_, err := dynamicClient.Resource(GatewayResource).Namespace("default").Create(
+			gw, metav1.CreateOptions{})
+		Expect(err).To(BeNil())
+
+		mockMgr = newMockAppManager(&Params{
+			KubeClient:            fakeClient,
+			DynamicClient:         dynamicClient,
+			restClient:            test.CreateFakeHTTPClient(),
+			ProcessAgentLabels:    func(m map[string]string, n, ns string) bool { return true },
+			IsNodePort:            true,
+			broadcasterFunc:       NewFakeEventBroadcaster,
+			ManageGateways:        true,
+			GatewayControllerName: "f5.com/k8s-bigip-ctlr",
+		})
+		mockMgr.appMgr.AgentCIS, _ = agent.CreateAgent(agent.CCCLAgent)
+		mockMgr.appMgr.AgentCIS.Init(&cccl.Params{ConfigWriter: &test.MockWriter{
+			FailStyle: test.Success,
+			Sections:  make(map[string]interface{}),
+		}})
+		Expect(mockMgr.startNonLabelMode([]string{"default"})).To(BeNil())
+
+		node := test.NewNode("node0", "0", false, []v1.NodeAddress{
+			{Type: "ExternalIP", Address: "127.0.0.1"}}, []v1.Taint{})
+		mockMgr.processNodeUpdate([]v1.Node{*node}, nil)
+		Expect(mockMgr.addService(newGatewayService("foo", 80, 30001))).To(BeTrue())
+		Expect(mockMgr.addService(newGatewayService("bar", 80, 30002))).To(BeTrue())
+	})
+	AfterEach(func() {
+		mockMgr.shutdown()
+	})
+
+	It("watches only the resources served", func() {
+		appInf, _ := mockMgr.appMgr.getNamespaceInformer("default")
+		Expect(mockMgr.appMgr.gwClassInformer).NotTo(BeNil())
+		Expect(appInf.gatewayInformer).NotTo(BeNil())
+		Expect(appInf.httpRouteInformer).NotTo(BeNil())
+		Expect(appInf.tlsRouteInformer).To(BeNil())
+		Expect(appInf.tcpRouteInformer).To(BeNil())
+	})
+
+	It("configures the listeners and routes of Gateways", func() {
+		appInf, _ := mockMgr.appMgr.getNamespaceInformer("default")
+		Expect(mockMgr.appMgr.gwClassInformer.GetStore().Add(gwClass)).To(BeNil())
+		mockMgr.appMgr.acceptGatewayClass(gwClass)
+		obj, err := dynamicClient.Resource(GatewayClassResource).Get("f5", metav1.GetOptions{})
+		Expect(err).To(BeNil())
+		Expect(gatewayConditions(obj.Object["status"].(map[string]interface{})["conditions"])).To(
+			Equal(map[string]string{ConditionAccepted: "True/Accepted"}))
+
+		for _, route := range []*unstructured.Unstructured{fooRoute, unsupportedRoute, redirectRoute} {
+			syncGatewayAPIObject(appInf.httpRouteInformer, route)
+		}
+		Expect(mockMgr.resources().GetAllResources()).To(BeEmpty(), "Routes need a Gateway.")
+		syncGatewayAPIObject(appInf.gatewayInformer, gw)
+
+		// Listeners on port 80 conflict, UDP is not supported
+		Expect(mockMgr.resources().GetAllResources()).To(HaveLen(1))
+		rsCfg, ok := mockMgr.resources().GetByName("gateway_default_gw_80")
+		Expect(ok).To(BeTrue())
+		Expect(rsCfg.MetaData.ResourceType).To(Equal(ResourceTypeGateway))
+		Expect(rsCfg.Virtual.Destination).To(Equal("/velcro/10.1.1.1:80"))
+		Expect(rsCfg.Pools).To(HaveLen(2))
+		Expect(rsCfg.Pools[0].Name).To(Equal("gateway_default_foo_80"))
+		Expect(rsCfg.Pools[0].Members).To(Equal([]Member{
+			{Address: "127.0.0.1", Port: 30001, Session: "user-enabled"}}))
+		Expect(rsCfg.Pools[1].Name).To(Equal("gateway_default_bar_80"))
+		Expect(rsCfg.Pools[1].Members).To(Equal([]Member{
+			{Address: "127.0.0.1", Port: 30002, Session: "user-enabled"}}))
+
+		// The exact path with header and query parameter matches comes
+		// first, the weighted backends are selected by the A/B deployment
+		// iRule
+		Expect(rsCfg.Policies).To(HaveLen(1))
+		Expect(rsCfg.Virtual.IRules).To(ContainElement(
+			JoinBigipPath(DEFAULT_PARTITION, AbDeploymentPathIRuleName)))
+		rules := rsCfg.Policies[0].Rules
+		Expect(rules).To(HaveLen(2))
+		Expect(rules[0].Conditions).To(HaveLen(4))
+		Expect(rules[0].Conditions[0].Values).To(Equal([]string{"foo.example.com"}))
+		Expect(rules[0].Conditions[1].Path).To(BeTrue())
+		Expect(rules[0].Conditions[1].Values).To(Equal([]string{"/login"}))
+		Expect(rules[0].Conditions[2].HTTPHeader).To(BeTrue())
+		Expect(rules[0].Conditions[2].Tmname).To(Equal("x-env"))
+		Expect(*rules[0].Conditions[3]).To(Equal(Condition{
+			Name: "3", Equals: true, HTTPURI: true, QueryParameter: true, Request: true,
+			Tmname: "debug", Values: []string{"1"},
+		}))
+		Expect(rules[0].Actions).To(HaveLen(2))
+		Expect(rules[0].Actions[0].Pool).To(Equal("/velcro/gateway_default_foo_80"))
+		Expect(*rules[0].Actions[1]).To(Equal(Action{
+			Name: "1", HTTPHeader: true, Replace: true, Request: true, Tmname: "x-gw", Value: "f5",
+		}))
+		Expect(rules[1].Conditions[0].Values).To(Equal([]string{"old.example.com"}))
+		Expect(rules[1].Actions).To(HaveLen(1))
+		Expect(rules[1].Actions[0].Redirect).To(BeTrue())
+		Expect(rules[1].Actions[0].Location).To(Equal("tcl:http://new.example.com[HTTP::uri]"))
+
+		dg := mockMgr.appMgr.intDgMap[NameRef{Name: AbDeploymentDgName, Partition: DEFAULT_PARTITION}]
+		Expect(dg["default"].Records).To(Equal(InternalDataGroupRecords{{
+			Name: "foo.example.com",
+			Data: "gateway_default_foo_80,0.250;gateway_default_bar_80,1.000",
+		}}))
+
+		status := getStatus(GatewayResource, "gw")
+		Expect(gatewayConditions(status["conditions"])).To(Equal(map[string]string{
+			ConditionAccepted:   "True/Accepted",
+			ConditionProgrammed: "True/Programmed",
+		}))
+		Expect(status["addresses"]).To(Equal([]interface{}{
+			map[string]interface{}{"type": "IPAddress", "value": "10.1.1.1"}}))
+		listeners := status["listeners"].([]interface{})
+		Expect(listeners).To(HaveLen(3))
+		http := listeners[0].(map[string]interface{})
+		Expect(http["attachedRoutes"]).To(Equal(int64(2)))
+		Expect(gatewayConditions(http["conditions"])).To(Equal(map[string]string{
+			ConditionAccepted:     "True/Accepted",
+			ConditionResolvedRefs: "True/ResolvedRefs",
+			ConditionConflicted:   "False/NoConflicts",
+			ConditionProgrammed:   "True/Programmed",
+		}))
+		Expect(gatewayConditions(listeners[1].(map[string]interface{})["conditions"])).To(
+			HaveKeyWithValue(ConditionConflicted, "True/ProtocolConflict"))
+		Expect(gatewayConditions(listeners[2].(map[string]interface{})["conditions"])).To(
+			HaveKeyWithValue(ConditionAccepted, "False/UnsupportedProtocol"))
+
+		Expect(routeParentConditions("foo")).To(Equal(map[string]string{
+			ConditionAccepted:     "True/Accepted",
+			ConditionResolvedRefs: "True/ResolvedRefs",
+		}))
+		Expect(routeParentConditions("unsupported")).To(
+			HaveKeyWithValue(ConditionAccepted, "False/UnsupportedValue"))
+		Expect(routeParentConditions("redirect")).To(Equal(map[string]string{
+			ConditionAccepted:     "True/Accepted",
+			ConditionResolvedRefs: "False/BackendNotFound",
+		}))
+
+		// Routes of Gateways of other controllers are left as they are
+		Expect(appInf.gatewayInformer.GetStore().Delete(gw)).To(BeNil())
+		other := gw.DeepCopy()
+		other.SetName("other")
+		other.Object["spec"].(map[string]interface{})["gatewayClassName"] = "other"
+		syncGatewayAPIObject(appInf.gatewayInformer, other)
+		Expect(mockMgr.resources().GetAllResources()).To(BeEmpty())
+		Expect(mockMgr.appMgr.intDgMap).To(BeEmpty())
+	})
+
+	It("reports the matches it does not support", func() {
+		method := "GET"
+		regex := "RegularExpression"
+		exact := QueryMatchExact
+		unsupported := func(m HTTPRouteMatch) string {
+			return gatewayRouteUnsupported(&gatewayRoute{
+				kind:      HTTPRouteKind,
+				httpRules: []HTTPRouteRule{{Matches: []HTTPRouteMatch{m}}},
+			})
+		}
+		Expect(unsupported(HTTPRouteMatch{Method: &method})).To(
+			Equal("method matches are not supported"))
+		Expect(unsupported(HTTPRouteMatch{QueryParams: []HTTPQueryParamMatch{
+			{Type: &regex, Name: "id", Value: "[0-9]+"}}})).To(
+			Equal("RegularExpression query parameter matches are not supported"))
+		Expect(unsupported(HTTPRouteMatch{QueryParams: []HTTPQueryParamMatch{
+			{Type: &exact, Name: "debug", Value: "1"}}})).To(BeEmpty())
+	})
+})
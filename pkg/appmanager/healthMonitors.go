@@ -23,14 +23,11 @@ import (
 	"strings"
 
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
-
-	"k8s.io/api/extensions/v1beta1"
 )
 
 func (appMgr *Manager) assignHealthMonitorsByPath(
 	rsName string,
-	ing *v1beta1.Ingress, // used in Ingress case for logging events
+	ing *Ingress, // used in Ingress case for logging events
 	rulesMap HostToPathMap,
 	monitors AnnotationHealthMonitors,
 ) error {
@@ -106,7 +103,7 @@ func (appMgr *Manager) assignMonitorToPool(
 
 func (appMgr *Manager) notifyUnusedHealthMonitorRules(
 	rsName string,
-	ing *v1beta1.Ingress,
+	ing *Ingress,
 	htpMap HostToPathMap,
 ) {
 	for _, paths := range htpMap {
@@ -125,7 +122,7 @@ func (appMgr *Manager) handleSingleServiceHealthMonitors(
 	rsName,
 	poolName string,
 	cfg *ResourceConfig,
-	ing *v1beta1.Ingress,
+	ing *Ingress,
 	monitors AnnotationHealthMonitors,
 ) {
 	// Setup the rule-to-pool map from the ingress
@@ -161,7 +158,7 @@ func (appMgr *Manager) handleSingleServiceHealthMonitors(
 func (appMgr *Manager) handleMultiServiceHealthMonitors(
 	rsName string,
 	cfg *ResourceConfig,
-	ing *v1beta1.Ingress,
+	ing *Ingress,
 	monitors AnnotationHealthMonitors,
 ) {
 	// Setup the rule-to-pool map from the ingress
@@ -118,13 +118,14 @@ var _ = Describe("Health Monitor Tests", func() {
 			hm.Timeout = 5
 
 			Expect(hm).To(MatchAllFields(Fields{
-				"Name":      Equal("svc"),
-				"Partition": Equal("f5"),
-				"Interval":  Equal(10),
-				"Type":      Equal("http"),
-				"Send":      Equal("GET / HTTP/1.0"),
-				"Recv":      Equal("Hello from"),
-				"Timeout":   Equal(5),
+				"Name":       Equal("svc"),
+				"Partition":  Equal("f5"),
+				"Interval":   Equal(10),
+				"Type":       Equal("http"),
+				"Send":       Equal("GET / HTTP/1.0"),
+				"Recv":       Equal("Hello from"),
+				"Timeout":    Equal(5),
+				"TargetPort": BeZero(),
 			}))
 		})
 
@@ -138,13 +139,14 @@ var _ = Describe("Health Monitor Tests", func() {
 			hm.Recv = "Hello from"
 			hm.Timeout = 5
 			Expect(hm).To(MatchAllFields(Fields{
-				"Name":      Equal("svc"),
-				"Partition": Equal("f5"),
-				"Interval":  Equal(10),
-				"Type":      Equal("https"),
-				"Send":      Equal("GET / HTTP/1.0"),
-				"Recv":      Equal("Hello from"),
-				"Timeout":   Equal(5),
+				"Name":       Equal("svc"),
+				"Partition":  Equal("f5"),
+				"Interval":   Equal(10),
+				"Type":       Equal("https"),
+				"Send":       Equal("GET / HTTP/1.0"),
+				"Recv":       Equal("Hello from"),
+				"Timeout":    Equal(5),
+				"TargetPort": BeZero(),
 			}))
 		})
 
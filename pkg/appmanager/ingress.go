@@ -0,0 +1,248 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package appmanager
+
+import (
+	"reflect"
+	"time"
+
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// The IngressClasses are resynced to pick up the changes of the ConfigMaps
+// of their parameters
+const ingressClassResyncPeriod = 30 * time.Second
+
+// managedIngressClass is an IngressClass of the controller
+type managedIngressClass struct {
+	isDefault bool
+	// Settings given by the ConfigMap of its parameters, empty when unset
+	partition        string
+	defaultIngressIP string
+}
+
+// newIngressClassInformer creates the informer of the IngressClasses. The
+// Ingresses of an IngressClass are synced when it changes.
+func (appMgr *Manager) newIngressClassInformer() {
+	appMgr.ingClassInformer = NewIngressClassInformer(
+		appMgr.dynamicClient, ingressClassResyncPeriod)
+	appMgr.ingClassInformer.AddEventHandler(
+		bigIPPrometheus.CountInformerEvents("IngressClass", &cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj interface{}) { appMgr.updateIngressClass(obj) },
+			UpdateFunc: func(old, cur interface{}) { appMgr.updateIngressClass(cur) },
+			DeleteFunc: func(obj interface{}) { appMgr.deleteIngressClass(obj) },
+		}),
+	)
+}
+
+// updateIngressClass keeps the IngressClass in obj when its controller is
+// the one of CIS, and syncs its Ingresses when it changed
+func (appMgr *Manager) updateIngressClass(obj interface{}) {
+	class, err := ToIngressClass(obj)
+	if nil != err {
+		log.Warningf("[CORE] Unable to parse IngressClass: %v", err)
+		return
+	}
+	var managed *managedIngressClass
+	if class.Spec.Controller == appMgr.ingressClassController {
+		managed = &managedIngressClass{
+			isDefault: class.ObjectMeta.Annotations[IngressClassDefaultAnnotation] == "true",
+		}
+		appMgr.readIngressClassParameters(class, managed)
+	}
+	appMgr.ingressClassesMutex.Lock()
+	changed := !reflect.DeepEqual(appMgr.ingressClasses[class.Name], managed)
+	if nil == managed {
+		delete(appMgr.ingressClasses, class.Name)
+	} else {
+		appMgr.ingressClasses[class.Name] = managed
+	}
+	appMgr.ingressClassesMutex.Unlock()
+	if changed {
+		appMgr.enqueueIngressClass(class.Name)
+	}
+}
+
+func (appMgr *Manager) deleteIngressClass(obj interface{}) {
+	class, err := ToIngressClass(obj)
+	if nil != err {
+		log.Warningf("[CORE] Unable to parse IngressClass: %v", err)
+		return
+	}
+	appMgr.ingressClassesMutex.Lock()
+	_, found := appMgr.ingressClasses[class.Name]
+	delete(appMgr.ingressClasses, class.Name)
+	appMgr.ingressClassesMutex.Unlock()
+	if found {
+		appMgr.enqueueIngressClass(class.Name)
+	}
+}
+
+// readIngressClassParameters sets the settings of class given by the
+// ConfigMap referenced by its parameters
+func (appMgr *Manager) readIngressClassParameters(
+	class *IngressClass,
+	managed *managedIngressClass,
+) {
+	params := class.Spec.Parameters
+	if nil == params {
+		return
+	}
+	if (nil != params.APIGroup && *params.APIGroup != "") || params.Kind != "ConfigMap" {
+		log.Warningf("[CORE] IngressClass '%v': parameters of kind '%v' are not supported, "+
+			"expected a ConfigMap.", class.Name, params.Kind)
+		return
+	}
+	if nil == params.Namespace || *params.Namespace == "" {
+		log.Warningf("[CORE] IngressClass '%v': the namespace of the parameters is not set.",
+			class.Name)
+		return
+	}
+	cm, err := appMgr.kubeClient.CoreV1().ConfigMaps(*params.Namespace).
+		Get(params.Name, metav1.GetOptions{})
+	if nil != err {
+		log.Warningf("[CORE] IngressClass '%v': unable to read the parameters: %v",
+			class.Name, err)
+		return
+	}
+	managed.partition = cm.Data[IngressClassPartitionKey]
+	managed.defaultIngressIP = cm.Data[IngressClassDefaultIngressIPKey]
+}
+
+// enqueueIngressClass syncs the Ingresses of the IngressClass name, and the
+// ones without class which may fall back on it as the default class
+func (appMgr *Manager) enqueueIngressClass(name string) {
+	var objs []interface{}
+	appMgr.informersMutex.Lock()
+	for _, appInf := range appMgr.appInformers {
+		if nil == appInf.ingInformer {
+			continue
+		}
+		for _, obj := range appInf.ingInformer.GetIndexer().List() {
+			ing, err := ToIngress(obj)
+			if nil != err {
+				continue
+			}
+			if nil == ing.ClassName || *ing.ClassName == name {
+				objs = append(objs, obj)
+			}
+		}
+	}
+	appMgr.informersMutex.Unlock()
+	for _, obj := range objs {
+		appMgr.enqueueIngress(obj)
+	}
+}
+
+// ingressClassOf returns the IngressClass of CIS of an Ingress, by its
+// spec.ingressClassName or the default one when it has neither that nor the
+// class annotation, nil when there is none
+func (appMgr *Manager) ingressClassOf(ing *Ingress) *managedIngressClass {
+	appMgr.ingressClassesMutex.RLock()
+	defer appMgr.ingressClassesMutex.RUnlock()
+	if nil != ing.ClassName {
+		return appMgr.ingressClasses[*ing.ClassName]
+	}
+	if _, ok := ing.ObjectMeta.Annotations[K8sIngressClass]; ok {
+		return nil
+	}
+	for _, class := range appMgr.ingressClasses {
+		if class.isDefault {
+			return class
+		}
+	}
+	return nil
+}
+
+// ingressPartition returns the partition of an Ingress, given by its
+// annotation, the parameters of its class or the default one
+func (appMgr *Manager) ingressPartition(ing *Ingress) string {
+	if partition, ok := ing.ObjectMeta.Annotations[F5VsPartitionAnnotation]; ok {
+		return partition
+	}
+	if class := appMgr.ingressClassOf(ing); nil != class && class.partition != "" {
+		return class.partition
+	}
+	return DEFAULT_PARTITION
+}
+
+// ingressDefaultIP returns the controller default IP of an Ingress, which
+// the parameters of its class override
+func (appMgr *Manager) ingressDefaultIP(ing *Ingress) string {
+	if class := appMgr.ingressClassOf(ing); nil != class && class.defaultIngressIP != "" {
+		return class.defaultIngressIP
+	}
+	return appMgr.defaultIngIP
+}
+
+// updateIngress writes the annotations of an Ingress
+func (appMgr *Manager) updateIngress(ing *Ingress) error {
+	if obj, ok := ing.Obj.(*unstructured.Unstructured); ok {
+		obj = obj.DeepCopy()
+		obj.SetAnnotations(ing.ObjectMeta.Annotations)
+		_, err := appMgr.dynamicClient.Resource(IngressV1Resource).
+			Namespace(obj.GetNamespace()).Update(obj, metav1.UpdateOptions{})
+		return err
+	}
+	_, err := appMgr.kubeClient.ExtensionsV1beta1().
+		Ingresses(ing.ObjectMeta.Namespace).Update(ing.Ingress)
+	return err
+}
+
+// updateIngressStatus writes the status of an Ingress
+func (appMgr *Manager) updateIngressStatus(ing *Ingress) error {
+	if obj, ok := ing.Obj.(*unstructured.Unstructured); ok {
+		obj, err := SetIngressV1Status(obj, ing.Status)
+		if nil != err {
+			return err
+		}
+		_, err = appMgr.dynamicClient.Resource(IngressV1Resource).
+			Namespace(obj.GetNamespace()).UpdateStatus(obj, metav1.UpdateOptions{})
+		return err
+	}
+	_, err := appMgr.kubeClient.ExtensionsV1beta1().
+		Ingresses(ing.ObjectMeta.Namespace).UpdateStatus(ing.Ingress)
+	return err
+}
+
+// servedResources returns which of the resources gvrs the cluster serves
+func servedResources(
+	kubeClient kubernetes.Interface,
+	gvrs ...schema.GroupVersionResource,
+) map[schema.GroupVersionResource]bool {
+	served := make(map[schema.GroupVersionResource]bool)
+	for _, gvr := range gvrs {
+		list, err := kubeClient.Discovery().ServerResourcesForGroupVersion(
+			gvr.GroupVersion().String())
+		if nil != err {
+			continue
+		}
+		for _, res := range list.APIResources {
+			if res.Name == gvr.Resource {
+				served[gvr] = true
+			}
+		}
+	}
+	return served
+}
@@ -0,0 +1,275 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package appmanager
+
+import (
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent/cccl"
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/test"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newIngressService(name string, port, nodePort int32) *v1.Service {
+	return test.NewService(name, "1", "default", v1.ServiceTypeNodePort,
+		[]v1.ServicePort{{
+			Port:       port,
+			NodePort:   nodePort,
+			TargetPort: intstr.FromInt(int(port)),
+		}})
+}
+
+func newIngressV1(name string, annotations map[string]string, spec map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name, "namespace": "default"}
+	if nil != annotations {
+		values := make(map[string]interface{})
+		for key, value := range annotations {
+			values[key] = value
+		}
+		metadata["annotations"] = values
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "Ingress",
+		"metadata":   metadata,
+		"spec":       spec,
+	}}
+}
+
+func newIngressV1Path(path, pathType, svc string, port int64) map[string]interface{} {
+	return map[string]interface{}{
+		"path":     path,
+		"pathType": pathType,
+		"backend": map[string]interface{}{
+			"service": map[string]interface{}{
+				"name": svc,
+				"port": map[string]interface{}{"number": port},
+			},
+		},
+	}
+}
+
+func newIngressClass(name, controller string, isDefault bool) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": name}
+	if isDefault {
+		metadata["annotations"] = map[string]interface{}{IngressClassDefaultAnnotation: "true"}
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.k8s.io/v1",
+		"kind":       "IngressClass",
+		"metadata":   metadata,
+		"spec": map[string]interface{}{
+			"controller": controller,
+			"parameters": map[string]interface{}{
+				"kind":      "ConfigMap",
+				"name":      "f5-ingress",
+				"scope":     "Namespace",
+				"namespace": "kube-system",
+			},
+		},
+	}}
+}
+
+var _ = Describe("networking.k8s.io/v1 Ingress Tests", func() {
+	var mockMgr *mockAppManager
+	var fakeClient *fake.Clientset
+	var dynamicClient *dynamicfake.FakeDynamicClient
+	var ing *unstructured.Unstructured
+
+	syncIngress := func(obj *unstructured.Unstructured) {
+		appInf, _ := mockMgr.appMgr.getNamespaceInformer("default")
+		Expect(appInf.ingInformer.GetStore().Update(obj)).To(BeNil())
+		ok, keys := mockMgr.appMgr.checkValidIngress(obj)
+		Expect(ok).To(BeTrue())
+		for _, key := range keys {
+			Expect(mockMgr.appMgr.syncVirtualServer(*key)).To(BeNil())
+		}
+	}
+	toIngress := func(obj *unstructured.Unstructured) *Ingress {
+		ing, err := ToIngress(obj)
+		Expect(err).To(BeNil())
+		return ing
+	}
+
+	BeforeEach(func() {
+		RegisterBigIPSchemaTypes()
+		ing = newIngressV1("ing", nil, map[string]interface{}{
+			"ingressClassName": "f5",
+			"rules": []interface{}{map[string]interface{}{
+				"host": "foo.com",
+				"http": map[string]interface{}{
+					"paths": []interface{}{
+						newIngressV1Path("/login", PathTypeExact, "foo", 80),
+						newIngressV1Path("/api", PathTypePrefix, "bar", 80),
+					},
+				},
+			}},
+		})
+
+		fakeClient = fake.NewSimpleClientset(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "f5-ingress", Namespace: "kube-system"},
+			Data: map[string]string{
+				IngressClassPartitionKey:        "ingress",
+				IngressClassDefaultIngressIPKey: "10.2.2.2",
+			},
+		})
+		fakeClient.Resources = []*metav1.APIResourceList{{
+			GroupVersion: "networking.k8s.io/v1",
+			APIResources: []metav1.APIResource{{Name: "ingresses"}, {Name: "ingressclasses"}},
+		}}
+		dynamicClient = dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), ing)
+
+		mockMgr = newMockAppManager(&Params{
+			KubeClient:             fakeClient,
+			DynamicClient:          dynamicClient,
+			restClient:             test.CreateFakeHTTPClient(),
+			ProcessAgentLabels:     func(m map[string]string, n, ns string) bool { return true },
+			IsNodePort:             true,
+			broadcasterFunc:        NewFakeEventBroadcaster,
+			ManageIngress:          true,
+			IngressClass:           "f5",
+			IngressClassController: "f5.com/cntr-ingress-svcs",
+		})
+		mockMgr.appMgr.AgentCIS, _ = agent.CreateAgent(agent.CCCLAgent)
+		mockMgr.appMgr.AgentCIS.Init(&cccl.Params{ConfigWriter: &test.MockWriter{
+			FailStyle: test.Success,
+			Sections:  make(map[string]interface{}),
+		}})
+		Expect(mockMgr.startNonLabelMode([]string{"default"})).To(BeNil())
+
+		node := test.NewNode("node0", "0", false, []v1.NodeAddress{
+			{Type: "ExternalIP", Address: "127.0.0.1"}}, []v1.Taint{})
+		mockMgr.processNodeUpdate([]v1.Node{*node}, nil)
+		Expect(mockMgr.addService(newIngressService("foo", 80, 30001))).To(BeTrue())
+		Expect(mockMgr.addService(newIngressService("bar", 80, 30002))).To(BeTrue())
+	})
+	AfterEach(func() {
+		mockMgr.shutdown()
+	})
+
+	It("watches the v1 Ingresses and the IngressClasses when served", func() {
+		Expect(mockMgr.appMgr.useIngressV1).To(BeTrue())
+		Expect(mockMgr.appMgr.ingClassInformer).NotTo(BeNil())
+	})
+
+	It("configures the v1 Ingresses of its IngressClasses", func() {
+		mockMgr.appMgr.updateIngressClass(newIngressClass("f5", "f5.com/cntr-ingress-svcs", false))
+		syncIngress(ing)
+
+		// The partition and the address are given by the class parameters
+		rsCfg, ok := mockMgr.resources().GetByName("ingress_10-2-2-2_80")
+		Expect(ok).To(BeTrue())
+		Expect(rsCfg.Virtual.Partition).To(Equal("ingress"))
+		Expect(rsCfg.Virtual.VirtualAddress.BindAddr).To(Equal("10.2.2.2"))
+		Expect(rsCfg.Pools).To(HaveLen(2))
+		Expect(rsCfg.Pools[0].Members).To(Equal([]Member{
+			{Address: "127.0.0.1", Port: 30001, Session: "user-enabled"}}))
+
+		// Exact paths match the whole path, prefixes its segments
+		Expect(rsCfg.Policies).To(HaveLen(1))
+		rules := make(map[string]*Rule)
+		for _, rl := range rsCfg.Policies[0].Rules {
+			rules[rl.FullURI] = rl
+		}
+		Expect(rules["foo.com/login"].Conditions).To(HaveLen(2))
+		Expect(rules["foo.com/login"].Conditions[1].Path).To(BeTrue())
+		Expect(rules["foo.com/login"].Conditions[1].PathSegment).To(BeFalse())
+		Expect(rules["foo.com/login"].Conditions[1].Values).To(Equal([]string{"/login"}))
+		Expect(rules["foo.com/api"].Conditions).To(HaveLen(2))
+		Expect(rules["foo.com/api"].Conditions[1].PathSegment).To(BeTrue())
+		Expect(rules["foo.com/api"].Conditions[1].Values).To(Equal([]string{"api"}))
+
+		// The status is written with the dynamic client
+		obj, err := dynamicClient.Resource(IngressV1Resource).Namespace("default").Get(
+			"ing", metav1.GetOptions{})
+		Expect(err).To(BeNil())
+		ips, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+		Expect(ips).To(Equal([]interface{}{map[string]interface{}{"ip": "10.2.2.2"}}))
+	})
+
+	It("configures exact and prefix paths of the same uri", func() {
+		mockMgr.appMgr.updateIngressClass(newIngressClass("f5", "f5.com/cntr-ingress-svcs", false))
+		syncIngress(newIngressV1("ing", nil, map[string]interface{}{
+			"ingressClassName": "f5",
+			"rules": []interface{}{map[string]interface{}{
+				"host": "foo.com",
+				"http": map[string]interface{}{
+					"paths": []interface{}{
+						newIngressV1Path("/foo", PathTypePrefix, "bar", 80),
+						newIngressV1Path("/foo", PathTypeExact, "foo", 80),
+					},
+				},
+			}},
+		}))
+
+		rsCfg, ok := mockMgr.resources().GetByName("ingress_10-2-2-2_80")
+		Expect(ok).To(BeTrue())
+		Expect(rsCfg.Policies).To(HaveLen(1))
+		rules := rsCfg.Policies[0].Rules
+		Expect(rules).To(HaveLen(2))
+		// The exact path is matched before the prefix
+		Expect(rules[0].Name).To(Equal("ingress_foo.com_foo_ingress_default_foo_exact"))
+		Expect(rules[0].Conditions[1].PathSegment).To(BeFalse())
+		Expect(rules[0].Actions[0].Pool).To(Equal("/ingress/ingress_default_foo"))
+		Expect(rules[1].Name).To(Equal("ingress_foo.com_foo_ingress_default_bar"))
+		Expect(rules[1].Conditions[1].PathSegment).To(BeTrue())
+		Expect(rules[1].Actions[0].Pool).To(Equal("/ingress/ingress_default_bar"))
+	})
+
+	It("only processes the Ingresses of its IngressClasses", func() {
+		newClassIngress := func(class string, annotations map[string]string) *Ingress {
+			spec := map[string]interface{}{
+				"defaultBackend": map[string]interface{}{"service": map[string]interface{}{
+					"name": "foo", "port": map[string]interface{}{"number": int64(80)},
+				}},
+			}
+			if class != "" {
+				spec["ingressClassName"] = class
+			}
+			return toIngress(newIngressV1("ing", annotations, spec))
+		}
+		appMgr := mockMgr.appMgr
+		appMgr.updateIngressClass(newIngressClass("f5", "f5.com/cntr-ingress-svcs", false))
+		Expect(appMgr.isManagedIngress(newClassIngress("f5", nil))).To(BeTrue())
+		Expect(appMgr.isManagedIngress(newClassIngress("nginx", nil))).To(BeFalse())
+		// The class annotation takes precedence
+		Expect(appMgr.isManagedIngress(newClassIngress("nginx",
+			map[string]string{K8sIngressClass: "f5"}))).To(BeTrue())
+
+		// Ingresses without class belong to the default IngressClass
+		appMgr.manageIngressClassOnly = true
+		Expect(appMgr.isManagedIngress(newClassIngress("", nil))).To(BeFalse())
+		appMgr.updateIngressClass(newIngressClass("f5", "f5.com/cntr-ingress-svcs", true))
+		Expect(appMgr.isManagedIngress(newClassIngress("", nil))).To(BeTrue())
+		Expect(appMgr.ingressPartition(newClassIngress("", nil))).To(Equal("ingress"))
+
+		// Not an IngressClass of CIS anymore
+		appMgr.updateIngressClass(newIngressClass("f5", "k8s.io/ingress-nginx", true))
+		Expect(appMgr.isManagedIngress(newClassIngress("f5", nil))).To(BeFalse())
+		Expect(appMgr.isManagedIngress(newClassIngress("", nil))).To(BeFalse())
+		Expect(appMgr.ingressPartition(newClassIngress("", nil))).To(Equal(DEFAULT_PARTITION))
+	})
+})
@@ -0,0 +1,290 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package appmanager
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// syncLoadBalancerService configures a virtual server for each port of a
+// Service of type LoadBalancer, and writes its address to the status of the
+// Service. The virtual servers of the ports left in rsMap are deleted by
+// syncVirtualServer.
+func (appMgr *Manager) syncLoadBalancerService(
+	stats *vsSyncStats,
+	sKey serviceQueueKey,
+	rsMap ResourceMap,
+	svc *v1.Service,
+	appInf *appInformer,
+) {
+	if nil == svc || !appMgr.isManagedLoadBalancer(svc, appInf) {
+		addr := appMgr.releaseLoadBalancerAddress(sKey.Namespace + "/" + sKey.ServiceName)
+		if nil != svc && addr != "" {
+			appMgr.clearLoadBalancerStatus(svc, addr)
+		}
+		return
+	}
+
+	addr, err := appMgr.loadBalancerAddress(svc)
+	if nil != err {
+		log.Warningf("[CORE] %v", err)
+		appMgr.recordWarningEvent(svc, svc.ObjectMeta.Namespace, "AddressNotAssigned", err.Error())
+		return
+	}
+
+	for _, portSpec := range svc.Spec.Ports {
+		var mode string
+		switch portSpec.Protocol {
+		case v1.ProtocolTCP, "":
+			mode = "tcp"
+		case v1.ProtocolUDP:
+			mode = "udp"
+		default:
+			log.Warningf("[CORE] Protocol %v of port %v of Service '%v/%v' is not supported.",
+				portSpec.Protocol, portSpec.Port, svc.ObjectMeta.Namespace, svc.ObjectMeta.Name)
+			continue
+		}
+		rsName := FormatLoadBalancerVSName(
+			svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, mode, portSpec.Port)
+		poolName := FormatLoadBalancerPoolName(
+			svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, mode, portSpec.Port)
+		var rsCfg ResourceConfig
+		rsCfg.MetaData.ResourceType = ResourceTypeLoadBalancer
+		rsCfg.Virtual.Name = rsName
+		rsCfg.Virtual.Partition = DEFAULT_PARTITION
+		rsCfg.Virtual.Enabled = true
+		rsCfg.Virtual.SourceAddrTranslation = SetSourceAddrTranslation(appMgr.getVsSnatPoolName())
+		rsCfg.Virtual.SetVirtualAddress(addr, portSpec.Port)
+		rsCfg.Virtual.PoolName = JoinBigipPath(DEFAULT_PARTITION, poolName)
+		SetProfilesForMode(mode, &rsCfg)
+		rsCfg.Pools = []Pool{{
+			Name:        poolName,
+			Partition:   DEFAULT_PARTITION,
+			Balance:     DEFAULT_BALANCE,
+			ServiceName: svc.ObjectMeta.Name,
+			ServicePort: portSpec.Port,
+		}}
+
+		svcKey := ServiceKey{
+			Namespace:   svc.ObjectMeta.Namespace,
+			ServiceName: svc.ObjectMeta.Name,
+			ServicePort: portSpec.Port,
+		}
+		// The members are looked up by port number, restrict the Service to
+		// the port of this protocol
+		portSvc := svc.DeepCopy()
+		portSvc.Spec.Ports = []v1.ServicePort{portSpec}
+		if appMgr.IsNodePort() {
+			appMgr.updatePoolMembersForNodePort(portSvc, svcKey, &rsCfg, 0)
+		} else {
+			appMgr.updatePoolMembersForCluster(portSvc, svcKey, &rsCfg, appInf, 0)
+		}
+		stats.vsFound += 1
+		if appMgr.saveLoadBalancerConfig(svcKey, rsName, &rsCfg) {
+			stats.vsUpdated += 1
+		}
+
+		// The virtual server is still valid, keep it
+		var others []*ResourceConfig
+		for _, cfg := range rsMap[portSpec.Port] {
+			if cfg.GetName() != rsName {
+				others = append(others, cfg)
+			}
+		}
+		if 0 == len(others) {
+			delete(rsMap, portSpec.Port)
+		} else {
+			rsMap[portSpec.Port] = others
+		}
+	}
+	appMgr.updateLoadBalancerStatus(svc, addr)
+}
+
+// isManagedLoadBalancer tells whether svc is a Service of type LoadBalancer
+// of the class of CIS. Without loadBalancerClass, the Services of another
+// class are left to their controller. Without dynamic client, the class
+// cannot be read and all the Services are managed.
+func (appMgr *Manager) isManagedLoadBalancer(svc *v1.Service, appInf *appInformer) bool {
+	if svc.Spec.Type != v1.ServiceTypeLoadBalancer {
+		return false
+	}
+	if nil == appInf.lbClassInformer {
+		return true
+	}
+	obj, found, _ := appInf.lbClassInformer.GetIndexer().GetByKey(
+		svc.ObjectMeta.Namespace + "/" + svc.ObjectMeta.Name)
+	return found && LoadBalancerClass(obj) == appMgr.loadBalancerClass
+}
+
+// loadBalancerAddress returns the address of the virtual servers of a
+// Service of type LoadBalancer: its spec.loadBalancerIP, its
+// virtual-server.f5.com/ip annotation, or an address allocated from the
+// address range.
+func (appMgr *Manager) loadBalancerAddress(svc *v1.Service) (string, error) {
+	for _, addr := range []string{
+		svc.Spec.LoadBalancerIP,
+		svc.ObjectMeta.Annotations[F5VsBindAddrAnnotation],
+	} {
+		if addr == "" {
+			continue
+		}
+		if ip, _ := Split_ip_with_route_domain(addr); nil == net.ParseIP(ip) {
+			return "", fmt.Errorf("Address '%v' of Service '%v/%v' is not an IP address.",
+				addr, svc.ObjectMeta.Namespace, svc.ObjectMeta.Name)
+		}
+		return addr, nil
+	}
+
+	if nil == appMgr.lbAddressRange {
+		return "", fmt.Errorf("Service '%v/%v' has no loadBalancerIP or '%v' annotation, "+
+			"and no load-balancer-address-range is configured.",
+			svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, F5VsBindAddrAnnotation)
+	}
+	key := svc.ObjectMeta.Namespace + "/" + svc.ObjectMeta.Name
+	appMgr.lbAddressesMutex.Lock()
+	defer appMgr.lbAddressesMutex.Unlock()
+	if addr, ok := appMgr.lbAddresses[key]; ok {
+		return addr, nil
+	}
+	used := appMgr.usedLoadBalancerAddresses(key)
+	var addr string
+	// Keep the address written to the status before a restart
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if appMgr.lbAddressRange.Contains(ingress.IP) &&
+			!used[net.ParseIP(ingress.IP).String()] {
+			addr = net.ParseIP(ingress.IP).String()
+			break
+		}
+	}
+	if addr == "" {
+		addr = appMgr.lbAddressRange.Allocate(used)
+	}
+	if addr == "" {
+		return "", fmt.Errorf("No address is left in the load-balancer-address-range %v "+
+			"for Service '%v'.", appMgr.lbAddressRange, key)
+	}
+	appMgr.lbAddresses[key] = addr
+	return addr, nil
+}
+
+// usedLoadBalancerAddresses returns the addresses allocated to, requested by
+// or written to the status of the LoadBalancer Services other than the one
+// of key. Called with lbAddressesMutex held.
+func (appMgr *Manager) usedLoadBalancerAddresses(key string) map[string]bool {
+	used := make(map[string]bool)
+	add := func(addr string) {
+		ip, _ := Split_ip_with_route_domain(addr)
+		if parsed := net.ParseIP(ip); nil != parsed {
+			used[parsed.String()] = true
+		}
+	}
+	for svcKey, addr := range appMgr.lbAddresses {
+		if svcKey != key {
+			add(addr)
+		}
+	}
+	appMgr.informersMutex.Lock()
+	defer appMgr.informersMutex.Unlock()
+	for _, appInf := range appMgr.appInformers {
+		for _, obj := range appInf.svcInformer.GetStore().List() {
+			svc := obj.(*v1.Service)
+			if svc.Spec.Type != v1.ServiceTypeLoadBalancer ||
+				svc.ObjectMeta.Namespace+"/"+svc.ObjectMeta.Name == key {
+				continue
+			}
+			add(svc.Spec.LoadBalancerIP)
+			add(svc.ObjectMeta.Annotations[F5VsBindAddrAnnotation])
+			for _, ingress := range svc.Status.LoadBalancer.Ingress {
+				add(ingress.IP)
+			}
+		}
+	}
+	return used
+}
+
+// releaseLoadBalancerAddress frees the address allocated to the Service of
+// key, and returns it
+func (appMgr *Manager) releaseLoadBalancerAddress(key string) string {
+	appMgr.lbAddressesMutex.Lock()
+	defer appMgr.lbAddressesMutex.Unlock()
+	addr := appMgr.lbAddresses[key]
+	delete(appMgr.lbAddresses, key)
+	return addr
+}
+
+// saveLoadBalancerConfig saves the virtual server of a port of a Service, and
+// tells whether it changed. The members of its pool are kept while the
+// Service has no endpoints.
+func (appMgr *Manager) saveLoadBalancerConfig(
+	svcKey ServiceKey,
+	rsName string,
+	rsCfg *ResourceConfig,
+) bool {
+	appMgr.resources.Lock()
+	defer appMgr.resources.Unlock()
+	if oldCfg, ok := appMgr.resources.Get(svcKey, rsName); ok &&
+		reflect.DeepEqual(oldCfg, rsCfg) {
+		return false
+	}
+	appMgr.resources.Assign(svcKey, rsName, rsCfg)
+	return true
+}
+
+// updateLoadBalancerStatus writes the address of the virtual servers of a
+// Service to its status
+func (appMgr *Manager) updateLoadBalancerStatus(svc *v1.Service, addr string) {
+	ip, _ := Split_ip_with_route_domain(addr)
+	ingress := []v1.LoadBalancerIngress{{IP: ip}}
+	if reflect.DeepEqual(svc.Status.LoadBalancer.Ingress, ingress) {
+		return
+	}
+	svcCopy := svc.DeepCopy()
+	svcCopy.Status.LoadBalancer.Ingress = ingress
+	appMgr.writeLoadBalancerStatus(svcCopy)
+}
+
+// clearLoadBalancerStatus removes the address allocated by CIS from the
+// status of a Service that is no longer managed
+func (appMgr *Manager) clearLoadBalancerStatus(svc *v1.Service, addr string) {
+	svcCopy := svc.DeepCopy()
+	svcCopy.Status.LoadBalancer.Ingress = nil
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != addr {
+			svcCopy.Status.LoadBalancer.Ingress = append(svcCopy.Status.LoadBalancer.Ingress, ingress)
+		}
+	}
+	if len(svcCopy.Status.LoadBalancer.Ingress) != len(svc.Status.LoadBalancer.Ingress) {
+		appMgr.writeLoadBalancerStatus(svcCopy)
+	}
+}
+
+func (appMgr *Manager) writeLoadBalancerStatus(svc *v1.Service) {
+	if nil == appMgr.kubeClient {
+		return
+	}
+	_, err := appMgr.kubeClient.CoreV1().Services(svc.ObjectMeta.Namespace).UpdateStatus(svc)
+	if nil != err {
+		log.Warningf("[CORE] Error while updating the status of Service '%v/%v': %v",
+			svc.ObjectMeta.Namespace, svc.ObjectMeta.Name, err)
+	}
+}
@@ -0,0 +1,212 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package appmanager
+
+import (
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/agent/cccl"
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/test"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newLoadBalancerService(name string, ports ...v1.ServicePort) *v1.Service {
+	return test.NewService(name, "1", "default", v1.ServiceTypeLoadBalancer, ports)
+}
+
+var _ = Describe("LoadBalancer Service Tests", func() {
+	var mockMgr *mockAppManager
+	var fakeClient *fake.Clientset
+
+	newManager := func(params *Params) {
+		params.KubeClient = fakeClient
+		params.restClient = test.CreateFakeHTTPClient()
+		params.ProcessAgentLabels = func(m map[string]string, n, ns string) bool { return true }
+		params.IsNodePort = true
+		params.broadcasterFunc = NewFakeEventBroadcaster
+		params.ManageLoadBalancers = true
+		mockMgr = newMockAppManager(params)
+		mockMgr.appMgr.AgentCIS, _ = agent.CreateAgent(agent.CCCLAgent)
+		mockMgr.appMgr.AgentCIS.Init(&cccl.Params{ConfigWriter: &test.MockWriter{
+			FailStyle: test.Success,
+			Sections:  make(map[string]interface{}),
+		}})
+		Expect(mockMgr.startNonLabelMode([]string{"default"})).To(BeNil())
+		node := test.NewNode("node0", "0", false, []v1.NodeAddress{
+			{Type: "ExternalIP", Address: "127.0.0.1"}}, []v1.Taint{})
+		mockMgr.processNodeUpdate([]v1.Node{*node}, nil)
+	}
+	addService := func(svc *v1.Service) {
+		_, err := fakeClient.CoreV1().Services(svc.ObjectMeta.Namespace).Create(svc)
+		Expect(err).To(BeNil())
+		Expect(mockMgr.addService(svc)).To(BeTrue())
+	}
+	statusIPs := func(name string) []string {
+		svc, err := fakeClient.CoreV1().Services("default").Get(name, metav1.GetOptions{})
+		Expect(err).To(BeNil())
+		var ips []string
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			ips = append(ips, ingress.IP)
+		}
+		return ips
+	}
+
+	BeforeEach(func() {
+		RegisterBigIPSchemaTypes()
+		fakeClient = fake.NewSimpleClientset()
+	})
+	AfterEach(func() {
+		mockMgr.shutdown()
+	})
+
+	It("configures a virtual server for each port", func() {
+		newManager(&Params{})
+		svc := newLoadBalancerService("dns",
+			v1.ServicePort{Name: "tcp", Port: 53, NodePort: 30053, Protocol: v1.ProtocolTCP},
+			v1.ServicePort{Name: "udp", Port: 53, NodePort: 30054, Protocol: v1.ProtocolUDP},
+			v1.ServicePort{Name: "sctp", Port: 54, NodePort: 30055, Protocol: v1.ProtocolSCTP})
+		svc.Spec.LoadBalancerIP = "10.1.1.1"
+		addService(svc)
+
+		// SCTP is not supported
+		Expect(mockMgr.resources().PoolCount()).To(Equal(2))
+		key := ServiceKey{ServiceName: "dns", ServicePort: 53, Namespace: "default"}
+		rsCfg, ok := mockMgr.resources().Get(key, "loadbalancer_default_dns_tcp_53")
+		Expect(ok).To(BeTrue())
+		Expect(rsCfg.MetaData.ResourceType).To(Equal(ResourceTypeLoadBalancer))
+		Expect(rsCfg.Virtual.Destination).To(Equal("/velcro/10.1.1.1:53"))
+		Expect(rsCfg.Virtual.PoolName).To(Equal("/velcro/loadbalancer_default_dns_tcp_53_pool"))
+		Expect(rsCfg.Virtual.IpProtocol).To(Equal("tcp"))
+		Expect(rsCfg.Pools[0].Members).To(Equal([]Member{
+			{Address: "127.0.0.1", Port: 30053, Session: "user-enabled"}}))
+		rsCfg, ok = mockMgr.resources().Get(key, "loadbalancer_default_dns_udp_53")
+		Expect(ok).To(BeTrue())
+		Expect(rsCfg.Virtual.IpProtocol).To(Equal("udp"))
+		Expect(rsCfg.Pools[0].Members).To(Equal([]Member{
+			{Address: "127.0.0.1", Port: 30054, Session: "user-enabled"}}))
+		Expect(statusIPs("dns")).To(Equal([]string{"10.1.1.1"}))
+
+		// Not a LoadBalancer anymore
+		svc = svc.DeepCopy()
+		svc.Spec.Type = v1.ServiceTypeNodePort
+		svc.Spec.Ports = svc.Spec.Ports[:1]
+		Expect(mockMgr.updateService(svc)).To(BeTrue())
+		Expect(mockMgr.resources().PoolCount()).To(Equal(0))
+	})
+
+	It("allocates addresses from the address range", func() {
+		lbRange, _ := ParseAddressRange("10.1.1.10-10.1.1.11")
+		newManager(&Params{LBAddressRange: lbRange})
+		port := v1.ServicePort{Port: 80, NodePort: 30080}
+
+		foo := newLoadBalancerService("foo", port)
+		foo.Status.LoadBalancer.Ingress = []v1.LoadBalancerIngress{{IP: "10.1.1.11"}}
+		addService(foo)
+		Expect(statusIPs("foo")).To(Equal([]string{"10.1.1.11"}), "The status is kept")
+
+		bar := newLoadBalancerService("bar", port)
+		bar.ObjectMeta.Annotations = map[string]string{F5VsBindAddrAnnotation: "10.1.1.20%2"}
+		addService(bar)
+		Expect(statusIPs("bar")).To(Equal([]string{"10.1.1.20"}))
+		rsCfg, _ := mockMgr.resources().GetByName("loadbalancer_default_bar_tcp_80")
+		Expect(rsCfg.Virtual.Destination).To(Equal("/velcro/10.1.1.20%2:80"))
+
+		baz := newLoadBalancerService("baz", port)
+		addService(baz)
+		Expect(statusIPs("baz")).To(Equal([]string{"10.1.1.10"}))
+
+		qux := newLoadBalancerService("qux", port)
+		addService(qux)
+		Expect(statusIPs("qux")).To(BeEmpty(), "The range is exhausted")
+		_, ok := mockMgr.resources().GetByName("loadbalancer_default_qux_tcp_80")
+		Expect(ok).To(BeFalse())
+
+		// Deleting a Service frees its address
+		Expect(mockMgr.deleteService(baz)).To(BeTrue())
+		_, ok = mockMgr.resources().GetByName("loadbalancer_default_baz_tcp_80")
+		Expect(ok).To(BeFalse())
+		Expect(mockMgr.updateService(qux)).To(BeTrue())
+		Expect(statusIPs("qux")).To(Equal([]string{"10.1.1.10"}))
+	})
+
+	newClassService := func(name, class string) *unstructured.Unstructured {
+		spec := map[string]interface{}{"type": "LoadBalancer"}
+		if class != "" {
+			spec["loadBalancerClass"] = class
+		}
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "Service",
+			"metadata":   map[string]interface{}{"name": name, "namespace": "default"},
+			"spec":       spec,
+		}}
+	}
+
+	It("processes the Services of its class", func() {
+		newManager(&Params{
+			DynamicClient:     dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+			LoadBalancerClass: "f5.com/bigip",
+		})
+		appInf, _ := mockMgr.appMgr.getNamespaceInformer("default")
+		Expect(appInf.lbClassInformer).NotTo(BeNil())
+		Expect(appInf.lbClassInformer.GetStore().Add(newClassService("foo", "f5.com/bigip"))).To(BeNil())
+		Expect(appInf.lbClassInformer.GetStore().Add(newClassService("bar", ""))).To(BeNil())
+		ok, keys := mockMgr.appMgr.checkValidLoadBalancerClass(newClassService("foo", ""))
+		Expect(ok).To(BeTrue())
+		Expect(keys[0].ServiceName).To(Equal("foo"))
+
+		for _, name := range []string{"foo", "bar"} {
+			svc := newLoadBalancerService(name, v1.ServicePort{Port: 80, NodePort: 30080})
+			svc.Spec.LoadBalancerIP = "10.1.1.1"
+			addService(svc)
+		}
+		_, ok = mockMgr.resources().GetByName("loadbalancer_default_foo_tcp_80")
+		Expect(ok).To(BeTrue())
+		_, ok = mockMgr.resources().GetByName("loadbalancer_default_bar_tcp_80")
+		Expect(ok).To(BeFalse())
+		Expect(statusIPs("bar")).To(BeEmpty())
+	})
+
+	It("leaves the Services of another class without load-balancer-class", func() {
+		newManager(&Params{
+			DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		})
+		appInf, _ := mockMgr.appMgr.getNamespaceInformer("default")
+		Expect(appInf.lbClassInformer).NotTo(BeNil())
+		Expect(appInf.lbClassInformer.GetStore().Add(newClassService("foo", ""))).To(BeNil())
+		Expect(appInf.lbClassInformer.GetStore().Add(newClassService("bar", "example.com/lb"))).To(BeNil())
+
+		for _, name := range []string{"foo", "bar"} {
+			svc := newLoadBalancerService(name, v1.ServicePort{Port: 80, NodePort: 30080})
+			svc.Spec.LoadBalancerIP = "10.1.1.1"
+			addService(svc)
+		}
+		_, ok := mockMgr.resources().GetByName("loadbalancer_default_foo_tcp_80")
+		Expect(ok).To(BeTrue())
+		_, ok = mockMgr.resources().GetByName("loadbalancer_default_bar_tcp_80")
+		Expect(ok).To(BeFalse())
+		Expect(statusIPs("bar")).To(BeEmpty())
+	})
+})
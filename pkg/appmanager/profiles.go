@@ -24,11 +24,9 @@ import (
 	"strings"
 
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 
 	routeapi "github.com/openshift/api/route/v1"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
 )
 
 func (appMgr *Manager) setClientSslProfile(
@@ -41,17 +39,18 @@ func (appMgr *Manager) setClientSslProfile(
 	defer appMgr.customProfiles.Unlock()
 
 	// First handle the Default for SNI profile
-	if appMgr.routeConfig.ClientSSL != "" {
+	routeConfig := appMgr.getRouteConfig()
+	if routeConfig.ClientSSL != "" {
 		// User has provided a name
 		profRef := ConvertStringToProfileRef(
-			appMgr.routeConfig.ClientSSL, CustomProfileClient, sKey.Namespace)
+			routeConfig.ClientSSL, CustomProfileClient, sKey.Namespace)
 		rsCfg.Virtual.AddOrUpdateProfile(profRef)
 		rKey := RouteKey{
 			Name:      route.ObjectMeta.Name,
 			Namespace: route.ObjectMeta.Namespace,
 			Context:   CustomProfileClient,
 		}
-		rsCfg.MetaData.RouteProfs[rKey] = appMgr.routeConfig.ClientSSL
+		rsCfg.MetaData.RouteProfs[rKey] = routeConfig.ClientSSL
 	} else {
 		// No provided name, so we create a default
 		skey := SecretKey{
@@ -195,10 +194,11 @@ func (appMgr *Manager) handleServerSNIDefaultProfile(
 	peerCert string,
 	sKey serviceQueueKey,
 ) {
-	if appMgr.routeConfig.ServerSSL != "" {
+	routeConfig := appMgr.getRouteConfig()
+	if routeConfig.ServerSSL != "" {
 		// User has provided a name
 		prof := ConvertStringToProfileRef(
-			appMgr.routeConfig.ServerSSL, CustomProfileServer, sKey.Namespace)
+			routeConfig.ServerSSL, CustomProfileServer, sKey.Namespace)
 		rsCfg.Virtual.AddOrUpdateProfile(prof)
 	} else {
 		// No provided name, so we create a default
@@ -411,6 +411,7 @@ func (appMgr *Manager) deleteUnusedProfiles(
 	namespace string,
 	stats *vsSyncStats,
 ) {
+	routeConfig := appMgr.getRouteConfig()
 	var reencryptRouteCount int
 	// delete any custom profiles that are no longer referenced
 	// Get the list of routes and check if there are any reencrypt
@@ -459,8 +460,8 @@ func (appMgr *Manager) deleteUnusedProfiles(
 				continue
 			}
 			// If route clientssl has been given via cli, don't remove it
-			if appMgr.routeConfig.ClientSSL != "" {
-				_, rtClient := SplitBigipPath(appMgr.routeConfig.ClientSSL, false)
+			if routeConfig.ClientSSL != "" {
+				_, rtClient := SplitBigipPath(routeConfig.ClientSSL, false)
 				if prof.Name == rtClient {
 					continue
 				}
@@ -468,8 +469,8 @@ func (appMgr *Manager) deleteUnusedProfiles(
 				continue
 			}
 			// If route serverssl has been given via cli, don't remove it
-			if appMgr.routeConfig.ServerSSL != "" {
-				_, rtServer := SplitBigipPath(appMgr.routeConfig.ServerSSL, false)
+			if routeConfig.ServerSSL != "" {
+				_, rtServer := SplitBigipPath(routeConfig.ServerSSL, false)
 				if prof.Name == rtServer {
 					continue
 				}
@@ -515,7 +516,10 @@ func (appMgr *Manager) deleteUnusedProfiles(
 				ingresses, _ := appInf.ingInformer.GetIndexer().ByIndex(
 					"namespace", namespace)
 				for _, obj := range ingresses {
-					ing := obj.(*v1beta1.Ingress)
+					ing, err := ToIngress(obj)
+					if nil != err {
+						continue
+					}
 					if 0 == len(ing.Spec.TLS) {
 						// Nothing to do if no TLS section
 						continue
@@ -575,6 +579,10 @@ func (appMgr *Manager) deleteUnusedProfiles(
 						break
 					}
 				}
+			} else if cfg.MetaData.ResourceType == ResourceTypeGateway {
+				// Gateway virtual servers are rebuilt with their profiles on
+				// each sync
+				referenced = true
 			}
 			if !referenced {
 				log.Debugf("[CORE] deleteUnusedProfiles Removing profile: %v.",
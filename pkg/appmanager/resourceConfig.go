@@ -22,16 +22,14 @@ import (
 	"strings"
 
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 
 	routeapi "github.com/openshift/api/route/v1"
-	"k8s.io/api/extensions/v1beta1"
 	"k8s.io/client-go/tools/cache"
 )
 
 // Create a ResourceConfig based on an Ingress resource config
 func (appMgr *Manager) createRSConfigFromIngress(
-	ing *v1beta1.Ingress,
+	ing *Ingress,
 	resources *Resources,
 	ns string,
 	svcIndexer cache.Indexer,
@@ -39,16 +37,8 @@ func (appMgr *Manager) createRSConfigFromIngress(
 	defaultIP,
 	snatPoolName string,
 ) *ResourceConfig {
-	if class, ok := ing.ObjectMeta.Annotations[K8sIngressClass]; ok == true {
-		if class != appMgr.ingressClass {
-			return nil
-		}
-	} else {
-		// at this point we dont have k8sIngressClass defined in Ingress definition.
-		// So check whether we need to process those ingress or not.
-		if appMgr.manageIngressClassOnly {
-			return nil
-		}
+	if !appMgr.isManagedIngress(ing) {
+		return nil
 	}
 	var cfg ResourceConfig
 	var balance string
@@ -58,28 +48,19 @@ func (appMgr *Manager) createRSConfigFromIngress(
 		balance = DEFAULT_BALANCE
 	}
 
-	if partition, ok := ing.ObjectMeta.Annotations[F5VsPartitionAnnotation]; ok == true {
-		cfg.Virtual.Partition = partition
-	} else {
-		cfg.Virtual.Partition = DEFAULT_PARTITION
+	minActive, err := ParseZoneAwareAnnotations(ing.ObjectMeta.Annotations)
+	if nil != err {
+		log.Warningf("[CORE] Ingress '%v/%v': %v, its pools are not zone aware.",
+			ing.ObjectMeta.Namespace, ing.ObjectMeta.Name, err)
 	}
 
-	bindAddr := ""
-	if addr, ok := ing.ObjectMeta.Annotations[F5VsBindAddrAnnotation]; ok == true {
-		if addr == "controller-default" {
-			bindAddr = defaultIP
-		} else {
-			bindAddr = addr
-		}
-	} else {
-		// if no annotation is provided, take the IP from controller config.
-		if defaultIP != "" && defaultIP != "0.0.0.0" {
-			bindAddr = defaultIP
-		} else {
-			// Ingress IP is not given in either as controller deployment option or in annotation, exit with error log.
-			log.Error("Ingress IP Address is not provided. Unable to process ingress resources. " +
-				"Either configure controller with 'default-ingress-ip' or Ingress with annotation 'virtual-server.f5.com/ip'.")
-		}
+	cfg.Virtual.Partition = appMgr.ingressPartition(ing)
+
+	bindAddr := getIngressBindAddr(ing, defaultIP)
+	if bindAddr == "" {
+		// Ingress IP is not given in either as controller deployment option or in annotation, exit with error log.
+		log.Error("Ingress IP Address is not provided. Unable to process ingress resources. " +
+			"Either configure controller with 'default-ingress-ip' or Ingress with annotation 'virtual-server.f5.com/ip'.")
 	}
 
 	cfg.Virtual.Name = FormatIngressVSName(bindAddr, pStruct.port)
@@ -135,10 +116,11 @@ func (appMgr *Manager) createRSConfigFromIngress(
 							ing.ObjectMeta.Namespace,
 							path.Backend.ServiceName,
 						),
-						Partition:   cfg.Virtual.Partition,
-						Balance:     balance,
-						ServiceName: path.Backend.ServiceName,
-						ServicePort: path.Backend.ServicePort.IntVal,
+						Partition:            cfg.Virtual.Partition,
+						Balance:              balance,
+						ServiceName:          path.Backend.ServiceName,
+						ServicePort:          path.Backend.ServicePort.IntVal,
+						MinimumMembersActive: minActive,
 					}
 					pools = append(pools, pool)
 				}
@@ -146,7 +128,7 @@ func (appMgr *Manager) createRSConfigFromIngress(
 		}
 
 		rules, urlRewriteRefs, appRootRefs = processIngressRules(
-			&ing.Spec,
+			ing,
 			urlRewriteMap,
 			whitelistSourceRanges,
 			appRootMap,
@@ -160,10 +142,11 @@ func (appMgr *Manager) createRSConfigFromIngress(
 				ing.ObjectMeta.Namespace,
 				ing.Spec.Backend.ServiceName,
 			),
-			Partition:   cfg.Virtual.Partition,
-			Balance:     balance,
-			ServiceName: ing.Spec.Backend.ServiceName,
-			ServicePort: ing.Spec.Backend.ServicePort.IntVal,
+			Partition:            cfg.Virtual.Partition,
+			Balance:              balance,
+			ServiceName:          ing.Spec.Backend.ServiceName,
+			ServicePort:          ing.Spec.Backend.ServicePort.IntVal,
+			MinimumMembersActive: minActive,
 		}
 		ssPoolName = pool.Name
 		pools = append(pools, pool)
@@ -200,6 +183,7 @@ func (appMgr *Manager) createRSConfigFromIngress(
 					if pl.Balance != newPool.Balance {
 						cfg.Pools[i].Balance = newPool.Balance
 					}
+					cfg.Pools[i].MinimumMembersActive = newPool.MinimumMembersActive
 					break
 				}
 			}
@@ -222,7 +206,8 @@ func (appMgr *Manager) createRSConfigFromIngress(
 				found := false
 				for i, rl := range policy.Rules {
 					if rl.Name == newRule.Name || (!IsAnnotationRule(rl.Name) &&
-						!IsAnnotationRule(newRule.Name) && rl.FullURI == newRule.FullURI) {
+						!IsAnnotationRule(newRule.Name) && rl.FullURI == newRule.FullURI &&
+						rl.HasExactPath() == newRule.HasExactPath()) {
 						found = true
 						policy.Rules[i] = newRule
 						break
@@ -257,7 +242,7 @@ func (appMgr *Manager) createRSConfigFromIngress(
 // Return value is whether or not a custom profile was updated
 func (appMgr *Manager) handleIngressTls(
 	rsCfg *ResourceConfig,
-	ing *v1beta1.Ingress,
+	ing *Ingress,
 	svcFwdRulesMap ServiceFwdRuleMap,
 ) bool {
 	if 0 == len(ing.Spec.TLS) {
@@ -372,6 +357,40 @@ func (appMgr *Manager) handleIngressTls(
 	return false
 }
 
+// isManagedIngress reports whether the class of an Ingress is the one
+// processed by the controller. The class annotation takes precedence over
+// spec.ingressClassName, which must name an IngressClass of the controller.
+func (appMgr *Manager) isManagedIngress(ing *Ingress) bool {
+	if class, ok := ing.ObjectMeta.Annotations[K8sIngressClass]; ok == true {
+		return class == appMgr.ingressClass
+	}
+	if nil != appMgr.ingressClassOf(ing) {
+		return true
+	}
+	if nil != ing.ClassName {
+		return false
+	}
+	// at this point we dont have k8sIngressClass defined in Ingress definition.
+	// So check whether we need to process those ingress or not.
+	return !appMgr.manageIngressClassOnly
+}
+
+// getIngressBindAddr returns the virtual address of an Ingress, given by
+// its annotation or the controller default, and "" when neither is set
+func getIngressBindAddr(ing *Ingress, defaultIP string) string {
+	if addr, ok := ing.ObjectMeta.Annotations[F5VsBindAddrAnnotation]; ok == true {
+		if addr == "controller-default" {
+			return defaultIP
+		}
+		return addr
+	}
+	// if no annotation is provided, take the IP from controller config.
+	if defaultIP != "" && defaultIP != "0.0.0.0" {
+		return defaultIP
+	}
+	return ""
+}
+
 func (appMgr *Manager) createRSConfigFromRoute(
 	route *routeapi.Route,
 	svcName string,
@@ -58,7 +58,7 @@ var _ = Describe("Resource Config Tests", func() {
 				port:     80,
 			}
 			cfg := mockMgr.appMgr.createRSConfigFromIngress(
-				ingress, &Resources{}, namespace, nil, ps, "", "test-snat-pool")
+				legacyIngress(ingress), &Resources{}, namespace, nil, ps, "", "test-snat-pool")
 			Expect(cfg.Pools[0].Balance).To(Equal("round-robin"))
 			Expect(cfg.Virtual.Partition).To(Equal("velcro"))
 			Expect(cfg.Virtual.VirtualAddress.BindAddr).To(Equal("1.2.3.4"))
@@ -81,7 +81,7 @@ var _ = Describe("Resource Config Tests", func() {
 				port:     100,
 			}
 			cfg = mockMgr.appMgr.createRSConfigFromIngress(
-				ingress, &Resources{}, namespace, nil, ps, "", "")
+				legacyIngress(ingress), &Resources{}, namespace, nil, ps, "", "")
 			Expect(cfg.Pools[0].Balance).To(Equal("foobar"))
 			Expect(cfg.Virtual.VirtualAddress.Port).To(Equal(int32(100)))
 
@@ -90,7 +90,7 @@ var _ = Describe("Resource Config Tests", func() {
 					K8sIngressClass: "notf5",
 				})
 			cfg = mockMgr.appMgr.createRSConfigFromIngress(
-				ingress, &Resources{}, namespace, nil, ps, "", "")
+				legacyIngress(ingress), &Resources{}, namespace, nil, ps, "", "")
 			Expect(cfg).To(BeNil())
 
 			// Use controller default IP
@@ -100,7 +100,7 @@ var _ = Describe("Resource Config Tests", func() {
 					F5VsPartitionAnnotation: "velcro",
 				})
 			cfg = mockMgr.appMgr.createRSConfigFromIngress(
-				defaultIng, &Resources{}, namespace, nil, ps, "5.6.7.8", "")
+				legacyIngress(defaultIng), &Resources{}, namespace, nil, ps, "5.6.7.8", "")
 			Expect(cfg.Virtual.VirtualAddress.BindAddr).To(Equal("5.6.7.8"))
 		})
 
@@ -31,10 +31,8 @@ import (
 	"sync"
 
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 
 	routeapi "github.com/openshift/api/route/v1"
-	"k8s.io/api/extensions/v1beta1"
 )
 
 type Routes []*routeapi.Route
@@ -114,6 +112,25 @@ func createRule(uri, poolName, partition, ruleName string) (*Rule, error) {
 	return &rl, nil
 }
 
+// setExactPathCondition replaces the path segment conditions of a rule,
+// which match the path as a prefix, with one matching path exactly
+func setExactPathCondition(rl *Rule, path string) {
+	var c []*Condition
+	for _, cond := range rl.Conditions {
+		if !cond.PathSegment {
+			c = append(c, cond)
+		}
+	}
+	rl.Conditions = append(c, &Condition{
+		Name:    strconv.Itoa(len(c)),
+		Equals:  true,
+		HTTPURI: true,
+		Path:    true,
+		Request: true,
+		Values:  []string{path},
+	})
+}
+
 // format the rule name for an Ingress
 func formatIngressRuleName(host, path, pool string) string {
 	var rule string
@@ -129,7 +146,7 @@ func formatIngressRuleName(host, path, pool string) string {
 }
 
 func processIngressRules(
-	ing *v1beta1.IngressSpec,
+	ing *Ingress,
 	urlRewriteMap map[string]string,
 	whitelistSourceRanges []string,
 	appRootMap map[string]string,
@@ -147,7 +164,7 @@ func processIngressRules(
 	urlRewriteRefs := make(map[string]string)
 	appRootRefs := make(map[string][]string)
 
-	for _, rule := range ing.Rules {
+	for _, rule := range ing.Spec.Rules {
 		if nil != rule.IngressRuleValue.HTTP {
 			for _, path := range rule.IngressRuleValue.HTTP.Paths {
 				uri = rule.Host + path.Path
@@ -160,16 +177,26 @@ func processIngressRules(
 					continue
 				}
 				ruleName := formatIngressRuleName(rule.Host, path.Path, poolName)
+				// Exact and prefix paths of the same uri are distinct rules
+				rlKey := uri
+				exact := ing.PathType(rule.Host, path) == PathTypeExact
+				if exact {
+					ruleName += "_exact"
+					rlKey += " exact"
+				}
 				// This blank name gets overridden by an ordinal later on
 				rl, err = createRule(uri, poolName, partition, ruleName)
 				if nil != err {
 					log.Warningf("[CORE] Error configuring rule: %v", err)
 					return nil, nil, nil
 				}
+				if exact {
+					setExactPathCondition(rl, path.Path)
+				}
 				if true == strings.HasPrefix(uri, "*.") {
-					wildcards[uri] = rl
+					wildcards[rlKey] = rl
 				} else {
-					rlMap[uri] = rl
+					rlMap[rlKey] = rl
 				}
 
 				// Process url-rewrite annotation
@@ -717,10 +744,12 @@ func updateDataGroupForABRoute(
 		return
 	}
 
-	weightTotal := 0
 	svcs := GetRouteServices(route)
+	var pools []string
+	var weights []int
 	for _, svc := range svcs {
-		weightTotal = weightTotal + svc.Weight
+		pools = append(pools, FormatRoutePoolName(route.ObjectMeta.Namespace, svc.Name))
+		weights = append(weights, svc.Weight)
 	}
 
 	path := route.Spec.Path
@@ -736,31 +765,38 @@ func updateDataGroupForABRoute(
 	}
 	key := route.Spec.Host + path
 
+	// If all services have 0 weight, openshift requires a 503 to be returned
+	// (see https://docs.openshift.com/container-platform/3.6/architecture
+	//  /networking/routes.html#alternateBackends)
+	updateDataGroup(dgMap, AbDeploymentDgName,
+		partition, namespace, key, abDeploymentDgValue(pools, weights))
+}
+
+// Returns the record of an A/B deployment data group for pools weighted by
+// weights, empty when all weights are 0.
+func abDeploymentDgValue(pools []string, weights []int) string {
+	weightTotal := 0
+	for _, weight := range weights {
+		weightTotal = weightTotal + weight
+	}
 	if weightTotal == 0 {
-		// If all services have 0 weight, openshift requires a 503 to be returned
-		// (see https://docs.openshift.com/container-platform/3.6/architecture
-		//  /networking/routes.html#alternateBackends)
-		updateDataGroup(dgMap, AbDeploymentDgName, partition, namespace, key, "")
-	} else {
-		// Place each service in a segment between 0.0 and 1.0 that corresponds to
-		// it's ratio percentage.  The order does not matter in regards to which
-		// service is listed first, but the list must be in ascending order.
-		var entries []string
-		runningWeightTotal := 0
-		for _, svc := range svcs {
-			if svc.Weight == 0 {
-				continue
-			}
-			runningWeightTotal = runningWeightTotal + svc.Weight
-			weightedSliceThreshold := float64(runningWeightTotal) / float64(weightTotal)
-			pool := FormatRoutePoolName(route.ObjectMeta.Namespace, svc.Name)
-			entry := fmt.Sprintf("%s,%4.3f", pool, weightedSliceThreshold)
-			entries = append(entries, entry)
+		return ""
+	}
+	// Place each service in a segment between 0.0 and 1.0 that corresponds to
+	// it's ratio percentage.  The order does not matter in regards to which
+	// service is listed first, but the list must be in ascending order.
+	var entries []string
+	runningWeightTotal := 0
+	for i, pool := range pools {
+		if weights[i] == 0 {
+			continue
 		}
-		value := strings.Join(entries, ";")
-		updateDataGroup(dgMap, AbDeploymentDgName,
-			partition, namespace, key, value)
+		runningWeightTotal = runningWeightTotal + weights[i]
+		weightedSliceThreshold := float64(runningWeightTotal) / float64(weightTotal)
+		entry := fmt.Sprintf("%s,%4.3f", pool, weightedSliceThreshold)
+		entries = append(entries, entry)
 	}
+	return strings.Join(entries, ";")
 }
 
 // Add or update a data group record
@@ -0,0 +1,368 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package appmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	routeapi "github.com/openshift/api/route/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// tenantOwner is a Kubernetes object that contributes to AS3 tenants,
+// along with the AS3 paths (/tenant/application[/pool]) it declares
+type tenantOwner struct {
+	obj       runtime.Object
+	namespace string
+	tenants   []string
+	paths     []string
+}
+
+// recordTenantErrors adds a Warning event and the AS3 error annotation to
+// the objects that produced the tenants rejected by BIG-IP
+func (appMgr *Manager) recordTenantErrors(tenantErrors map[string]string) {
+	tenants := make([]string, 0, len(tenantErrors))
+	for tenant := range tenantErrors {
+		tenants = append(tenants, tenant)
+	}
+	sort.Strings(tenants)
+
+	for _, tenant := range tenants {
+		msg := tenantErrors[tenant]
+		owners := appMgr.getTenantOwners(tenant, msg)
+		if len(owners) == 0 {
+			log.Warningf("[CORE] No resources found for rejected tenant %v", tenant)
+			continue
+		}
+		event := fmt.Sprintf("BIG-IP rejected the declaration of tenant %v: %v", tenant, msg)
+		for _, owner := range owners {
+			appMgr.recordWarningEvent(owner.obj, owner.namespace, "AS3DeclarationFailed", event)
+			appMgr.setAS3ErrorAnnotation(owner.obj, msg)
+		}
+	}
+}
+
+// clearTenantErrors removes the AS3 error annotation once BIG-IP accepted
+// the declaration
+func (appMgr *Manager) clearTenantErrors() {
+	for _, owner := range appMgr.getAllTenantOwners() {
+		appMgr.setAS3ErrorAnnotation(owner.obj, "")
+	}
+}
+
+// getTenantOwners returns the objects that contribute to tenant. If msg
+// names the AS3 path of some of them, only those are returned.
+func (appMgr *Manager) getTenantOwners(tenant, msg string) []tenantOwner {
+	prefix := "/" + tenant + "/"
+	var owners, mentioned []tenantOwner
+	for _, owner := range appMgr.getAllTenantOwners() {
+		if !containsString(owner.tenants, tenant) {
+			continue
+		}
+		owners = append(owners, owner)
+		for _, path := range owner.paths {
+			if strings.HasPrefix(path, prefix) && mentionsAS3Path(msg, path) {
+				mentioned = append(mentioned, owner)
+				break
+			}
+		}
+	}
+	if len(mentioned) != 0 {
+		return mentioned
+	}
+	return owners
+}
+
+// getAllTenantOwners lists the Ingresses of the ingress class, the processed
+// Routes and the AS3 ConfigMaps known to the informers together with the
+// AS3 paths they declare
+func (appMgr *Manager) getAllTenantOwners() []tenantOwner {
+	appMgr.informersMutex.Lock()
+	defer appMgr.informersMutex.Unlock()
+
+	informers := []*appInformer{}
+	for _, appInf := range appMgr.appInformers {
+		informers = append(informers, appInf)
+	}
+	if appMgr.as3Informer != nil {
+		informers = append(informers, appMgr.as3Informer)
+	}
+
+	var owners []tenantOwner
+	for _, appInf := range informers {
+		if appInf.ingInformer != nil {
+			for _, obj := range appInf.ingInformer.GetStore().List() {
+				ing, err := ToIngress(obj)
+				if nil != err || !appMgr.isManagedIngress(ing) {
+					continue
+				}
+				owners = append(owners, tenantOwner{
+					obj:       ing.Obj,
+					namespace: ing.ObjectMeta.Namespace,
+					tenants:   []string{DEFAULT_PARTITION},
+					paths:     append(getIngressAS3Paths(ing), appMgr.getIngressVSAS3Paths(ing)...),
+				})
+			}
+		}
+		if appInf.routeInformer != nil {
+			for _, obj := range appInf.routeInformer.GetStore().List() {
+				route := obj.(*routeapi.Route)
+				if !containsString(appMgr.RoutesProcessed[route.ObjectMeta.Namespace],
+					route.ObjectMeta.Name) {
+					continue
+				}
+				owners = append(owners, tenantOwner{
+					obj:       route,
+					namespace: route.ObjectMeta.Namespace,
+					tenants:   []string{DEFAULT_PARTITION},
+					paths:     append(getRouteAS3Paths(route), appMgr.getRouteVSAS3Paths(route)...),
+				})
+			}
+		}
+		if appInf.cfgMapInformer != nil && appMgr.processAgentLabels != nil {
+			for _, obj := range appInf.cfgMapInformer.GetStore().List() {
+				cm := obj.(*v1.ConfigMap)
+				if !appMgr.processAgentLabels(cm.Labels, cm.Name, cm.Namespace) {
+					continue
+				}
+				tenants, paths := getConfigMapAS3Paths(cm.Data["template"])
+				owners = append(owners, tenantOwner{
+					obj:       cm,
+					namespace: cm.ObjectMeta.Namespace,
+					tenants:   tenants,
+					paths:     paths,
+				})
+			}
+		}
+	}
+	return owners
+}
+
+// setAS3ErrorAnnotation sets the AS3 error annotation of obj to msg, or
+// removes it when msg is empty
+func (appMgr *Manager) setAS3ErrorAnnotation(obj runtime.Object, msg string) {
+	if appMgr.kubeClient == nil {
+		return
+	}
+	var err error
+	switch o := obj.(type) {
+	case *v1beta1.Ingress:
+		ing := o.DeepCopy()
+		if !updateAS3ErrorAnnotation(&ing.ObjectMeta.Annotations, msg) {
+			return
+		}
+		_, err = appMgr.kubeClient.ExtensionsV1beta1().
+			Ingresses(ing.ObjectMeta.Namespace).Update(ing)
+	case *unstructured.Unstructured:
+		// networking.k8s.io/v1 Ingress
+		ing := o.DeepCopy()
+		annotations := ing.GetAnnotations()
+		if !updateAS3ErrorAnnotation(&annotations, msg) {
+			return
+		}
+		ing.SetAnnotations(annotations)
+		_, err = appMgr.dynamicClient.Resource(IngressV1Resource).
+			Namespace(ing.GetNamespace()).Update(ing, metav1.UpdateOptions{})
+	case *routeapi.Route:
+		if appMgr.routeClientV1 == nil {
+			return
+		}
+		route := o.DeepCopy()
+		if !updateAS3ErrorAnnotation(&route.ObjectMeta.Annotations, msg) {
+			return
+		}
+		_, err = appMgr.routeClientV1.Routes(route.ObjectMeta.Namespace).Update(route)
+	case *v1.ConfigMap:
+		cm := o.DeepCopy()
+		if !updateAS3ErrorAnnotation(&cm.ObjectMeta.Annotations, msg) {
+			return
+		}
+		_, err = appMgr.kubeClient.CoreV1().ConfigMaps(cm.ObjectMeta.Namespace).Update(cm)
+	}
+	if err != nil {
+		log.Warningf("[CORE] Error when updating %v annotation: %v", AS3ErrorAnnotation, err)
+	}
+}
+
+// updateAS3ErrorAnnotation sets or removes the AS3 error annotation and
+// reports whether the annotations changed
+func updateAS3ErrorAnnotation(annotations *map[string]string, msg string) bool {
+	current, found := (*annotations)[AS3ErrorAnnotation]
+	if msg == "" {
+		if !found {
+			return false
+		}
+		delete(*annotations, AS3ErrorAnnotation)
+		return true
+	}
+	if found && current == msg {
+		return false
+	}
+	if *annotations == nil {
+		*annotations = make(map[string]string)
+	}
+	(*annotations)[AS3ErrorAnnotation] = msg
+	return true
+}
+
+// getIngressAS3Paths returns the AS3 paths of the pools of an Ingress
+func getIngressAS3Paths(ing *Ingress) []string {
+	var svcs []string
+	if ing.Spec.Backend != nil {
+		svcs = append(svcs, ing.Spec.Backend.ServiceName)
+	}
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, path := range rule.HTTP.Paths {
+			svcs = append(svcs, path.Backend.ServiceName)
+		}
+	}
+	var paths []string
+	for _, svc := range svcs {
+		poolName := FormatIngressPoolName(ing.ObjectMeta.Namespace, svc)
+		poolName = strings.Replace(poolName, ".", "_", -1)
+		paths = append(paths, getSharedAppAS3Path(poolName))
+	}
+	return paths
+}
+
+// getRouteAS3Paths returns the AS3 paths of the pools of a Route
+func getRouteAS3Paths(route *routeapi.Route) []string {
+	svcs := []string{route.Spec.To.Name}
+	for _, backend := range route.Spec.AlternateBackends {
+		svcs = append(svcs, backend.Name)
+	}
+	var paths []string
+	for _, svc := range svcs {
+		paths = append(paths, getSharedAppAS3Path(
+			FormatRoutePoolName(route.ObjectMeta.Namespace, svc)))
+	}
+	return paths
+}
+
+// getIngressVSAS3Paths returns the AS3 paths of the virtual servers of an
+// Ingress, whose policies share their names
+func (appMgr *Manager) getIngressVSAS3Paths(ing *Ingress) []string {
+	bindAddr := getIngressBindAddr(ing, appMgr.ingressDefaultIP(ing))
+	if bindAddr == "" {
+		return nil
+	}
+	var paths []string
+	for _, port := range appMgr.virtualPorts(ing) {
+		vsName := strings.Replace(FormatIngressVSName(bindAddr, port.port), ".", "_", -1)
+		paths = append(paths, getSharedAppAS3Path(vsName))
+	}
+	return paths
+}
+
+// getRouteVSAS3Paths returns the AS3 paths of the virtual servers and
+// policies shared by the Routes that a Route is attached to
+func (appMgr *Manager) getRouteVSAS3Paths(route *routeapi.Route) []string {
+	var names []string
+	virtuals := appMgr.affectedVirtuals(route)
+	if virtuals == HTTP || virtuals == HTTPANDS {
+		names = append(names, appMgr.routeConfig.HttpVs, "openshift_insecure_routes")
+	}
+	if virtuals == HTTPS || virtuals == HTTPANDS {
+		names = append(names, appMgr.routeConfig.HttpsVs, "openshift_secure_routes")
+	}
+	var paths []string
+	for _, name := range names {
+		paths = append(paths, getSharedAppAS3Path(name))
+	}
+	return paths
+}
+
+// getSharedAppAS3Path returns the AS3 path of an object in the Shared
+// application of the default partition, where Ingresses and Routes live
+func getSharedAppAS3Path(name string) string {
+	return "/" + DEFAULT_PARTITION + "/Shared/" + strings.Replace(name, "-", "_", -1)
+}
+
+// getConfigMapAS3Paths returns the tenants and the application paths
+// declared by an AS3 template
+func getConfigMapAS3Paths(template string) ([]string, []string) {
+	var as3Obj map[string]interface{}
+	if err := json.Unmarshal([]byte(template), &as3Obj); err != nil {
+		return nil, nil
+	}
+	adc, ok := as3Obj["declaration"].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+	var tenants, paths []string
+	for tnName, tnObj := range adc {
+		tenant, ok := tnObj.(map[string]interface{})
+		if !ok || tenant["class"] != "Tenant" {
+			continue
+		}
+		tenants = append(tenants, tnName)
+		for appName, appObj := range tenant {
+			if app, ok := appObj.(map[string]interface{}); ok && app["class"] == "Application" {
+				paths = append(paths, "/"+tnName+"/"+appName)
+			}
+		}
+	}
+	return tenants, paths
+}
+
+// overlapsAS3Path reports whether the AS3 paths a and b are the same object
+// or one contains the other
+func overlapsAS3Path(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/")
+}
+
+// mentionsAS3Path reports whether msg refers to the AS3 object at path or
+// one of its children
+func mentionsAS3Path(msg, path string) bool {
+	for idx := strings.Index(msg, path); idx != -1; {
+		end := idx + len(path)
+		if end == len(msg) || !isAS3NameChar(msg[end]) {
+			return true
+		}
+		next := strings.Index(msg[end:], path)
+		if next == -1 {
+			break
+		}
+		idx = end + next
+	}
+	return false
+}
+
+func isAS3NameChar(c byte) bool {
+	return c == '_' || c == '-' || c == '.' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package appmanager
+
+import (
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	routeapi "github.com/openshift/api/route/v1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("Tenant Status Tests", func() {
+	var partition string
+	BeforeEach(func() {
+		partition = DEFAULT_PARTITION
+		DEFAULT_PARTITION = "test_AS3"
+	})
+	AfterEach(func() {
+		DEFAULT_PARTITION = partition
+	})
+
+	It("Computes the AS3 paths of Ingresses and Routes", func() {
+		ing := legacyIngress(test.NewIngress("ing", "1", "default", v1beta1.IngressSpec{
+			Rules: []v1beta1.IngressRule{{
+				Host: "foo.com",
+				IngressRuleValue: v1beta1.IngressRuleValue{
+					HTTP: &v1beta1.HTTPIngressRuleValue{
+						Paths: []v1beta1.HTTPIngressPath{{
+							Path: "/foo",
+							Backend: v1beta1.IngressBackend{
+								ServiceName: "foo-svc",
+								ServicePort: intstr.IntOrString{IntVal: 80},
+							},
+						}},
+					},
+				},
+			}},
+		}, nil))
+		Expect(getIngressAS3Paths(ing)).To(Equal(
+			[]string{"/test_AS3/Shared/ingress_default_foo_svc"}))
+
+		route := test.NewRoute("route", "1", "default", routeapi.RouteSpec{
+			Host: "foo.com",
+			To:   routeapi.RouteTargetReference{Kind: "Service", Name: "foo"},
+			AlternateBackends: []routeapi.RouteTargetReference{
+				{Kind: "Service", Name: "bar-svc"},
+			},
+		}, nil)
+		Expect(getRouteAS3Paths(route)).To(Equal([]string{
+			"/test_AS3/Shared/openshift_default_foo",
+			"/test_AS3/Shared/openshift_default_bar_svc",
+		}))
+	})
+
+	It("Computes the AS3 paths of the virtual servers of Ingresses and Routes", func() {
+		appMgr := &Manager{
+			defaultIngIP: "1.2.3.4",
+			routeConfig: RouteConfig{
+				HttpVs:  "ose-vserver",
+				HttpsVs: "https-ose-vserver",
+			},
+		}
+		ing := legacyIngress(test.NewIngress("ing", "1", "default", v1beta1.IngressSpec{
+			Backend: &v1beta1.IngressBackend{ServiceName: "foo"},
+		}, map[string]string{F5VsHttpPortAnnotation: "8080"}))
+		Expect(appMgr.getIngressVSAS3Paths(ing)).To(Equal(
+			[]string{"/test_AS3/Shared/ingress_1_2_3_4_8080"}))
+		appMgr.defaultIngIP = ""
+		Expect(appMgr.getIngressVSAS3Paths(ing)).To(BeEmpty())
+
+		route := test.NewRoute("route", "1", "default", routeapi.RouteSpec{
+			Host: "foo.com",
+			To:   routeapi.RouteTargetReference{Kind: "Service", Name: "foo"},
+			TLS: &routeapi.TLSConfig{
+				Termination:                   routeapi.TLSTerminationEdge,
+				InsecureEdgeTerminationPolicy: routeapi.InsecureEdgeTerminationPolicyRedirect,
+			},
+		}, nil)
+		Expect(appMgr.getRouteVSAS3Paths(route)).To(Equal([]string{
+			"/test_AS3/Shared/ose_vserver",
+			"/test_AS3/Shared/openshift_insecure_routes",
+			"/test_AS3/Shared/https_ose_vserver",
+			"/test_AS3/Shared/openshift_secure_routes",
+		}))
+	})
+
+	It("Only considers Ingresses of the ingress class", func() {
+		appMgr := &Manager{ingressClass: "f5"}
+		ing := legacyIngress(test.NewIngress("ing", "1", "default", v1beta1.IngressSpec{},
+			map[string]string{K8sIngressClass: "f5"}))
+		Expect(appMgr.isManagedIngress(ing)).To(BeTrue())
+		ing.ObjectMeta.Annotations[K8sIngressClass] = "nginx"
+		Expect(appMgr.isManagedIngress(ing)).To(BeFalse())
+		delete(ing.ObjectMeta.Annotations, K8sIngressClass)
+		Expect(appMgr.isManagedIngress(ing)).To(BeTrue())
+		appMgr.manageIngressClassOnly = true
+		Expect(appMgr.isManagedIngress(ing)).To(BeFalse())
+	})
+
+	It("Matches overlapping AS3 paths", func() {
+		Expect(overlapsAS3Path("/tnt/app/pool", "/tnt/app/pool")).To(BeTrue())
+		Expect(overlapsAS3Path("/tnt/app", "/tnt/app/pool")).To(BeTrue())
+		Expect(overlapsAS3Path("/tnt/app/pool", "/tnt")).To(BeTrue())
+		Expect(overlapsAS3Path("/tnt/app/pool", "/tnt/app/pool_2")).To(BeFalse())
+		Expect(overlapsAS3Path("/tnt/app", "/tnt/app2")).To(BeFalse())
+	})
+
+	It("Computes the tenants and applications of AS3 ConfigMaps", func() {
+		tenants, paths := getConfigMapAS3Paths(`{
+		  "class": "AS3",
+		  "declaration": {
+		    "class": "ADC",
+		    "tnt": {"class": "Tenant", "app": {"class": "Application"}, "label": "x"}
+		  }
+		}`)
+		Expect(tenants).To(Equal([]string{"tnt"}))
+		Expect(paths).To(Equal([]string{"/tnt/app"}))
+
+		tenants, paths = getConfigMapAS3Paths("invalid")
+		Expect(tenants).To(BeEmpty())
+		Expect(paths).To(BeEmpty())
+	})
+
+	It("Matches AS3 paths in error messages", func() {
+		msg := "declaration is invalid: /test_AS3/Shared/ingress_default_foo_svc: " +
+			"pool member 1.1.1.1 is invalid"
+		Expect(mentionsAS3Path(msg, "/test_AS3/Shared/ingress_default_foo_svc")).To(BeTrue())
+		Expect(mentionsAS3Path(msg, "/test_AS3/Shared/ingress_default_foo")).To(BeFalse())
+		Expect(mentionsAS3Path(msg+" /test_AS3/Shared/ingress_default_foo",
+			"/test_AS3/Shared/ingress_default_foo")).To(BeTrue())
+		Expect(mentionsAS3Path("/tnt/app/pool", "/tnt/app")).To(BeTrue())
+	})
+
+	It("Sets and removes the AS3 error annotation", func() {
+		var annotations map[string]string
+		Expect(updateAS3ErrorAnnotation(&annotations, "")).To(BeFalse())
+		Expect(updateAS3ErrorAnnotation(&annotations, "failed")).To(BeTrue())
+		Expect(annotations[AS3ErrorAnnotation]).To(Equal("failed"))
+		Expect(updateAS3ErrorAnnotation(&annotations, "failed")).To(BeFalse())
+		Expect(updateAS3ErrorAnnotation(&annotations, "")).To(BeTrue())
+		Expect(annotations).NotTo(HaveKey(AS3ErrorAnnotation))
+	})
+})
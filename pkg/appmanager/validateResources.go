@@ -18,11 +18,11 @@ package appmanager
 
 import (
 	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 
 	routeapi "github.com/openshift/api/route/v1"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
 )
 
 func (appMgr *Manager) checkValidConfigMap(
@@ -59,7 +59,7 @@ func (appMgr *Manager) checkValidConfigMap(
 		}
 	}
 
-	cfg, err := ParseConfigMap(cm, appMgr.schemaLocal, appMgr.vsSnatPoolName)
+	cfg, err := ParseConfigMap(cm, appMgr.schemaLocal, appMgr.getVsSnatPoolName())
 	if nil != err {
 		if handleConfigMapParseFailure(appMgr, cm, cfg, err) {
 			// resources is updated if true is returned, write out the config.
@@ -131,10 +131,85 @@ func (appMgr *Manager) checkValidEndpoints(
 	return true, keyList
 }
 
+func (appMgr *Manager) checkValidEndpointSlice(
+	obj interface{},
+) (bool, []*serviceQueueKey) {
+	namespace, svcName, ok := EndpointSliceService(obj)
+	if !ok {
+		// Not the slice of a service
+		return false, nil
+	}
+	// Check if the service to see if we care about it.
+	_, ok = appMgr.getNamespaceInformer(namespace)
+	if !ok {
+		// Not watching this namespace
+		return false, nil
+	}
+	key := &serviceQueueKey{
+		ServiceName: svcName,
+		Namespace:   namespace,
+	}
+	var keyList []*serviceQueueKey
+	keyList = append(keyList, key)
+	return true, keyList
+}
+
+// checkValidGatewayAPIObject returns the key of the namespace of a Gateway
+// or route, whose Gateways and routes are all synced together
+func (appMgr *Manager) checkValidGatewayAPIObject(
+	obj interface{},
+) (bool, []*serviceQueueKey) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return false, nil
+	}
+	namespace := u.GetNamespace()
+	_, ok = appMgr.getNamespaceInformer(namespace)
+	if !ok {
+		// Not watching this namespace
+		return false, nil
+	}
+	key := &serviceQueueKey{
+		Namespace: namespace,
+		Name:      u.GetName(),
+	}
+	return true, []*serviceQueueKey{key}
+}
+
+func (appMgr *Manager) checkValidLoadBalancerClass(
+	obj interface{},
+) (bool, []*serviceQueueKey) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	svc, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return false, nil
+	}
+	namespace := svc.GetNamespace()
+	_, ok = appMgr.getNamespaceInformer(namespace)
+	if !ok {
+		// Not watching this namespace
+		return false, nil
+	}
+	key := &serviceQueueKey{
+		ServiceName: svc.GetName(),
+		Namespace:   namespace,
+	}
+	return true, []*serviceQueueKey{key}
+}
+
 func (appMgr *Manager) checkValidIngress(
 	obj interface{},
 ) (bool, []*serviceQueueKey) {
-	ing := obj.(*v1beta1.Ingress)
+	ing, err := ToIngress(obj)
+	if nil != err {
+		log.Warningf("[CORE] Unable to parse Ingress: %v", err)
+		return false, nil
+	}
 	namespace := ing.ObjectMeta.Namespace
 	appInf, ok := appMgr.getNamespaceInformer(namespace)
 	if !ok {
@@ -155,8 +230,8 @@ func (appMgr *Manager) checkValidIngress(
 			namespace,
 			appInf.svcInformer.GetIndexer(),
 			portStruct,
-			appMgr.defaultIngIP,
-			appMgr.vsSnatPoolName,
+			appMgr.ingressDefaultIP(ing),
+			appMgr.getVsSnatPoolName(),
 		)
 		var rsType int
 		rsName := FormatIngressVSName(bindAddr, portStruct.port)
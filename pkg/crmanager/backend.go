@@ -24,10 +24,9 @@ import (
 	"strings"
 	"time"
 
-	"github.com/F5Networks/k8s-bigip-ctlr/pkg/writer"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
 
 	rsc "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 )
 
 const (
@@ -52,19 +51,15 @@ var DEFAULT_PARTITION string
 func NewAgent(params AgentParams) *Agent {
 	DEFAULT_PARTITION = params.Partition
 	postMgr := NewPostManager(params.PostParams)
-	configWriter, err := writer.NewConfigWriter()
-	if nil != err {
-		log.Fatalf("Failed creating ConfigWriter tool: %v", err)
-	}
 	agent := &Agent{
 		PostManager:  postMgr,
 		Partition:    params.Partition,
-		ConfigWriter: configWriter,
+		ConfigWriter: params.ConfigWriter,
 		EventChan:    make(chan interface{}),
 		activeDecl:   "",
 	}
 	// If running in VXLAN mode, extract the partition name from the tunnel
-	// to be used in configuring a net instance of CCCL for that partition
+	// to be used for its FDB records and ARP entries
 	var vxlanPartition string
 	if len(params.VXLANName) > 0 {
 		cleanPath := strings.TrimLeft(params.VXLANName, "/")
@@ -82,36 +77,46 @@ func NewAgent(params AgentParams) *Agent {
 		LogLevel:       params.LogLevel,
 		VerifyInterval: params.VerifyInterval,
 		VXLANPartition: vxlanPartition,
-		DisableLTM:     true,
 	}
-	bs := bigIPSection{
-		BigIPUsername:   params.PostParams.BIGIPUsername,
-		BigIPPassword:   params.PostParams.BIGIPPassword,
-		BigIPURL:        params.PostParams.BIGIPURL,
-		BigIPPartitions: []string{params.Partition},
+	agent.global = gs
+	if _, _, err := agent.ConfigWriter.SendSection("global", gs); err != nil {
+		log.Fatalf("Could not initialize the L2-L3 configuration: %v", err)
 	}
 
-	agent.startPythonDriver(
-		gs,
-		bs,
-		params.PythonBaseDir,
-	)
-
 	return agent
 }
 
+// UpdateCredentials swaps the BIG-IP credentials used by the PostManager
+func (agent *Agent) UpdateCredentials(bigIPURL, username, password string) {
+	agent.PostManager.UpdateCredentials(bigIPURL, username, password)
+}
+
+// UpdateGlobalSettings hands the log level and the verify interval to the
+// L2-L3 config writer
+func (agent *Agent) UpdateGlobalSettings(logLevel string, verifyInterval int) {
+	agent.global.LogLevel = logLevel
+	agent.global.VerifyInterval = verifyInterval
+	if _, _, err := agent.ConfigWriter.SendSection("global", agent.global); err != nil {
+		log.Errorf("Failed to write updated global settings: %v", err)
+	}
+}
+
 func (agent *Agent) Stop() {
 	agent.ConfigWriter.Stop()
-	agent.stopPythonDriver()
 }
 
-func (agent *Agent) PostConfig(rsCfgs ResourceConfigs) {
+// PostConfig posts the declaration of rsCfgs, traced as a child of trace
+func (agent *Agent) PostConfig(trace tracing.SpanContext, rsCfgs ResourceConfigs) {
+	span := tracing.Start(trace, tracing.SpanBuildDeclaration)
 	decl := createAS3Declaration(rsCfgs)
-	if DeepEqualJSON(agent.activeDecl, decl) {
+	changed := !DeepEqualJSON(agent.activeDecl, decl)
+	span.SetAttributes(tracing.Bool("changed", changed))
+	span.End()
+	if !changed {
 		log.Debug("[AS3] No Change in the Configuration")
 		return
 	}
-	agent.Write(string(decl), nil)
+	agent.Write(string(decl), nil, span.SpanContext())
 	agent.activeDecl = decl
 
 	allPoolMembers := rsCfgs.GetAllPoolMembers()
@@ -218,12 +223,14 @@ func createPoolDecl(cfg *ResourceConfig, sharedApp as3Application) {
 		pool := &as3Pool{}
 		// TODO
 		// pool.LoadBalancingMode = v.Balance
+		pool.MinimumMembersActive = v.MinimumMembersActive
 		pool.Class = "Pool"
 		for _, val := range v.Members {
 			var member as3PoolMember
 			member.AddressDiscovery = "static"
 			member.ServicePort = val.Port
 			member.ServerAddresses = append(member.ServerAddresses, val.Address)
+			member.PriorityGroup = val.PriorityGroup
 			pool.Members = append(pool.Members, member)
 		}
 		// TODO
@@ -287,7 +294,6 @@ func createServiceDecl(cfg *ResourceConfig, sharedApp as3Application) {
 	}
 
 	svc.Layer4 = cfg.Virtual.IpProtocol
-	svc.Source = "0.0.0.0/0"
 	svc.TranslateServerAddress = true
 	svc.TranslateServerPort = true
 
@@ -296,8 +302,21 @@ func createServiceDecl(cfg *ResourceConfig, sharedApp as3Application) {
 	virtualAddress, port := extractVirtualAddressAndPort(cfg.Virtual.Destination)
 	// verify that ip address and port exists.
 	if virtualAddress != "" && port != 0 {
-		va := append(svc.VirtualAddresses, virtualAddress)
-		svc.VirtualAddresses = va
+		if cfg.Virtual.SecondaryDestination == "" {
+			svc.Source = rsc.AnySourceAddress(virtualAddress)
+			svc.VirtualAddresses = append(svc.VirtualAddresses, virtualAddress)
+		} else {
+			// A dual-stack virtual pairs each address with the source
+			// addresses of its own IP family
+			svc.VirtualAddresses = append(svc.VirtualAddresses,
+				[]string{virtualAddress, rsc.AnySourceAddress(virtualAddress)})
+			secondaryAddress, _ := extractVirtualAddressAndPort(
+				cfg.Virtual.SecondaryDestination)
+			if secondaryAddress != "" {
+				svc.VirtualAddresses = append(svc.VirtualAddresses,
+					[]string{secondaryAddress, rsc.AnySourceAddress(secondaryAddress)})
+			}
+		}
 		svc.VirtualPort = port
 	}
 
@@ -425,17 +444,13 @@ func createRuleAction(rl *Rule, rulesData *as3Rule) {
 
 //Extract virtual address and port from host URL
 func extractVirtualAddressAndPort(str string) (string, int) {
-	destination := strings.Split(str, "/")
-	ipPort := strings.Split(destination[len(destination)-1], ":")
+	address, port, err := rsc.ParseVirtualDestination(str)
 	// verify that ip address and port exists else log error.
-	if len(ipPort) == 2 {
-		port, _ := strconv.Atoi(ipPort[1])
-		return ipPort[0], port
-	} else {
-		log.Error("Invalid Virtual Server Destination IP address/Port.")
+	if nil != err {
+		log.Errorf("Invalid Virtual Server Destination IP address/Port: %v", err)
 		return "", 0
 	}
-
+	return address, port
 }
 
 func DeepEqualJSON(decl1, decl2 as3Declaration) bool {
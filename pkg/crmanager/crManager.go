@@ -21,15 +21,20 @@ import (
 	"time"
 
 	"github.com/F5Networks/k8s-bigip-ctlr/config/client/clientset/versioned"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/debug"
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/util/workqueue"
 )
 
+var log = vlogger.NewComponentLogger(vlogger.ComponentCRManager)
+
 const (
 	// DefaultCustomResourceLabel is a label used for F5 Custom Resources.
 	DefaultCustomResourceLabel = "f5cr in (true)"
@@ -39,23 +44,30 @@ const (
 	Service = "Service"
 	// Endpoints is a k8s native Endpoint Resource.
 	Endpoints = "Endpoints"
+	// EndpointSlice is a k8s native EndpointSlice Resource.
+	EndpointSlice = "EndpointSlice"
 
 	NodePortMode = "nodeport"
 )
 
 // NewCRManager creates a new CRManager Instance.
 func NewCRManager(params Params) *CRManager {
+	// Metrics must be registered before the work queue is created
+	bigIPPrometheus.RegisterMetrics()
 
 	crMgr := &CRManager{
 		namespaces:  params.Namespaces,
 		crInformers: make(map[string]*CRInformer),
-		rscQueue: workqueue.NewNamedRateLimitingQueue(
-			workqueue.DefaultControllerRateLimiter(), "custom-resource-controller"),
-		resources:       NewResources(),
-		Agent:           params.Agent,
-		ControllerMode:  params.ControllerMode,
-		UseNodeInternal: params.UseNodeInternal,
-		initState:       true,
+		rscQueue: debug.NewInspectableQueue(workqueue.NewNamedRateLimitingQueue(
+			workqueue.DefaultControllerRateLimiter(), "custom-resource-controller")),
+		resources:         NewResources(),
+		Agent:             params.Agent,
+		ControllerMode:    params.ControllerMode,
+		UseNodeInternal:   params.UseNodeInternal,
+		useEndpointSlices: params.UseEndpointSlices,
+		localZone:         params.LocalZone,
+		staticRouteMgr:    params.StaticRouteMgr,
+		initState:         true,
 	}
 
 	log.Debug("Custom Resource Manager Created")
@@ -75,9 +87,11 @@ func NewCRManager(params Params) *CRManager {
 
 	err := crMgr.SetupNodePolling(
 		params.NodePollInterval,
+		params.WatchNodes,
 		params.NodeLabelSelector,
 		params.VXLANMode,
 		params.VXLANName,
+		params.TunnelType,
 	)
 	if err != nil {
 		log.Errorf("Failed to Setup Node Polling: %v", err)
@@ -115,9 +129,15 @@ func (crMgr *CRManager) setupClients(config *rest.Config) error {
 		return fmt.Errorf("Failed to create kubeClient: %v", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("Failed to create dynamicClient: %v", err)
+	}
+
 	log.Debug("Client Created")
 	crMgr.kubeCRClient = kubeCRClient
 	crMgr.kubeClient = kubeClient
+	crMgr.dynamicClient = dynamicClient
 	return nil
 }
 
@@ -142,6 +162,8 @@ func (crMgr *CRManager) Start() {
 
 	crMgr.nodePoller.Run()
 
+	go crMgr.tenantStatusWorker()
+
 	stopChan := make(chan struct{})
 	go wait.Until(crMgr.customResourceWorker, time.Second, stopChan)
 
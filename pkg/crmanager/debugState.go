@@ -0,0 +1,61 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crmanager
+
+import (
+	"encoding/json"
+
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/debug"
+)
+
+// RegisterDebugState serves the resources, merged rules, work queue,
+// watched namespaces, node cache and declarations of the manager on dbg
+func (crMgr *CRManager) RegisterDebugState(dbg *debug.Server) {
+	dbg.Register("resources", func() (interface{}, error) {
+		crMgr.resources.Lock()
+		defer crMgr.resources.Unlock()
+		return debug.MarshalState(crMgr.resources.GetAllResources())
+	})
+	dbg.Register("merged-rules", func() (interface{}, error) {
+		crMgr.resources.Lock()
+		defer crMgr.resources.Unlock()
+		return debug.MarshalState(crMgr.mergedRulesMap)
+	})
+	dbg.Register("queues", func() (interface{}, error) {
+		queues := make(map[string]interface{})
+		if q, ok := crMgr.rscQueue.(*debug.InspectableQueue); ok {
+			queues["custom-resource-controller"] = q.Contents()
+		}
+		return queues, nil
+	})
+	dbg.Register("namespaces", func() (interface{}, error) {
+		return crMgr.namespaces, nil
+	})
+	dbg.Register("nodes", func() (interface{}, error) {
+		return crMgr.getNodesFromCache(), nil
+	})
+	if crMgr.Agent != nil {
+		dbg.Register("declarations", func() (interface{}, error) {
+			return crMgr.Agent.Declarations(), nil
+		})
+		if cw, ok := crMgr.Agent.ConfigWriter.(interface{ Sections() json.RawMessage }); ok {
+			dbg.Register("cccl-sections", func() (interface{}, error) {
+				return cw.Sections(), nil
+			})
+		}
+	}
+}
@@ -22,7 +22,9 @@ import (
 
 	cisapiv1 "github.com/F5Networks/k8s-bigip-ctlr/config/apis/cis/v1"
 	cisinfv1 "github.com/F5Networks/k8s-bigip-ctlr/config/client/informers/externalversions/cis/v1"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
@@ -40,12 +42,41 @@ func (crInfr *CRInformer) start() {
 	if crInfr.epsInformer != nil {
 		go crInfr.epsInformer.Run(crInfr.stopCh)
 	}
+	if crInfr.epSliceInformer != nil {
+		go crInfr.epSliceInformer.Run(crInfr.stopCh)
+	}
+}
+
+// hasSynced reports whether the caches of the informers are synced
+func (crInfr *CRInformer) hasSynced() bool {
+	for _, inf := range []cache.SharedIndexInformer{
+		crInfr.vsInformer,
+		crInfr.svcInformer,
+		crInfr.epsInformer,
+		crInfr.epSliceInformer,
+	} {
+		if inf != nil && !inf.HasSynced() {
+			return false
+		}
+	}
+	return true
 }
 
 func (crInfr *CRInformer) stop() {
 	close(crInfr.stopCh)
 }
 
+// InformersSynced reports whether the caches of all informers hold the
+// initial state of the watched resources
+func (crMgr *CRManager) InformersSynced() bool {
+	for _, crInf := range crMgr.crInformers {
+		if !crInf.hasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
 func (crMgr *CRManager) watchingAllNamespaces() bool {
 	if 0 == len(crMgr.crInformers) {
 		// Not watching any namespaces.
@@ -111,7 +142,16 @@ func (crMgr *CRManager) newInformer(
 			resyncPeriod,
 			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
 		),
-		epsInformer: cache.NewSharedIndexInformer(
+	}
+
+	if crMgr.useEndpointSlices {
+		crInf.epSliceInformer = resource.NewEndpointSliceInformer(
+			crMgr.dynamicClient,
+			namespace,
+			resyncPeriod,
+		)
+	} else {
+		crInf.epsInformer = cache.NewSharedIndexInformer(
 			cache.NewFilteredListWatchFromClient(
 				restClientv1,
 				"endpoints",
@@ -121,7 +161,7 @@ func (crMgr *CRManager) newInformer(
 			&corev1.Endpoints{},
 			resyncPeriod,
 			cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
-		),
+		)
 	}
 
 	return crInf
@@ -130,33 +170,44 @@ func (crMgr *CRManager) newInformer(
 func (crMgr *CRManager) addEventHandlers(crInf *CRInformer) {
 
 	crInf.vsInformer.AddEventHandler(
-		&cache.ResourceEventHandlerFuncs{
+		bigIPPrometheus.CountInformerEvents("VirtualServer", &cache.ResourceEventHandlerFuncs{
 			AddFunc:    func(obj interface{}) { crMgr.enqueueVirtualServer(obj) },
 			UpdateFunc: func(old, cur interface{}) { crMgr.enqueueVirtualServer(cur) },
 			DeleteFunc: func(obj interface{}) { crMgr.enqueueDeletedVirtualServer(obj) },
-		},
+		}),
 	)
 
 	crInf.svcInformer.AddEventHandler(
-		&cache.ResourceEventHandlerFuncs{
+		bigIPPrometheus.CountInformerEvents("Service", &cache.ResourceEventHandlerFuncs{
 			// Ignore AddFunc for service as we dont bother about services until they are
 			// mapped to VirtualServer. Any new service added and mapped to a VirtualServer
 			// will be handled in the VirtualServer Informer AddFunc.
 			// AddFunc:    func(obj interface{}) { crMgr.enqueueService(obj) },
 			UpdateFunc: func(obj, cur interface{}) { crMgr.enqueueService(cur) },
 			DeleteFunc: func(obj interface{}) { crMgr.enqueueService(obj) },
-		},
+		}),
 	)
 
+	if crInf.epSliceInformer != nil {
+		crInf.epSliceInformer.AddEventHandler(
+			bigIPPrometheus.CountInformerEvents("EndpointSlice", &cache.ResourceEventHandlerFuncs{
+				// Unlike Endpoints, a service may get new EndpointSlices at any time
+				AddFunc:    func(obj interface{}) { crMgr.enqueueEndpointSlice(obj) },
+				UpdateFunc: func(obj, cur interface{}) { crMgr.enqueueEndpointSlice(cur) },
+				DeleteFunc: func(obj interface{}) { crMgr.enqueueEndpointSlice(obj) },
+			}),
+		)
+		return
+	}
 	crInf.epsInformer.AddEventHandler(
-		&cache.ResourceEventHandlerFuncs{
+		bigIPPrometheus.CountInformerEvents("Endpoints", &cache.ResourceEventHandlerFuncs{
 			// Ignore AddFunc for endpoint as we dont bother about endpoints until they are
 			// mapped to VirtualServer. Any new endpoint added and mapped to a Service
 			// will be handled in the Service Informer AddFunc.
 			// AddFunc:    func(obj interface{}) { crMgr.enqueueEndpoints(obj) },
 			UpdateFunc: func(obj, cur interface{}) { crMgr.enqueueEndpoints(cur) },
 			DeleteFunc: func(obj interface{}) { crMgr.enqueueEndpoints(obj) },
-		},
+		}),
 	)
 }
 
@@ -180,7 +231,7 @@ func (crMgr *CRManager) enqueueVirtualServer(obj interface{}) {
 		rsc:       obj,
 	}
 
-	crMgr.rscQueue.Add(key)
+	crMgr.enqueue(key)
 }
 
 func (crMgr *CRManager) enqueueDeletedVirtualServer(obj interface{}) {
@@ -194,7 +245,7 @@ func (crMgr *CRManager) enqueueDeletedVirtualServer(obj interface{}) {
 		rscDelete: true,
 	}
 
-	crMgr.rscQueue.Add(key)
+	crMgr.enqueue(key)
 }
 
 func (crMgr *CRManager) enqueueService(obj interface{}) {
@@ -207,7 +258,7 @@ func (crMgr *CRManager) enqueueService(obj interface{}) {
 		rsc:       obj,
 	}
 
-	crMgr.rscQueue.Add(key)
+	crMgr.enqueue(key)
 }
 
 func (crMgr *CRManager) enqueueEndpoints(obj interface{}) {
@@ -220,5 +271,36 @@ func (crMgr *CRManager) enqueueEndpoints(obj interface{}) {
 		rsc:       obj,
 	}
 
+	crMgr.enqueue(key)
+}
+
+func (crMgr *CRManager) enqueueEndpointSlice(obj interface{}) {
+	namespace, svcName, ok := resource.EndpointSliceService(obj)
+	if !ok {
+		return
+	}
+	log.Debugf("Enqueueing EndpointSlice of service %v/%v", namespace, svcName)
+	key := &rqKey{
+		namespace: namespace,
+		kind:      EndpointSlice,
+		rscName:   svcName,
+		rsc:       obj,
+	}
+
+	crMgr.enqueue(key)
+}
+
+// enqueue queues key with the spans tracing the informer event and the
+// enqueue
+func (crMgr *CRManager) enqueue(key *rqKey) {
+	span := tracing.Start(tracing.SpanContext{}, tracing.SpanInformerEvent,
+		tracing.String("kind", key.kind),
+		tracing.String("namespace", key.namespace),
+		tracing.String("name", key.rscName),
+	)
+	defer span.End()
+	enqueueSpan := tracing.Start(span.SpanContext(), tracing.SpanEnqueue)
+	key.trace = enqueueSpan.SpanContext()
 	crMgr.rscQueue.Add(key)
+	enqueueSpan.End()
 }
@@ -7,20 +7,33 @@ import (
 	"time"
 
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/pollers"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vxlan"
 
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 	v1 "k8s.io/api/core/v1"
 )
 
+// SetNodeLabelSelector changes the label selector of the polled nodes
+func (crMgr *CRManager) SetNodeLabelSelector(nodeLabelSelector string) {
+	if np, ok := crMgr.nodePoller.(interface{ SetNodeLabel(string) }); ok {
+		np.SetNodeLabel(nodeLabelSelector)
+	}
+}
+
 func (crMgr *CRManager) SetupNodePolling(
 	nodePollInterval int,
+	watchNodes bool,
 	nodeLabelSelector string,
 	vxlanMode string,
 	vxlanName string,
+	tunnelType string,
 ) error {
-	intervalFactor := time.Duration(nodePollInterval)
-	crMgr.nodePoller = pollers.NewNodePoller(crMgr.kubeClient, intervalFactor*time.Second, nodeLabelSelector)
+	if watchNodes {
+		crMgr.nodePoller = pollers.NewNodeWatcher(crMgr.kubeClient, nodeLabelSelector)
+	} else {
+		intervalFactor := time.Duration(nodePollInterval)
+		crMgr.nodePoller = pollers.NewNodePoller(crMgr.kubeClient, intervalFactor*time.Second, nodeLabelSelector)
+	}
 
 	// Register appMgr to watch for node updates to keep track of watched nodes
 	err := crMgr.nodePoller.RegisterListener(crMgr.ProcessNodeUpdate)
@@ -37,9 +50,14 @@ func (crMgr *CRManager) SetupNodePolling(
 		if slashPos != -1 {
 			tunnelName = cleanPath[slashPos+1:]
 		}
+		discovery, err := vxlan.NewTunnelDiscovery(tunnelType)
+		if nil != err {
+			return fmt.Errorf("error creating vxlan manager: %v", err)
+		}
 		vxMgr, err := vxlan.NewVxlanMgr(
 			vxlanMode,
 			tunnelName,
+			discovery,
 			crMgr.UseNodeInternal,
 			crMgr.Agent.ConfigWriter,
 			crMgr.Agent.EventChan,
@@ -60,12 +78,22 @@ func (crMgr *CRManager) SetupNodePolling(
 		}
 	}
 
+	if nil != crMgr.staticRouteMgr {
+		// Register staticRouteMgr to watch for node updates to route pod CIDRs
+		err = crMgr.nodePoller.RegisterListener(crMgr.staticRouteMgr.ProcessNodeUpdate)
+		if nil != err {
+			return fmt.Errorf("error registering node update listener for static routes: %v",
+				err)
+		}
+	}
+
 	return nil
 }
 
 type Node struct {
 	Name string
 	Addr string
+	Zone string
 }
 
 // Check for a change in Node state
@@ -93,27 +121,32 @@ func (crMgr *CRManager) ProcessNodeUpdate(
 				virtuals := crMgr.getAllVirtualServers(ns)
 				for _, virtual := range virtuals {
 					qKey := &rqKey{
-						ns,
-						VirtualServer,
-						virtual.ObjectMeta.Name,
-						virtual,
-						false,
+						namespace: ns,
+						kind:      VirtualServer,
+						rscName:   virtual.ObjectMeta.Name,
+						rsc:       virtual,
 					}
 					crMgr.rscQueue.Add(qKey)
 				}
 			}
 
 			// Update node cache
+			crMgr.oldNodesMutex.Lock()
 			crMgr.oldNodes = newNodes
+			crMgr.oldNodesMutex.Unlock()
 		}
 	} else {
 		// Initialize crMgr nodes on our first pass through
+		crMgr.oldNodesMutex.Lock()
 		crMgr.oldNodes = newNodes
+		crMgr.oldNodesMutex.Unlock()
 	}
 }
 
 // Return a copy of the node cache
 func (crMgr *CRManager) getNodesFromCache() []Node {
+	crMgr.oldNodesMutex.Lock()
+	defer crMgr.oldNodesMutex.Unlock()
 	nodes := make([]Node, len(crMgr.oldNodes))
 	copy(nodes, crMgr.oldNodes)
 
@@ -148,6 +181,7 @@ func (crMgr *CRManager) getNodes(
 				n := Node{
 					Name: node.ObjectMeta.Name,
 					Addr: addr.Address,
+					Zone: resource.NodeZone(&node),
 				}
 				watchedNodes = append(watchedNodes, n)
 			}
@@ -23,10 +23,15 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tokenmanager"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
 )
 
 const (
@@ -39,6 +44,20 @@ const (
 type PostManager struct {
 	postChan   chan config
 	httpClient *http.Client
+	// Authenticates requests with X-F5-Auth-Token when token auth is enabled
+	tokenManager *tokenmanager.TokenManager
+	// Guards BIG-IP credentials in PostParams, which can be swapped at runtime
+	credsMutex sync.RWMutex
+	// Delivers the AS3 error of each rejected tenant after every change,
+	// an empty map once BIG-IP accepts the declaration
+	tenantStatusChan chan map[string]string
+	// Tenant errors last sent on tenantStatusChan
+	tenantErrors map[string]string
+	// Time of the first failed post since the last successful one
+	unsyncedSince time.Time
+	// Last posted and last failed declarations
+	declarations DeclarationStatus
+	syncMutex    sync.Mutex
 	PostParams
 }
 
@@ -51,18 +70,27 @@ type PostParams struct {
 	AS3PostDelay  int
 	//Log the AS3 response body in Controller logs
 	LogResponse bool
+	// Use token based authentication instead of basic auth
+	TokenAuth bool
+	// BIG-IP login provider used for token based authentication
+	LoginProvider string
 }
 
 type config struct {
 	data      string
 	routesMap map[string][]string
-	as3APIURL string
+	// Tenants in the declaration, the URL is resolved at post time so that
+	// updated credentials apply to queued configuration
+	tenants []string
+	// Parent of the post spans
+	trace tracing.SpanContext
 }
 
 func NewPostManager(params PostParams) *PostManager {
 	pm := &PostManager{
-		postChan:   make(chan config, 1),
-		PostParams: params,
+		postChan:         make(chan config, 1),
+		tenantStatusChan: make(chan map[string]string, 1),
+		PostParams:       params,
 	}
 	pm.setupBIGIPRESTClient()
 
@@ -97,10 +125,53 @@ func (postMgr *PostManager) setupBIGIPRESTClient() {
 		Transport: tr,
 		Timeout:   timeoutLarge,
 	}
+
+	if postMgr.TokenAuth {
+		postMgr.tokenManager = tokenmanager.NewTokenManager(
+			postMgr.BIGIPURL,
+			postMgr.BIGIPUsername,
+			postMgr.BIGIPPassword,
+			postMgr.LoginProvider,
+			postMgr.httpClient,
+		)
+	}
+}
+
+// doRequest sends request to BIG-IP authenticated with either an auth token
+// or basic auth
+func (postMgr *PostManager) doRequest(request *http.Request) (*http.Response, error) {
+	if postMgr.tokenManager != nil {
+		return postMgr.tokenManager.Do(request)
+	}
+	_, username, password := postMgr.getCredentials()
+	request.SetBasicAuth(username, password)
+	return postMgr.httpClient.Do(request)
+}
+
+// UpdateCredentials swaps the BIG-IP URL and credentials used by subsequent
+// requests. Configuration waiting to be posted is kept.
+func (postMgr *PostManager) UpdateCredentials(bigIPURL, username, password string) {
+	postMgr.credsMutex.Lock()
+	postMgr.BIGIPURL = bigIPURL
+	postMgr.BIGIPUsername = username
+	postMgr.BIGIPPassword = password
+	postMgr.credsMutex.Unlock()
+
+	if postMgr.tokenManager != nil {
+		postMgr.tokenManager.SetCredentials(bigIPURL, username, password)
+	}
+}
+
+// getCredentials returns BIG-IP URL, username and password
+func (postMgr *PostManager) getCredentials() (string, string, string) {
+	postMgr.credsMutex.RLock()
+	defer postMgr.credsMutex.RUnlock()
+	return postMgr.BIGIPURL, postMgr.BIGIPUsername, postMgr.BIGIPPassword
 }
 
 func (postMgr *PostManager) getAS3APIURL(tenants []string) string {
-	apiURL := postMgr.BIGIPURL + "/mgmt/shared/appsvcs/declare/" + strings.Join(tenants, ",")
+	bigIPURL, _, _ := postMgr.getCredentials()
+	apiURL := bigIPURL + "/mgmt/shared/appsvcs/declare/" + strings.Join(tenants, ",")
 	return apiURL
 }
 
@@ -109,10 +180,12 @@ func (postMgr *PostManager) getAS3APIURL(tenants []string) string {
 func (postMgr *PostManager) Write(
 	data string,
 	partitions []string,
+	trace tracing.SpanContext,
 ) {
 	activeConfig := config{
-		data:      data,
-		as3APIURL: postMgr.getAS3APIURL(partitions),
+		data:    data,
+		tenants: partitions,
+		trace:   trace,
 	}
 
 	// Always push latest activeConfig to channel
@@ -168,18 +241,44 @@ func (postMgr *PostManager) postOnEventOrTimeout(timeout time.Duration, cfg conf
 func (postMgr *PostManager) postConfig(cfg config) bool {
 	httpReqBody := bytes.NewBuffer([]byte(cfg.data))
 
-	req, err := http.NewRequest("POST", cfg.as3APIURL, httpReqBody)
+	as3APIURL := postMgr.getAS3APIURL(cfg.tenants)
+	req, err := http.NewRequest("POST", as3APIURL, httpReqBody)
 	if err != nil {
 		log.Errorf("[AS3] Creating new HTTP request error: %v ", err)
 		return false
 	}
-	log.Debugf("[AS3] posting request to %v", cfg.as3APIURL)
-	req.SetBasicAuth(postMgr.BIGIPUsername, postMgr.BIGIPPassword)
+	log.Debugf("[AS3] posting request to %v", as3APIURL)
 
+	tenants := cfg.tenants
+	if len(tenants) == 0 {
+		tenants = []string{DEFAULT_PARTITION}
+	}
+	span := tracing.StartKind(cfg.trace, tracing.SpanPost, tracing.SpanKindClient,
+		tracing.String("tenants", strings.Join(tenants, ",")),
+		tracing.Int("declaration.size", len(cfg.data)),
+	)
+	start := time.Now()
 	httpResp, responseMap := postMgr.httpPOST(req)
+	bigIPPrometheus.ObservePost("as3", start, len(cfg.data))
 	if httpResp == nil || responseMap == nil {
+		span.SetStatus(tracing.StatusError, "no response from BIG-IP")
+		span.End()
+		bigIPPrometheus.RecordAS3Results("as3", 0, nil, tenants)
+		postMgr.recordPostResult(cfg.data, tenants, 0, nil)
 		return false
 	}
+	span.SetAttributes(tracing.Int("http.status_code", httpResp.StatusCode))
+	if httpResp.StatusCode/100 != 2 {
+		span.SetStatus(tracing.StatusError, httpResp.Status)
+	}
+	span.End()
+	bigIPPrometheus.RecordAS3Results("as3", httpResp.StatusCode, responseMap, tenants)
+	// Failed posts are retried from the response handlers, record them first
+	var tenantErrors map[string]string
+	if httpResp.StatusCode/100 != 2 {
+		tenantErrors = resource.GetAS3TenantErrors(responseMap, tenants)
+	}
+	postMgr.recordPostResult(cfg.data, tenants, httpResp.StatusCode, tenantErrors)
 
 	switch httpResp.StatusCode {
 	case http.StatusOK, http.StatusCreated, http.StatusAccepted:
@@ -193,8 +292,78 @@ func (postMgr *PostManager) postConfig(cfg config) bool {
 	}
 }
 
+// PostedDeclaration is a declaration posted to BIG-IP
+type PostedDeclaration struct {
+	Time    time.Time `json:"time"`
+	Tenants []string  `json:"tenants"`
+	// HTTP status of the response, 0 when BIG-IP did not respond, and AS3
+	// error of each rejected tenant
+	StatusCode   int               `json:"statusCode"`
+	TenantErrors map[string]string `json:"tenantErrors,omitempty"`
+	Declaration  interface{}       `json:"declaration"`
+}
+
+// DeclarationStatus holds the last posted and the last failed declarations
+type DeclarationStatus struct {
+	LastPosted *PostedDeclaration `json:"lastPosted"`
+	LastFailed *PostedDeclaration `json:"lastFailed"`
+}
+
+// recordPostResult keeps the posted declaration and tracks since when the
+// declaration failed to post
+func (postMgr *PostManager) recordPostResult(
+	data string,
+	tenants []string,
+	statusCode int,
+	tenantErrors map[string]string,
+) {
+	decl := &PostedDeclaration{
+		Time:         time.Now(),
+		Tenants:      tenants,
+		StatusCode:   statusCode,
+		TenantErrors: tenantErrors,
+		Declaration:  data,
+	}
+	if json.Valid([]byte(data)) {
+		decl.Declaration = json.RawMessage(data)
+	}
+
+	postMgr.syncMutex.Lock()
+	defer postMgr.syncMutex.Unlock()
+	if statusCode/100 == 2 {
+		postMgr.unsyncedSince = time.Time{}
+		postMgr.declarations.LastPosted = decl
+	} else {
+		if postMgr.unsyncedSince.IsZero() {
+			postMgr.unsyncedSince = decl.Time
+		}
+		postMgr.declarations.LastFailed = decl
+	}
+}
+
+// Declarations returns the last posted and the last failed declarations
+func (postMgr *PostManager) Declarations() DeclarationStatus {
+	postMgr.syncMutex.Lock()
+	defer postMgr.syncMutex.Unlock()
+	return postMgr.declarations
+}
+
+// UnsyncedSince returns the time of the first failed post since the last
+// successful one, and the zero time when the last post succeeded
+func (postMgr *PostManager) UnsyncedSince() time.Time {
+	postMgr.syncMutex.Lock()
+	defer postMgr.syncMutex.Unlock()
+	return postMgr.unsyncedSince
+}
+
+// GetBigipAS3Version returns the version of AS3 installed on BIG-IP
+func (postMgr *PostManager) GetBigipAS3Version() (string, error) {
+	bigIPURL, _, _ := postMgr.getCredentials()
+	return resource.GetBigipAS3Version(bigIPURL, postMgr.httpPOST)
+}
+
 func (postMgr *PostManager) httpPOST(request *http.Request) (*http.Response, map[string]interface{}) {
-	httpResp, err := postMgr.httpClient.Do(request)
+	httpResp, err := postMgr.doRequest(request)
 	if err != nil {
 		log.Errorf("[AS3] REST call error: %v ", err)
 		return nil, nil
@@ -226,6 +395,7 @@ func (postMgr *PostManager) handleResponseStatusOK(responseMap map[string]interf
 		//log result with code, tenant and message
 		log.Debugf("[AS3] Response from BIG-IP: code: %v --- tenant:%v --- message: %v", v["code"], v["tenant"], v["message"])
 	}
+	postMgr.reportTenantErrors(nil)
 
 	return true
 }
@@ -250,6 +420,11 @@ func (postMgr *PostManager) handleResponseStatusNotFound(responseMap map[string]
 }
 
 func (postMgr *PostManager) handleResponseOthers(responseMap map[string]interface{}, cfg config) bool {
+	tenants := cfg.tenants
+	if len(tenants) == 0 {
+		tenants = []string{DEFAULT_PARTITION}
+	}
+	postMgr.reportTenantErrors(resource.GetAS3TenantErrors(responseMap, tenants))
 	if results, ok := (responseMap["results"]).([]interface{}); ok {
 		for _, value := range results {
 			v := value.(map[string]interface{})
@@ -267,3 +442,22 @@ func (postMgr *PostManager) handleResponseOthers(responseMap map[string]interfac
 	}
 	return postMgr.postOnEventOrTimeout(timeoutMedium, cfg)
 }
+
+// reportTenantErrors sends tenantErrors on tenantStatusChan if they differ
+// from the ones sent last, replacing any status not consumed yet
+func (postMgr *PostManager) reportTenantErrors(tenantErrors map[string]string) {
+	if len(tenantErrors) == 0 && len(postMgr.tenantErrors) == 0 ||
+		reflect.DeepEqual(tenantErrors, postMgr.tenantErrors) {
+		return
+	}
+	postMgr.tenantErrors = tenantErrors
+	status := make(map[string]string, len(tenantErrors))
+	for tenant, msg := range tenantErrors {
+		status[tenant] = msg
+	}
+	select {
+	case postMgr.tenantStatusChan <- status:
+	case <-postMgr.tenantStatusChan:
+		postMgr.tenantStatusChan <- status
+	}
+}
@@ -18,15 +18,13 @@ package crmanager
 
 import (
 	"fmt"
-	"net"
 	"reflect"
-	"regexp"
 	"sort"
 	"strings"
 	"sync"
 
 	cisapiv1 "github.com/F5Networks/k8s-bigip-ctlr/config/apis/cis/v1"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	rsc "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
 )
 
 // NewResources is Constructor for Resources
@@ -162,7 +160,7 @@ func (crMgr *CRManager) virtualPorts(vs *cisapiv1.VirtualServer) []portStruct {
 func formatVirtualServerName(ip string, port int32) string {
 	// Strip any bracket characters; replace special characters ". : /"
 	// with "-" and "%" with ".", for naming purposes
-	ip = strings.Trim(ip, "[]")
+	ip = strings.NewReplacer("[", "", "]", "").Replace(ip)
 	ip = AS3NameFormatter(ip)
 	return fmt.Sprintf("f5_crd_virtualserver_%s_%d", ip, port)
 }
@@ -197,15 +195,24 @@ func (crMgr *CRManager) createRSConfigFromVirtualServer(
 	// Create VirtualServer in resource config.
 	cfg.Virtual.Name = formatVirtualServerName(bindAddr, pStruct.port)
 
+	var minActive int32
+	if vs.Spec.ZoneAware {
+		minActive = vs.Spec.MinimumMembersActive
+		if minActive < 1 {
+			minActive = 1
+		}
+	}
+
 	for _, pl := range vs.Spec.Pools {
 		pool := Pool{
 			Name: formatVirtualServerPoolName(
 				vs.ObjectMeta.Namespace,
 				pl.Service,
 			),
-			Partition:   cfg.Virtual.Partition,
-			ServiceName: pl.Service,
-			ServicePort: pl.ServicePort,
+			Partition:            cfg.Virtual.Partition,
+			ServiceName:          pl.Service,
+			ServicePort:          pl.ServicePort,
+			MinimumMembersActive: minActive,
 		}
 		pools = append(pools, pool)
 	}
@@ -221,13 +228,22 @@ func (crMgr *CRManager) createRSConfigFromVirtualServer(
 	cfg.MetaData.ResourceType = VirtualServer
 	cfg.Virtual.Enabled = true
 	cfg.Virtual.SetVirtualAddress(bindAddr, pStruct.port)
+	if vs.Spec.SecondaryVirtualServerAddress != "" {
+		cfg.Virtual.SecondaryDestination = rsc.FormatVirtualDestination(
+			cfg.Virtual.Partition,
+			vs.Spec.SecondaryVirtualServerAddress,
+			pStruct.port,
+		)
+	}
 	cfg.Pools = append(cfg.Pools, pools...)
 	if plcy != nil {
 		cfg.SetPolicy(*plcy)
 	}
 
 	// If virtual server already exists with same name, it gets overridden
+	crMgr.resources.Lock()
 	crMgr.resources.rsMap[cfg.Virtual.Name] = &cfg
+	crMgr.resources.Unlock()
 	return &cfg
 }
 
@@ -241,21 +257,7 @@ func (v *Virtual) SetVirtualAddress(bindAddr string, port int32) {
 			BindAddr: bindAddr,
 			Port:     port,
 		}
-		// Validate the IP address, and create the destination
-		ip, rd := split_ip_with_route_domain(bindAddr)
-		if len(rd) > 0 {
-			rd = "%" + rd
-		}
-		addr := net.ParseIP(ip)
-		if nil != addr {
-			var format string
-			if nil != addr.To4() {
-				format = "/%s/%s%s:%d"
-			} else {
-				format = "/%s/%s%s.%d"
-			}
-			v.Destination = fmt.Sprintf(format, v.Partition, ip, rd, port)
-		}
+		v.Destination = rsc.FormatVirtualDestination(v.Partition, bindAddr, port)
 	}
 }
 
@@ -390,23 +392,6 @@ func (rc *ResourceConfig) copyConfig(cfg *ResourceConfig) {
 	}
 }
 
-// split_ip_with_route_domain splits ip into ip and route domain
-func split_ip_with_route_domain(address string) (ip string, rd string) {
-	// Split the address into the ip and routeDomain (optional) parts
-	//     address is of the form: <ipv4_or_ipv6>[%<routeDomainID>]
-	idRdRegex := regexp.MustCompile(`^([^%]*)%(\d+)$`)
-
-	match := idRdRegex.FindStringSubmatch(address)
-	if match != nil {
-		ip = match[1]
-		rd = match[2]
-	} else {
-		ip = address
-		rd = ""
-	}
-	return
-}
-
 // UpdateDependencies will keep the rs.objDeps map updated, and return two
 // arrays identifying what has changed - added for dependencies that were
 // added, and removed for dependencies that were removed.
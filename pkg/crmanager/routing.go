@@ -25,7 +25,6 @@ import (
 	"sync"
 
 	cisapiv1 "github.com/F5Networks/k8s-bigip-ctlr/config/apis/cis/v1"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 )
 
 // processVirtualServerRules process rules for VirtualServer
@@ -0,0 +1,154 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package crmanager
+
+import (
+	"fmt"
+	"strings"
+
+	cisapiv1 "github.com/F5Networks/k8s-bigip-ctlr/config/apis/cis/v1"
+	cisscheme "github.com/F5Networks/k8s-bigip-ctlr/config/client/clientset/versioned/scheme"
+
+	v1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// AS3ErrorAnnotation holds the AS3 error of the tenant a VirtualServer
+// contributes to, while BIG-IP rejects it
+const AS3ErrorAnnotation = "status.virtual-server.f5.com/as3-error"
+
+// tenantStatusWorker marks the VirtualServers of the tenants rejected by
+// BIG-IP with a Warning event and the AS3 error annotation, and removes the
+// annotation once BIG-IP accepts the declaration
+func (crMgr *CRManager) tenantStatusWorker() {
+	var recorder record.EventRecorder
+	if crMgr.kubeClient != nil {
+		broadcaster := record.NewBroadcaster()
+		broadcaster.StartRecordingToSink(&corev1.EventSinkImpl{
+			Interface: crMgr.kubeClient.CoreV1().Events(""),
+		})
+		recorder = broadcaster.NewRecorder(cisscheme.Scheme,
+			v1.EventSource{Component: "k8s-bigip-ctlr"})
+	}
+
+	for tenantErrors := range crMgr.Agent.tenantStatusChan {
+		if len(tenantErrors) == 0 {
+			for _, vs := range crMgr.listVirtualServers() {
+				crMgr.setAS3ErrorAnnotation(vs, "")
+			}
+			continue
+		}
+		for tenant, msg := range tenantErrors {
+			event := fmt.Sprintf("BIG-IP rejected the declaration of tenant %v: %v", tenant, msg)
+			for _, vs := range crMgr.getTenantVirtualServers(tenant, msg) {
+				if recorder != nil {
+					recorder.Event(vs, v1.EventTypeWarning, "AS3DeclarationFailed", event)
+				}
+				crMgr.setAS3ErrorAnnotation(vs, msg)
+			}
+		}
+	}
+}
+
+// getTenantVirtualServers returns the VirtualServers declared in tenant. If
+// msg names the AS3 path of some of their pools, only those are returned.
+func (crMgr *CRManager) getTenantVirtualServers(
+	tenant string,
+	msg string,
+) []*cisapiv1.VirtualServer {
+	// All VirtualServers are declared in the Shared application of the
+	// controller partition
+	if tenant != DEFAULT_PARTITION {
+		return nil
+	}
+	var all, mentioned []*cisapiv1.VirtualServer
+	for _, vs := range crMgr.listVirtualServers() {
+		all = append(all, vs)
+		for _, pl := range vs.Spec.Pools {
+			path := fmt.Sprintf("/%s/%s/%s", tenant, as3SharedApplication,
+				formatVirtualServerPoolName(vs.ObjectMeta.Namespace, pl.Service))
+			if mentionsAS3Path(msg, path) {
+				mentioned = append(mentioned, vs)
+				break
+			}
+		}
+	}
+	if len(mentioned) != 0 {
+		return mentioned
+	}
+	return all
+}
+
+// listVirtualServers returns the VirtualServers of all watched namespaces
+func (crMgr *CRManager) listVirtualServers() []*cisapiv1.VirtualServer {
+	var virtuals []*cisapiv1.VirtualServer
+	for _, crInf := range crMgr.crInformers {
+		for _, obj := range crInf.vsInformer.GetIndexer().List() {
+			virtuals = append(virtuals, obj.(*cisapiv1.VirtualServer))
+		}
+	}
+	return virtuals
+}
+
+// setAS3ErrorAnnotation sets the AS3 error annotation of vs to msg, or
+// removes it when msg is empty
+func (crMgr *CRManager) setAS3ErrorAnnotation(vs *cisapiv1.VirtualServer, msg string) {
+	if crMgr.kubeCRClient == nil {
+		return
+	}
+	current, found := vs.ObjectMeta.Annotations[AS3ErrorAnnotation]
+	if (msg == "" && !found) || (msg != "" && found && current == msg) {
+		return
+	}
+	vs = vs.DeepCopy()
+	if msg == "" {
+		delete(vs.ObjectMeta.Annotations, AS3ErrorAnnotation)
+	} else {
+		if vs.ObjectMeta.Annotations == nil {
+			vs.ObjectMeta.Annotations = make(map[string]string)
+		}
+		vs.ObjectMeta.Annotations[AS3ErrorAnnotation] = msg
+	}
+	_, err := crMgr.kubeCRClient.K8sV1().VirtualServers(vs.ObjectMeta.Namespace).Update(vs)
+	if err != nil {
+		log.Warningf("Error when updating %v annotation of VirtualServer %v/%v: %v",
+			AS3ErrorAnnotation, vs.ObjectMeta.Namespace, vs.ObjectMeta.Name, err)
+	}
+}
+
+// mentionsAS3Path reports whether msg refers to the AS3 object at path or
+// one of its children
+func mentionsAS3Path(msg, path string) bool {
+	for idx := strings.Index(msg, path); idx != -1; {
+		end := idx + len(path)
+		if end == len(msg) || !isAS3NameChar(msg[end]) {
+			return true
+		}
+		next := strings.Index(msg[end:], path)
+		if next == -1 {
+			break
+		}
+		idx = end + next
+	}
+	return false
+}
+
+func isAS3NameChar(c byte) bool {
+	return c == '_' || c == '-' || c == '.' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -17,11 +17,16 @@
 package crmanager
 
 import (
+	"sync"
+
 	"github.com/F5Networks/k8s-bigip-ctlr/config/client/clientset/versioned"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/pollers"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/staticroute"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/writer"
 
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -31,22 +36,37 @@ import (
 type (
 	// CRManager defines the structure of Custom Resource Manager
 	CRManager struct {
-		resources        *Resources
-		kubeCRClient     versioned.Interface
-		kubeClient       kubernetes.Interface
-		crInformers      map[string]*CRInformer
-		resourceSelector labels.Selector
-		namespaces       []string
-		rscQueue         workqueue.RateLimitingInterface
-		Partition        string
-		Agent            *Agent
-		ControllerMode   string
+		resources    *Resources
+		kubeCRClient versioned.Interface
+		kubeClient   kubernetes.Interface
+		// Client of the EndpointSlices, watched instead of the Endpoints
+		// when useEndpointSlices is set
+		dynamicClient     dynamic.Interface
+		useEndpointSlices bool
+		crInformers       map[string]*CRInformer
+		resourceSelector  labels.Selector
+		namespaces        []string
+		rscQueue          workqueue.RateLimitingInterface
+		Partition         string
+		Agent             *Agent
+		ControllerMode    string
 		// map of rules that have been merged
-		mergedRulesMap  map[string]map[string]mergedRuleEntry
-		nodePoller      pollers.Poller
-		oldNodes        []Node
+		mergedRulesMap map[string]map[string]mergedRuleEntry
+		nodePoller     pollers.Poller
+		// Routes the pod CIDRs of the nodes on BIG-IP, nil without static
+		// routing
+		staticRouteMgr *staticroute.RouteMgr
+		oldNodes       []Node
+		// Guards oldNodes, which the debug endpoints read
+		oldNodesMutex   sync.Mutex
 		UseNodeInternal bool
-		initState       bool
+		// Zone of the BIG-IP, whose members take precedence in zone aware
+		// pools
+		localZone string
+		initState bool
+		// Span of the first resource processed since the last post, parent
+		// of the next post. Only used by the worker.
+		postTrace tracing.SpanContext
 	}
 	// Params defines parameters
 	Params struct {
@@ -57,9 +77,14 @@ type (
 		ControllerMode    string
 		VXLANName         string
 		VXLANMode         string
+		TunnelType        string
 		UseNodeInternal   bool
 		NodePollInterval  int
+		WatchNodes        bool
 		NodeLabelSelector string
+		UseEndpointSlices bool
+		LocalZone         string
+		StaticRouteMgr    *staticroute.RouteMgr
 	}
 	// CRInformer defines the structure of Custom Resource Informer
 	CRInformer struct {
@@ -68,6 +93,8 @@ type (
 		vsInformer  cache.SharedIndexInformer
 		svcInformer cache.SharedIndexInformer
 		epsInformer cache.SharedIndexInformer
+		// Informer of the EndpointSlices, see resource.NewEndpointSliceInformer
+		epSliceInformer cache.SharedIndexInformer
 	}
 
 	rqKey struct {
@@ -76,6 +103,8 @@ type (
 		rscName   string
 		rsc       interface{}
 		rscDelete bool
+		// Span of the enqueue, parent of the processing span
+		trace tracing.SpanContext
 	}
 
 	metaData struct {
@@ -104,6 +133,8 @@ type (
 		IRules                []string              `json:"rules,omitempty"`
 		Description           string                `json:"description,omitempty"`
 		VirtualAddress        *virtualAddress       `json:"-"`
+		// Destination of the other IP family of a dual-stack virtual
+		SecondaryDestination string `json:"-"`
 	}
 	// Virtuals is slice of virtuals
 	Virtuals []Virtual
@@ -143,6 +174,8 @@ type (
 		ServiceName string   `json:"-"`
 		ServicePort int32    `json:"-"`
 		Members     []Member `json:"members"`
+		// Zone aware pools, see resource.ZonePriorityGroup
+		MinimumMembersActive int32 `json:"minimumMembersActive,omitempty"`
 	}
 	// Pools is slice of pool
 	Pools []Pool
@@ -233,11 +266,12 @@ type (
 type (
 	Agent struct {
 		*PostManager
-		Partition       string
-		ConfigWriter    writer.Writer
-		EventChan       chan interface{}
-		PythonDriverPID int
-		activeDecl      as3Declaration
+		Partition    string
+		ConfigWriter writer.Writer
+		EventChan    chan interface{}
+		activeDecl   as3Declaration
+		// Global section of the L2-L3 config writer, see UpdateGlobalSettings
+		global globalSection
 	}
 
 	AgentParams struct {
@@ -247,21 +281,14 @@ type (
 		LogLevel       string
 		VerifyInterval int
 		VXLANName      string
-		PythonBaseDir  string
+		// Applies the FDB records and ARP entries, see l2l3.Writer
+		ConfigWriter writer.Writer
 	}
 
 	globalSection struct {
 		LogLevel       string `json:"log-level,omitempty"`
 		VerifyInterval int    `json:"verify-interval,omitempty"`
 		VXLANPartition string `json:"vxlan-partition,omitempty"`
-		DisableLTM     bool   `json:"disable-ltm,omitempty"`
-	}
-
-	bigIPSection struct {
-		BigIPUsername   string   `json:"username,omitempty"`
-		BigIPPassword   string   `json:"password,omitempty"`
-		BigIPURL        string   `json:"url,omitempty"`
-		BigIPPartitions []string `json:"partitions,omitempty"`
 	}
 
 	as3Template    string
@@ -348,10 +375,11 @@ type (
 
 	// as3Pool maps to Pool in AS3 Resources
 	as3Pool struct {
-		Class             string               `json:"class,omitempty"`
-		LoadBalancingMode string               `json:"loadBalancingMode,omitempty"`
-		Members           []as3PoolMember      `json:"members,omitempty"`
-		Monitors          []as3ResourcePointer `json:"monitors,omitempty"`
+		Class                string               `json:"class,omitempty"`
+		LoadBalancingMode    string               `json:"loadBalancingMode,omitempty"`
+		Members              []as3PoolMember      `json:"members,omitempty"`
+		Monitors             []as3ResourcePointer `json:"monitors,omitempty"`
+		MinimumMembersActive int32                `json:"minimumMembersActive,omitempty"`
 	}
 
 	// as3PoolMember maps to Pool_Member in AS3 Resources
@@ -359,6 +387,7 @@ type (
 		AddressDiscovery string   `json:"addressDiscovery,omitempty"`
 		ServerAddresses  []string `json:"serverAddresses,omitempty"`
 		ServicePort      int32    `json:"servicePort,omitempty"`
+		PriorityGroup    int32    `json:"priorityGroup,omitempty"`
 	}
 
 	// as3ResourcePointer maps to following in AS3 Resources
@@ -378,20 +407,20 @@ type (
 	// - Service_TCP
 	// - Service_UDP
 	as3Service struct {
-		Layer4                 string            `json:"layer4,omitempty"`
-		Source                 string            `json:"source,omitempty"`
-		TranslateServerAddress bool              `json:"translateServerAddress,omitempty"`
-		TranslateServerPort    bool              `json:"translateServerPort,omitempty"`
-		Class                  string            `json:"class,omitempty"`
-		VirtualAddresses       []string          `json:"virtualAddresses,omitempty"`
-		VirtualPort            int               `json:"virtualPort,omitempty"`
-		SNAT                   string            `json:"snat,omitempty"`
-		PolicyEndpoint         as3MultiTypeParam `json:"policyEndpoint,omitempty"`
-		ClientTLS              as3MultiTypeParam `json:"clientTLS,omitempty"`
-		ServerTLS              as3MultiTypeParam `json:"serverTLS,omitempty"`
-		IRules                 []string          `json:"iRules,omitempty"`
-		Redirect80             *bool             `json:"redirect80,omitempty"`
-		Pool                   string            `json:"pool,omitempty"`
+		Layer4                 string              `json:"layer4,omitempty"`
+		Source                 string              `json:"source,omitempty"`
+		TranslateServerAddress bool                `json:"translateServerAddress,omitempty"`
+		TranslateServerPort    bool                `json:"translateServerPort,omitempty"`
+		Class                  string              `json:"class,omitempty"`
+		VirtualAddresses       []as3MultiTypeParam `json:"virtualAddresses,omitempty"`
+		VirtualPort            int                 `json:"virtualPort,omitempty"`
+		SNAT                   string              `json:"snat,omitempty"`
+		PolicyEndpoint         as3MultiTypeParam   `json:"policyEndpoint,omitempty"`
+		ClientTLS              as3MultiTypeParam   `json:"clientTLS,omitempty"`
+		ServerTLS              as3MultiTypeParam   `json:"serverTLS,omitempty"`
+		IRules                 []string            `json:"iRules,omitempty"`
+		Redirect80             *bool               `json:"redirect80,omitempty"`
+		Pool                   string              `json:"pool,omitempty"`
 	}
 
 	// as3Monitor maps to the following in AS3 Resources
@@ -469,8 +498,9 @@ type (
 	}
 
 	Member struct {
-		Address string `json:"address"`
-		Port    int32  `json:"port"`
-		Session string `json:"session,omitempty"`
+		Address       string `json:"address"`
+		Port          int32  `json:"port"`
+		Session       string `json:"session,omitempty"`
+		PriorityGroup int32  `json:"priorityGroup,omitempty"`
 	}
 )
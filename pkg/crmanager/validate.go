@@ -18,9 +18,10 @@ package crmanager
 
 import (
 	"fmt"
+	"net"
 
 	cisapiv1 "github.com/F5Networks/k8s-bigip-ctlr/config/apis/cis/v1"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	rsc "github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
 )
 
 func (crMgr *CRManager) checkValidVirtualServer(
@@ -52,5 +53,17 @@ func (crMgr *CRManager) checkValidVirtualServer(
 		return false
 	}
 
+	secondaryAddr := vsResource.Spec.SecondaryVirtualServerAddress
+	if secondaryAddr != "" {
+		ip, _ := rsc.Split_ip_with_route_domain(secondaryAddr)
+		if nil == net.ParseIP(ip) ||
+			rsc.IsIPv6(secondaryAddr) == rsc.IsIPv6(bindAddr) {
+			log.Errorf("The secondary address %s of the virtual server %s "+
+				"must be an address of the other IP family than %s",
+				secondaryAddr, vsName, bindAddr)
+			return false
+		}
+	}
+
 	return true
 }
@@ -22,7 +22,8 @@ import (
 	"time"
 
 	cisapiv1 "github.com/F5Networks/k8s-bigip-ctlr/config/apis/cis/v1"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
 	v1 "k8s.io/api/core/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 )
@@ -52,6 +53,15 @@ func (crMgr *CRManager) processResource() bool {
 	defer crMgr.rscQueue.Done(key)
 	rKey := key.(*rqKey)
 	log.Debugf("Processing Key: %v", rKey)
+	span := tracing.Start(rKey.trace, tracing.SpanProcessResource,
+		tracing.String("kind", rKey.kind),
+		tracing.String("namespace", rKey.namespace),
+		tracing.String("name", rKey.rscName),
+	)
+	defer span.End()
+	if !crMgr.postTrace.IsValid() {
+		crMgr.postTrace = span.SpanContext()
+	}
 
 	// Check the type of resource and process accordingly.
 	switch rKey.kind {
@@ -62,7 +72,9 @@ func (crMgr *CRManager) processResource() bool {
 			// TODO: Handle for TLS
 			// Use portSpec
 			vsName := formatVirtualServerName(vs.Spec.VirtualServerAddress, 80)
+			crMgr.resources.Lock()
 			crMgr.resources.deleteVirtualServer(vsName)
+			crMgr.resources.Unlock()
 			break
 		}
 		err := crMgr.syncVirtualServer(vs)
@@ -94,7 +106,7 @@ func (crMgr *CRManager) processResource() bool {
 			break
 		}
 		ep := rKey.rsc.(*v1.Endpoints)
-		svc := crMgr.syncEndpoints(ep)
+		svc := crMgr.getService(ep.ObjectMeta.Namespace, ep.ObjectMeta.Name)
 		// No Services are effected with the change in service.
 		if nil == svc {
 			break
@@ -108,38 +120,63 @@ func (crMgr *CRManager) processResource() bool {
 				isError = true
 			}
 		}
+	case EndpointSlice:
+		if crMgr.initState {
+			break
+		}
+		svc := crMgr.getService(rKey.namespace, rKey.rscName)
+		if nil == svc {
+			break
+		}
+		virtuals := crMgr.syncService(svc)
+		for _, virtual := range virtuals {
+			err := crMgr.syncVirtualServer(virtual)
+			if err != nil {
+				// TODO
+				utilruntime.HandleError(fmt.Errorf("Sync %v failed with %v", key, err))
+				isError = true
+			}
+		}
 	default:
 		log.Errorf("Unknown resource Kind: %v", rKey.kind)
 	}
 
 	if isError {
+		span.SetStatus(tracing.StatusError, "sync failed")
 		crMgr.rscQueue.AddRateLimited(key)
 	} else {
 		crMgr.rscQueue.Forget(key)
 	}
 
-	if isLastInQueue && !reflect.DeepEqual(
-		crMgr.resources.rsMap,
-		crMgr.resources.oldRsMap,
-	) {
-
-		crMgr.Agent.PostConfig(crMgr.resources.GetAllResources())
+	if !isLastInQueue {
+		return true
+	}
+	// The debug endpoints read the resources concurrently, they are only
+	// locked while read and written, not while posted
+	crMgr.resources.Lock()
+	changed := !reflect.DeepEqual(crMgr.resources.rsMap, crMgr.resources.oldRsMap)
+	rsCfgs := crMgr.resources.GetAllResources()
+	crMgr.resources.Unlock()
+	if changed {
+		crMgr.Agent.PostConfig(crMgr.postTrace, rsCfgs)
+		crMgr.postTrace = tracing.SpanContext{}
 		crMgr.initState = false
+		crMgr.resources.Lock()
 		crMgr.resources.updateOldConfig()
+		crMgr.resources.Unlock()
 	}
 	return true
 }
 
-// syncEndpoints returns the service associated with endpoints.
-func (crMgr *CRManager) syncEndpoints(ep *v1.Endpoints) *v1.Service {
+// getService returns the service associated with endpoints or
+// EndpointSlices.
+func (crMgr *CRManager) getService(namespace, name string) *v1.Service {
 
-	epName := ep.ObjectMeta.Name
-	epNamespace := ep.ObjectMeta.Namespace
-	svcKey := fmt.Sprintf("%s/%s", epNamespace, epName)
+	svcKey := fmt.Sprintf("%s/%s", namespace, name)
 
-	crInf, ok := crMgr.getNamespaceInformer(epNamespace)
+	crInf, ok := crMgr.getNamespaceInformer(namespace)
 	if !ok {
-		log.Errorf("Informer not found for namespace: %v", epNamespace)
+		log.Errorf("Informer not found for namespace: %v", namespace)
 		return nil
 	}
 	svc, exists, err := crInf.svcInformer.GetIndexer().GetByKey(svcKey)
@@ -419,8 +456,14 @@ func (crMgr *CRManager) updatePoolMembersForNodePort(
 			// TODO: Instead of looping over Spec Ports, get the port from the pool itself
 			for _, portSpec := range svc.Spec.Ports {
 				rsCfg.MetaData.Active = true
-				rsCfg.Pools[index].Members =
-					crMgr.getEndpointsForNodePort(portSpec.NodePort)
+				if resource.HasLocalTrafficPolicy(svc) {
+					rsCfg.Pools[index].Members = crMgr.getLocalEndpointsForNodePort(
+						crInf, svc, portSpec.NodePort)
+				} else {
+					rsCfg.Pools[index].Members =
+						crMgr.getEndpointsForNodePort(portSpec.NodePort)
+				}
+				crMgr.setZonePriorityGroups(&rsCfg.Pools[index], nil)
 			}
 		} else {
 			log.Debugf("Requested service backend %s not of NodePort or LoadBalancer type",
@@ -446,13 +489,24 @@ func (crMgr *CRManager) updatePoolMembersForCluster(
 		svcName := pool.ServiceName
 		svcKey := namespace + "/" + svcName
 
-		// TODO: Too Many API calls?
-		item, found, _ := crInf.epsInformer.GetIndexer().GetByKey(svcKey)
-		if !found {
-			log.Debugf("Endpoints for service '%v' not found!", svcKey)
-			continue
+		var eps *v1.Endpoints
+		var slices []*resource.EndpointSlice
+		if crInf.epSliceInformer != nil {
+			slices, _ = resource.GetServiceEndpointSlices(
+				crInf.epSliceInformer.GetIndexer(), namespace, svcName)
+			if len(slices) == 0 {
+				log.Debugf("EndpointSlices for service '%v' not found!", svcKey)
+				continue
+			}
+		} else {
+			// TODO: Too Many API calls?
+			item, found, _ := crInf.epsInformer.GetIndexer().GetByKey(svcKey)
+			if !found {
+				log.Debugf("Endpoints for service '%v' not found!", svcKey)
+				continue
+			}
+			eps, _ = item.(*v1.Endpoints)
 		}
-		eps, _ := item.(*v1.Endpoints)
 		// TODO: Too Many API calls?
 		// Get Service
 		service, exist, _ := crInf.svcInformer.GetIndexer().GetByKey(svcKey)
@@ -466,11 +520,23 @@ func (crMgr *CRManager) updatePoolMembersForCluster(
 		svc := service.(*v1.Service)
 
 		// TODO: Instead of looping over Spec Ports, get the port from the pool itself
+		var memberNodes map[string]string
+		if slices != nil {
+			memberNodes = resource.EndpointSliceNodes(slices)
+		} else {
+			memberNodes = resource.EndpointsNodes(eps)
+		}
 		for _, portSpec := range svc.Spec.Ports {
-			ipPorts := crMgr.getEndpointsForCluster(portSpec.Name, eps)
+			var ipPorts []Member
+			if slices != nil {
+				ipPorts = crMgr.getEndpointSliceMembers(portSpec.Name, slices)
+			} else {
+				ipPorts = crMgr.getEndpointsForCluster(portSpec.Name, eps)
+			}
 			log.Debugf("Found endpoints for backend %+v: %v", svcKey, ipPorts)
 			rsCfg.MetaData.Active = true
 			rsCfg.Pools[index].Members = ipPorts
+			crMgr.setZonePriorityGroups(&rsCfg.Pools[index], memberNodes)
 		}
 	}
 }
@@ -493,6 +559,38 @@ func (crMgr *CRManager) getEndpointsForNodePort(
 	return members
 }
 
+// getLocalEndpointsForNodePort returns the members of the node port of a
+// Service with the Local external traffic policy, on the nodes hosting a
+// ready endpoint of the Service.
+func (crMgr *CRManager) getLocalEndpointsForNodePort(
+	crInf *CRInformer,
+	svc *v1.Service,
+	nodePort int32,
+) []Member {
+	var readyNodes map[string]bool
+	if crInf.epSliceInformer != nil {
+		slices, _ := resource.GetServiceEndpointSlices(
+			crInf.epSliceInformer.GetIndexer(), svc.Namespace, svc.Name)
+		readyNodes = resource.EndpointSliceReadyNodes(slices)
+	} else {
+		item, _, _ := crInf.epsInformer.GetIndexer().GetByKey(
+			svc.Namespace + "/" + svc.Name)
+		eps, _ := item.(*v1.Endpoints)
+		readyNodes = resource.EndpointsReadyNodes(eps)
+	}
+	var members []Member
+	for _, v := range crMgr.getNodesFromCache() {
+		if readyNodes[v.Name] {
+			members = append(members, Member{
+				Address: v.Addr,
+				Port:    nodePort,
+				Session: "user-enabled",
+			})
+		}
+	}
+	return members
+}
+
 // getEndpointsForCluster returns members.
 func (crMgr *CRManager) getEndpointsForCluster(
 	portName string,
@@ -524,6 +622,45 @@ func (crMgr *CRManager) getEndpointsForCluster(
 	return members
 }
 
+// getEndpointSliceMembers returns members.
+func (crMgr *CRManager) getEndpointSliceMembers(
+	portName string,
+	slices []*resource.EndpointSlice,
+) []Member {
+	nodes := crMgr.getNodesFromCache()
+	onNode := func(nodeName string) bool {
+		return containsNode(nodes, nodeName)
+	}
+	var members []Member
+	for _, member := range resource.EndpointSliceMembers(slices, portName, onNode) {
+		members = append(members, Member(member))
+	}
+	return members
+}
+
+// setZonePriorityGroups gives the members of a zone aware pool in the local
+// zone of the BIG-IP precedence over the others. memberNodes maps the
+// addresses of pod members to their node, the members of NodePort mode are
+// nodes.
+func (crMgr *CRManager) setZonePriorityGroups(pool *Pool, memberNodes map[string]string) {
+	if 0 == pool.MinimumMembersActive || "" == crMgr.localZone {
+		return
+	}
+	zonesByName := make(map[string]string)
+	zonesByAddr := make(map[string]string)
+	for _, node := range crMgr.getNodesFromCache() {
+		zonesByName[node.Name] = node.Zone
+		zonesByAddr[node.Addr] = node.Zone
+	}
+	for i, member := range pool.Members {
+		zone := zonesByAddr[member.Address]
+		if nil != memberNodes {
+			zone = zonesByName[memberNodes[member.Address]]
+		}
+		pool.Members[i].PriorityGroup = resource.ZonePriorityGroup(zone, crMgr.localZone)
+	}
+}
+
 // containsNode returns true for a valid node.
 func containsNode(nodes []Node, name string) bool {
 	for _, node := range nodes {
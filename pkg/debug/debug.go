@@ -0,0 +1,197 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package debug serves read-only JSON views of the controller state under
+// /debug/, with secrets redacted.
+package debug
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+)
+
+// PathPrefix is where the debug endpoints are served
+const PathPrefix = "/debug/"
+
+// Redacted replaces secrets in the debug output
+const Redacted = "<redacted>"
+
+// StateFunc returns a piece of controller state to be marshalled to JSON.
+// State guarded by a lock can be returned already marshalled, as a
+// json.RawMessage.
+type StateFunc func() (interface{}, error)
+
+// MarshalState marshals state for a StateFunc while the caller holds the
+// lock guarding it
+func MarshalState(state interface{}) (interface{}, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
+}
+
+// Server serves the registered state functions at /debug/<name>
+type Server struct {
+	// Bearer token required to access the endpoints, none when empty
+	token    string
+	mutex    sync.RWMutex
+	handlers map[string]StateFunc
+}
+
+// NewServer creates a debug Server protected by token, if not empty
+func NewServer(token string) *Server {
+	return &Server{
+		token:    token,
+		handlers: make(map[string]StateFunc),
+	}
+}
+
+// Register serves the state returned by fn at /debug/<name>
+func (srv *Server) Register(name string, fn StateFunc) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.handlers[name] = fn
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !Authorized(r, srv.token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, PathPrefix), "/")
+	srv.mutex.RLock()
+	fn, found := srv.handlers[name]
+	names := make([]string, 0, len(srv.handlers))
+	for n := range srv.handlers {
+		names = append(names, n)
+	}
+	srv.mutex.RUnlock()
+
+	var state interface{}
+	var err error
+	switch {
+	case name == "":
+		// Index of the available endpoints
+		sort.Strings(names)
+		state = names
+	case found:
+		state, err = fn()
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	var body []byte
+	if err == nil {
+		body, err = marshalRedacted(state)
+	}
+	if err != nil {
+		log.Errorf("[DEBUG] Failed to get %v: %v", name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// Authorized checks the bearer token of r against token, if not empty
+func Authorized(r *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) == 1
+}
+
+// marshalRedacted marshals state to indented JSON with secrets replaced
+func marshalRedacted(state interface{}) ([]byte, error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	var obj interface{}
+	if err = json.Unmarshal(data, &obj); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(Redact(obj), "", "  ")
+}
+
+// secretKeys are object keys whose values are always redacted
+var secretKeys = map[string]bool{
+	"privatekey": true,
+	"passphrase": true,
+	"password":   true,
+	"token":      true,
+}
+
+// Redact replaces passwords, passphrases, tokens and PEM private keys in
+// an unmarshalled JSON value. Strings holding JSON, like AS3 declarations
+// and templates, are redacted as well.
+func Redact(obj interface{}) interface{} {
+	switch v := obj.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if secretKeys[strings.ToLower(key)] {
+				if val != nil && val != "" {
+					v[key] = Redacted
+				}
+				continue
+			}
+			v[key] = Redact(val)
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = Redact(val)
+		}
+	case string:
+		return redactString(v)
+	}
+	return obj
+}
+
+func redactString(str string) string {
+	hasKey := strings.Contains(str, "PRIVATE KEY")
+	if !hasKey && !strings.Contains(str, `"privateKey"`) &&
+		!strings.Contains(str, `"passphrase"`) && !strings.Contains(str, `"password"`) {
+		return str
+	}
+	var obj interface{}
+	if strings.HasPrefix(strings.TrimSpace(str), "{") && json.Unmarshal([]byte(str), &obj) == nil {
+		if data, err := json.Marshal(Redact(obj)); err == nil {
+			return string(data)
+		}
+	}
+	if hasKey {
+		return Redacted
+	}
+	return str
+}
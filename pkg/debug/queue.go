@@ -0,0 +1,122 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package debug
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// QueueContents lists the items of a work queue
+type QueueContents struct {
+	// Items waiting to be processed
+	Queued []string `json:"queued"`
+	// Items added with a delay or rate limit, not queued yet
+	Waiting []string `json:"waiting"`
+	// Items being processed by a worker
+	Processing []string `json:"processing"`
+}
+
+// InspectableQueue is a rate limiting work queue that tracks its items, as
+// the work queue itself does not expose them
+type InspectableQueue struct {
+	workqueue.RateLimitingInterface
+	mutex      sync.Mutex
+	queued     map[interface{}]bool
+	waiting    map[interface{}]bool
+	processing map[interface{}]bool
+}
+
+// NewInspectableQueue wraps queue to track its items
+func NewInspectableQueue(queue workqueue.RateLimitingInterface) *InspectableQueue {
+	return &InspectableQueue{
+		RateLimitingInterface: queue,
+		queued:                make(map[interface{}]bool),
+		waiting:               make(map[interface{}]bool),
+		processing:            make(map[interface{}]bool),
+	}
+}
+
+func (q *InspectableQueue) Add(item interface{}) {
+	q.mutex.Lock()
+	q.queued[item] = true
+	q.mutex.Unlock()
+	q.RateLimitingInterface.Add(item)
+}
+
+func (q *InspectableQueue) AddAfter(item interface{}, duration time.Duration) {
+	q.mutex.Lock()
+	if duration <= 0 {
+		q.queued[item] = true
+	} else if !q.queued[item] {
+		q.waiting[item] = true
+	}
+	q.mutex.Unlock()
+	q.RateLimitingInterface.AddAfter(item, duration)
+}
+
+func (q *InspectableQueue) AddRateLimited(item interface{}) {
+	q.mutex.Lock()
+	if !q.queued[item] {
+		q.waiting[item] = true
+	}
+	q.mutex.Unlock()
+	q.RateLimitingInterface.AddRateLimited(item)
+}
+
+func (q *InspectableQueue) Get() (interface{}, bool) {
+	item, shutdown := q.RateLimitingInterface.Get()
+	if item != nil {
+		q.mutex.Lock()
+		delete(q.queued, item)
+		delete(q.waiting, item)
+		q.processing[item] = true
+		q.mutex.Unlock()
+	}
+	return item, shutdown
+}
+
+func (q *InspectableQueue) Done(item interface{}) {
+	q.mutex.Lock()
+	delete(q.processing, item)
+	q.mutex.Unlock()
+	q.RateLimitingInterface.Done(item)
+}
+
+// Contents returns the items of the queue, formatted with %+v
+func (q *InspectableQueue) Contents() QueueContents {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return QueueContents{
+		Queued:     formatItems(q.queued),
+		Waiting:    formatItems(q.waiting),
+		Processing: formatItems(q.processing),
+	}
+}
+
+func formatItems(items map[interface{}]bool) []string {
+	formatted := []string{}
+	for item := range items {
+		formatted = append(formatted, fmt.Sprintf("%+v", item))
+	}
+	sort.Strings(formatted)
+	return formatted
+}
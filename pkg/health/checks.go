@@ -0,0 +1,91 @@
+package health
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"k8s.io/client-go/discovery"
+)
+
+// Check reports why a component is unhealthy, nil when it is healthy
+type Check func() error
+
+// InformersSyncedCheck fails until synced reports that the informer caches
+// hold the initial state of the watched resources
+func InformersSyncedCheck(synced func() bool) Check {
+	return func() error {
+		if !synced() {
+			return fmt.Errorf("informer caches are not synced")
+		}
+		return nil
+	}
+}
+
+// KubernetesAPICheck fails when the Kubernetes API server does not answer
+// a version request
+func KubernetesAPICheck(client discovery.ServerVersionInterface) Check {
+	return func() error {
+		if _, err := client.ServerVersion(); err != nil {
+			return fmt.Errorf("Kubernetes API is unreachable: %v", err)
+		}
+		return nil
+	}
+}
+
+// AS3Check fails when the BIG-IP AS3 endpoint does not report its version.
+// The result is kept for ttl, so that frequent probes do not load BIG-IP.
+func AS3Check(getVersion func() (string, error), ttl time.Duration) Check {
+	var mutex sync.Mutex
+	var checked time.Time
+	var result error
+	return func() error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !checked.IsZero() && time.Since(checked) < ttl {
+			return result
+		}
+		result = nil
+		if _, err := getVersion(); err != nil {
+			result = fmt.Errorf("BIG-IP AS3 endpoint is unreachable: %v", err)
+		}
+		checked = time.Now()
+		return result
+	}
+}
+
+// LastPostCheck fails when configuration has been waiting for a successful
+// post to BIG-IP for longer than maxAge. unsyncedSince returns the time of
+// the first failed post since the last successful one, and the zero time
+// when the last post succeeded.
+func LastPostCheck(unsyncedSince func() time.Time, maxAge time.Duration) Check {
+	return func() error {
+		since := unsyncedSince()
+		if since.IsZero() {
+			return nil
+		}
+		if age := time.Since(since); age > maxAge {
+			return fmt.Errorf("no successful post to BIG-IP for %v",
+				age.Round(time.Second))
+		}
+		return nil
+	}
+}
+
+// ProcessCheck fails when the process pid, e.g. the python driver used for
+// CCCL, is not running
+func ProcessCheck(pid int) Check {
+	return func() error {
+		proc, err := os.FindProcess(pid)
+		if err == nil {
+			// Signal 0 checks that the process exists without affecting it
+			err = proc.Signal(syscall.Signal(0))
+		}
+		if err != nil {
+			return fmt.Errorf("process %d is not running: %v", pid, err)
+		}
+		return nil
+	}
+}
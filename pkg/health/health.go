@@ -1,33 +1,137 @@
 package health
 
 import (
+	"encoding/json"
 	"net/http"
 	"os"
+	"sync"
 
 	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 )
 
+const (
+	statusOK     = "ok"
+	statusFailed = "failed"
+)
+
 type HealthChecker struct {
 	SubPID int
+	// Reports the AS3 circuit breaker state, nil when AS3 is not in use
+	AS3BreakerState func() string
+
+	mutex     sync.RWMutex
+	liveness  []namedCheck
+	readiness []namedCheck
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// CheckResult is the outcome of a single check in the /health/live and
+// /health/ready responses
+type CheckResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the JSON body of the /health/live and /health/ready responses
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// AddLivenessCheck registers a check served by LiveHandler. A failing
+// liveness check means the controller has to be restarted.
+func (hc *HealthChecker) AddLivenessCheck(name string, check Check) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+	hc.liveness = append(hc.liveness, namedCheck{name: name, check: check})
+}
+
+// AddReadinessCheck registers a check served by ReadyHandler. A failing
+// readiness check means the controller can not apply configuration now.
+func (hc *HealthChecker) AddReadinessCheck(name string, check Check) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+	hc.readiness = append(hc.readiness, namedCheck{name: name, check: check})
+}
+
+// LiveHandler serves the liveness checks
+func (hc *HealthChecker) LiveHandler() http.Handler {
+	return hc.checksHandler(func() []namedCheck { return hc.liveness })
+}
+
+// ReadyHandler serves the readiness checks
+func (hc *HealthChecker) ReadyHandler() http.Handler {
+	return hc.checksHandler(func() []namedCheck { return hc.readiness })
+}
+
+// checksHandler runs the checks returned by getChecks and writes their
+// results as a Report, with status 503 if any of them failed
+func (hc *HealthChecker) checksHandler(getChecks func() []namedCheck) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hc.mutex.RLock()
+		checks := getChecks()
+		hc.mutex.RUnlock()
+
+		report := runChecks(checks)
+		body, err := json.Marshal(report)
+		if err != nil {
+			log.Errorf("Failed to marshal health report: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status != statusOK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+		w.Write(body)
+	})
+}
+
+func runChecks(checks []namedCheck) Report {
+	report := Report{
+		Status: statusOK,
+		Checks: []CheckResult{},
+	}
+	for _, nc := range checks {
+		result := CheckResult{Name: nc.name, Status: statusOK}
+		if err := nc.check(); err != nil {
+			result.Status = statusFailed
+			result.Error = err.Error()
+			report.Status = statusFailed
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
 }
 
 //TODO: Add additional health checks
 //TODO: add health check if Kubernetes API is still reachable
-func (hc HealthChecker) HealthCheckHandler() http.Handler {
+func (hc *HealthChecker) HealthCheckHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Without SubPID, as with the AS3 agent, there is no python driver
+		// to watch. Otherwise, assume that Python process is still running
+		// when it is found.
 		if hc.SubPID != 0 {
-			_, err := os.FindProcess(hc.SubPID)
-			if err == nil {
-				// assume that Python process is still running
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("Ok"))
+			if _, err := os.FindProcess(hc.SubPID); err != nil {
+				log.Errorf(err.Error())
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte("Python process is dead"))
 				return
 			}
-
-			log.Errorf(err.Error())
 		}
-
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Python process is dead"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Ok"))
+		if hc.AS3BreakerState != nil {
+			// An open breaker means BIG-IP is unreachable, which a
+			// restart of the controller would not fix
+			w.Write([]byte("\nAS3 circuit breaker: " + hc.AS3BreakerState()))
+		}
 	})
 }
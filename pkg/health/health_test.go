@@ -0,0 +1,120 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func getReport(handler http.Handler) (int, Report) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/health/ready", nil))
+	var report Report
+	Expect(json.Unmarshal(rec.Body.Bytes(), &report)).To(Succeed())
+	return rec.Code, report
+}
+
+var _ = Describe("Health Check Tests", func() {
+	var hc *HealthChecker
+
+	BeforeEach(func() {
+		hc = &HealthChecker{}
+	})
+
+	It("Reports ok without checks", func() {
+		code, report := getReport(hc.ReadyHandler())
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report.Status).To(Equal("ok"))
+		Expect(report.Checks).To(BeEmpty())
+	})
+
+	It("Reports each check in the body", func() {
+		hc.AddReadinessCheck("good", func() error { return nil })
+		hc.AddReadinessCheck("bad", func() error { return fmt.Errorf("broken") })
+		hc.AddLivenessCheck("alive", func() error { return nil })
+
+		code, report := getReport(hc.ReadyHandler())
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(report).To(Equal(Report{
+			Status: "failed",
+			Checks: []CheckResult{
+				{Name: "good", Status: "ok"},
+				{Name: "bad", Status: "failed", Error: "broken"},
+			},
+		}))
+
+		code, report = getReport(hc.LiveHandler())
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report.Checks).To(Equal([]CheckResult{{Name: "alive", Status: "ok"}}))
+	})
+
+	It("Checks informer sync", func() {
+		synced := false
+		check := InformersSyncedCheck(func() bool { return synced })
+		Expect(check()).NotTo(Succeed())
+		synced = true
+		Expect(check()).To(Succeed())
+	})
+
+	It("Checks the Kubernetes API and the AS3 endpoint", func() {
+		Expect(KubernetesAPICheck(fake.NewSimpleClientset().Discovery())()).To(Succeed())
+
+		Expect(AS3Check(func() (string, error) { return "3.18", nil }, 0)()).To(Succeed())
+		err := AS3Check(func() (string, error) { return "", fmt.Errorf("timeout") }, 0)()
+		Expect(err).To(MatchError(ContainSubstring("timeout")))
+	})
+
+	It("Caches the result of the AS3 endpoint check", func() {
+		calls := 0
+		var versionErr error
+		getVersion := func() (string, error) {
+			calls++
+			return "3.18", versionErr
+		}
+		check := AS3Check(getVersion, time.Hour)
+		Expect(check()).To(Succeed())
+		versionErr = fmt.Errorf("timeout")
+		Expect(check()).To(Succeed())
+		Expect(calls).To(Equal(1))
+
+		check = AS3Check(getVersion, 0)
+		Expect(check()).NotTo(Succeed())
+		Expect(check()).NotTo(Succeed())
+		Expect(calls).To(Equal(3))
+	})
+
+	It("Checks the age of failing posts", func() {
+		var since time.Time
+		check := LastPostCheck(func() time.Time { return since }, time.Minute)
+		Expect(check()).To(Succeed())
+		since = time.Now().Add(-30 * time.Second)
+		Expect(check()).To(Succeed())
+		since = time.Now().Add(-2 * time.Minute)
+		Expect(check()).NotTo(Succeed())
+	})
+
+	It("Checks that a process is running", func() {
+		Expect(ProcessCheck(os.Getpid())()).To(Succeed())
+	})
+
+	It("Reports ok without python driver", func() {
+		rec := httptest.NewRecorder()
+		hc.HealthCheckHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		hc.SubPID = os.Getpid()
+		hc.AS3BreakerState = func() string { return "closed" }
+		rec = httptest.NewRecorder()
+		hc.HealthCheckHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("Ok\nAS3 circuit breaker: closed"))
+	})
+})
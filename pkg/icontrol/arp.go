@@ -0,0 +1,63 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package icontrol
+
+import "net/url"
+
+const arpPath = "/mgmt/tm/net/arp"
+
+// ARP is a static ARP entry of BIG-IP, a net arp object
+type ARP struct {
+	Name       string `json:"name"`
+	Partition  string `json:"partition,omitempty"`
+	IPAddress  string `json:"ipAddress"`
+	MACAddress string `json:"macAddress"`
+}
+
+type arpList struct {
+	Items []ARP `json:"items"`
+}
+
+// ARPs returns the static ARP entries of partition
+func (c *Client) ARPs(partition string) ([]ARP, error) {
+	var arps arpList
+	query := url.Values{"$filter": {"partition eq " + partition}}
+	if err := c.get(arpPath+"?"+query.Encode(), &arps); err != nil {
+		return nil, err
+	}
+	return arps.Items, nil
+}
+
+// CreateARP creates arp
+func (c *Client) CreateARP(arp ARP) error {
+	return c.post(arpPath, arp)
+}
+
+// UpdateARPMAC sets the MAC address of the ARP entry name of partition
+func (c *Client) UpdateARPMAC(partition, name, mac string) error {
+	return c.patch(objectPath(arpPath, partition, name), map[string]string{"macAddress": mac})
+}
+
+// DeleteARP deletes the ARP entry name of partition, which may already be
+// deleted
+func (c *Client) DeleteARP(partition, name string) error {
+	err := c.delete(objectPath(arpPath, partition, name))
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package icontrol manages the BIG-IP network objects that AS3 does not
+// declare, with the iControl REST API.
+package icontrol
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tokenmanager"
+)
+
+const requestTimeout = 30 * time.Second
+
+// Params of a Client
+type Params struct {
+	BIGIPURL      string
+	BIGIPUsername string
+	BIGIPPassword string
+	TrustedCerts  string
+	SSLInsecure   bool
+	// Use token based authentication instead of basic auth
+	TokenAuth bool
+	// BIG-IP login provider used for token based authentication
+	LoginProvider string
+}
+
+// Client sends iControl REST requests to BIG-IP
+type Client struct {
+	httpClient *http.Client
+	// Authenticates requests with X-F5-Auth-Token when token auth is enabled
+	tokenManager *tokenmanager.TokenManager
+	// Guards the BIG-IP URL and credentials, which can be swapped at runtime
+	credsMutex sync.RWMutex
+	bigIPURL   string
+	username   string
+	password   string
+}
+
+// Error is the error of a request BIG-IP answered with a status other than
+// 2xx
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("BIG-IP responded with status code %v: %v", e.StatusCode, e.Message)
+}
+
+// IsNotFound tells whether err is a 404 response of BIG-IP
+func IsNotFound(err error) bool {
+	icrErr, ok := err.(*Error)
+	return ok && icrErr.StatusCode == http.StatusNotFound
+}
+
+// NewClient returns a Client of the BIG-IP given by params
+func NewClient(params Params) *Client {
+	// Get the SystemCertPool, continue with an empty pool on error
+	rootCAs, _ := x509.SystemCertPool()
+	if rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	rootCAs.AppendCertsFromPEM([]byte(params.TrustedCerts))
+
+	client := &Client{
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: params.SSLInsecure,
+					RootCAs:            rootCAs,
+				},
+			},
+			Timeout: requestTimeout,
+		},
+		bigIPURL: strings.TrimRight(params.BIGIPURL, "/"),
+		username: params.BIGIPUsername,
+		password: params.BIGIPPassword,
+	}
+	if params.TokenAuth {
+		client.tokenManager = tokenmanager.NewTokenManager(
+			client.bigIPURL,
+			params.BIGIPUsername,
+			params.BIGIPPassword,
+			params.LoginProvider,
+			client.httpClient,
+		)
+	}
+	return client
+}
+
+// UpdateCredentials swaps the BIG-IP URL and credentials used by subsequent
+// requests
+func (c *Client) UpdateCredentials(bigIPURL, username, password string) {
+	bigIPURL = strings.TrimRight(bigIPURL, "/")
+	c.credsMutex.Lock()
+	c.bigIPURL = bigIPURL
+	c.username = username
+	c.password = password
+	c.credsMutex.Unlock()
+
+	if c.tokenManager != nil {
+		c.tokenManager.SetCredentials(bigIPURL, username, password)
+	}
+}
+
+// get decodes the resource at path into v
+func (c *Client) get(path string, v interface{}) error {
+	return c.do("GET", path, nil, v)
+}
+
+// post creates the resource body in the collection at path
+func (c *Client) post(path string, body interface{}) error {
+	return c.do("POST", path, body, nil)
+}
+
+// patch modifies the resource at path with the properties of body
+func (c *Client) patch(path string, body interface{}) error {
+	return c.do("PATCH", path, body, nil)
+}
+
+// delete removes the resource at path
+func (c *Client) delete(path string) error {
+	return c.do("DELETE", path, nil, nil)
+}
+
+func (c *Client) do(method, path string, body, v interface{}) error {
+	c.credsMutex.RLock()
+	bigIPURL, username, password := c.bigIPURL, c.username, c.password
+	c.credsMutex.RUnlock()
+
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, bigIPURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var resp *http.Response
+	if c.tokenManager != nil {
+		resp, err = c.tokenManager.Do(req)
+	} else {
+		req.SetBasicAuth(username, password)
+		resp, err = c.httpClient.Do(req)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// iControl REST errors have a code and a message
+		var icrErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(respBody, &icrErr) != nil || icrErr.Message == "" {
+			icrErr.Message = http.StatusText(resp.StatusCode)
+		}
+		return &Error{StatusCode: resp.StatusCode, Message: icrErr.Message}
+	}
+	if v != nil {
+		return json.Unmarshal(respBody, v)
+	}
+	return nil
+}
+
+// objectPath returns the path of the object name of partition in the
+// collection at path, as in /mgmt/tm/net/route/~Common~name
+func objectPath(path, partition, name string) string {
+	return fmt.Sprintf("%s/~%s~%s", path, partition, name)
+}
@@ -0,0 +1,257 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package icontrol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// mockBigIP keeps the objects of the iControl REST collections it serves,
+// by collection path and full path
+type mockBigIP struct {
+	sync.Mutex
+	server   *httptest.Server
+	objects  map[string]map[string]map[string]interface{}
+	requests []string
+}
+
+func newMockBigIP() *mockBigIP {
+	bigip := &mockBigIP{objects: make(map[string]map[string]map[string]interface{})}
+	bigip.server = httptest.NewServer(http.HandlerFunc(bigip.serve))
+	return bigip
+}
+
+func (bigip *mockBigIP) serve(w http.ResponseWriter, r *http.Request) {
+	bigip.Lock()
+	defer bigip.Unlock()
+
+	bigip.requests = append(bigip.requests, r.Method+" "+r.URL.Path)
+	if user, pass, ok := r.BasicAuth(); !ok || user != "admin" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	collection, fullPath := r.URL.Path, ""
+	if i := strings.Index(r.URL.Path, "/~"); i != -1 {
+		collection, fullPath = r.URL.Path[:i], strings.Replace(r.URL.Path[i+1:], "~", "/", -1)
+	}
+	objects, ok := bigip.objects[collection]
+	if !ok {
+		objects = make(map[string]map[string]interface{})
+		bigip.objects[collection] = objects
+	}
+
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"code": 404, "message": "01020036:3: The requested object was not found."}`))
+	}
+	switch r.Method {
+	case "GET":
+		if fullPath != "" {
+			obj, ok := objects[fullPath]
+			if !ok {
+				notFound()
+				return
+			}
+			json.NewEncoder(w).Encode(obj)
+			return
+		}
+		items := []map[string]interface{}{}
+		for _, obj := range objects {
+			if filter := r.URL.Query().Get("$filter"); filter != "" &&
+				filter != "partition eq "+obj["partition"].(string) {
+				continue
+			}
+			items = append(items, obj)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+	case "POST":
+		var obj map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&obj)
+		fullPath = "/" + obj["partition"].(string) + "/" + obj["name"].(string)
+		if _, ok := objects[fullPath]; ok {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"code": 409, "message": "object already exists"}`))
+			return
+		}
+		objects[fullPath] = obj
+		json.NewEncoder(w).Encode(obj)
+	case "PATCH":
+		obj, ok := objects[fullPath]
+		if !ok {
+			notFound()
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&obj)
+		json.NewEncoder(w).Encode(obj)
+	case "DELETE":
+		if _, ok := objects[fullPath]; !ok {
+			notFound()
+			return
+		}
+		delete(objects, fullPath)
+	}
+}
+
+func indexOf(requests []string, request string) int {
+	for i, req := range requests {
+		if req == request {
+			return i
+		}
+	}
+	return -1
+}
+
+var _ = Describe("iControl Client Tests", func() {
+	var bigip *mockBigIP
+	var client *Client
+
+	BeforeEach(func() {
+		bigip = newMockBigIP()
+		client = NewClient(Params{
+			BIGIPURL:      bigip.server.URL + "/",
+			BIGIPUsername: "admin",
+			BIGIPPassword: "secret",
+		})
+	})
+
+	AfterEach(func() {
+		bigip.server.Close()
+	})
+
+	It("manages static routes", func() {
+		route := Route{
+			Name:        "foo",
+			Partition:   "Common",
+			Network:     "10.244.1.0/24",
+			Gateway:     "192.168.1.10",
+			Description: "test",
+		}
+		Expect(client.CreateRoute(route)).To(BeNil())
+		Expect(client.CreateRoute(Route{Name: "bar", Partition: "test"})).To(BeNil())
+		routes, err := client.Routes("Common")
+		Expect(err).To(BeNil())
+		Expect(routes).To(Equal([]Route{route}))
+
+		Expect(client.UpdateRouteGateway("Common", "foo", "192.168.1.11")).To(BeNil())
+		routes, _ = client.Routes("Common")
+		Expect(routes[0].Gateway).To(Equal("192.168.1.11"))
+		Expect(routes[0].Network).To(Equal("10.244.1.0/24"))
+
+		Expect(client.DeleteRoute("Common", "foo")).To(BeNil())
+		routes, _ = client.Routes("Common")
+		Expect(routes).To(BeEmpty())
+		// Already deleted
+		Expect(client.DeleteRoute("Common", "foo")).To(BeNil())
+		Expect(bigip.requests).To(ContainElement("DELETE /mgmt/tm/net/route/~Common~foo"))
+	})
+
+	It("manages FDB records", func() {
+		bigip.objects[fdbTunnelPath] = map[string]map[string]interface{}{
+			"/Common/vxlan500": {"name": "vxlan500", "partition": "Common"},
+		}
+		records, err := client.FDBRecords("Common", "vxlan500")
+		Expect(err).To(BeNil())
+		Expect(records).To(BeEmpty())
+
+		records = []FDBRecord{
+			{Name: "0a:0a:c0:a8:01:0a", Endpoint: "192.168.1.10"},
+			{Name: "0a:0a:c0:a8:01:0b", Endpoint: "192.168.1.11"},
+		}
+		Expect(client.UpdateFDBRecords("Common", "vxlan500", records)).To(BeNil())
+		Expect(client.FDBRecords("Common", "vxlan500")).To(Equal(records))
+
+		Expect(client.UpdateFDBRecords("Common", "vxlan500", nil)).To(BeNil())
+		Expect(client.FDBRecords("Common", "vxlan500")).To(BeEmpty())
+		Expect(bigip.objects[fdbTunnelPath]["/Common/vxlan500"]["records"]).ToNot(BeNil())
+
+		_, err = client.FDBRecords("Common", "vxlan501")
+		Expect(IsNotFound(err)).To(BeTrue())
+	})
+
+	It("manages ARP entries", func() {
+		arp := ARP{
+			Name:       "k8s-10.244.1.5",
+			Partition:  "Common",
+			IPAddress:  "10.244.1.5",
+			MACAddress: "12:ab:34:cd:56:ef",
+		}
+		Expect(client.CreateARP(arp)).To(BeNil())
+		Expect(client.CreateARP(ARP{Name: "bar", Partition: "test"})).To(BeNil())
+		arps, err := client.ARPs("Common")
+		Expect(err).To(BeNil())
+		Expect(arps).To(Equal([]ARP{arp}))
+
+		Expect(client.UpdateARPMAC("Common", "k8s-10.244.1.5", "12:ab:34:cd:56:00")).To(BeNil())
+		arps, _ = client.ARPs("Common")
+		Expect(arps[0].MACAddress).To(Equal("12:ab:34:cd:56:00"))
+		Expect(arps[0].IPAddress).To(Equal("10.244.1.5"))
+
+		Expect(client.DeleteARP("Common", "k8s-10.244.1.5")).To(BeNil())
+		arps, _ = client.ARPs("Common")
+		Expect(arps).To(BeEmpty())
+		// Already deleted
+		Expect(client.DeleteARP("Common", "k8s-10.244.1.5")).To(BeNil())
+		Expect(bigip.requests).To(ContainElement("DELETE /mgmt/tm/net/arp/~Common~k8s-10.244.1.5"))
+	})
+
+	It("deletes the LTM objects of a partition", func() {
+		object := func(partition, fullPath string) map[string]interface{} {
+			return map[string]interface{}{"partition": partition, "fullPath": fullPath}
+		}
+		bigip.objects["/mgmt/tm/sys/application/service"] = map[string]map[string]interface{}{
+			"/k8s/foo.app/foo": object("k8s", "/k8s/foo.app/foo"),
+		}
+		bigip.objects["/mgmt/tm/ltm/virtual"] = map[string]map[string]interface{}{
+			"/k8s/bar":    object("k8s", "/k8s/bar"),
+			"/Common/baz": object("Common", "/Common/baz"),
+		}
+		bigip.objects["/mgmt/tm/ltm/pool"] = map[string]map[string]interface{}{
+			"/k8s/bar": object("k8s", "/k8s/bar"),
+		}
+		Expect(client.DeleteLTMObjects("k8s")).To(BeNil())
+		Expect(bigip.objects["/mgmt/tm/sys/application/service"]).To(BeEmpty())
+		Expect(bigip.objects["/mgmt/tm/ltm/virtual"]).To(HaveLen(1))
+		Expect(bigip.objects["/mgmt/tm/ltm/virtual"]).To(HaveKey("/Common/baz"))
+		Expect(bigip.objects["/mgmt/tm/ltm/pool"]).To(BeEmpty())
+		Expect(bigip.requests).To(ContainElement(
+			"DELETE /mgmt/tm/sys/application/service/~k8s~foo.app~foo"))
+		// The virtual servers are deleted before their pool
+		Expect(bigip.requests).To(ContainElement("DELETE /mgmt/tm/ltm/virtual/~k8s~bar"))
+		Expect(indexOf(bigip.requests, "DELETE /mgmt/tm/ltm/virtual/~k8s~bar")).To(
+			BeNumerically("<", indexOf(bigip.requests, "DELETE /mgmt/tm/ltm/pool/~k8s~bar")))
+
+		client.UpdateCredentials(bigip.server.URL, "admin", "wrong")
+		Expect(client.DeleteLTMObjects("k8s")).NotTo(BeNil())
+	})
+
+	It("reports the errors of BIG-IP", func() {
+		err := client.UpdateRouteGateway("Common", "foo", "192.168.1.11")
+		Expect(IsNotFound(err)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("The requested object was not found"))
+
+		client.UpdateCredentials(bigip.server.URL, "admin", "wrong")
+		_, err = client.Routes("Common")
+		Expect(err).To(Equal(&Error{StatusCode: http.StatusUnauthorized, Message: "Unauthorized"}))
+	})
+})
@@ -0,0 +1,49 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package icontrol
+
+const fdbTunnelPath = "/mgmt/tm/net/fdb/tunnel"
+
+// FDBRecord is a record of the forwarding database of a tunnel, which sends
+// the traffic to the MAC address Name to the VTEP at Endpoint
+type FDBRecord struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"`
+}
+
+type fdbTunnel struct {
+	Records []FDBRecord `json:"records"`
+}
+
+// FDBRecords returns the FDB records of the tunnel name of partition
+func (c *Client) FDBRecords(partition, name string) ([]FDBRecord, error) {
+	var tunnel fdbTunnel
+	if err := c.get(objectPath(fdbTunnelPath, partition, name), &tunnel); err != nil {
+		return nil, err
+	}
+	return tunnel.Records, nil
+}
+
+// UpdateFDBRecords replaces the FDB records of the tunnel name of partition
+// with records
+func (c *Client) UpdateFDBRecords(partition, name string, records []FDBRecord) error {
+	if records == nil {
+		// BIG-IP removes the records of an empty list only
+		records = []FDBRecord{}
+	}
+	return c.patch(objectPath(fdbTunnelPath, partition, name), fdbTunnel{Records: records})
+}
@@ -0,0 +1,13 @@
+package icontrol_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestIControl(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "IControl Suite")
+}
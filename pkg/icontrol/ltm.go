@@ -0,0 +1,74 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package icontrol
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ltmPaths are the collections of the LTM objects the CCCL agent creates,
+// in the order they are deleted: an object is deleted before the objects it
+// references. The iApps come first, as they own the objects they create.
+var ltmPaths = []string{
+	"/mgmt/tm/sys/application/service",
+	"/mgmt/tm/ltm/virtual",
+	"/mgmt/tm/ltm/virtual-address",
+	"/mgmt/tm/ltm/policy",
+	"/mgmt/tm/ltm/pool",
+	"/mgmt/tm/ltm/monitor/http",
+	"/mgmt/tm/ltm/monitor/https",
+	"/mgmt/tm/ltm/monitor/tcp",
+	"/mgmt/tm/ltm/monitor/udp",
+	"/mgmt/tm/ltm/monitor/gateway-icmp",
+	"/mgmt/tm/ltm/profile/client-ssl",
+	"/mgmt/tm/ltm/profile/server-ssl",
+	"/mgmt/tm/ltm/rule",
+	"/mgmt/tm/ltm/data-group/internal",
+}
+
+type ltmObjectList struct {
+	Items []struct {
+		FullPath string `json:"fullPath"`
+	} `json:"items"`
+}
+
+// DeleteLTMObjects deletes the virtual servers, pools, monitors, policies,
+// profiles, iRules, data groups and iApps of partition, the objects the CCCL
+// agent manages. It goes on after an error and returns them all.
+func (c *Client) DeleteLTMObjects(partition string) error {
+	var errs []string
+	query := url.Values{"$filter": {"partition eq " + partition}}
+	for _, path := range ltmPaths {
+		var objects ltmObjectList
+		if err := c.get(path+"?"+query.Encode(), &objects); err != nil {
+			errs = append(errs, fmt.Sprintf("listing %v: %v", path, err))
+			continue
+		}
+		for _, obj := range objects.Items {
+			err := c.delete(path + "/" + strings.Replace(obj.FullPath, "/", "~", -1))
+			if err != nil && !IsNotFound(err) {
+				errs = append(errs, fmt.Sprintf("deleting %v: %v", obj.FullPath, err))
+			}
+		}
+	}
+	if len(errs) != 0 {
+		return fmt.Errorf("%v", strings.Join(errs, ", "))
+	}
+	return nil
+}
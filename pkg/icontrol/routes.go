@@ -0,0 +1,64 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package icontrol
+
+import "net/url"
+
+const routePath = "/mgmt/tm/net/route"
+
+// Route is a static route of BIG-IP, a net route object
+type Route struct {
+	Name        string `json:"name"`
+	Partition   string `json:"partition,omitempty"`
+	Network     string `json:"network"`
+	Gateway     string `json:"gw"`
+	Description string `json:"description,omitempty"`
+}
+
+type routeList struct {
+	Items []Route `json:"items"`
+}
+
+// Routes returns the static routes of partition
+func (c *Client) Routes(partition string) ([]Route, error) {
+	var routes routeList
+	query := url.Values{"$filter": {"partition eq " + partition}}
+	if err := c.get(routePath+"?"+query.Encode(), &routes); err != nil {
+		return nil, err
+	}
+	return routes.Items, nil
+}
+
+// CreateRoute creates route
+func (c *Client) CreateRoute(route Route) error {
+	return c.post(routePath, route)
+}
+
+// UpdateRouteGateway sets the gateway of the route name of partition
+func (c *Client) UpdateRouteGateway(partition, name, gateway string) error {
+	return c.patch(objectPath(routePath, partition, name), map[string]string{"gw": gateway})
+}
+
+// DeleteRoute deletes the route name of partition, which may already be
+// deleted
+func (c *Client) DeleteRoute(partition, name string) error {
+	err := c.delete(objectPath(routePath, partition, name))
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+package l2l3_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestL2L3(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "L2L3 Suite")
+}
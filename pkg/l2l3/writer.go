@@ -0,0 +1,340 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package l2l3 applies the VXLAN FDB records and ARP entries of the
+// controller to BIG-IP with iControl REST, in place of the python driver.
+// Its Writer reads the config sections the python driver reads, so that the
+// VXLAN manager and the agents write to it unchanged.
+package l2l3
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/icontrol"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+)
+
+// FDB records and ARP entries belong to the VXLAN tunnels, they share their
+// log level
+var log = vlogger.NewComponentLogger(vlogger.ComponentVxLAN)
+
+const (
+	// DefaultPartition is the partition of the tunnel when the global
+	// section does not give one
+	DefaultPartition = "Common"
+	// DefaultVerifyInterval is the interval at which BIG-IP is checked
+	// when the global section does not give one
+	DefaultVerifyInterval = 30 * time.Second
+	// arpPrefix marks the ARP entries of the controller
+	arpPrefix = "k8s-"
+)
+
+// Client manages the FDB records and ARP entries of BIG-IP
+type Client interface {
+	FDBRecords(partition, tunnel string) ([]icontrol.FDBRecord, error)
+	UpdateFDBRecords(partition, tunnel string, records []icontrol.FDBRecord) error
+	ARPs(partition string) ([]icontrol.ARP, error)
+	CreateARP(arp icontrol.ARP) error
+	UpdateARPMAC(partition, name, mac string) error
+	DeleteARP(partition, name string) error
+}
+
+// Sections of the python driver read by the Writer
+type (
+	globalSection struct {
+		VerifyInterval int    `json:"verify-interval,omitempty"`
+		VXLANPartition string `json:"vxlan-partition,omitempty"`
+	}
+
+	fdbSection struct {
+		TunnelName string               `json:"name"`
+		Records    []icontrol.FDBRecord `json:"records"`
+	}
+
+	arpSection struct {
+		Entries []icontrol.ARP `json:"arps"`
+	}
+)
+
+// Writer is a writer.Writer that keeps the FDB records of the "vxlan-fdb"
+// section and the ARP entries of the "vxlan-arp" section on BIG-IP. It
+// applies each section when it changes, and again at the verify interval
+// to undo the changes made outside the controller. The "global" section
+// gives the partition of the tunnel and the verify interval, the other
+// sections are ignored.
+type Writer struct {
+	client Client
+	// Guards the sections, which are applied in the background
+	mutex          sync.Mutex
+	partition      string
+	verifyInterval time.Duration
+	fdb            *fdbSection
+	arps           *arpSection
+
+	syncCh   chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWriter returns a Writer that applies the sections with client
+func NewWriter(client Client) *Writer {
+	w := &Writer{
+		client:         client,
+		partition:      DefaultPartition,
+		verifyInterval: DefaultVerifyInterval,
+		syncCh:         make(chan struct{}, 1),
+		stopCh:         make(chan struct{}),
+	}
+	go w.run()
+	log.Infof("[L2L3] Writer started: %p", w)
+	return w
+}
+
+// GetOutputFilename returns no file, as the sections are applied directly
+func (w *Writer) GetOutputFilename() string {
+	return ""
+}
+
+// Stop stops applying the sections
+func (w *Writer) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+		log.Infof("[L2L3] Writer stopped: %p", w)
+	})
+}
+
+// SendSection takes the section name, which is applied in the background.
+// The done channel is closed once the section is taken, errors of BIG-IP
+// are logged.
+func (w *Writer) SendSection(
+	name string,
+	obj interface{},
+) (<-chan struct{}, <-chan error, error) {
+	if 0 == len(name) {
+		return nil, nil, fmt.Errorf("cannot marshal section without name")
+	}
+	data, err := json.Marshal(obj)
+	if nil != err {
+		return nil, nil, fmt.Errorf("cannot marshal section %s: %v", name, err)
+	}
+
+	w.mutex.Lock()
+	switch name {
+	case "global":
+		var global globalSection
+		if err = json.Unmarshal(data, &global); nil == err {
+			if "" != global.VXLANPartition {
+				w.partition = global.VXLANPartition
+			}
+			if global.VerifyInterval > 0 {
+				w.verifyInterval = time.Duration(global.VerifyInterval) * time.Second
+			}
+		}
+	case "vxlan-fdb":
+		var fdb fdbSection
+		if err = json.Unmarshal(data, &fdb); nil == err {
+			w.fdb = &fdb
+		}
+	case "vxlan-arp":
+		var arps arpSection
+		if err = json.Unmarshal(data, &arps); nil == err {
+			w.arps = &arps
+		}
+	default:
+		log.Debugf("[L2L3] Writer (%p) ignoring section %s", w, name)
+	}
+	w.mutex.Unlock()
+	if nil != err {
+		return nil, nil, fmt.Errorf("invalid section %s: %v", name, err)
+	}
+
+	select {
+	case w.syncCh <- struct{}{}:
+	default:
+		// A sync is already pending, it applies this section too
+	}
+	done := make(chan struct{})
+	close(done)
+	return done, make(chan error), nil
+}
+
+// Sections returns the sections applied to BIG-IP
+func (w *Writer) Sections() json.RawMessage {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	sections := make(map[string]interface{})
+	if nil != w.fdb {
+		sections["vxlan-fdb"] = w.fdb
+	}
+	if nil != w.arps {
+		sections["vxlan-arp"] = w.arps
+	}
+	data, _ := json.Marshal(sections)
+	return json.RawMessage(data)
+}
+
+func (w *Writer) run() {
+	for {
+		w.mutex.Lock()
+		interval := w.verifyInterval
+		w.mutex.Unlock()
+
+		select {
+		case <-w.stopCh:
+			return
+		case <-w.syncCh:
+		case <-time.After(interval):
+		}
+		if err := w.sync(); nil != err {
+			log.Warningf("[L2L3] Writer failed to update BIG-IP: %v", err)
+		}
+	}
+}
+
+// sync applies the sections to BIG-IP
+func (w *Writer) sync() error {
+	w.mutex.Lock()
+	partition, fdb, arps := w.partition, w.fdb, w.arps
+	w.mutex.Unlock()
+
+	var errs []string
+	if nil != fdb {
+		if err := w.syncFDB(partition, fdb); nil != err {
+			errs = append(errs, err.Error())
+		}
+	}
+	if nil != arps {
+		if err := w.syncARPs(partition, arps); nil != err {
+			errs = append(errs, err.Error())
+		}
+	}
+	if 0 != len(errs) {
+		return fmt.Errorf("%v", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// syncFDB replaces the FDB records of the tunnel of fdb when they differ
+func (w *Writer) syncFDB(partition string, fdb *fdbSection) error {
+	current, err := w.client.FDBRecords(partition, fdb.TunnelName)
+	if nil != err {
+		return fmt.Errorf("getting FDB records of tunnel %v: %v", fdb.TunnelName, err)
+	}
+	if sameRecords(current, fdb.Records) {
+		return nil
+	}
+	log.Infof("[L2L3] Updating the %v FDB records of tunnel %v",
+		len(fdb.Records), fdb.TunnelName)
+	err = w.client.UpdateFDBRecords(partition, fdb.TunnelName, fdb.Records)
+	if nil != err {
+		return fmt.Errorf("updating FDB records of tunnel %v: %v", fdb.TunnelName, err)
+	}
+	return nil
+}
+
+// syncARPs creates, updates and deletes the ARP entries of the controller
+// on BIG-IP to match arps
+func (w *Writer) syncARPs(partition string, arps *arpSection) error {
+	current, err := w.client.ARPs(partition)
+	if nil != err {
+		return fmt.Errorf("getting ARP entries: %v", err)
+	}
+	existing := make(map[string]icontrol.ARP)
+	for _, arp := range current {
+		if strings.HasPrefix(arp.Name, arpPrefix) {
+			existing[arp.Name] = arp
+		}
+	}
+	desired := make(map[string]icontrol.ARP)
+	for _, arp := range arps.Entries {
+		arp.Partition = partition
+		desired[arp.Name] = arp
+	}
+
+	var errs []string
+	for _, name := range sortedNames(existing) {
+		if _, ok := desired[name]; !ok {
+			log.Debugf("[L2L3] Deleting ARP entry %v", name)
+			if err := w.client.DeleteARP(partition, name); nil != err {
+				errs = append(errs, fmt.Sprintf("deleting ARP entry %v: %v", name, err))
+			}
+		}
+	}
+	for _, name := range sortedNames(desired) {
+		arp := desired[name]
+		old, ok := existing[name]
+		if !ok {
+			log.Debugf("[L2L3] Creating ARP entry %v for %v at %v",
+				name, arp.IPAddress, arp.MACAddress)
+			if err := w.client.CreateARP(arp); nil != err {
+				errs = append(errs, fmt.Sprintf("creating ARP entry %v: %v", name, err))
+			}
+		} else if !strings.EqualFold(old.MACAddress, arp.MACAddress) {
+			log.Debugf("[L2L3] Updating ARP entry %v for %v at %v",
+				name, arp.IPAddress, arp.MACAddress)
+			err := w.client.UpdateARPMAC(partition, name, arp.MACAddress)
+			if nil != err {
+				errs = append(errs, fmt.Sprintf("updating ARP entry %v: %v", name, err))
+			}
+		}
+	}
+	if 0 != len(errs) {
+		return fmt.Errorf("%v", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// sameRecords compares FDB records regardless of their order, as BIG-IP
+// may write their MAC and IP addresses differently
+func sameRecords(a, b []icontrol.FDBRecord) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	keys := func(records []icontrol.FDBRecord) []string {
+		var keys []string
+		for _, rec := range records {
+			endpoint := rec.Endpoint
+			if ip := net.ParseIP(endpoint); nil != ip {
+				endpoint = ip.String()
+			}
+			keys = append(keys, strings.ToLower(rec.Name)+" "+endpoint)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	keysA, keysB := keys(a), keys(b)
+	for i := range keysA {
+		if keysA[i] != keysB[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedNames(arps map[string]icontrol.ARP) []string {
+	var names []string
+	for name := range arps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
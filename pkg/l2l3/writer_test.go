@@ -0,0 +1,258 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package l2l3
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/icontrol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockClient struct {
+	sync.Mutex
+	records map[string][]icontrol.FDBRecord
+	arps    map[string]icontrol.ARP
+	calls   []string
+	err     error
+}
+
+func (mc *mockClient) FDBRecords(partition, tunnel string) ([]icontrol.FDBRecord, error) {
+	mc.Lock()
+	defer mc.Unlock()
+	records, ok := mc.records[partition+"/"+tunnel]
+	if !ok {
+		return nil, fmt.Errorf("tunnel %s not found", tunnel)
+	}
+	return records, mc.err
+}
+
+func (mc *mockClient) UpdateFDBRecords(partition, tunnel string, records []icontrol.FDBRecord) error {
+	mc.Lock()
+	defer mc.Unlock()
+	mc.calls = append(mc.calls, "update "+partition+"/"+tunnel)
+	mc.records[partition+"/"+tunnel] = records
+	return nil
+}
+
+func (mc *mockClient) ARPs(partition string) ([]icontrol.ARP, error) {
+	mc.Lock()
+	defer mc.Unlock()
+	var arps []icontrol.ARP
+	for _, arp := range mc.arps {
+		if arp.Partition == partition {
+			arps = append(arps, arp)
+		}
+	}
+	return arps, mc.err
+}
+
+func (mc *mockClient) CreateARP(arp icontrol.ARP) error {
+	mc.Lock()
+	defer mc.Unlock()
+	mc.calls = append(mc.calls, "create "+arp.Name)
+	mc.arps[arp.Name] = arp
+	return nil
+}
+
+func (mc *mockClient) UpdateARPMAC(partition, name, mac string) error {
+	mc.Lock()
+	defer mc.Unlock()
+	mc.calls = append(mc.calls, "update "+name)
+	arp := mc.arps[name]
+	arp.MACAddress = mac
+	mc.arps[name] = arp
+	return nil
+}
+
+func (mc *mockClient) DeleteARP(partition, name string) error {
+	mc.Lock()
+	defer mc.Unlock()
+	mc.calls = append(mc.calls, "delete "+name)
+	delete(mc.arps, name)
+	return nil
+}
+
+func (mc *mockClient) takeCalls() []string {
+	mc.Lock()
+	defer mc.Unlock()
+	calls := mc.calls
+	mc.calls = nil
+	return calls
+}
+
+// Sections as written by the VXLAN manager and the python driver config
+type (
+	testFDBSection struct {
+		TunnelName string          `json:"name"`
+		Records    []testFDBRecord `json:"records"`
+	}
+	testFDBRecord struct {
+		Name     string `json:"name,omitempty"`
+		Endpoint string `json:"endpoint"`
+	}
+	testARPSection struct {
+		Entries []testARPEntry `json:"arps"`
+	}
+	testARPEntry struct {
+		Name    string `json:"name"`
+		IPAddr  string `json:"ipAddress"`
+		MACAddr string `json:"macAddress"`
+	}
+	testGlobalSection struct {
+		LogLevel       string `json:"log-level,omitempty"`
+		VerifyInterval int    `json:"verify-interval,omitempty"`
+		VXLANPartition string `json:"vxlan-partition,omitempty"`
+	}
+)
+
+var _ = Describe("L2L3 Writer Tests", func() {
+	var client *mockClient
+	var w *Writer
+
+	send := func(name string, obj interface{}) {
+		doneCh, _, err := w.SendSection(name, obj)
+		Expect(err).To(BeNil())
+		Eventually(doneCh).Should(BeClosed())
+	}
+
+	BeforeEach(func() {
+		client = &mockClient{
+			records: map[string][]icontrol.FDBRecord{
+				"Common/vxlan500": nil,
+				"test/vxlan500":   nil,
+			},
+			arps: map[string]icontrol.ARP{
+				// Not managed by the controller
+				"gateway": {Name: "gateway", Partition: "test", IPAddress: "10.1.1.1",
+					MACAddress: "00:00:00:00:00:01"},
+			},
+		}
+		// Sections are applied on calls of sync, not in the background
+		w = &Writer{
+			client:         client,
+			partition:      DefaultPartition,
+			verifyInterval: DefaultVerifyInterval,
+			syncCh:         make(chan struct{}, 1),
+			stopCh:         make(chan struct{}),
+		}
+	})
+
+	It("applies the FDB records when they change", func() {
+		send("vxlan-fdb", testFDBSection{
+			TunnelName: "vxlan500",
+			Records: []testFDBRecord{
+				{Name: "0a:0a:c0:a8:01:0a", Endpoint: "192.168.1.10"},
+				{Name: "0a:0a:c0:a8:01:0b", Endpoint: "192.168.1.11"},
+			},
+		})
+		Expect(w.sync()).To(BeNil())
+		Expect(client.takeCalls()).To(Equal([]string{"update Common/vxlan500"}))
+		Expect(client.records["Common/vxlan500"]).To(HaveLen(2))
+
+		// Written differently by BIG-IP
+		client.records["Common/vxlan500"] = []icontrol.FDBRecord{
+			{Name: "0a:0a:c0:a8:01:0b", Endpoint: "192.168.1.11"},
+			{Name: "0A:0A:C0:A8:01:0A", Endpoint: "192.168.1.10"},
+		}
+		Expect(w.sync()).To(BeNil())
+		Expect(client.takeCalls()).To(BeEmpty())
+
+		// Changed outside the controller
+		client.records["Common/vxlan500"] = nil
+		Expect(w.sync()).To(BeNil())
+		Expect(client.takeCalls()).To(Equal([]string{"update Common/vxlan500"}))
+
+		send("vxlan-fdb", testFDBSection{TunnelName: "vxlan501"})
+		Expect(w.sync()).ToNot(BeNil())
+	})
+
+	It("applies the ARP entries of the controller", func() {
+		send("global", testGlobalSection{LogLevel: "INFO", VXLANPartition: "test"})
+		send("vxlan-arp", testARPSection{Entries: []testARPEntry{
+			{Name: "k8s-10.244.1.5", IPAddr: "10.244.1.5", MACAddr: "12:ab:34:cd:56:ef"},
+			{Name: "k8s-10.244.2.5", IPAddr: "10.244.2.5", MACAddr: "12:ab:34:cd:56:00"},
+		}})
+		Expect(w.sync()).To(BeNil())
+		Expect(client.takeCalls()).To(Equal([]string{
+			"create k8s-10.244.1.5",
+			"create k8s-10.244.2.5",
+		}))
+		Expect(client.arps["k8s-10.244.1.5"]).To(Equal(icontrol.ARP{
+			Name:       "k8s-10.244.1.5",
+			Partition:  "test",
+			IPAddress:  "10.244.1.5",
+			MACAddress: "12:ab:34:cd:56:ef",
+		}))
+
+		// Unchanged
+		Expect(w.sync()).To(BeNil())
+		Expect(client.takeCalls()).To(BeEmpty())
+
+		send("vxlan-arp", testARPSection{Entries: []testARPEntry{
+			{Name: "k8s-10.244.1.5", IPAddr: "10.244.1.5", MACAddr: "12:ab:34:cd:56:aa"},
+		}})
+		Expect(w.sync()).To(BeNil())
+		Expect(client.takeCalls()).To(Equal([]string{
+			"delete k8s-10.244.2.5",
+			"update k8s-10.244.1.5",
+		}))
+		Expect(client.arps).To(HaveKey("gateway"))
+		Expect(client.arps).To(HaveLen(2))
+	})
+
+	It("ignores the other sections", func() {
+		send("bigip", map[string]string{"url": "https://bigip.example.com"})
+		send("resources", map[string]interface{}{"test": struct{}{}})
+		Expect(w.sync()).To(BeNil())
+		Expect(client.takeCalls()).To(BeEmpty())
+		Expect(w.Sections()).To(MatchJSON("{}"))
+
+		_, _, err := w.SendSection("", nil)
+		Expect(err).ToNot(BeNil())
+		_, _, err = w.SendSection("vxlan-arp", "not a section")
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("leaves BIG-IP unchanged on errors", func() {
+		send("vxlan-arp", testARPSection{})
+		client.err = fmt.Errorf("BIG-IP unavailable")
+		Expect(w.sync()).ToNot(BeNil())
+		Expect(client.takeCalls()).To(BeEmpty())
+	})
+
+	It("applies the sections in the background", func() {
+		w = NewWriter(client)
+		defer w.Stop()
+		Expect(w.GetOutputFilename()).To(BeEmpty())
+
+		send("vxlan-fdb", testFDBSection{
+			TunnelName: "vxlan500",
+			Records:    []testFDBRecord{{Name: "0a:0a:c0:a8:01:0a", Endpoint: "192.168.1.10"}},
+		})
+		Eventually(client.takeCalls).Should(Equal([]string{"update Common/vxlan500"}))
+		Expect(w.Sections()).To(MatchJSON(`{"vxlan-fdb": {"name": "vxlan500",
+			"records": [{"name": "0a:0a:c0:a8:01:0a", "endpoint": "192.168.1.10"}]}}`))
+
+		w.Stop()
+		// Stopping twice is harmless
+		w.Stop()
+	})
+})
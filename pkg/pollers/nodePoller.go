@@ -21,7 +21,7 @@ import (
 	"sync"
 	"time"
 
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 
 	"k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -30,6 +30,8 @@ import (
 	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
 )
 
+var log = vlogger.NewComponentLogger(vlogger.ComponentPollers)
+
 type pollData struct {
 	nl  []v1.Node
 	err error
@@ -45,6 +47,7 @@ type nodePoller struct {
 	pollInterval time.Duration
 	stopCh       chan struct{}
 	addCh        chan pollListener
+	labelCh      chan string
 	running      bool
 	runningLock  *sync.Mutex
 	regListeners []PollListener
@@ -63,6 +66,7 @@ func NewNodePoller(
 		pollInterval: pollInterval,
 		stopCh:       make(chan struct{}),
 		addCh:        make(chan pollListener),
+		labelCh:      make(chan string),
 		running:      false,
 		runningLock:  &sync.Mutex{},
 		nodeLabel:    nodeLabel,
@@ -124,6 +128,20 @@ func (np *nodePoller) RegisterListener(p PollListener) error {
 	return nil
 }
 
+// SetNodeLabel changes the label selector of the polled nodes. A running
+// poller polls the nodes right away.
+func (np *nodePoller) SetNodeLabel(nodeLabel string) {
+	np.runningLock.Lock()
+	defer np.runningLock.Unlock()
+
+	log.Infof("[CORE] NodePoller (%p) node label selector set to '%v'", np, nodeLabel)
+	if false == np.running {
+		np.nodeLabel = nodeLabel
+		return
+	}
+	np.labelCh <- nodeLabel
+}
+
 func (np *nodePoller) runListener(p PollListener) {
 	listener := make(chan pollData)
 	stopCh := make(chan struct{})
@@ -219,6 +237,13 @@ func (np *nodePoller) poller() {
 			}
 
 			listeners = append(listeners, pl)
+		case nodeLabel := <-np.labelCh:
+			if nodeLabel != np.nodeLabel {
+				bigIPPrometheus.MonitoredNodes.DeleteLabelValues(np.nodeLabel)
+				np.nodeLabel = nodeLabel
+				remainingInterval = np.pollInterval
+				doPoll = true
+			}
 		case <-time.After(remainingInterval):
 			log.Debugf("[CORE] NodePoller (%p) ready to poll, last wait: %v\n",
 				np, remainingInterval)
@@ -380,4 +380,35 @@ var _ = Describe("Node Poller Tests", func() {
 
 		assertRegister(np, expectedNodes, true, len(expectedNodes))
 	})
+
+	It("changes the nodeLabelSelector while running", func() {
+		np, allNodes := initTestData("")
+		_, labelledNodes := initTestData(nodeLabel)
+		setter, ok := np.(interface{ SetNodeLabel(string) })
+		Expect(ok).To(BeTrue())
+
+		var mutex sync.Mutex
+		var polled []v1.Node
+		np.RegisterListener(func(obj interface{}, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			polled, _ = obj.([]v1.Node)
+		})
+		getPolled := func() int {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return len(polled)
+		}
+
+		Expect(np.Run()).To(BeNil())
+		Eventually(getPolled).Should(Equal(len(allNodes)))
+		setter.SetNodeLabel(nodeLabel)
+		Eventually(getPolled).Should(Equal(len(labelledNodes)))
+		Expect(np.Stop()).To(BeNil())
+
+		setter.SetNodeLabel("")
+		Expect(np.Run()).To(BeNil())
+		Eventually(getPolled).Should(Equal(len(allNodes)))
+		Expect(np.Stop()).To(BeNil())
+	})
 })
@@ -0,0 +1,278 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pollers
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	bigIPPrometheus "github.com/F5Networks/k8s-bigip-ctlr/pkg/prometheus"
+)
+
+// nodeWatcher is a Poller whose listeners are notified as soon as a node is
+// added, deleted or changed, from an informer on the nodes. Listeners receive
+// the whole list of nodes, like with the nodePoller. Changes to the status of
+// the nodes other than their addresses, such as heartbeats, are ignored.
+type nodeWatcher struct {
+	kubeClient  kubernetes.Interface
+	nodeLabel   string
+	runningLock *sync.Mutex
+	running     bool
+	// Informer of the nodes with nodeLabel, and its stop channel
+	nodeStore    cache.Store
+	synced       bool
+	stopCh       chan struct{}
+	regListeners []PollListener
+	listeners    []watchListener
+}
+
+type watchListener struct {
+	// Buffered, so that notifications received while the listener runs are
+	// delivered once
+	notify chan struct{}
+	s      chan struct{}
+}
+
+func NewNodeWatcher(
+	kubeClient kubernetes.Interface,
+	nodeLabel string,
+) Poller {
+	nw := &nodeWatcher{
+		kubeClient:  kubeClient,
+		nodeLabel:   nodeLabel,
+		runningLock: &sync.Mutex{},
+	}
+
+	log.Debugf("[CORE] NodeWatcher object created: %p", nw)
+	return nw
+}
+
+func (nw *nodeWatcher) Run() error {
+	nw.runningLock.Lock()
+	defer nw.runningLock.Unlock()
+
+	if true == nw.running {
+		return fmt.Errorf("NodeWatcher Run method called while running")
+	}
+	nw.running = true
+	nw.startInformer()
+	for _, p := range nw.regListeners {
+		log.Debugf("[CORE] NodeWatcher (%p) registering cached listener: %p", nw, p)
+		nw.runListener(p)
+	}
+
+	log.Infof("[CORE] NodeWatcher started: (%p)", nw)
+	return nil
+}
+
+func (nw *nodeWatcher) Stop() error {
+	nw.runningLock.Lock()
+	defer nw.runningLock.Unlock()
+
+	if false == nw.running {
+		return fmt.Errorf("NodeWatcher Stop method called while stopped")
+	}
+	nw.running = false
+	nw.stopInformer()
+	for _, wl := range nw.listeners {
+		close(wl.s)
+	}
+	nw.listeners = nil
+
+	log.Infof("[CORE] NodeWatcher stopped: %p", nw)
+	return nil
+}
+
+func (nw *nodeWatcher) RegisterListener(p PollListener) error {
+	nw.runningLock.Lock()
+	defer nw.runningLock.Unlock()
+
+	log.Infof("[CORE] NodeWatcher (%p) registering new listener: %p", nw, p)
+
+	nw.regListeners = append(nw.regListeners, p)
+	if false == nw.running {
+		log.Debugf("[CORE] NodeWatcher (%p) caching listener %p, watcher is not running",
+			nw, p)
+		return nil
+	}
+
+	nw.runListener(p)
+	return nil
+}
+
+// SetNodeLabel changes the label selector of the watched nodes. A running
+// watcher lists the nodes again and notifies its listeners once listed.
+func (nw *nodeWatcher) SetNodeLabel(nodeLabel string) {
+	nw.runningLock.Lock()
+	defer nw.runningLock.Unlock()
+
+	log.Infof("[CORE] NodeWatcher (%p) node label selector set to '%v'", nw, nodeLabel)
+	if nodeLabel == nw.nodeLabel {
+		return
+	}
+	bigIPPrometheus.MonitoredNodes.DeleteLabelValues(nw.nodeLabel)
+	nw.nodeLabel = nodeLabel
+	if true == nw.running {
+		nw.stopInformer()
+		nw.startInformer()
+	}
+}
+
+// startInformer starts watching the nodes, with the lock held
+func (nw *nodeWatcher) startInformer() {
+	nodeLabel := nw.nodeLabel
+	informer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				options.LabelSelector = nodeLabel
+				return nw.kubeClient.CoreV1().Nodes().List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				options.LabelSelector = nodeLabel
+				return nw.kubeClient.CoreV1().Nodes().Watch(options)
+			},
+		},
+		&v1.Node{},
+		0,
+		cache.Indexers{},
+	)
+	store := informer.GetStore()
+	informer.AddEventHandler(&cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) { nw.nodesChanged(store) },
+		UpdateFunc: func(old, cur interface{}) {
+			if nodeChanged(old.(*v1.Node), cur.(*v1.Node)) {
+				nw.nodesChanged(store)
+			}
+		},
+		DeleteFunc: func(obj interface{}) { nw.nodesChanged(store) },
+	})
+
+	stopCh := make(chan struct{})
+	nw.nodeStore = store
+	nw.synced = false
+	nw.stopCh = stopCh
+	go informer.Run(stopCh)
+	go func() {
+		if !cache.WaitForCacheSync(stopCh, informer.HasSynced) {
+			return
+		}
+		nw.runningLock.Lock()
+		defer nw.runningLock.Unlock()
+		if store == nw.nodeStore {
+			log.Debugf("[CORE] NodeWatcher (%p) nodes listed", nw)
+			nw.synced = true
+			nw.notifyListeners()
+		}
+	}()
+}
+
+// stopInformer stops watching the nodes, with the lock held
+func (nw *nodeWatcher) stopInformer() {
+	close(nw.stopCh)
+	nw.nodeStore = nil
+	nw.synced = false
+}
+
+// nodesChanged notifies the listeners of a change of the nodes of store,
+// once all the nodes are listed. Changes to the store of a previous label
+// selector are ignored.
+func (nw *nodeWatcher) nodesChanged(store cache.Store) {
+	nw.runningLock.Lock()
+	defer nw.runningLock.Unlock()
+
+	if store == nw.nodeStore && nw.synced {
+		nw.notifyListeners()
+	}
+}
+
+// notifyListeners with the lock held
+func (nw *nodeWatcher) notifyListeners() {
+	for _, wl := range nw.listeners {
+		select {
+		case wl.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// nodes returns the watched nodes sorted by name, nil until they are listed
+func (nw *nodeWatcher) nodes() ([]v1.Node, bool) {
+	nw.runningLock.Lock()
+	defer nw.runningLock.Unlock()
+
+	if nil == nw.nodeStore || !nw.synced {
+		return nil, false
+	}
+	var nodes []v1.Node
+	for _, obj := range nw.nodeStore.List() {
+		nodes = append(nodes, *obj.(*v1.Node))
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ObjectMeta.Name < nodes[j].ObjectMeta.Name
+	})
+	bigIPPrometheus.MonitoredNodes.WithLabelValues(nw.nodeLabel).Set(float64(len(nodes)))
+	return nodes, true
+}
+
+// runListener with the lock held
+func (nw *nodeWatcher) runListener(p PollListener) {
+	wl := watchListener{
+		notify: make(chan struct{}, 1),
+		s:      make(chan struct{}),
+	}
+	nw.listeners = append(nw.listeners, wl)
+	if nw.synced {
+		wl.notify <- struct{}{}
+	}
+
+	go func() {
+		log.Debugf("[CORE] NodeWatcher (%p) listener goroutine started: %p", nw, p)
+		for {
+			select {
+			case <-wl.s:
+				log.Debugf("[CORE] NodeWatcher (%p) listener stopped: %p", nw, p)
+				return
+			case <-wl.notify:
+				nodes, ok := nw.nodes()
+				if !ok {
+					continue
+				}
+				log.Debugf("[CORE] NodeWatcher (%p) listener callback - num items: %v",
+					nw, len(nodes))
+				p(nodes, nil)
+			}
+		}
+	}()
+}
+
+// nodeChanged tells whether cur differs from old in what the listeners use:
+// metadata, spec and addresses
+func nodeChanged(old, cur *v1.Node) bool {
+	return !reflect.DeepEqual(old.ObjectMeta.Labels, cur.ObjectMeta.Labels) ||
+		!reflect.DeepEqual(old.ObjectMeta.Annotations, cur.ObjectMeta.Annotations) ||
+		!reflect.DeepEqual(old.Spec, cur.Spec) ||
+		!reflect.DeepEqual(old.Status.Addresses, cur.Status.Addresses)
+}
@@ -0,0 +1,123 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pollers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var _ = Describe("Node Watcher Tests", func() {
+	var fakeClient *fake.Clientset
+	var nw Poller
+	var notified chan []string
+
+	nodeNames := func(obj interface{}) []string {
+		names := []string{}
+		for _, node := range obj.([]v1.Node) {
+			names = append(names, node.ObjectMeta.Name)
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		fakeClient = fake.NewSimpleClientset()
+		for _, node := range []*v1.Node{
+			newNode("node0", "0", false, []v1.NodeAddress{{Type: "ExternalIP", Address: "127.0.0.0"}},
+				map[string]string{masterLabel: "true"}),
+			newNode("node1", "1", false, []v1.NodeAddress{{Type: "ExternalIP", Address: "127.0.0.1"}},
+				map[string]string{nodeLabel: "true"}),
+		} {
+			_, err := fakeClient.CoreV1().Nodes().Create(node)
+			Expect(err).To(BeNil())
+		}
+		nw = NewNodeWatcher(fakeClient, "")
+		notified = make(chan []string, 10)
+		err := nw.RegisterListener(func(obj interface{}, err error) {
+			Expect(err).To(BeNil())
+			notified <- nodeNames(obj)
+		})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		nw.Stop()
+	})
+
+	It("starts and stops", func() {
+		Expect(nw.Run()).To(BeNil())
+		Expect(nw.Run()).ToNot(BeNil())
+		Eventually(notified).Should(Receive(Equal([]string{"node0", "node1"})))
+
+		Expect(nw.Stop()).To(BeNil())
+		Expect(nw.Stop()).ToNot(BeNil())
+		Expect(nw.Run()).To(BeNil())
+		Eventually(notified).Should(Receive(Equal([]string{"node0", "node1"})))
+	})
+
+	It("notifies the listeners of node changes", func() {
+		Expect(nw.Run()).To(BeNil())
+		Eventually(notified).Should(Receive(Equal([]string{"node0", "node1"})))
+
+		late := make(chan []string, 10)
+		nw.RegisterListener(func(obj interface{}, err error) {
+			late <- nodeNames(obj)
+		})
+		Eventually(late).Should(Receive(Equal([]string{"node0", "node1"})))
+
+		_, err := fakeClient.CoreV1().Nodes().Create(newNode("node2", "2", false,
+			[]v1.NodeAddress{{Type: "ExternalIP", Address: "127.0.0.2"}}, nil))
+		Expect(err).To(BeNil())
+		Eventually(notified).Should(Receive(Equal([]string{"node0", "node1", "node2"})))
+		Eventually(late).Should(Receive(Equal([]string{"node0", "node1", "node2"})))
+
+		// Heartbeats are ignored
+		node, _ := fakeClient.CoreV1().Nodes().Get("node2", metav1.GetOptions{})
+		node.ResourceVersion = "3"
+		node.Status.Conditions = []v1.NodeCondition{{Type: v1.NodeReady, Status: v1.ConditionTrue}}
+		_, err = fakeClient.CoreV1().Nodes().UpdateStatus(node)
+		Expect(err).To(BeNil())
+		Consistently(notified, 200*time.Millisecond).ShouldNot(Receive())
+
+		node.ResourceVersion = "4"
+		node.Spec.Taints = []v1.Taint{{Key: "foo", Effect: v1.TaintEffectNoExecute}}
+		_, err = fakeClient.CoreV1().Nodes().Update(node)
+		Expect(err).To(BeNil())
+		Eventually(notified).Should(Receive(Equal([]string{"node0", "node1", "node2"})))
+
+		Expect(fakeClient.CoreV1().Nodes().Delete("node0", nil)).To(BeNil())
+		Eventually(notified).Should(Receive(Equal([]string{"node1", "node2"})))
+	})
+
+	It("changes the nodeLabelSelector while running", func() {
+		setter, ok := nw.(interface{ SetNodeLabel(string) })
+		Expect(ok).To(BeTrue())
+		Expect(nw.Run()).To(BeNil())
+		Eventually(notified).Should(Receive(Equal([]string{"node0", "node1"})))
+
+		setter.SetNodeLabel(nodeLabel)
+		Eventually(notified).Should(Receive(Equal([]string{"node1"})))
+		setter.SetNodeLabel("")
+		Eventually(notified).Should(Receive(Equal([]string{"node0", "node1"})))
+	})
+})
@@ -0,0 +1,31 @@
+package prometheus
+
+import (
+	"k8s.io/client-go/tools/cache"
+)
+
+type countingEventHandler struct {
+	kind    string
+	handler cache.ResourceEventHandler
+}
+
+// CountInformerEvents wraps handler so that every add, update and delete
+// notification for kind is counted in InformerEvents
+func CountInformerEvents(kind string, handler cache.ResourceEventHandler) cache.ResourceEventHandler {
+	return &countingEventHandler{kind: kind, handler: handler}
+}
+
+func (h *countingEventHandler) OnAdd(obj interface{}) {
+	InformerEvents.WithLabelValues(h.kind, "add").Inc()
+	h.handler.OnAdd(obj)
+}
+
+func (h *countingEventHandler) OnUpdate(oldObj, newObj interface{}) {
+	InformerEvents.WithLabelValues(h.kind, "update").Inc()
+	h.handler.OnUpdate(oldObj, newObj)
+}
+
+func (h *countingEventHandler) OnDelete(obj interface{}) {
+	InformerEvents.WithLabelValues(h.kind, "delete").Inc()
+	h.handler.OnDelete(obj)
+}
@@ -1,6 +1,10 @@
 package prometheus
 
 import (
+	"strconv"
+	"sync"
+	"time"
+
 	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -31,11 +35,141 @@ var CurrentErrors = prometheus.NewGaugeVec(
 	[]string{},
 )
 
-// further metrics? todo think about
-// RegisterMetrics registers all Prometheus metrics defined above
+var AS3CircuitBreakerState = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "bigip_as3_circuit_breaker_state",
+		Help: "State of the AS3 circuit breaker (0 closed, 1 half-open, 2 open)",
+	},
+)
+
+var AS3ConsecutiveFailures = prometheus.NewGauge(
+	prometheus.GaugeOpts{
+		Name: "bigip_as3_consecutive_post_failures",
+		Help: "Count of consecutive failed AS3 posts to BigIP",
+	},
+)
+
+var AS3DriftDetected = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "bigip_as3_drift_detected_total",
+		Help: "Total count of AS3 tenants found to differ from the declaration posted by the BigIP k8s CTLR",
+	},
+	[]string{"tenant"},
+)
+
+var AS3Rollbacks = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "bigip_as3_rollbacks_total",
+		Help: "Total count of last-known-good AS3 declarations re-posted after BigIP rejected a declaration",
+	},
+)
+
+var PostDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "bigip_post_duration_seconds",
+		Help:    "Time taken to post a declaration to BigIP by agent",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+	},
+	[]string{"agent"},
+)
+
+var DeclarationSize = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "bigip_declaration_size_bytes",
+		Help:    "Size of the declarations posted to BigIP by agent",
+		Buckets: prometheus.ExponentialBuckets(1024, 4, 8),
+	},
+	[]string{"agent"},
+)
+
+var PostResponses = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "bigip_post_responses_total",
+		Help: "Total count of BigIP responses to posted declarations by HTTP code and tenant",
+	},
+	[]string{"agent", "code", "tenant"},
+)
+
+var LastSuccessfulSync = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "bigip_last_successful_sync_timestamp_seconds",
+		Help: "Unix time of the last configuration BigIP accepted for a partition",
+	},
+	[]string{"partition"},
+)
+
+var InformerEvents = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "bigip_informer_events_total",
+		Help: "Total count of informer notifications by resource kind and event",
+	},
+	[]string{"kind", "event"},
+)
+
+var registerOnce sync.Once
+
+// RegisterMetrics registers all Prometheus metrics defined above, including
+// the work queue metrics. It is called by both the appmanager and the
+// crmanager, only the first call has an effect.
 func RegisterMetrics() {
-	log.Info("[CORE] Registered BigIP Metrics")
-	prometheus.MustRegister(MonitoredNodes)
-	prometheus.MustRegister(MonitoredServices)
-	prometheus.MustRegister(CurrentErrors)
+	registerOnce.Do(func() {
+		log.Info("[CORE] Registered BigIP Metrics")
+		prometheus.MustRegister(MonitoredNodes)
+		prometheus.MustRegister(MonitoredServices)
+		prometheus.MustRegister(CurrentErrors)
+		prometheus.MustRegister(AS3CircuitBreakerState)
+		prometheus.MustRegister(AS3ConsecutiveFailures)
+		prometheus.MustRegister(AS3DriftDetected)
+		prometheus.MustRegister(AS3Rollbacks)
+		prometheus.MustRegister(PostDuration)
+		prometheus.MustRegister(DeclarationSize)
+		prometheus.MustRegister(PostResponses)
+		prometheus.MustRegister(LastSuccessfulSync)
+		prometheus.MustRegister(InformerEvents)
+		registerWorkqueueMetrics()
+	})
+}
+
+// ObservePost records the duration and the declaration size of a post
+func ObservePost(agent string, start time.Time, size int) {
+	PostDuration.WithLabelValues(agent).Observe(time.Since(start).Seconds())
+	DeclarationSize.WithLabelValues(agent).Observe(float64(size))
+}
+
+// SetSynced records that BigIP accepted the configuration of partition
+func SetSynced(partition string) {
+	LastSuccessfulSync.WithLabelValues(partition).Set(float64(time.Now().Unix()))
+}
+
+// RecordAS3Results counts the AS3 result of each posted tenant and marks the
+// tenants BigIP accepted as synced. Tenants without a result are counted
+// with the HTTP status code, 0 if BigIP did not respond.
+func RecordAS3Results(
+	agent string,
+	statusCode int,
+	responseMap map[string]interface{},
+	tenants []string,
+) {
+	codes := make(map[string]int)
+	for _, tenant := range tenants {
+		codes[tenant] = statusCode
+	}
+	if results, ok := responseMap["results"].([]interface{}); ok {
+		for _, value := range results {
+			v, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			tenant, _ := v["tenant"].(string)
+			if code, ok := v["code"].(float64); ok && tenant != "" {
+				codes[tenant] = int(code)
+			}
+		}
+	}
+	for tenant, code := range codes {
+		PostResponses.WithLabelValues(agent, strconv.Itoa(code), tenant).Inc()
+		if code >= 200 && code < 300 {
+			SetSynced(tenant)
+		}
+	}
 }
@@ -0,0 +1,71 @@
+package prometheus
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+func metricValue(m prometheus.Metric) *dto.Metric {
+	metric := &dto.Metric{}
+	Expect(m.Write(metric)).To(Succeed())
+	return metric
+}
+
+var _ = Describe("Prometheus Metrics Tests", func() {
+	BeforeEach(func() {
+		RegisterMetrics()
+	})
+
+	It("Counts AS3 results by code and tenant", func() {
+		rsp := map[string]interface{}{
+			"results": []interface{}{
+				map[string]interface{}{"code": float64(200), "tenant": "t1"},
+				map[string]interface{}{"code": float64(422), "tenant": "t2"},
+			},
+		}
+		RecordAS3Results("test", 422, rsp, []string{"t1", "t2", "t3"})
+		Expect(metricValue(PostResponses.WithLabelValues("test", "200", "t1")).
+			Counter.GetValue()).To(BeEquivalentTo(1))
+		Expect(metricValue(PostResponses.WithLabelValues("test", "422", "t2")).
+			Counter.GetValue()).To(BeEquivalentTo(1))
+		Expect(metricValue(PostResponses.WithLabelValues("test", "422", "t3")).
+			Counter.GetValue()).To(BeEquivalentTo(1))
+		Expect(metricValue(LastSuccessfulSync.WithLabelValues("t1")).
+			Gauge.GetValue()).NotTo(BeZero())
+		Expect(metricValue(LastSuccessfulSync.WithLabelValues("t2")).
+			Gauge.GetValue()).To(BeZero())
+	})
+
+	It("Counts informer events by kind", func() {
+		added := false
+		handler := CountInformerEvents("TestKind", &cache.ResourceEventHandlerFuncs{
+			AddFunc: func(obj interface{}) { added = true },
+		})
+		handler.OnAdd(nil)
+		handler.OnDelete(nil)
+		Expect(added).To(BeTrue())
+		Expect(metricValue(InformerEvents.WithLabelValues("TestKind", "add")).
+			Counter.GetValue()).To(BeEquivalentTo(1))
+		Expect(metricValue(InformerEvents.WithLabelValues("TestKind", "delete")).
+			Counter.GetValue()).To(BeEquivalentTo(1))
+	})
+
+	It("Tracks the depth of named work queues", func() {
+		queue := workqueue.NewNamed("test-queue")
+		defer queue.ShutDown()
+		queue.Add("key")
+		Expect(metricValue(workqueueDepth.WithLabelValues("test-queue")).
+			Gauge.GetValue()).To(BeEquivalentTo(1))
+		key, _ := queue.Get()
+		queue.Done(key)
+		Expect(metricValue(workqueueDepth.WithLabelValues("test-queue")).
+			Gauge.GetValue()).To(BeEquivalentTo(0))
+		Expect(metricValue(workqueueWorkDuration.WithLabelValues("test-queue").(prometheus.Histogram)).
+			Histogram.GetSampleCount()).To(BeEquivalentTo(1))
+	})
+})
@@ -0,0 +1,106 @@
+package prometheus
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// Metrics of the named work queues (virtual-server-controller,
+// namespace-controller and custom-resource-controller)
+var (
+	workqueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bigip_workqueue_depth",
+			Help: "Current number of keys waiting in the work queue",
+		},
+		[]string{"queue"},
+	)
+	workqueueAdds = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bigip_workqueue_adds_total",
+			Help: "Total count of keys added to the work queue",
+		},
+		[]string{"queue"},
+	)
+	workqueueLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bigip_workqueue_queue_duration_seconds",
+			Help:    "Time a key waits in the work queue before it is processed",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		},
+		[]string{"queue"},
+	)
+	workqueueWorkDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "bigip_workqueue_work_duration_seconds",
+			Help:    "Time taken to process a key from the work queue",
+			Buckets: prometheus.ExponentialBuckets(0.001, 4, 10),
+		},
+		[]string{"queue"},
+	)
+	workqueueUnfinishedWork = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bigip_workqueue_unfinished_work_seconds",
+			Help: "Seconds spent on keys of the work queue still in progress",
+		},
+		[]string{"queue"},
+	)
+	workqueueLongestRunning = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bigip_workqueue_longest_running_processor_seconds",
+			Help: "Seconds the longest running work queue key has been processed",
+		},
+		[]string{"queue"},
+	)
+	workqueueRetries = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bigip_workqueue_retries_total",
+			Help: "Total count of keys re-added to the work queue after a failure",
+		},
+		[]string{"queue"},
+	)
+)
+
+// workqueueMetricsProvider feeds the metrics of the client-go work queues
+// into the metrics above
+type workqueueMetricsProvider struct{}
+
+func registerWorkqueueMetrics() {
+	prometheus.MustRegister(workqueueDepth)
+	prometheus.MustRegister(workqueueAdds)
+	prometheus.MustRegister(workqueueLatency)
+	prometheus.MustRegister(workqueueWorkDuration)
+	prometheus.MustRegister(workqueueUnfinishedWork)
+	prometheus.MustRegister(workqueueLongestRunning)
+	prometheus.MustRegister(workqueueRetries)
+	// Only applies to queues created afterwards
+	workqueue.SetProvider(workqueueMetricsProvider{})
+}
+
+func (workqueueMetricsProvider) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return workqueueDepth.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewAddsMetric(name string) workqueue.CounterMetric {
+	return workqueueAdds.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLatencyMetric(name string) workqueue.HistogramMetric {
+	return workqueueLatency.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewWorkDurationMetric(name string) workqueue.HistogramMetric {
+	return workqueueWorkDuration.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewUnfinishedWorkSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueUnfinishedWork.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewLongestRunningProcessorSecondsMetric(name string) workqueue.SettableGaugeMetric {
+	return workqueueLongestRunning.WithLabelValues(name)
+}
+
+func (workqueueMetricsProvider) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return workqueueRetries.WithLabelValues(name)
+}
@@ -0,0 +1,114 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// AS3InfoPath is the BIG-IP endpoint reporting the version of AS3
+const AS3InfoPath = "/mgmt/shared/appsvcs/info"
+
+// GetBigipAS3Version returns the major and minor version of AS3 installed
+// on the BIG-IP at bigIPURL. httpReq sends the request and returns the
+// response with its decoded JSON body, or nils on failure.
+func GetBigipAS3Version(
+	bigIPURL string,
+	httpReq func(*http.Request) (*http.Response, map[string]interface{}),
+) (string, error) {
+	req, err := http.NewRequest("GET", bigIPURL+AS3InfoPath, nil)
+	if err != nil {
+		return "", err
+	}
+	httpResp, responseMap := httpReq(req)
+	if httpResp == nil || responseMap == nil {
+		return "", fmt.Errorf("Internal Error")
+	}
+	return getAS3Version(httpResp.StatusCode, responseMap)
+}
+
+// getAS3Version returns the major and minor version of AS3 from the
+// response of the AS3InfoPath endpoint
+func getAS3Version(statusCode int, responseMap map[string]interface{}) (string, error) {
+	switch statusCode {
+	case http.StatusOK:
+		if versionField, ok := responseMap["version"].(string); ok {
+			if idx := strings.LastIndex(versionField, "."); idx != -1 {
+				return versionField[:idx], nil
+			}
+			return versionField, nil
+		}
+	case http.StatusNotFound:
+		if code, ok := responseMap["code"].(float64); ok && int(code) == http.StatusNotFound {
+			return "", fmt.Errorf("App services are not installed on BIGIP,"+
+				" Error response from BIGIP with status code %v", statusCode)
+		}
+		// In case of 503 status code : CIS will exit and auto restart of the
+		// controller might fetch the BIGIP version once BIGIP is available.
+	}
+	return "", fmt.Errorf("Error response from BIGIP with status code %v", statusCode)
+}
+
+// GetAS3TenantErrors returns the AS3 error message of each tenant that BIG-IP
+// rejected. Errors not reported per tenant are attributed to all tenants
+// of the declaration.
+func GetAS3TenantErrors(responseMap map[string]interface{}, tenants []string) map[string]string {
+	tenantErrors := make(map[string]string)
+	if results, ok := (responseMap["results"]).([]interface{}); ok {
+		for _, value := range results {
+			v, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			tenant, _ := v["tenant"].(string)
+			if code, ok := v["code"].(float64); tenant == "" || (ok && code < 300) {
+				continue
+			}
+			tenantErrors[tenant] = GetAS3ErrorMessage(v)
+		}
+		if len(tenantErrors) != 0 {
+			return tenantErrors
+		}
+	}
+	msg := GetAS3ErrorMessage(responseMap)
+	if err, ok := (responseMap["error"]).(map[string]interface{}); ok {
+		msg = GetAS3ErrorMessage(err)
+	}
+	for _, tenant := range tenants {
+		tenantErrors[tenant] = msg
+	}
+	return tenantErrors
+}
+
+// GetAS3ErrorMessage combines the message and the errors of an AS3 result
+func GetAS3ErrorMessage(result map[string]interface{}) string {
+	var msgs []string
+	if msg, ok := result["message"].(string); ok && msg != "" {
+		msgs = append(msgs, msg)
+	}
+	if errs, ok := result["errors"].([]interface{}); ok {
+		for _, err := range errs {
+			msgs = append(msgs, fmt.Sprintf("%v", err))
+		}
+	}
+	if len(msgs) == 0 {
+		return fmt.Sprintf("declaration failed with code %v", result["code"])
+	}
+	return strings.Join(msgs, ": ")
+}
@@ -0,0 +1,83 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AS3 Error Tests", func() {
+	It("Extracts errors of rejected tenants", func() {
+		rsp := map[string]interface{}{
+			"results": []interface{}{
+				map[string]interface{}{"code": float64(200), "tenant": "app1", "message": "success"},
+				map[string]interface{}{"code": float64(422), "tenant": "app2",
+					"message": "declaration failed", "errors": []interface{}{"/app2/svc/pool: invalid"}},
+			},
+		}
+		Expect(GetAS3TenantErrors(rsp, []string{"app1", "app2"})).To(Equal(
+			map[string]string{"app2": "declaration failed: /app2/svc/pool: invalid"}))
+	})
+
+	It("Attributes declaration errors to all tenants", func() {
+		rsp := map[string]interface{}{
+			"code":    float64(422),
+			"message": "declaration is invalid",
+			"errors":  []interface{}{"/app1/svc: should have required property 'class'"},
+		}
+		msg := "declaration is invalid: /app1/svc: should have required property 'class'"
+		Expect(GetAS3TenantErrors(rsp, []string{"app1", "app2"})).To(Equal(
+			map[string]string{"app1": msg, "app2": msg}))
+	})
+
+	It("Combines the message and errors of a result", func() {
+		Expect(GetAS3ErrorMessage(map[string]interface{}{
+			"message": "declaration failed",
+			"errors":  []interface{}{"first", "second"},
+		})).To(Equal("declaration failed: first: second"))
+		Expect(GetAS3ErrorMessage(map[string]interface{}{"code": float64(500)})).To(
+			Equal("declaration failed with code 500"))
+	})
+
+	It("Reads the AS3 version of BIG-IP", func() {
+		var statusCode int
+		var body map[string]interface{}
+		var url string
+		httpReq := func(req *http.Request) (*http.Response, map[string]interface{}) {
+			url = req.URL.String()
+			if body == nil {
+				return nil, nil
+			}
+			return &http.Response{StatusCode: statusCode}, body
+		}
+
+		statusCode, body = http.StatusOK, map[string]interface{}{"version": "3.18.0"}
+		Expect(GetBigipAS3Version("https://bigip", httpReq)).To(Equal("3.18"))
+		Expect(url).To(Equal("https://bigip" + AS3InfoPath))
+
+		statusCode, body = http.StatusNotFound, map[string]interface{}{"code": float64(404)}
+		_, err := GetBigipAS3Version("https://bigip", httpReq)
+		Expect(err).To(MatchError(ContainSubstring("App services are not installed")))
+
+		body = nil
+		_, err = GetBigipAS3Version("https://bigip", httpReq)
+		Expect(err).To(HaveOccurred())
+	})
+})
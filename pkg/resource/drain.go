@@ -0,0 +1,129 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Session states of pool members. Disabled members take no new connections
+// but keep their active ones.
+const (
+	MemberSessionEnabled  = "user-enabled"
+	MemberSessionDisabled = "user-disabled"
+)
+
+// MemberDrainer keeps the pool members of a service port whose endpoint is
+// no longer ready, because its pod is not ready or is terminating, as
+// disabled members so that their connections are not cut. They are removed
+// once their endpoint is gone, or after the drain period at most.
+type MemberDrainer struct {
+	sync.Mutex
+	period time.Duration
+	now    func() time.Time
+	// Members of each service port, with the time they started draining,
+	// zero while they are ready
+	members map[ServiceKey]map[memberAddr]time.Time
+}
+
+type memberAddr struct {
+	Address string
+	Port    int32
+}
+
+// NewMemberDrainer creates a MemberDrainer draining members for period
+func NewMemberDrainer(period time.Duration) *MemberDrainer {
+	return &MemberDrainer{
+		period:  period,
+		now:     time.Now,
+		members: make(map[ServiceKey]map[memberAddr]time.Time),
+	}
+}
+
+// Drain returns the ready members of the service port of key followed by the
+// members of its not ready or terminating endpoints that were ready before,
+// disabled, and the time left until the first of them expires, zero when
+// none is draining.
+func (d *MemberDrainer) Drain(key ServiceKey, ready, notReady []Member) ([]Member, time.Duration) {
+	d.Lock()
+	defer d.Unlock()
+	now := d.now()
+	known := d.members[key]
+	current := make(map[memberAddr]time.Time)
+	for _, member := range ready {
+		current[memberAddr{member.Address, member.Port}] = time.Time{}
+	}
+
+	var draining []Member
+	var next time.Duration
+	for _, member := range notReady {
+		addr := memberAddr{member.Address, member.Port}
+		if _, ok := current[addr]; ok {
+			continue
+		}
+		// Members which were never ready have no connections to drain
+		since, ok := known[addr]
+		if !ok {
+			continue
+		}
+		if since.IsZero() {
+			since = now
+		}
+		// Expired members are kept so that they are not drained again
+		current[addr] = since
+		left := since.Add(d.period).Sub(now)
+		if left <= 0 {
+			continue
+		}
+		member.Session = MemberSessionDisabled
+		draining = append(draining, member)
+		if 0 == next || left < next {
+			next = left
+		}
+	}
+	if 0 == len(current) {
+		delete(d.members, key)
+	} else {
+		d.members[key] = current
+	}
+	if 0 == len(draining) {
+		return ready, 0
+	}
+
+	sort.Slice(draining, func(i, j int) bool {
+		if draining[i].Address != draining[j].Address {
+			return draining[i].Address < draining[j].Address
+		}
+		return draining[i].Port < draining[j].Port
+	})
+	members := make([]Member, 0, len(ready)+len(draining))
+	members = append(members, ready...)
+	return append(members, draining...), next
+}
+
+// Forget drops the members of the ports of a service, once it is gone
+func (d *MemberDrainer) Forget(namespace, serviceName string) {
+	d.Lock()
+	defer d.Unlock()
+	for key := range d.members {
+		if key.Namespace == namespace && key.ServiceName == serviceName {
+			delete(d.members, key)
+		}
+	}
+}
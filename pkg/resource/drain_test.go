@@ -0,0 +1,97 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Member Drainer Tests", func() {
+	var drainer *MemberDrainer
+	var now time.Time
+	key := ServiceKey{Namespace: "default", ServiceName: "foo", ServicePort: 80}
+	enabled := func(addr string) Member {
+		return Member{Address: addr, Port: 8080, Session: MemberSessionEnabled}
+	}
+	disabled := func(addr string) Member {
+		return Member{Address: addr, Port: 8080, Session: MemberSessionDisabled}
+	}
+
+	BeforeEach(func() {
+		now = time.Unix(1000, 0)
+		drainer = NewMemberDrainer(30 * time.Second)
+		drainer.now = func() time.Time { return now }
+	})
+
+	It("drains the members that are no longer ready", func() {
+		members, next := drainer.Drain(key, []Member{enabled("10.1.1.1"), enabled("10.1.1.2")}, nil)
+		Expect(members).To(Equal([]Member{enabled("10.1.1.1"), enabled("10.1.1.2")}))
+		Expect(next).To(BeZero())
+
+		// Members which were never ready are not drained
+		members, next = drainer.Drain(key, []Member{enabled("10.1.1.1")},
+			[]Member{enabled("10.1.1.2"), enabled("10.1.1.3")})
+		Expect(members).To(Equal([]Member{enabled("10.1.1.1"), disabled("10.1.1.2")}))
+		Expect(next).To(Equal(30 * time.Second))
+
+		now = now.Add(10 * time.Second)
+		members, next = drainer.Drain(key, nil,
+			[]Member{enabled("10.1.1.1"), enabled("10.1.1.2"), enabled("10.1.1.3")})
+		Expect(members).To(Equal([]Member{disabled("10.1.1.1"), disabled("10.1.1.2")}))
+		Expect(next).To(Equal(20 * time.Second))
+
+		// The drain period is an upper bound
+		now = now.Add(20 * time.Second)
+		members, next = drainer.Drain(key, nil, []Member{enabled("10.1.1.1"), enabled("10.1.1.2")})
+		Expect(members).To(Equal([]Member{disabled("10.1.1.1")}))
+		Expect(next).To(Equal(10 * time.Second))
+		now = now.Add(20 * time.Second)
+		members, next = drainer.Drain(key, nil, []Member{enabled("10.1.1.1"), enabled("10.1.1.2")})
+		Expect(members).To(BeEmpty())
+		Expect(next).To(BeZero())
+
+		// Ready again
+		members, next = drainer.Drain(key, []Member{enabled("10.1.1.1")}, nil)
+		Expect(members).To(Equal([]Member{enabled("10.1.1.1")}))
+		Expect(next).To(BeZero())
+	})
+
+	It("removes the draining members whose endpoint is gone", func() {
+		drainer.Drain(key, []Member{enabled("10.1.1.1"), enabled("10.1.1.2")}, nil)
+		members, _ := drainer.Drain(key, []Member{enabled("10.1.1.1")}, []Member{enabled("10.1.1.2")})
+		Expect(members).To(Equal([]Member{enabled("10.1.1.1"), disabled("10.1.1.2")}))
+		members, next := drainer.Drain(key, []Member{enabled("10.1.1.1")}, nil)
+		Expect(members).To(Equal([]Member{enabled("10.1.1.1")}))
+		Expect(next).To(BeZero())
+	})
+
+	It("keeps the members of each service port apart", func() {
+		other := ServiceKey{Namespace: "default", ServiceName: "foo", ServicePort: 443}
+		drainer.Drain(key, []Member{enabled("10.1.1.1")}, nil)
+		members, _ := drainer.Drain(other, nil, []Member{enabled("10.1.1.1")})
+		Expect(members).To(BeEmpty())
+		members, _ = drainer.Drain(key, nil, []Member{enabled("10.1.1.1")})
+		Expect(members).To(Equal([]Member{disabled("10.1.1.1")}))
+
+		drainer.Forget("default", "foo")
+		members, _ = drainer.Drain(key, nil, []Member{enabled("10.1.1.1")})
+		Expect(members).To(BeEmpty())
+	})
+})
@@ -0,0 +1,278 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// EndpointSliceServiceLabel labels the EndpointSlices of a service with
+	// the service name
+	EndpointSliceServiceLabel = "kubernetes.io/service-name"
+	// EndpointSliceServiceIndex indexes the EndpointSlices by the
+	// <namespace>/<name> of their service
+	EndpointSliceServiceIndex = "service"
+)
+
+// EndpointSliceResource is the discovery.k8s.io/v1 EndpointSlice resource,
+// served by Kubernetes 1.21 and later
+var EndpointSliceResource = schema.GroupVersionResource{
+	Group:    "discovery.k8s.io",
+	Version:  "v1",
+	Resource: "endpointslices",
+}
+
+// The Kubernetes client libraries predate discovery.k8s.io/v1, so the
+// EndpointSlices are watched with the dynamic client and converted to the
+// following types, which only hold the fields CIS uses.
+type (
+	// EndpointSlice is a subset of the endpoints of a service
+	EndpointSlice struct {
+		metav1.ObjectMeta `json:"metadata,omitempty"`
+		// IPv4, IPv6 or FQDN
+		AddressType string                  `json:"addressType"`
+		Endpoints   []EndpointSliceEndpoint `json:"endpoints"`
+		Ports       []EndpointSlicePort     `json:"ports"`
+	}
+
+	EndpointSliceEndpoint struct {
+		Addresses  []string                `json:"addresses"`
+		Conditions EndpointSliceConditions `json:"conditions,omitempty"`
+		NodeName   *string                 `json:"nodeName,omitempty"`
+		Zone       *string                 `json:"zone,omitempty"`
+	}
+
+	// EndpointSliceConditions are unknown when nil. An unknown ready or
+	// serving condition is taken as true.
+	EndpointSliceConditions struct {
+		Ready       *bool `json:"ready,omitempty"`
+		Serving     *bool `json:"serving,omitempty"`
+		Terminating *bool `json:"terminating,omitempty"`
+	}
+
+	EndpointSlicePort struct {
+		Name     *string      `json:"name,omitempty"`
+		Protocol *v1.Protocol `json:"protocol,omitempty"`
+		Port     *int32       `json:"port,omitempty"`
+	}
+)
+
+// NewEndpointSliceInformer creates an informer of the EndpointSlices in
+// namespace, indexed by EndpointSliceServiceIndex. Its store holds
+// unstructured objects, see ToEndpointSlice.
+func NewEndpointSliceInformer(
+	client dynamic.Interface,
+	namespace string,
+	resyncPeriod time.Duration,
+) cache.SharedIndexInformer {
+	return newDynamicInformer(client, EndpointSliceResource, namespace, resyncPeriod,
+		cache.Indexers{
+			cache.NamespaceIndex:      cache.MetaNamespaceIndexFunc,
+			EndpointSliceServiceIndex: endpointSliceServiceIndexFunc,
+		})
+}
+
+func endpointSliceServiceIndexFunc(obj interface{}) ([]string, error) {
+	namespace, svcName, ok := EndpointSliceService(obj)
+	if !ok {
+		return nil, nil
+	}
+	return []string{namespace + "/" + svcName}, nil
+}
+
+func metaAccessor(obj interface{}) (metav1.Object, error) {
+	switch o := obj.(type) {
+	case *unstructured.Unstructured:
+		return o, nil
+	case *EndpointSlice:
+		return o, nil
+	}
+	return nil, fmt.Errorf("unexpected EndpointSlice object %T", obj)
+}
+
+// ToEndpointSlice converts an object of the EndpointSlice informer
+func ToEndpointSlice(obj interface{}) (*EndpointSlice, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	switch o := obj.(type) {
+	case *EndpointSlice:
+		return o, nil
+	case *unstructured.Unstructured:
+		var slice EndpointSlice
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, &slice); err != nil {
+			return nil, err
+		}
+		return &slice, nil
+	}
+	return nil, fmt.Errorf("unexpected EndpointSlice object %T", obj)
+}
+
+// EndpointSliceService returns the namespace and name of the service of an
+// object of the EndpointSlice informer, false when it has none
+func EndpointSliceService(obj interface{}) (string, string, bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	meta, err := metaAccessor(obj)
+	if err != nil {
+		return "", "", false
+	}
+	svcName, ok := meta.GetLabels()[EndpointSliceServiceLabel]
+	return meta.GetNamespace(), svcName, ok && svcName != ""
+}
+
+// GetServiceEndpointSlices returns the EndpointSlices of the service
+// namespace/name from the store of the EndpointSlice informer
+func GetServiceEndpointSlices(indexer cache.Indexer, namespace, name string) ([]*EndpointSlice, error) {
+	objs, err := indexer.ByIndex(EndpointSliceServiceIndex, namespace+"/"+name)
+	if err != nil {
+		return nil, err
+	}
+	var slices []*EndpointSlice
+	for _, obj := range objs {
+		slice, err := ToEndpointSlice(obj)
+		if err != nil {
+			return nil, err
+		}
+		slices = append(slices, slice)
+	}
+	return slices, nil
+}
+
+// EndpointSliceMembers aggregates the members of the service port portName
+// over the EndpointSlices of the service. Endpoints not on a node accepted
+// by onNode are left out. Only ready endpoints are members, unless none is
+// ready; terminating endpoints still serving then take over, like they do
+// in kube-proxy.
+func EndpointSliceMembers(
+	slices []*EndpointSlice,
+	portName string,
+	onNode func(nodeName string) bool,
+) []Member {
+	members, serving := endpointSliceMembers(slices, portName, onNode)
+	if len(members) == 0 {
+		members = serving
+	}
+	return members
+}
+
+// EndpointSliceDrainingMembers returns the members of the service port
+// portName whose endpoints are terminating but still serving, which are
+// drained rather than removed
+func EndpointSliceDrainingMembers(
+	slices []*EndpointSlice,
+	portName string,
+	onNode func(nodeName string) bool,
+) []Member {
+	_, serving := endpointSliceMembers(slices, portName, onNode)
+	return serving
+}
+
+// endpointSliceMembers returns the members of the ready endpoints and the
+// ones of the terminating endpoints still serving, sorted
+func endpointSliceMembers(
+	slices []*EndpointSlice,
+	portName string,
+	onNode func(nodeName string) bool,
+) ([]Member, []Member) {
+	var ready, serving []Member
+	seen := make(map[Member]bool)
+	for _, slice := range slices {
+		if slice.AddressType != "IPv4" && slice.AddressType != "IPv6" {
+			continue
+		}
+		for _, port := range slice.Ports {
+			name := ""
+			if port.Name != nil {
+				name = *port.Name
+			}
+			if name != portName || port.Port == nil {
+				continue
+			}
+			for _, ep := range slice.Endpoints {
+				if ep.NodeName == nil || !onNode(*ep.NodeName) {
+					continue
+				}
+				isReady := ep.Conditions.Ready == nil || *ep.Conditions.Ready
+				isServing := isReady
+				if ep.Conditions.Serving != nil {
+					isServing = *ep.Conditions.Serving
+				}
+				isTerminating := ep.Conditions.Terminating != nil && *ep.Conditions.Terminating
+				for _, addr := range ep.Addresses {
+					member := Member{
+						Address: addr,
+						Port:    *port.Port,
+						Session: MemberSessionEnabled,
+					}
+					// Endpoints may be in two slices while they are moved
+					if seen[member] {
+						continue
+					}
+					switch {
+					case isReady && !isTerminating:
+						ready = append(ready, member)
+					case isServing && isTerminating:
+						serving = append(serving, member)
+					default:
+						continue
+					}
+					seen[member] = true
+				}
+			}
+		}
+	}
+	// Slices come from the store in any order
+	for _, members := range [][]Member{ready, serving} {
+		sort.Slice(members, func(i, j int) bool {
+			if members[i].Address != members[j].Address {
+				return members[i].Address < members[j].Address
+			}
+			return members[i].Port < members[j].Port
+		})
+	}
+	return ready, serving
+}
+
+// EndpointSliceNodes returns the node names of the endpoint addresses of
+// the EndpointSlices of a service
+func EndpointSliceNodes(slices []*EndpointSlice) map[string]string {
+	nodes := make(map[string]string)
+	for _, slice := range slices {
+		for _, ep := range slice.Endpoints {
+			if ep.NodeName == nil {
+				continue
+			}
+			for _, addr := range ep.Addresses {
+				nodes[addr] = *ep.NodeName
+			}
+		}
+	}
+	return nodes
+}
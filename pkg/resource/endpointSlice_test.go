@@ -0,0 +1,143 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newUnstructuredEndpointSlice(name, svcName string, endpoints ...interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "discovery.k8s.io/v1",
+		"kind":       "EndpointSlice",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": "default",
+			"labels":    map[string]interface{}{EndpointSliceServiceLabel: svcName},
+		},
+		"addressType": "IPv4",
+		"endpoints":   endpoints,
+		"ports": []interface{}{
+			map[string]interface{}{"name": "http", "port": int64(8080), "protocol": "TCP"},
+		},
+	}}
+}
+
+func newSliceEndpoint(addr, node string, ready, serving, terminating bool) interface{} {
+	return map[string]interface{}{
+		"addresses": []interface{}{addr},
+		"nodeName":  node,
+		"conditions": map[string]interface{}{
+			"ready":       ready,
+			"serving":     serving,
+			"terminating": terminating,
+		},
+	}
+}
+
+func sliceMembers(port int32, addrs ...string) []Member {
+	var members []Member
+	for _, addr := range addrs {
+		members = append(members, Member{Address: addr, Port: port, Session: "user-enabled"})
+	}
+	return members
+}
+
+var _ = Describe("EndpointSlice Tests", func() {
+	anyNode := func(string) bool { return true }
+
+	It("converts the objects of the informer", func() {
+		obj := newUnstructuredEndpointSlice("foo-abc", "foo",
+			newSliceEndpoint("10.1.1.1", "node0", true, true, false))
+		slice, err := ToEndpointSlice(obj)
+		Expect(err).To(BeNil())
+		Expect(slice.Name).To(Equal("foo-abc"))
+		Expect(slice.AddressType).To(Equal("IPv4"))
+		Expect(slice.Endpoints).To(HaveLen(1))
+		Expect(*slice.Endpoints[0].NodeName).To(Equal("node0"))
+		Expect(*slice.Endpoints[0].Conditions.Ready).To(BeTrue())
+		Expect(*slice.Ports[0].Port).To(Equal(int32(8080)))
+
+		tombstone, err := ToEndpointSlice(cache.DeletedFinalStateUnknown{Key: "default/foo-abc", Obj: obj})
+		Expect(err).To(BeNil())
+		Expect(tombstone).To(Equal(slice))
+		_, err = ToEndpointSlice("foo")
+		Expect(err).NotTo(BeNil())
+
+		ns, svcName, ok := EndpointSliceService(obj)
+		Expect(ok).To(BeTrue())
+		Expect(ns).To(Equal("default"))
+		Expect(svcName).To(Equal("foo"))
+		_, _, ok = EndpointSliceService(newUnstructuredEndpointSlice("bar-abc", ""))
+		Expect(ok).To(BeFalse())
+	})
+
+	It("indexes the slices by service", func() {
+		informer := NewEndpointSliceInformer(nil, "", 0)
+		indexer := informer.GetIndexer()
+		Expect(indexer.Add(newUnstructuredEndpointSlice("foo-abc", "foo"))).To(BeNil())
+		Expect(indexer.Add(newUnstructuredEndpointSlice("foo-def", "foo"))).To(BeNil())
+		Expect(indexer.Add(newUnstructuredEndpointSlice("bar-abc", "bar"))).To(BeNil())
+
+		slices, err := GetServiceEndpointSlices(indexer, "default", "foo")
+		Expect(err).To(BeNil())
+		Expect(slices).To(HaveLen(2))
+		slices, err = GetServiceEndpointSlices(indexer, "other", "foo")
+		Expect(err).To(BeNil())
+		Expect(slices).To(BeEmpty())
+	})
+
+	It("aggregates the ready members of the slices", func() {
+		var slices []*EndpointSlice
+		for _, obj := range []*unstructured.Unstructured{
+			newUnstructuredEndpointSlice("foo-abc", "foo",
+				newSliceEndpoint("10.1.1.3", "node0", true, true, false),
+				newSliceEndpoint("10.1.1.2", "node1", false, false, false),
+				newSliceEndpoint("10.1.1.1", "node1", true, true, false)),
+			// 10.1.1.3 being moved to another slice
+			newUnstructuredEndpointSlice("foo-def", "foo",
+				newSliceEndpoint("10.1.1.3", "node0", true, true, false),
+				newSliceEndpoint("10.1.1.4", "node2", false, true, true)),
+		} {
+			slice, err := ToEndpointSlice(obj)
+			Expect(err).To(BeNil())
+			slices = append(slices, slice)
+		}
+
+		Expect(EndpointSliceMembers(slices, "http", anyNode)).To(Equal(
+			sliceMembers(8080, "10.1.1.1", "10.1.1.3")))
+		Expect(EndpointSliceMembers(slices, "https", anyNode)).To(BeEmpty())
+		Expect(EndpointSliceMembers(slices, "http", func(node string) bool {
+			return node == "node1"
+		})).To(Equal(sliceMembers(8080, "10.1.1.1")))
+
+		// Only the terminating endpoints still serving are left
+		Expect(EndpointSliceMembers(slices, "http", func(node string) bool {
+			return node == "node2"
+		})).To(Equal(sliceMembers(8080, "10.1.1.4")))
+		Expect(EndpointSliceDrainingMembers(slices, "http", anyNode)).To(Equal(
+			sliceMembers(8080, "10.1.1.4")))
+
+		fqdn, _ := ToEndpointSlice(newUnstructuredEndpointSlice("foo-ghi", "foo",
+			newSliceEndpoint("foo.example.com", "node0", true, true, false)))
+		fqdn.AddressType = "FQDN"
+		Expect(EndpointSliceMembers([]*EndpointSlice{fqdn}, "http", anyNode)).To(BeEmpty())
+	})
+})
@@ -0,0 +1,510 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// GatewayAPIGroup is the API group of the Gateway API resources
+const GatewayAPIGroup = "gateway.networking.k8s.io"
+
+// Gateway API resources watched by CIS. GatewayClass, Gateway and HTTPRoute
+// are served in v1 by the Gateway API 1.0 CRDs, TLSRoute and TCPRoute only
+// in the experimental v1alpha2.
+var (
+	GatewayClassResource = schema.GroupVersionResource{
+		Group:    GatewayAPIGroup,
+		Version:  "v1",
+		Resource: "gatewayclasses",
+	}
+	GatewayResource = schema.GroupVersionResource{
+		Group:    GatewayAPIGroup,
+		Version:  "v1",
+		Resource: "gateways",
+	}
+	HTTPRouteResource = schema.GroupVersionResource{
+		Group:    GatewayAPIGroup,
+		Version:  "v1",
+		Resource: "httproutes",
+	}
+	TLSRouteResource = schema.GroupVersionResource{
+		Group:    GatewayAPIGroup,
+		Version:  "v1alpha2",
+		Resource: "tlsroutes",
+	}
+	TCPRouteResource = schema.GroupVersionResource{
+		Group:    GatewayAPIGroup,
+		Version:  "v1alpha2",
+		Resource: "tcproutes",
+	}
+)
+
+// Values of the Gateway API fields and conditions used by CIS
+const (
+	GatewayKind   = "Gateway"
+	HTTPRouteKind = "HTTPRoute"
+	TLSRouteKind  = "TLSRoute"
+	TCPRouteKind  = "TCPRoute"
+
+	HTTPProtocolType  = "HTTP"
+	HTTPSProtocolType = "HTTPS"
+	TLSProtocolType   = "TLS"
+	TCPProtocolType   = "TCP"
+
+	TLSModeTerminate   = "Terminate"
+	TLSModePassthrough = "Passthrough"
+
+	IPAddressType = "IPAddress"
+
+	PathMatchExact      = "Exact"
+	PathMatchPathPrefix = "PathPrefix"
+	HeaderMatchExact    = "Exact"
+	QueryMatchExact     = "Exact"
+
+	RequestHeaderModifierFilter = "RequestHeaderModifier"
+	RequestRedirectFilter       = "RequestRedirect"
+	URLRewriteFilter            = "URLRewrite"
+
+	FullPathHTTPPathModifier = "ReplaceFullPath"
+
+	ConditionAccepted     = "Accepted"
+	ConditionProgrammed   = "Programmed"
+	ConditionResolvedRefs = "ResolvedRefs"
+	ConditionConflicted   = "Conflicted"
+
+	ReasonAccepted              = "Accepted"
+	ReasonProgrammed            = "Programmed"
+	ReasonResolvedRefs          = "ResolvedRefs"
+	ReasonInvalid               = "Invalid"
+	ReasonAddressNotAssigned    = "AddressNotAssigned"
+	ReasonUnsupportedAddress    = "UnsupportedAddress"
+	ReasonUnsupportedProtocol   = "UnsupportedProtocol"
+	ReasonProtocolConflict      = "ProtocolConflict"
+	ReasonHostnameConflict      = "HostnameConflict"
+	ReasonNoConflicts           = "NoConflicts"
+	ReasonInvalidCertificateRef = "InvalidCertificateRef"
+	ReasonNoMatchingParent      = "NoMatchingParent"
+	ReasonNotAllowedByListeners = "NotAllowedByListeners"
+	ReasonNoMatchingHostname    = "NoMatchingListenerHostname"
+	ReasonUnsupportedValue      = "UnsupportedValue"
+	ReasonBackendNotFound       = "BackendNotFound"
+	ReasonInvalidKind           = "InvalidKind"
+	ReasonRefNotPermitted       = "RefNotPermitted"
+)
+
+// The Kubernetes client libraries predate the Gateway API, so its resources
+// are watched with the dynamic client and converted to the following types,
+// which only hold the fields CIS uses.
+type (
+	// GatewayClass selects the controller of its Gateways
+	GatewayClass struct {
+		metav1.ObjectMeta `json:"metadata,omitempty"`
+		Spec              GatewayClassSpec   `json:"spec"`
+		Status            GatewayClassStatus `json:"status,omitempty"`
+	}
+
+	GatewayClassSpec struct {
+		ControllerName string `json:"controllerName"`
+	}
+
+	GatewayClassStatus struct {
+		Conditions []GatewayCondition `json:"conditions,omitempty"`
+	}
+
+	// Gateway exposes listeners on its addresses
+	Gateway struct {
+		metav1.ObjectMeta `json:"metadata,omitempty"`
+		Spec              GatewaySpec   `json:"spec"`
+		Status            GatewayStatus `json:"status,omitempty"`
+	}
+
+	GatewaySpec struct {
+		GatewayClassName string           `json:"gatewayClassName"`
+		Listeners        []Listener       `json:"listeners"`
+		Addresses        []GatewayAddress `json:"addresses,omitempty"`
+	}
+
+	Listener struct {
+		Name     string            `json:"name"`
+		Hostname *string           `json:"hostname,omitempty"`
+		Port     int32             `json:"port"`
+		Protocol string            `json:"protocol"`
+		TLS      *GatewayTLSConfig `json:"tls,omitempty"`
+	}
+
+	GatewayTLSConfig struct {
+		Mode            *string                 `json:"mode,omitempty"`
+		CertificateRefs []SecretObjectReference `json:"certificateRefs,omitempty"`
+	}
+
+	SecretObjectReference struct {
+		Group     *string `json:"group,omitempty"`
+		Kind      *string `json:"kind,omitempty"`
+		Name      string  `json:"name"`
+		Namespace *string `json:"namespace,omitempty"`
+	}
+
+	GatewayAddress struct {
+		Type  *string `json:"type,omitempty"`
+		Value string  `json:"value"`
+	}
+
+	GatewayStatus struct {
+		Addresses  []GatewayStatusAddress `json:"addresses,omitempty"`
+		Conditions []GatewayCondition     `json:"conditions,omitempty"`
+		Listeners  []ListenerStatus       `json:"listeners,omitempty"`
+	}
+
+	GatewayStatusAddress struct {
+		Type  *string `json:"type,omitempty"`
+		Value string  `json:"value"`
+	}
+
+	ListenerStatus struct {
+		Name           string             `json:"name"`
+		SupportedKinds []RouteGroupKind   `json:"supportedKinds"`
+		AttachedRoutes int32              `json:"attachedRoutes"`
+		Conditions     []GatewayCondition `json:"conditions"`
+	}
+
+	RouteGroupKind struct {
+		Group *string `json:"group,omitempty"`
+		Kind  string  `json:"kind"`
+	}
+
+	// GatewayCondition is a metav1.Condition, which the client libraries
+	// predate as well
+	GatewayCondition struct {
+		Type               string      `json:"type"`
+		Status             string      `json:"status"`
+		ObservedGeneration int64       `json:"observedGeneration,omitempty"`
+		LastTransitionTime metav1.Time `json:"lastTransitionTime"`
+		Reason             string      `json:"reason"`
+		Message            string      `json:"message"`
+	}
+
+	// HTTPRoute routes HTTP requests of Gateway listeners to services
+	HTTPRoute struct {
+		metav1.ObjectMeta `json:"metadata,omitempty"`
+		Spec              HTTPRouteSpec `json:"spec"`
+		Status            RouteStatus   `json:"status,omitempty"`
+	}
+
+	HTTPRouteSpec struct {
+		ParentRefs []ParentReference `json:"parentRefs,omitempty"`
+		Hostnames  []string          `json:"hostnames,omitempty"`
+		Rules      []HTTPRouteRule   `json:"rules,omitempty"`
+	}
+
+	ParentReference struct {
+		Group       *string `json:"group,omitempty"`
+		Kind        *string `json:"kind,omitempty"`
+		Namespace   *string `json:"namespace,omitempty"`
+		Name        string  `json:"name"`
+		SectionName *string `json:"sectionName,omitempty"`
+		Port        *int32  `json:"port,omitempty"`
+	}
+
+	HTTPRouteRule struct {
+		Matches     []HTTPRouteMatch  `json:"matches,omitempty"`
+		Filters     []HTTPRouteFilter `json:"filters,omitempty"`
+		BackendRefs []HTTPBackendRef  `json:"backendRefs,omitempty"`
+	}
+
+	HTTPRouteMatch struct {
+		Path        *HTTPPathMatch        `json:"path,omitempty"`
+		Headers     []HTTPHeaderMatch     `json:"headers,omitempty"`
+		QueryParams []HTTPQueryParamMatch `json:"queryParams,omitempty"`
+		Method      *string               `json:"method,omitempty"`
+	}
+
+	HTTPPathMatch struct {
+		Type  *string `json:"type,omitempty"`
+		Value *string `json:"value,omitempty"`
+	}
+
+	HTTPHeaderMatch struct {
+		Type  *string `json:"type,omitempty"`
+		Name  string  `json:"name"`
+		Value string  `json:"value"`
+	}
+
+	HTTPQueryParamMatch struct {
+		Type  *string `json:"type,omitempty"`
+		Name  string  `json:"name"`
+		Value string  `json:"value"`
+	}
+
+	HTTPRouteFilter struct {
+		Type                  string                     `json:"type"`
+		RequestHeaderModifier *HTTPHeaderFilter          `json:"requestHeaderModifier,omitempty"`
+		RequestRedirect       *HTTPRequestRedirectFilter `json:"requestRedirect,omitempty"`
+		URLRewrite            *HTTPURLRewriteFilter      `json:"urlRewrite,omitempty"`
+	}
+
+	HTTPHeaderFilter struct {
+		Set    []HTTPHeader `json:"set,omitempty"`
+		Add    []HTTPHeader `json:"add,omitempty"`
+		Remove []string     `json:"remove,omitempty"`
+	}
+
+	HTTPHeader struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	HTTPRequestRedirectFilter struct {
+		Scheme     *string           `json:"scheme,omitempty"`
+		Hostname   *string           `json:"hostname,omitempty"`
+		Path       *HTTPPathModifier `json:"path,omitempty"`
+		Port       *int32            `json:"port,omitempty"`
+		StatusCode *int              `json:"statusCode,omitempty"`
+	}
+
+	HTTPURLRewriteFilter struct {
+		Hostname *string           `json:"hostname,omitempty"`
+		Path     *HTTPPathModifier `json:"path,omitempty"`
+	}
+
+	HTTPPathModifier struct {
+		Type               string  `json:"type"`
+		ReplaceFullPath    *string `json:"replaceFullPath,omitempty"`
+		ReplacePrefixMatch *string `json:"replacePrefixMatch,omitempty"`
+	}
+
+	HTTPBackendRef struct {
+		BackendRef `json:",inline"`
+		Filters    []HTTPRouteFilter `json:"filters,omitempty"`
+	}
+
+	BackendRef struct {
+		Group     *string `json:"group,omitempty"`
+		Kind      *string `json:"kind,omitempty"`
+		Name      string  `json:"name"`
+		Namespace *string `json:"namespace,omitempty"`
+		Port      *int32  `json:"port,omitempty"`
+		Weight    *int32  `json:"weight,omitempty"`
+	}
+
+	// TLSRoute routes TLS connections of Gateway listeners to services
+	TLSRoute struct {
+		metav1.ObjectMeta `json:"metadata,omitempty"`
+		Spec              TLSRouteSpec `json:"spec"`
+		Status            RouteStatus  `json:"status,omitempty"`
+	}
+
+	TLSRouteSpec struct {
+		ParentRefs []ParentReference `json:"parentRefs,omitempty"`
+		Hostnames  []string          `json:"hostnames,omitempty"`
+		Rules      []L4RouteRule     `json:"rules"`
+	}
+
+	// TCPRoute routes TCP connections of Gateway listeners to services
+	TCPRoute struct {
+		metav1.ObjectMeta `json:"metadata,omitempty"`
+		Spec              TCPRouteSpec `json:"spec"`
+		Status            RouteStatus  `json:"status,omitempty"`
+	}
+
+	TCPRouteSpec struct {
+		ParentRefs []ParentReference `json:"parentRefs,omitempty"`
+		Rules      []L4RouteRule     `json:"rules"`
+	}
+
+	// L4RouteRule is a rule of a TLSRoute or a TCPRoute
+	L4RouteRule struct {
+		BackendRefs []BackendRef `json:"backendRefs,omitempty"`
+	}
+
+	RouteStatus struct {
+		Parents []RouteParentStatus `json:"parents"`
+	}
+
+	RouteParentStatus struct {
+		ParentRef      ParentReference    `json:"parentRef"`
+		ControllerName string             `json:"controllerName"`
+		Conditions     []GatewayCondition `json:"conditions,omitempty"`
+	}
+)
+
+// NewGatewayAPIInformer creates an informer of a Gateway API resource in
+// namespace, all namespaces for cluster resources. Its store holds
+// unstructured objects, see FromGatewayAPIObject.
+func NewGatewayAPIInformer(
+	client dynamic.Interface,
+	resource schema.GroupVersionResource,
+	namespace string,
+	resyncPeriod time.Duration,
+) cache.SharedIndexInformer {
+	return newDynamicInformer(client, resource, namespace, resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// FromGatewayAPIObject converts an object of a Gateway API informer into
+// out, a pointer to one of the Gateway API types
+func FromGatewayAPIObject(obj interface{}, out interface{}) error {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return fmt.Errorf("unexpected Gateway API object %T", obj)
+	}
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, out)
+}
+
+// SetGatewayAPIStatus returns a copy of obj with status set, false when
+// status does not change its status. Conditions keep their transition time
+// while their status does not change.
+func SetGatewayAPIStatus(obj *unstructured.Unstructured, status interface{}) (*unstructured.Unstructured, bool, error) {
+	current := reflect.New(reflect.TypeOf(status).Elem()).Interface()
+	if data, ok := obj.Object["status"].(map[string]interface{}); ok {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(data, current); err != nil {
+			return nil, false, err
+		}
+	}
+	keepTransitionTimes(reflect.ValueOf(current).Elem(), reflect.ValueOf(status).Elem())
+	if reflect.DeepEqual(current, status) {
+		return nil, false, nil
+	}
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return nil, false, err
+	}
+	updated := obj.DeepCopy()
+	updated.Object["status"] = data
+	return updated, true, nil
+}
+
+var gatewayConditionsType = reflect.TypeOf([]GatewayCondition{})
+
+// keepTransitionTimes copies the transition times of the unchanged
+// conditions of current into the status, recursively through the structs
+// and the slices of structs with the same name or parent
+func keepTransitionTimes(current, status reflect.Value) {
+	switch status.Kind() {
+	case reflect.Struct:
+		for i := 0; i < status.NumField(); i++ {
+			keepTransitionTimes(current.Field(i), status.Field(i))
+		}
+	case reflect.Slice:
+		if status.Type() == gatewayConditionsType {
+			for i := 0; i < status.Len(); i++ {
+				cond := status.Index(i).Addr().Interface().(*GatewayCondition)
+				for j := 0; j < current.Len(); j++ {
+					old := current.Index(j).Interface().(GatewayCondition)
+					if old.Type == cond.Type && old.Status == cond.Status {
+						cond.LastTransitionTime = old.LastTransitionTime
+					}
+				}
+			}
+			return
+		}
+		for i := 0; i < status.Len(); i++ {
+			for j := 0; j < current.Len(); j++ {
+				if sameStatusEntry(current.Index(j), status.Index(i)) {
+					keepTransitionTimes(current.Index(j), status.Index(i))
+				}
+			}
+		}
+	}
+}
+
+// sameStatusEntry tells whether two listener or route parent statuses are
+// about the same listener or parent
+func sameStatusEntry(a, b reflect.Value) bool {
+	switch x := a.Interface().(type) {
+	case ListenerStatus:
+		return x.Name == b.Interface().(ListenerStatus).Name
+	case RouteParentStatus:
+		y := b.Interface().(RouteParentStatus)
+		return reflect.DeepEqual(x.ParentRef, y.ParentRef) && x.ControllerName == y.ControllerName
+	}
+	return false
+}
+
+// NewGatewayCondition returns a condition of status "True" when ok,
+// "False" otherwise
+func NewGatewayCondition(condType string, ok bool, reason, message string, generation int64) GatewayCondition {
+	status := "False"
+	if ok {
+		status = "True"
+	}
+	return GatewayCondition{
+		Type:               condType,
+		Status:             status,
+		ObservedGeneration: generation,
+		LastTransitionTime: metav1.NewTime(time.Now().Truncate(time.Second)),
+		Reason:             reason,
+		Message:            message,
+	}
+}
+
+// FormatGatewayVSName returns the name of the virtual server of the
+// listeners of a Gateway on port
+func FormatGatewayVSName(namespace, gateway string, port int32) string {
+	return fmt.Sprintf("gateway_%s_%s_%d", namespace, gateway, port)
+}
+
+// FormatGatewayPoolName returns the name of the pool of a Gateway route
+// backend
+func FormatGatewayPoolName(namespace, svc string, port int32) string {
+	return fmt.Sprintf("gateway_%s_%s_%d", namespace, svc, port)
+}
+
+// GatewayListenerHostnames returns the hostnames of a route matching the
+// hostname of a listener, the listener hostname when the route has none,
+// and false when none matches. No hostname matches all hostnames.
+func GatewayListenerHostnames(listener *string, hostnames []string) ([]string, bool) {
+	if listener == nil || *listener == "" {
+		return hostnames, true
+	}
+	if len(hostnames) == 0 {
+		return []string{*listener}, true
+	}
+	var matched []string
+	for _, h := range hostnames {
+		switch {
+		case hostnameMatches(*listener, h):
+			matched = append(matched, h)
+		case hostnameMatches(h, *listener):
+			matched = append(matched, *listener)
+		}
+	}
+	return matched, len(matched) != 0
+}
+
+// hostnameMatches tells whether hostname is pattern or matches the wildcard
+// pattern
+func hostnameMatches(pattern, hostname string) bool {
+	if pattern == hostname {
+		return true
+	}
+	// Wildcard hostnames match the more specific hostnames and wildcards
+	return strings.HasPrefix(pattern, "*.") && strings.HasSuffix(hostname, pattern[1:])
+}
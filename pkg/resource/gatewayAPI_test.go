@@ -0,0 +1,145 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newUnstructuredHTTPRoute() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "HTTPRoute",
+		"metadata": map[string]interface{}{
+			"name":      "foo",
+			"namespace": "default",
+		},
+		"spec": map[string]interface{}{
+			"parentRefs": []interface{}{
+				map[string]interface{}{"name": "gw", "sectionName": "http"},
+			},
+			"hostnames": []interface{}{"foo.example.com"},
+			"rules": []interface{}{
+				map[string]interface{}{
+					"matches": []interface{}{
+						map[string]interface{}{
+							"path": map[string]interface{}{"type": "PathPrefix", "value": "/foo"},
+						},
+					},
+					"backendRefs": []interface{}{
+						map[string]interface{}{"name": "foo", "port": int64(80), "weight": int64(3)},
+					},
+				},
+			},
+		},
+	}}
+}
+
+var _ = Describe("Gateway API Tests", func() {
+	It("converts the objects of the informers", func() {
+		obj := newUnstructuredHTTPRoute()
+		var route HTTPRoute
+		Expect(FromGatewayAPIObject(obj, &route)).To(BeNil())
+		Expect(route.Name).To(Equal("foo"))
+		Expect(route.Spec.Hostnames).To(Equal([]string{"foo.example.com"}))
+		Expect(*route.Spec.ParentRefs[0].SectionName).To(Equal("http"))
+		rule := route.Spec.Rules[0]
+		Expect(*rule.Matches[0].Path.Type).To(Equal(PathMatchPathPrefix))
+		Expect(*rule.Matches[0].Path.Value).To(Equal("/foo"))
+		Expect(*rule.BackendRefs[0].Port).To(Equal(int32(80)))
+		Expect(*rule.BackendRefs[0].Weight).To(Equal(int32(3)))
+
+		var tombstone HTTPRoute
+		Expect(FromGatewayAPIObject(
+			cache.DeletedFinalStateUnknown{Key: "default/foo", Obj: obj}, &tombstone)).To(BeNil())
+		Expect(tombstone).To(Equal(route))
+		Expect(FromGatewayAPIObject("foo", &tombstone)).NotTo(BeNil())
+	})
+
+	It("sets the status when it changes", func() {
+		obj := newUnstructuredHTTPRoute()
+		status := &RouteStatus{Parents: []RouteParentStatus{{
+			ParentRef:      ParentReference{Name: "gw"},
+			ControllerName: "f5.com/k8s-bigip-ctlr",
+			Conditions: []GatewayCondition{
+				NewGatewayCondition(ConditionAccepted, true, ReasonAccepted, "", 1),
+			},
+		}}}
+		updated, changed, err := SetGatewayAPIStatus(obj, status)
+		Expect(err).To(BeNil())
+		Expect(changed).To(BeTrue())
+		Expect(obj.Object["status"]).To(BeNil(), "The object of the informer is left as is")
+		var route HTTPRoute
+		Expect(FromGatewayAPIObject(updated, &route)).To(BeNil())
+		Expect(route.Status.Parents[0].Conditions[0].Status).To(Equal("True"))
+
+		// Conditions created later keep the transition time of the status
+		later := &RouteStatus{Parents: []RouteParentStatus{{
+			ParentRef:      ParentReference{Name: "gw"},
+			ControllerName: "f5.com/k8s-bigip-ctlr",
+			Conditions: []GatewayCondition{
+				NewGatewayCondition(ConditionAccepted, true, ReasonAccepted, "", 1),
+			},
+		}}}
+		later.Parents[0].Conditions[0].LastTransitionTime = metav1.NewTime(
+			time.Now().Add(time.Hour).Truncate(time.Second))
+		_, changed, err = SetGatewayAPIStatus(updated, later)
+		Expect(err).To(BeNil())
+		Expect(changed).To(BeFalse())
+
+		later.Parents[0].Conditions[0] = NewGatewayCondition(
+			ConditionAccepted, false, ReasonNoMatchingParent, "", 1)
+		_, changed, err = SetGatewayAPIStatus(updated, later)
+		Expect(err).To(BeNil())
+		Expect(changed).To(BeTrue())
+	})
+
+	It("matches the hostnames of listeners and routes", func() {
+		listener := "*.example.com"
+		hostnames, ok := GatewayListenerHostnames(&listener,
+			[]string{"foo.example.com", "foo.example.org", "*.foo.example.com"})
+		Expect(ok).To(BeTrue())
+		Expect(hostnames).To(Equal([]string{"foo.example.com", "*.foo.example.com"}))
+
+		hostnames, ok = GatewayListenerHostnames(&listener, nil)
+		Expect(ok).To(BeTrue())
+		Expect(hostnames).To(Equal([]string{"*.example.com"}))
+
+		exact := "foo.example.com"
+		hostnames, ok = GatewayListenerHostnames(&exact, []string{"*.example.com"})
+		Expect(ok).To(BeTrue())
+		Expect(hostnames).To(Equal([]string{"foo.example.com"}))
+
+		_, ok = GatewayListenerHostnames(&exact, []string{"bar.example.com"})
+		Expect(ok).To(BeFalse())
+
+		hostnames, ok = GatewayListenerHostnames(nil, []string{"bar.example.com"})
+		Expect(ok).To(BeTrue())
+		Expect(hostnames).To(Equal([]string{"bar.example.com"}))
+	})
+
+	It("formats the names of virtual servers and pools", func() {
+		Expect(FormatGatewayVSName("default", "gw", 443)).To(Equal("gateway_default_gw_443"))
+		Expect(FormatGatewayPoolName("default", "foo", 8080)).To(Equal("gateway_default_foo_8080"))
+	})
+})
@@ -0,0 +1,302 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// Path types of the paths of a networking.k8s.io/v1 Ingress
+	PathTypeExact                  = "Exact"
+	PathTypePrefix                 = "Prefix"
+	PathTypeImplementationSpecific = "ImplementationSpecific"
+
+	// IngressClassDefaultAnnotation marks the default IngressClass, the class
+	// of the Ingresses without one
+	IngressClassDefaultAnnotation = "ingressclass.kubernetes.io/is-default-class"
+
+	// Keys of the ConfigMap referenced by the parameters of an IngressClass
+	IngressClassPartitionKey        = "partition"
+	IngressClassDefaultIngressIPKey = "default-ingress-ip"
+)
+
+// The networking.k8s.io/v1 Ingress and IngressClass resources, served by
+// Kubernetes 1.19 and later
+var (
+	IngressV1Resource = schema.GroupVersionResource{
+		Group:    "networking.k8s.io",
+		Version:  "v1",
+		Resource: "ingresses",
+	}
+	IngressClassResource = schema.GroupVersionResource{
+		Group:    "networking.k8s.io",
+		Version:  "v1",
+		Resource: "ingressclasses",
+	}
+)
+
+// The Kubernetes client libraries predate networking.k8s.io/v1, so its
+// Ingresses and IngressClasses are watched with the dynamic client and
+// converted to the following types, which only hold the fields CIS uses.
+type (
+	IngressV1 struct {
+		metav1.ObjectMeta `json:"metadata,omitempty"`
+		Spec              IngressV1Spec         `json:"spec,omitempty"`
+		Status            v1beta1.IngressStatus `json:"status,omitempty"`
+	}
+
+	IngressV1Spec struct {
+		IngressClassName *string              `json:"ingressClassName,omitempty"`
+		DefaultBackend   *IngressV1Backend    `json:"defaultBackend,omitempty"`
+		TLS              []v1beta1.IngressTLS `json:"tls,omitempty"`
+		Rules            []IngressV1Rule      `json:"rules,omitempty"`
+	}
+
+	IngressV1Rule struct {
+		Host string                  `json:"host,omitempty"`
+		HTTP *IngressV1HTTPRuleValue `json:"http,omitempty"`
+	}
+
+	IngressV1HTTPRuleValue struct {
+		Paths []IngressV1Path `json:"paths"`
+	}
+
+	IngressV1Path struct {
+		Path     string           `json:"path,omitempty"`
+		PathType *string          `json:"pathType,omitempty"`
+		Backend  IngressV1Backend `json:"backend"`
+	}
+
+	// IngressV1Backend is nil for resource backends, which CIS ignores
+	IngressV1Backend struct {
+		Service *IngressV1ServiceBackend `json:"service,omitempty"`
+	}
+
+	IngressV1ServiceBackend struct {
+		Name string               `json:"name"`
+		Port IngressV1ServicePort `json:"port,omitempty"`
+	}
+
+	IngressV1ServicePort struct {
+		Name   string `json:"name,omitempty"`
+		Number int32  `json:"number,omitempty"`
+	}
+
+	IngressClass struct {
+		metav1.ObjectMeta `json:"metadata,omitempty"`
+		Spec              IngressClassSpec `json:"spec,omitempty"`
+	}
+
+	IngressClassSpec struct {
+		Controller string                           `json:"controller,omitempty"`
+		Parameters *IngressClassParametersReference `json:"parameters,omitempty"`
+	}
+
+	// IngressClassParametersReference refers to the ConfigMap holding the
+	// settings of the Ingresses of an IngressClass
+	IngressClassParametersReference struct {
+		APIGroup  *string `json:"apiGroup,omitempty"`
+		Kind      string  `json:"kind"`
+		Name      string  `json:"name"`
+		Scope     *string `json:"scope,omitempty"`
+		Namespace *string `json:"namespace,omitempty"`
+	}
+)
+
+// Ingress is an extensions/v1beta1 Ingress, or a networking.k8s.io/v1
+// Ingress converted to one
+type Ingress struct {
+	*v1beta1.Ingress
+	// spec.ingressClassName of a v1 Ingress
+	ClassName *string
+	// Path types of the paths of a v1 Ingress by host, path and backend, as
+	// a path may be given with several types. Unset paths and the paths of
+	// v1beta1 Ingresses are ImplementationSpecific
+	PathTypes map[string]string
+	// The object of the informer, which events and updates are for
+	Obj runtime.Object
+}
+
+// NewIngressV1Informer creates an informer of the networking.k8s.io/v1
+// Ingresses in namespace. Its store holds unstructured objects, see
+// ToIngress.
+func NewIngressV1Informer(
+	client dynamic.Interface,
+	namespace string,
+	resyncPeriod time.Duration,
+) cache.SharedIndexInformer {
+	return newDynamicInformer(client, IngressV1Resource, namespace, resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// NewIngressClassInformer creates an informer of the IngressClasses. Its
+// store holds unstructured objects, see ToIngressClass.
+func NewIngressClassInformer(
+	client dynamic.Interface,
+	resyncPeriod time.Duration,
+) cache.SharedIndexInformer {
+	return newDynamicInformer(client, IngressClassResource, "", resyncPeriod, cache.Indexers{})
+}
+
+// newDynamicInformer creates an informer of resource in namespace, holding
+// unstructured objects
+func newDynamicInformer(
+	client dynamic.Interface,
+	resource schema.GroupVersionResource,
+	namespace string,
+	resyncPeriod time.Duration,
+	indexers cache.Indexers,
+) cache.SharedIndexInformer {
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return client.Resource(resource).Namespace(namespace).List(options)
+			},
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return client.Resource(resource).Namespace(namespace).Watch(options)
+			},
+		},
+		&unstructured.Unstructured{},
+		resyncPeriod,
+		indexers,
+	)
+}
+
+// ToIngress converts an object of the Ingress informer, either a v1beta1 or
+// an unstructured v1 Ingress. A v1 Ingress without rules must have a
+// service default backend.
+func ToIngress(obj interface{}) (*Ingress, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	switch o := obj.(type) {
+	case *v1beta1.Ingress:
+		return &Ingress{Ingress: o, Obj: o}, nil
+	case *unstructured.Unstructured:
+		var ingV1 IngressV1
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, &ingV1); err != nil {
+			return nil, err
+		}
+		ing := &Ingress{
+			Ingress: &v1beta1.Ingress{
+				ObjectMeta: ingV1.ObjectMeta,
+				Spec:       v1beta1.IngressSpec{TLS: ingV1.Spec.TLS},
+				Status:     ingV1.Status,
+			},
+			ClassName: ingV1.Spec.IngressClassName,
+			PathTypes: make(map[string]string),
+			Obj:       o,
+		}
+		ing.Spec.Backend = ingV1.Spec.DefaultBackend.toV1beta1()
+		for _, rule := range ingV1.Spec.Rules {
+			rl := v1beta1.IngressRule{Host: rule.Host}
+			if nil != rule.HTTP {
+				rl.HTTP = &v1beta1.HTTPIngressRuleValue{}
+				for _, path := range rule.HTTP.Paths {
+					backend := path.Backend.toV1beta1()
+					if nil == backend {
+						continue
+					}
+					p := v1beta1.HTTPIngressPath{
+						Path:    path.Path,
+						Backend: *backend,
+					}
+					rl.HTTP.Paths = append(rl.HTTP.Paths, p)
+					if nil != path.PathType {
+						ing.PathTypes[ingressPathKey(rule.Host, p)] = *path.PathType
+					}
+				}
+			}
+			ing.Spec.Rules = append(ing.Spec.Rules, rl)
+		}
+		if nil == ing.Spec.Rules && nil == ing.Spec.Backend {
+			return nil, fmt.Errorf("Ingress '%v/%v' has neither rules nor a service default backend",
+				ing.ObjectMeta.Namespace, ing.ObjectMeta.Name)
+		}
+		return ing, nil
+	}
+	return nil, fmt.Errorf("unexpected Ingress object %T", obj)
+}
+
+// PathType returns the path type of a path of the rule of host
+func (ing *Ingress) PathType(host string, path v1beta1.HTTPIngressPath) string {
+	if pathType, ok := ing.PathTypes[ingressPathKey(host, path)]; ok {
+		return pathType
+	}
+	return PathTypeImplementationSpecific
+}
+
+func ingressPathKey(host string, path v1beta1.HTTPIngressPath) string {
+	return fmt.Sprintf("%s%s|%s:%s", host, path.Path,
+		path.Backend.ServiceName, path.Backend.ServicePort.String())
+}
+
+func (backend *IngressV1Backend) toV1beta1() *v1beta1.IngressBackend {
+	if nil == backend || nil == backend.Service {
+		return nil
+	}
+	port := intstr.FromInt(int(backend.Service.Port.Number))
+	if backend.Service.Port.Name != "" {
+		port = intstr.FromString(backend.Service.Port.Name)
+	}
+	return &v1beta1.IngressBackend{
+		ServiceName: backend.Service.Name,
+		ServicePort: port,
+	}
+}
+
+// ToIngressClass converts an object of the IngressClass informer
+func ToIngressClass(obj interface{}) (*IngressClass, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected IngressClass object %T", obj)
+	}
+	var class IngressClass
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, &class); err != nil {
+		return nil, err
+	}
+	return &class, nil
+}
+
+// SetIngressV1Status returns a copy of the unstructured v1 Ingress obj with
+// the load balancer status of status
+func SetIngressV1Status(obj *unstructured.Unstructured, status v1beta1.IngressStatus) (*unstructured.Unstructured, error) {
+	data, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&status.LoadBalancer)
+	if err != nil {
+		return nil, err
+	}
+	obj = obj.DeepCopy()
+	if err := unstructured.SetNestedField(obj.Object, data, "status", "loadBalancer"); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
@@ -0,0 +1,190 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/cache"
+)
+
+var _ = Describe("Ingress Tests", func() {
+	serviceBackend := func(name string, port map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"service": map[string]interface{}{"name": name, "port": port},
+		}
+	}
+	newIngressV1 := func(spec map[string]interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "networking.k8s.io/v1",
+			"kind":       "Ingress",
+			"metadata":   map[string]interface{}{"name": "ing", "namespace": "default"},
+			"spec":       spec,
+			"status": map[string]interface{}{
+				"loadBalancer": map[string]interface{}{
+					"ingress": []interface{}{map[string]interface{}{"ip": "10.1.1.1"}},
+				},
+			},
+		}}
+	}
+
+	It("converts v1 Ingresses", func() {
+		obj := newIngressV1(map[string]interface{}{
+			"ingressClassName": "f5",
+			"tls": []interface{}{map[string]interface{}{
+				"hosts": []interface{}{"foo.com"}, "secretName": "foo-tls",
+			}},
+			"rules": []interface{}{map[string]interface{}{
+				"host": "foo.com",
+				"http": map[string]interface{}{"paths": []interface{}{
+					map[string]interface{}{
+						"path":     "/login",
+						"pathType": "Exact",
+						"backend":  serviceBackend("foo", map[string]interface{}{"number": int64(80)}),
+					},
+					map[string]interface{}{
+						"path":     "/api",
+						"pathType": "Prefix",
+						"backend":  serviceBackend("bar", map[string]interface{}{"name": "http"}),
+					},
+					map[string]interface{}{
+						"path":     "/static",
+						"pathType": "Prefix",
+						"backend": map[string]interface{}{"resource": map[string]interface{}{
+							"apiGroup": "k8s.example.com", "kind": "StorageBucket", "name": "static",
+						}},
+					},
+				}},
+			}},
+		})
+		ing, err := ToIngress(cache.DeletedFinalStateUnknown{Obj: obj})
+		Expect(err).To(BeNil())
+		Expect(ing.Obj).To(BeIdenticalTo(obj))
+		Expect(*ing.ClassName).To(Equal("f5"))
+		Expect(ing.ObjectMeta.Name).To(Equal("ing"))
+		Expect(ing.Spec.Backend).To(BeNil())
+		Expect(ing.Spec.TLS).To(Equal([]v1beta1.IngressTLS{
+			{Hosts: []string{"foo.com"}, SecretName: "foo-tls"}}))
+		Expect(ing.Status.LoadBalancer.Ingress).To(Equal([]v1.LoadBalancerIngress{{IP: "10.1.1.1"}}))
+
+		// Resource backends are left out
+		Expect(ing.Spec.Rules).To(HaveLen(1))
+		Expect(ing.Spec.Rules[0].HTTP.Paths).To(Equal([]v1beta1.HTTPIngressPath{
+			{Path: "/login", Backend: v1beta1.IngressBackend{
+				ServiceName: "foo", ServicePort: intstr.FromInt(80)}},
+			{Path: "/api", Backend: v1beta1.IngressBackend{
+				ServiceName: "bar", ServicePort: intstr.FromString("http")}},
+		}))
+		paths := ing.Spec.Rules[0].HTTP.Paths
+		Expect(ing.PathType("foo.com", paths[0])).To(Equal(PathTypeExact))
+		Expect(ing.PathType("foo.com", paths[1])).To(Equal(PathTypePrefix))
+		Expect(ing.PathType("bar.com", paths[0])).To(Equal(PathTypeImplementationSpecific))
+	})
+
+	It("keeps the types of a path given with several types", func() {
+		ing, err := ToIngress(newIngressV1(map[string]interface{}{
+			"rules": []interface{}{map[string]interface{}{
+				"host": "foo.com",
+				"http": map[string]interface{}{"paths": []interface{}{
+					map[string]interface{}{
+						"path":     "/foo",
+						"pathType": "Exact",
+						"backend":  serviceBackend("foo", map[string]interface{}{"number": int64(80)}),
+					},
+					map[string]interface{}{
+						"path":     "/foo",
+						"pathType": "Prefix",
+						"backend":  serviceBackend("bar", map[string]interface{}{"number": int64(80)}),
+					},
+				}},
+			}},
+		}))
+		Expect(err).To(BeNil())
+		paths := ing.Spec.Rules[0].HTTP.Paths
+		Expect(paths).To(HaveLen(2))
+		Expect(ing.PathType("foo.com", paths[0])).To(Equal(PathTypeExact))
+		Expect(ing.PathType("foo.com", paths[1])).To(Equal(PathTypePrefix))
+	})
+
+	It("converts v1 Ingresses with a default backend only", func() {
+		ing, err := ToIngress(newIngressV1(map[string]interface{}{
+			"defaultBackend": serviceBackend("foo", map[string]interface{}{"number": int64(80)}),
+		}))
+		Expect(err).To(BeNil())
+		Expect(ing.ClassName).To(BeNil())
+		Expect(ing.Spec.Rules).To(BeNil())
+		Expect(ing.Spec.Backend).To(Equal(&v1beta1.IngressBackend{
+			ServiceName: "foo", ServicePort: intstr.FromInt(80)}))
+
+		_, err = ToIngress(newIngressV1(map[string]interface{}{
+			"defaultBackend": map[string]interface{}{"resource": map[string]interface{}{
+				"kind": "StorageBucket", "name": "static",
+			}},
+		}))
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("wraps v1beta1 Ingresses", func() {
+		legacy := &v1beta1.Ingress{}
+		ing, err := ToIngress(legacy)
+		Expect(err).To(BeNil())
+		Expect(ing.Ingress).To(BeIdenticalTo(legacy))
+		Expect(ing.Obj).To(BeIdenticalTo(legacy))
+		Expect(ing.PathType("foo.com", v1beta1.HTTPIngressPath{Path: "/"})).To(
+			Equal(PathTypeImplementationSpecific))
+
+		_, err = ToIngress(&v1.Service{})
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("converts IngressClasses", func() {
+		class, err := ToIngressClass(&unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "networking.k8s.io/v1",
+			"kind":       "IngressClass",
+			"metadata":   map[string]interface{}{"name": "f5"},
+			"spec": map[string]interface{}{
+				"controller": "f5.com/cntr-ingress-svcs",
+				"parameters": map[string]interface{}{
+					"kind": "ConfigMap", "name": "f5-ingress", "scope": "Namespace", "namespace": "kube-system",
+				},
+			},
+		}})
+		Expect(err).To(BeNil())
+		Expect(class.Name).To(Equal("f5"))
+		Expect(class.Spec.Controller).To(Equal("f5.com/cntr-ingress-svcs"))
+		Expect(class.Spec.Parameters.Kind).To(Equal("ConfigMap"))
+		Expect(*class.Spec.Parameters.Namespace).To(Equal("kube-system"))
+	})
+
+	It("sets the status of v1 Ingresses", func() {
+		obj := newIngressV1(map[string]interface{}{})
+		status := v1beta1.IngressStatus{LoadBalancer: v1.LoadBalancerStatus{
+			Ingress: []v1.LoadBalancerIngress{{IP: "10.2.2.2"}},
+		}}
+		updated, err := SetIngressV1Status(obj, status)
+		Expect(err).To(BeNil())
+		ips, _, _ := unstructured.NestedSlice(updated.Object, "status", "loadBalancer", "ingress")
+		Expect(ips).To(Equal([]interface{}{map[string]interface{}{"ip": "10.2.2.2"}}))
+		ips, _, _ = unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+		Expect(ips).To(Equal([]interface{}{map[string]interface{}{"ip": "10.1.1.1"}}),
+			"The object of the informer is left unchanged")
+	})
+})
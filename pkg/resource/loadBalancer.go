@@ -0,0 +1,149 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ServiceResource is the core v1 Service resource. The Kubernetes client
+// libraries predate spec.loadBalancerClass, so the class of the Services is
+// read from an informer of the dynamic client.
+var ServiceResource = schema.GroupVersionResource{
+	Version:  "v1",
+	Resource: "services",
+}
+
+// NewLoadBalancerClassInformer creates an informer of the Services in
+// namespace holding unstructured objects, see LoadBalancerClass.
+func NewLoadBalancerClassInformer(
+	client dynamic.Interface,
+	namespace string,
+	resyncPeriod time.Duration,
+) cache.SharedIndexInformer {
+	return newDynamicInformer(client, ServiceResource, namespace, resyncPeriod,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+}
+
+// LoadBalancerClass returns the spec.loadBalancerClass of a Service of the
+// informer, empty when it has none
+func LoadBalancerClass(obj interface{}) string {
+	if svc, ok := obj.(*unstructured.Unstructured); ok {
+		class, _, _ := unstructured.NestedString(svc.Object, "spec", "loadBalancerClass")
+		return class
+	}
+	return ""
+}
+
+// AddressRange is a range of addresses of one IP family, First and Last
+// included
+type AddressRange struct {
+	First net.IP
+	Last  net.IP
+}
+
+// ParseAddressRange parses a range of addresses given as <first>-<last>
+func ParseAddressRange(str string) (*AddressRange, error) {
+	bounds := strings.Split(str, "-")
+	if len(bounds) != 2 {
+		return nil, fmt.Errorf("invalid address range '%s', expected <first>-<last>", str)
+	}
+	first := net.ParseIP(strings.TrimSpace(bounds[0]))
+	last := net.ParseIP(strings.TrimSpace(bounds[1]))
+	if nil == first || nil == last {
+		return nil, fmt.Errorf("invalid address range '%s', expected <first>-<last>", str)
+	}
+	if (nil == first.To4()) != (nil == last.To4()) {
+		return nil, fmt.Errorf("invalid address range '%s', the addresses are "+
+			"not of the same IP family", str)
+	}
+	if nil != first.To4() {
+		first, last = first.To4(), last.To4()
+	}
+	if bytes.Compare(first, last) > 0 {
+		return nil, fmt.Errorf("invalid address range '%s', the first address "+
+			"is after the last one", str)
+	}
+	return &AddressRange{First: first, Last: last}, nil
+}
+
+func (r *AddressRange) String() string {
+	return r.First.String() + "-" + r.Last.String()
+}
+
+// Contains tells whether address is in the range
+func (r *AddressRange) Contains(address string) bool {
+	ip := r.sameFamily(net.ParseIP(address))
+	return nil != ip && bytes.Compare(ip, r.First) >= 0 && bytes.Compare(ip, r.Last) <= 0
+}
+
+// Allocate returns the first address of the range that is not used, empty
+// when all are. The keys of used are addresses as formatted by net.IP.
+func (r *AddressRange) Allocate(used map[string]bool) string {
+	ip := append(net.IP(nil), r.First...)
+	for bytes.Compare(ip, r.Last) <= 0 {
+		if !used[ip.String()] {
+			return ip.String()
+		}
+		// Stop if the last address of the family was reached
+		carry := true
+		for i := len(ip) - 1; i >= 0 && carry; i-- {
+			ip[i]++
+			carry = ip[i] == 0
+		}
+		if carry {
+			break
+		}
+	}
+	return ""
+}
+
+// sameFamily returns ip with the length of the addresses of the range, nil
+// when it is of the other family
+func (r *AddressRange) sameFamily(ip net.IP) net.IP {
+	if nil == ip {
+		return nil
+	}
+	if ip4 := ip.To4(); len(r.First) == net.IPv4len {
+		return ip4
+	} else if nil != ip4 {
+		return nil
+	}
+	return ip.To16()
+}
+
+// FormatLoadBalancerVSName returns the name of the virtual server of a port
+// of a Service of type LoadBalancer. A port number may be used by both TCP
+// and UDP, so the name holds the protocol.
+func FormatLoadBalancerVSName(namespace, name, protocol string, port int32) string {
+	return fmt.Sprintf("loadbalancer_%s_%s_%s_%d", namespace, name, protocol, port)
+}
+
+// FormatLoadBalancerPoolName returns the name of the pool of a port of a
+// Service of type LoadBalancer
+func FormatLoadBalancerPoolName(namespace, name, protocol string, port int32) string {
+	return fmt.Sprintf("loadbalancer_%s_%s_%s_%d_pool", namespace, name, protocol, port)
+}
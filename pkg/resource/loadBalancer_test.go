@@ -0,0 +1,89 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+var _ = Describe("Load Balancer Tests", func() {
+	It("parses address ranges", func() {
+		r, err := ParseAddressRange("10.1.1.10-10.1.1.12")
+		Expect(err).To(BeNil())
+		Expect(r.String()).To(Equal("10.1.1.10-10.1.1.12"))
+		Expect(r.Contains("10.1.1.10")).To(BeTrue())
+		Expect(r.Contains("10.1.1.12")).To(BeTrue())
+		Expect(r.Contains("10.1.1.13")).To(BeFalse())
+		Expect(r.Contains("::ffff:10.1.1.11")).To(BeTrue())
+		Expect(r.Contains("2001:db8::1")).To(BeFalse())
+		Expect(r.Contains("foo")).To(BeFalse())
+
+		r, err = ParseAddressRange("2001:db8::10 - 2001:db8::1f")
+		Expect(err).To(BeNil())
+		Expect(r.Contains("2001:db8::1a")).To(BeTrue())
+		Expect(r.Contains("10.1.1.10")).To(BeFalse())
+
+		for _, str := range []string{
+			"", "10.1.1.10", "10.1.1.10-foo", "10.1.1.10-2001:db8::1",
+			"10.1.1.12-10.1.1.10", "10.1.1.1-10.1.1.2-10.1.1.3",
+		} {
+			_, err = ParseAddressRange(str)
+			Expect(err).NotTo(BeNil(), str)
+		}
+	})
+
+	It("allocates the first free address of a range", func() {
+		r, _ := ParseAddressRange("10.1.1.254-10.1.2.1")
+		used := make(map[string]bool)
+		for _, expected := range []string{"10.1.1.254", "10.1.1.255", "10.1.2.0", "10.1.2.1", ""} {
+			addr := r.Allocate(used)
+			Expect(addr).To(Equal(expected))
+			used[addr] = true
+		}
+		delete(used, "10.1.1.255")
+		Expect(r.Allocate(used)).To(Equal("10.1.1.255"))
+
+		r, _ = ParseAddressRange("255.255.255.255-255.255.255.255")
+		Expect(r.Allocate(map[string]bool{"255.255.255.255": true})).To(BeEmpty())
+
+		r, _ = ParseAddressRange("2001:db8::ffff-2001:db8::1:0")
+		Expect(r.Allocate(map[string]bool{"2001:db8::ffff": true})).To(Equal("2001:db8::1:0"))
+	})
+
+	It("reads the class of Services", func() {
+		svc := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"type":              string(v1.ServiceTypeLoadBalancer),
+				"loadBalancerClass": "f5.com/bigip",
+			},
+		}}
+		Expect(LoadBalancerClass(svc)).To(Equal("f5.com/bigip"))
+		unstructured.RemoveNestedField(svc.Object, "spec", "loadBalancerClass")
+		Expect(LoadBalancerClass(svc)).To(BeEmpty())
+		Expect(LoadBalancerClass(nil)).To(BeEmpty())
+	})
+
+	It("formats the names of virtual servers and pools", func() {
+		Expect(FormatLoadBalancerVSName("default", "foo", "udp", 53)).To(
+			Equal("loadbalancer_default_foo_udp_53"))
+		Expect(FormatLoadBalancerPoolName("default", "foo", "udp", 53)).To(
+			Equal("loadbalancer_default_foo_udp_53_pool"))
+	})
+})
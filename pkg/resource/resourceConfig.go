@@ -176,21 +176,7 @@ func (v *Virtual) SetVirtualAddress(bindAddr string, port int32) {
 			BindAddr: bindAddr,
 			Port:     port,
 		}
-		// Validate the IP address, and create the destination
-		ip, rd := Split_ip_with_route_domain(bindAddr)
-		if len(rd) > 0 {
-			rd = "%" + rd
-		}
-		addr := net.ParseIP(ip)
-		if nil != addr {
-			var format string
-			if nil != addr.To4() {
-				format = "/%s/%s%s:%d"
-			} else {
-				format = "/%s/%s%s.%d"
-			}
-			v.Destination = fmt.Sprintf(format, v.Partition, ip, rd, port)
-		}
+		v.Destination = FormatVirtualDestination(v.Partition, bindAddr, port)
 	}
 }
 
@@ -221,8 +207,8 @@ func FormatMonitorName(poolName, monitorType string) string {
 func FormatIngressVSName(ip string, port int32) string {
 	// Strip any bracket characters; replace special characters ". : /"
 	// with "-" and "%" with ".", for naming purposes
-	ip = strings.Trim(ip, "[]")
-	var replacer = strings.NewReplacer(".", "-", ":", "-", "/", "-", "%", ".")
+	var replacer = strings.NewReplacer("[", "", "]", "",
+		".", "-", ":", "-", "/", "-", "%", ".")
 	ip = replacer.Replace(ip)
 	return fmt.Sprintf("ingress_%s_%d", ip, port)
 }
@@ -2489,11 +2475,26 @@ func (r Rules) Less(i, j int) bool {
 		if len(r[j].Actions) > 0 && r[j].Actions[0].Reset {
 			return false
 		}
+		// Rules are evaluated in reverse order, exact paths before the
+		// prefixes of the same uri
+		if iExact, jExact := r[i].HasExactPath(), r[j].HasExactPath(); iExact != jExact {
+			return jExact
+		}
 		return true
 	}
 
 	return r[i].FullURI < r[j].FullURI
 }
+
+// HasExactPath reports whether a rule matches the whole request path
+func (rl *Rule) HasExactPath() bool {
+	for _, cond := range rl.Conditions {
+		if cond.Path && cond.Equals {
+			return true
+		}
+	}
+	return false
+}
 func (r Rules) Swap(i, j int) {
 	r[i], r[j] = r[j], r[i]
 	r[i].Ordinal = i
@@ -17,13 +17,18 @@
 package resource
 
 import (
+	"fmt"
+	"net"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 func Split_ip_with_route_domain(address string) (ip string, rd string) {
 	// Split the address into the ip and routeDomain (optional) parts
 	//     address is of the form: <ipv4_or_ipv6>[%<routeDomainID>]
-	idRdRegex := regexp.MustCompile(`^([^%]*)%(\d+)$`)
+	// IPv6 addresses may be bracketed, as in [<ipv6>]%<routeDomainID>
+	idRdRegex := regexp.MustCompile(`^([^%]*)%(\d+)\]?$`)
 
 	match := idRdRegex.FindStringSubmatch(address)
 	if match != nil {
@@ -33,5 +38,79 @@ func Split_ip_with_route_domain(address string) (ip string, rd string) {
 		ip = address
 		rd = ""
 	}
+	ip = strings.Trim(ip, "[]")
 	return
 }
+
+// IsIPv6 tells whether address, with an optional route domain, is an IPv6
+// address
+func IsIPv6(address string) bool {
+	ip, _ := Split_ip_with_route_domain(address)
+	addr := net.ParseIP(ip)
+	return nil != addr && nil == addr.To4()
+}
+
+// AnySourceAddress returns the source address matching any client of the
+// family of address
+func AnySourceAddress(address string) string {
+	if IsIPv6(address) {
+		return "::/0"
+	}
+	return "0.0.0.0/0"
+}
+
+// FormatVirtualDestination returns the destination of a virtual server in
+// partition, empty when bindAddr is not an IP address. BIG-IP separates the
+// port of IPv6 destinations with a dot.
+func FormatVirtualDestination(partition, bindAddr string, port int32) string {
+	ip, rd := Split_ip_with_route_domain(bindAddr)
+	if len(rd) > 0 {
+		rd = "%" + rd
+	}
+	addr := net.ParseIP(ip)
+	if nil == addr {
+		return ""
+	}
+	format := "/%s/%s%s:%d"
+	if nil == addr.To4() {
+		format = "/%s/%s%s.%d"
+	}
+	return fmt.Sprintf(format, partition, ip, rd, port)
+}
+
+// ParseVirtualDestination returns the address, with its route domain, and
+// the port of a virtual server destination such as /Common/10.1.1.1%2:80 or
+// /Common/2001:db8::1%2.80. Bracketed IPv6 destinations such as
+// [2001:db8::1]:80 are accepted too.
+func ParseVirtualDestination(destination string) (string, int, error) {
+	dest := destination[strings.LastIndex(destination, "/")+1:]
+	var address, port string
+	switch {
+	case strings.HasPrefix(dest, "["):
+		end := strings.Index(dest, "]")
+		sep := strings.LastIndex(dest, ":")
+		if end < 0 || sep < end {
+			return "", 0, fmt.Errorf("invalid destination '%s'", destination)
+		}
+		// The route domain may follow the bracket
+		address, port = dest[1:end]+dest[end+1:sep], dest[sep+1:]
+	case 1 == strings.Count(dest, ":"):
+		sep := strings.Index(dest, ":")
+		address, port = dest[:sep], dest[sep+1:]
+	default:
+		sep := strings.LastIndex(dest, ".")
+		if sep < 0 {
+			return "", 0, fmt.Errorf("invalid destination '%s'", destination)
+		}
+		address, port = dest[:sep], dest[sep+1:]
+	}
+	portNum, err := strconv.Atoi(port)
+	if nil != err {
+		return "", 0, fmt.Errorf("invalid port in destination '%s'", destination)
+	}
+	ip, _ := Split_ip_with_route_domain(address)
+	if nil == net.ParseIP(ip) {
+		return "", 0, fmt.Errorf("invalid address in destination '%s'", destination)
+	}
+	return address, portNum, nil
+}
@@ -49,6 +49,14 @@ var _ = Describe("Route Domain", func() {
 				address:    "fe80::%0",
 				expectedIP: "fe80::",
 				expectedRD: "0",
+			}, {
+				address:    "[2001:db8::1]",
+				expectedIP: "2001:db8::1",
+				expectedRD: "",
+			}, {
+				address:    "[2001:db8::1]%2",
+				expectedIP: "2001:db8::1",
+				expectedRD: "2",
 			},
 		}
 
@@ -58,4 +66,58 @@ var _ = Describe("Route Domain", func() {
 			Expect(rd).To(Equal(td.expectedRD))
 		}
 	})
+
+	It("detects IPv6 addresses", func() {
+		Expect(IsIPv6("1.2.3.4")).To(BeFalse())
+		Expect(IsIPv6("1.2.3.4%2")).To(BeFalse())
+		Expect(IsIPv6("::ffff:1.2.3.4")).To(BeFalse())
+		Expect(IsIPv6("2001:db8::1%2")).To(BeTrue())
+		Expect(IsIPv6("[2001:db8::1]")).To(BeTrue())
+		Expect(IsIPv6("foo")).To(BeFalse())
+
+		Expect(AnySourceAddress("1.2.3.4%2")).To(Equal("0.0.0.0/0"))
+		Expect(AnySourceAddress("2001:db8::1%2")).To(Equal("::/0"))
+	})
+
+	It("formats virtual server destinations", func() {
+		Expect(FormatVirtualDestination("test", "1.2.3.4", 80)).To(Equal("/test/1.2.3.4:80"))
+		Expect(FormatVirtualDestination("test", "1.2.3.4%2", 80)).To(Equal("/test/1.2.3.4%2:80"))
+		Expect(FormatVirtualDestination("test", "2001:db8::1", 80)).To(Equal("/test/2001:db8::1.80"))
+		Expect(FormatVirtualDestination("test", "[2001:db8::1]%2", 443)).To(
+			Equal("/test/2001:db8::1%2.443"))
+		Expect(FormatVirtualDestination("test", "foo", 80)).To(BeEmpty())
+	})
+
+	It("parses virtual server destinations", func() {
+		type testDataType struct {
+			destination string
+			address     string
+			port        int
+		}
+		testData := []testDataType{
+			{"/test/1.2.3.4:80", "1.2.3.4", 80},
+			{"/test/1.2.3.4%2:443", "1.2.3.4%2", 443},
+			{"1.2.3.4:80", "1.2.3.4", 80},
+			{"/test/2001:db8::1.80", "2001:db8::1", 80},
+			{"/test/2001:db8::1%2.8080", "2001:db8::1%2", 8080},
+			{"/test/::.80", "::", 80},
+			{"[2001:db8::1]:80", "2001:db8::1", 80},
+			{"/test/[2001:db8::1]%2:443", "2001:db8::1%2", 443},
+			{"[2001:db8::1%2]:443", "2001:db8::1%2", 443},
+		}
+		for _, td := range testData {
+			address, port, err := ParseVirtualDestination(td.destination)
+			Expect(err).To(BeNil(), td.destination)
+			Expect(address).To(Equal(td.address), td.destination)
+			Expect(port).To(Equal(td.port), td.destination)
+		}
+
+		for _, destination := range []string{
+			"", "/test/1.2.3.4", "/test/1.2.3.4:http", "/test/foo:80",
+			"/test/2001:db8::1", "[2001:db8::1]", "[2001:db8::1:80",
+		} {
+			_, _, err := ParseVirtualDestination(destination)
+			Expect(err).NotTo(BeNil(), destination)
+		}
+	})
 })
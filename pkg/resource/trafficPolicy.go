@@ -0,0 +1,103 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// HasLocalTrafficPolicy tells whether the node ports of svc only forward
+// traffic to the endpoints on their node
+func HasLocalTrafficPolicy(svc *v1.Service) bool {
+	return svc.Spec.ExternalTrafficPolicy == v1.ServiceExternalTrafficPolicyTypeLocal
+}
+
+// EndpointsReadyNodes returns the names of the nodes hosting a ready
+// endpoint of eps
+func EndpointsReadyNodes(eps *v1.Endpoints) map[string]bool {
+	nodes := make(map[string]bool)
+	if nil == eps {
+		return nodes
+	}
+	for _, subset := range eps.Subsets {
+		for _, addr := range subset.Addresses {
+			if nil != addr.NodeName {
+				nodes[*addr.NodeName] = true
+			}
+		}
+	}
+	return nodes
+}
+
+// EndpointSliceReadyNodes returns the names of the nodes hosting a ready
+// endpoint, not terminating, of the EndpointSlices of a service
+func EndpointSliceReadyNodes(slices []*EndpointSlice) map[string]bool {
+	nodes := make(map[string]bool)
+	for _, slice := range slices {
+		for _, ep := range slice.Endpoints {
+			isReady := ep.Conditions.Ready == nil || *ep.Conditions.Ready
+			isTerminating := ep.Conditions.Terminating != nil && *ep.Conditions.Terminating
+			if ep.NodeName != nil && isReady && !isTerminating {
+				nodes[*ep.NodeName] = true
+			}
+		}
+	}
+	return nodes
+}
+
+// HealthCheckNodePortMonitor returns the monitor of the members of a pool of
+// a Service with a healthCheckNodePort, which kube-proxy serves on each node.
+// kube-proxy answers 200 on nodes hosting a ready endpoint, 503 on the
+// others.
+func HealthCheckNodePortMonitor(pool Pool, healthCheckNodePort int32) Monitor {
+	return Monitor{
+		Name:       FormatMonitorName(pool.Name, "health_check_node_port"),
+		Partition:  pool.Partition,
+		Type:       "http",
+		Interval:   5,
+		Timeout:    16,
+		Send:       "GET /healthz HTTP/1.0\r\n\r\n",
+		Recv:       "200 OK",
+		TargetPort: healthCheckNodePort,
+	}
+}
+
+// UnsetMonitor removes monitor from pool and from the config, and tells
+// whether it was set
+func (rc *ResourceConfig) UnsetMonitor(pool *Pool, monitor Monitor) bool {
+	var removed bool
+	toFind := JoinBigipPath(monitor.Partition, monitor.Name)
+	for i, name := range pool.MonitorNames {
+		if name == toFind {
+			names := make([]string, 0, len(pool.MonitorNames)-1)
+			names = append(names, pool.MonitorNames[:i]...)
+			pool.MonitorNames = append(names, pool.MonitorNames[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	for i, mon := range rc.Monitors {
+		if mon.Name == monitor.Name && mon.Partition == monitor.Partition {
+			monitors := make(Monitors, 0, len(rc.Monitors)-1)
+			monitors = append(monitors, rc.Monitors[:i]...)
+			rc.Monitors = append(monitors, rc.Monitors[i+1:]...)
+			removed = true
+			break
+		}
+	}
+	return removed
+}
@@ -0,0 +1,67 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Traffic Policy Tests", func() {
+	It("finds the nodes with ready endpoints", func() {
+		node0, node1 := "node0", "node1"
+		eps := &v1.Endpoints{Subsets: []v1.EndpointSubset{{
+			Addresses:         []v1.EndpointAddress{{IP: "10.2.96.0", NodeName: &node0}},
+			NotReadyAddresses: []v1.EndpointAddress{{IP: "10.2.96.1", NodeName: &node1}},
+		}}}
+		Expect(EndpointsReadyNodes(eps)).To(Equal(map[string]bool{"node0": true}))
+		Expect(EndpointsReadyNodes(nil)).To(BeEmpty())
+
+		ready, notReady := true, false
+		slices := []*EndpointSlice{{Endpoints: []EndpointSliceEndpoint{
+			{Addresses: []string{"10.2.96.0"}, NodeName: &node0},
+			{
+				Addresses:  []string{"10.2.96.1"},
+				NodeName:   &node1,
+				Conditions: EndpointSliceConditions{Ready: &notReady},
+			},
+			{
+				Addresses:  []string{"10.2.96.2"},
+				NodeName:   &node1,
+				Conditions: EndpointSliceConditions{Ready: &ready, Terminating: &ready},
+			},
+		}}}
+		Expect(EndpointSliceReadyNodes(slices)).To(Equal(map[string]bool{"node0": true}))
+	})
+
+	It("sets and unsets the healthCheckNodePort monitor", func() {
+		rc := &ResourceConfig{}
+		rc.Pools = Pools{{Name: "foo", Partition: "test"}}
+		monitor := HealthCheckNodePortMonitor(rc.Pools[0], 32000)
+		Expect(monitor.TargetPort).To(Equal(int32(32000)))
+
+		rc.SetMonitor(&rc.Pools[0], monitor)
+		Expect(rc.Monitors).To(HaveLen(1))
+		Expect(rc.Pools[0].MonitorNames).To(Equal([]string{"/test/" + monitor.Name}))
+
+		Expect(rc.UnsetMonitor(&rc.Pools[0], monitor)).To(BeTrue())
+		Expect(rc.Monitors).To(BeEmpty())
+		Expect(rc.Pools[0].MonitorNames).To(BeEmpty())
+		Expect(rc.UnsetMonitor(&rc.Pools[0], monitor)).To(BeFalse())
+	})
+})
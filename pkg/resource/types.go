@@ -16,6 +16,10 @@
 
 package resource
 
+import (
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
+)
+
 type (
 	// Configs for each BIG-IP partition
 	PartitionMap map[string]*BigIPConfig
@@ -101,9 +105,10 @@ type (
 
 	// Pool Member
 	Member struct {
-		Address string `json:"address"`
-		Port    int32  `json:"port"`
-		Session string `json:"session,omitempty"`
+		Address       string `json:"address"`
+		Port          int32  `json:"port"`
+		Session       string `json:"session,omitempty"`
+		PriorityGroup int32  `json:"priorityGroup,omitempty"`
 	}
 
 	// Pool config
@@ -115,6 +120,8 @@ type (
 		Balance      string   `json:"loadBalancingMode"`
 		Members      []Member `json:"members"`
 		MonitorNames []string `json:"monitors,omitempty"`
+		// Zone aware pools, see ZonePriorityGroup
+		MinimumMembersActive int32 `json:"minimumMembersActive,omitempty"`
 	}
 	Pools []Pool
 
@@ -127,6 +134,9 @@ type (
 		Send      string `json:"send,omitempty"`
 		Recv      string `json:"recv,omitempty"`
 		Timeout   int    `json:"timeout,omitempty"`
+		// Port of the members to monitor, the port of the pool member
+		// when 0
+		TargetPort int32 `json:"targetPort,omitempty"`
 	}
 	Monitors []Monitor
 
@@ -161,20 +171,24 @@ type (
 
 	// Action config for a Rule
 	Action struct {
-		Name      string `json:"name"`
-		Pool      string `json:"pool,omitempty"`
-		HTTPHost  bool   `json:"httpHost,omitempty"`
-		HttpReply bool   `json:"httpReply,omitempty"`
-		HTTPURI   bool   `json:"httpUri,omitempty"`
-		Forward   bool   `json:"forward,omitempty"`
-		Location  string `json:"location,omitempty"`
-		Path      string `json:"path,omitempty"`
-		Redirect  bool   `json:"redirect,omitempty"`
-		Replace   bool   `json:"replace,omitempty"`
-		Request   bool   `json:"request,omitempty"`
-		Reset     bool   `json:"reset,omitempty"`
-		Select    bool   `json:"select,omitempty"`
-		Value     string `json:"value,omitempty"`
+		Name       string `json:"name"`
+		Pool       string `json:"pool,omitempty"`
+		HTTPHeader bool   `json:"httpHeader,omitempty"`
+		HTTPHost   bool   `json:"httpHost,omitempty"`
+		HttpReply  bool   `json:"httpReply,omitempty"`
+		HTTPURI    bool   `json:"httpUri,omitempty"`
+		Forward    bool   `json:"forward,omitempty"`
+		Insert     bool   `json:"insert,omitempty"`
+		Location   string `json:"location,omitempty"`
+		Path       string `json:"path,omitempty"`
+		Redirect   bool   `json:"redirect,omitempty"`
+		Remove     bool   `json:"remove,omitempty"`
+		Replace    bool   `json:"replace,omitempty"`
+		Request    bool   `json:"request,omitempty"`
+		Reset      bool   `json:"reset,omitempty"`
+		Select     bool   `json:"select,omitempty"`
+		Tmname     string `json:"tmName,omitempty"`
+		Value      string `json:"value,omitempty"`
 	}
 
 	// Condition config for a Rule
@@ -185,6 +199,7 @@ type (
 		Equals          bool     `json:"equals,omitempty"`
 		EndsWith        bool     `json:"endsWith,omitempty"`
 		External        bool     `json:"external,omitempty"`
+		HTTPHeader      bool     `json:"httpHeader,omitempty"`
 		HTTPHost        bool     `json:"httpHost,omitempty"`
 		Host            bool     `json:"host,omitempty"`
 		HTTPURI         bool     `json:"httpUri,omitempty"`
@@ -193,10 +208,12 @@ type (
 		Path            bool     `json:"path,omitempty"`
 		PathSegment     bool     `json:"pathSegment,omitempty"`
 		Present         bool     `json:"present,omitempty"`
+		QueryParameter  bool     `json:"queryParameter,omitempty"`
 		Remote          bool     `json:"remote,omitempty"`
 		Request         bool     `json:"request,omitempty"`
 		Scheme          bool     `json:"scheme,omitempty"`
 		Tcp             bool     `json:"tcp,omitempty"`
+		Tmname          string   `json:"tmName,omitempty"`
 		Values          []string `json:"values"`
 	}
 
@@ -417,12 +434,22 @@ type (
 		AdmitStatus bool
 		FdbRecords  bool
 		Members     map[Member]struct{}
+		// Tenants whose configuration on BIG-IP drifted from the declaration
+		DriftedTenants []string
+		// AS3 paths of the drifted objects of the drifted tenants
+		DriftedPaths []string
+		// AS3 error of each tenant that BIG-IP rejected
+		TenantErrors map[string]string
 	}
 
 	MessageRequest struct {
 		ReqID   uint
 		MsgType string
 		ResourceRequest
+		// Span of the request, parent of the spans deploying it
+		Trace tracing.SpanContext
+		// Resources whose changes triggered the request
+		Triggers []string
 	}
 
 	MessageResponse struct {
@@ -467,6 +494,8 @@ const (
 	ResourceTypeIngress          string = "ingress"
 	ResourceTypeRoute            string = "route"
 	ResourceTypeCfgMap           string = "cfgMap"
+	ResourceTypeGateway          string = "gateway"
+	ResourceTypeLoadBalancer     string = "loadbalancer"
 	DefaultSourceAddrTranslation        = "automap"
 	SnatSourceAddrTranslation           = "snat"
 )
@@ -477,6 +506,7 @@ const SslPassthroughIRuleName = "openshift_passthrough_irule"
 
 const DefaultConfigMapLabel = "f5type in (virtual-server)"
 const VsStatusBindAddrAnnotation = "status.virtual-server.f5.com/ip"
+const AS3ErrorAnnotation = "status.virtual-server.f5.com/as3-error"
 const IngressSslRedirect = "ingress.kubernetes.io/ssl-redirect"
 const IngressAllowHttp = "ingress.kubernetes.io/allow-http"
 const HealthMonitorAnnotation = "virtual-server.f5.com/health"
@@ -494,6 +524,8 @@ const F5ServerSslProfileAnnotation = "virtual-server.f5.com/serverssl"
 const F5ServerSslSecureAnnotation = "virtual-server.f5.com/secure-serverssl"
 const DefaultSslServerCAName = "openshift_route_cluster_default-ca"
 const F5VsWAFPolicy = "virtual-server.f5.com/waf"
+const F5VsZoneAwareAnnotation = "virtual-server.f5.com/zone-aware"
+const F5VsMinActiveMembersAnnotation = "virtual-server.f5.com/min-active-members"
 const OprTypeCreate = "create"
 const OprTypeModify = "modify"
 const OprTypeDelete = "delete"
@@ -0,0 +1,98 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Labels of the zone of nodes, the beta one is used by older clusters
+const (
+	ZoneLabel     = "topology.kubernetes.io/zone"
+	BetaZoneLabel = "failure-domain.beta.kubernetes.io/zone"
+)
+
+// LocalZonePriorityGroup is the priority group of the pool members in the
+// local zone of the BIG-IP, the members of other zones are in group 0
+const LocalZonePriorityGroup int32 = 1
+
+// NodeZone returns the zone of node, empty when it has none
+func NodeZone(node *v1.Node) string {
+	if zone, ok := node.ObjectMeta.Labels[ZoneLabel]; ok {
+		return zone
+	}
+	return node.ObjectMeta.Labels[BetaZoneLabel]
+}
+
+// ParseZoneAwareAnnotations returns the minimumMembersActive of the zone
+// aware pools of a resource with annotations, 0 when its pools are not zone
+// aware. Zone aware pools keep at least one member active by default.
+func ParseZoneAwareAnnotations(annotations map[string]string) (int32, error) {
+	zoneAware, ok := annotations[F5VsZoneAwareAnnotation]
+	if !ok {
+		return 0, nil
+	}
+	enabled, err := strconv.ParseBool(zoneAware)
+	if nil != err {
+		return 0, fmt.Errorf("invalid value '%s' of annotation %s", zoneAware, F5VsZoneAwareAnnotation)
+	}
+	if !enabled {
+		return 0, nil
+	}
+	minActive, ok := annotations[F5VsMinActiveMembersAnnotation]
+	if !ok {
+		return 1, nil
+	}
+	n, err := strconv.ParseInt(minActive, 10, 32)
+	if nil != err || n < 1 {
+		return 0, fmt.Errorf("invalid value '%s' of annotation %s, expected a positive integer",
+			minActive, F5VsMinActiveMembersAnnotation)
+	}
+	return int32(n), nil
+}
+
+// ZonePriorityGroup returns the priority group of a pool member in zone.
+// With the minimumMembersActive of their pool, the BIG-IP sends traffic to
+// the members of other zones than localZone only when fewer than that many
+// members of the local zone are available.
+func ZonePriorityGroup(zone, localZone string) int32 {
+	if zone != "" && zone == localZone {
+		return LocalZonePriorityGroup
+	}
+	return 0
+}
+
+// EndpointsNodes returns the node names of the addresses of eps
+func EndpointsNodes(eps *v1.Endpoints) map[string]string {
+	nodes := make(map[string]string)
+	if nil == eps {
+		return nodes
+	}
+	for _, subset := range eps.Subsets {
+		for _, addresses := range [][]v1.EndpointAddress{subset.Addresses, subset.NotReadyAddresses} {
+			for _, addr := range addresses {
+				if nil != addr.NodeName {
+					nodes[addr.IP] = *addr.NodeName
+				}
+			}
+		}
+	}
+	return nodes
+}
@@ -0,0 +1,88 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package resource
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Zone Tests", func() {
+	It("reads the zone of nodes", func() {
+		node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{
+			ZoneLabel:     "us-east-1a",
+			BetaZoneLabel: "us-east-1b",
+		}}}
+		Expect(NodeZone(node)).To(Equal("us-east-1a"))
+		delete(node.ObjectMeta.Labels, ZoneLabel)
+		Expect(NodeZone(node)).To(Equal("us-east-1b"))
+		delete(node.ObjectMeta.Labels, BetaZoneLabel)
+		Expect(NodeZone(node)).To(BeEmpty())
+	})
+
+	It("parses the zone aware annotations", func() {
+		for _, test := range []struct {
+			annotations map[string]string
+			minActive   int32
+			valid       bool
+		}{
+			{nil, 0, true},
+			{map[string]string{F5VsZoneAwareAnnotation: "false"}, 0, true},
+			{map[string]string{F5VsZoneAwareAnnotation: "true"}, 1, true},
+			{map[string]string{
+				F5VsZoneAwareAnnotation:        "true",
+				F5VsMinActiveMembersAnnotation: "3",
+			}, 3, true},
+			{map[string]string{F5VsZoneAwareAnnotation: "yes"}, 0, false},
+			{map[string]string{
+				F5VsZoneAwareAnnotation:        "true",
+				F5VsMinActiveMembersAnnotation: "0",
+			}, 0, false},
+		} {
+			minActive, err := ParseZoneAwareAnnotations(test.annotations)
+			Expect(minActive).To(Equal(test.minActive), "%v", test.annotations)
+			Expect(nil == err).To(Equal(test.valid), "%v", test.annotations)
+		}
+	})
+
+	It("gives the members of the local zone precedence", func() {
+		Expect(ZonePriorityGroup("us-east-1a", "us-east-1a")).To(Equal(LocalZonePriorityGroup))
+		Expect(ZonePriorityGroup("us-east-1b", "us-east-1a")).To(BeZero())
+		Expect(ZonePriorityGroup("", "")).To(BeZero())
+	})
+
+	It("maps endpoint addresses to their node", func() {
+		node0, node1 := "node0", "node1"
+		eps := &v1.Endpoints{Subsets: []v1.EndpointSubset{{
+			Addresses:         []v1.EndpointAddress{{IP: "10.2.96.0", NodeName: &node0}},
+			NotReadyAddresses: []v1.EndpointAddress{{IP: "10.2.96.1", NodeName: &node1}, {IP: "10.2.96.2"}},
+		}}}
+		Expect(EndpointsNodes(eps)).To(Equal(map[string]string{
+			"10.2.96.0": "node0",
+			"10.2.96.1": "node1",
+		}))
+		Expect(EndpointsNodes(nil)).To(BeEmpty())
+
+		slices := []*EndpointSlice{{Endpoints: []EndpointSliceEndpoint{
+			{Addresses: []string{"10.2.96.0"}, NodeName: &node0},
+			{Addresses: []string{"10.2.96.2"}},
+		}}}
+		Expect(EndpointSliceNodes(slices)).To(Equal(map[string]string{"10.2.96.0": "node0"}))
+	})
+})
@@ -0,0 +1,223 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package staticroute routes the pod CIDR of each node to the node on
+// BIG-IP, so that BIG-IP reaches the pods without VXLAN tunnels when the
+// pod network is routed, as with Calico or Cilium in direct routing mode.
+package staticroute
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/icontrol"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Static routes take the place of the VXLAN tunnels, they share their log
+// level
+var log = vlogger.NewComponentLogger(vlogger.ComponentVxLAN)
+
+// RoutePartition is the BIG-IP partition of the static routes
+const RoutePartition = "Common"
+
+// RouteClient manages the static routes of BIG-IP
+type RouteClient interface {
+	Routes(partition string) ([]icontrol.Route, error)
+	CreateRoute(route icontrol.Route) error
+	UpdateRouteGateway(partition, name, gateway string) error
+	DeleteRoute(partition, name string) error
+}
+
+// RouteMgr keeps one static route on BIG-IP for each pod CIDR of the nodes,
+// whose gateway is the address of the node, and deletes the routes of the
+// nodes that leave the cluster
+type RouteMgr struct {
+	client     RouteClient
+	owner      string
+	useNodeInt bool
+}
+
+// NewRouteMgr returns a RouteMgr of the routes of the controller managing
+// the BIG-IP partition owner, which tells its routes apart from the other
+// routes of BIG-IP
+func NewRouteMgr(
+	client RouteClient,
+	owner string,
+	useNodeInternal bool,
+) (*RouteMgr, error) {
+	if nil == client {
+		return nil, fmt.Errorf("required parameter client not supplied")
+	} else if 0 == len(owner) {
+		return nil, fmt.Errorf("required parameter owner not supplied")
+	}
+	return &RouteMgr{
+		client:     client,
+		owner:      owner,
+		useNodeInt: useNodeInternal,
+	}, nil
+}
+
+// ProcessNodeUpdate is the node poll listener that updates the routes
+func (rm *RouteMgr) ProcessNodeUpdate(obj interface{}, err error) {
+	if nil != err {
+		log.Warningf("[ROUTE] Static route manager unable to get list of nodes: %v", err)
+		return
+	}
+	nodes, ok := obj.([]v1.Node)
+	if false == ok {
+		log.Warningf("[ROUTE] Static route manager received poll update with unexpected type")
+		return
+	}
+
+	if err := rm.syncRoutes(rm.nodeRoutes(nodes)); nil != err {
+		log.Warningf("[ROUTE] Static route manager failed to update the BIG-IP routes: %v", err)
+	}
+}
+
+// nodeRoutes returns the routes to the pod CIDRs of nodes, by name
+func (rm *RouteMgr) nodeRoutes(nodes []v1.Node) map[string]icontrol.Route {
+	var addrType v1.NodeAddressType
+	if rm.useNodeInt {
+		addrType = v1.NodeInternalIP
+	} else {
+		addrType = v1.NodeExternalIP
+	}
+
+	routes := make(map[string]icontrol.Route)
+	for _, node := range nodes {
+		podCIDRs := node.Spec.PodCIDRs
+		if 0 == len(podCIDRs) && "" != node.Spec.PodCIDR {
+			podCIDRs = []string{node.Spec.PodCIDR}
+		}
+		for _, podCIDR := range podCIDRs {
+			_, network, err := net.ParseCIDR(podCIDR)
+			if nil != err {
+				log.Warningf("[ROUTE] Invalid pod CIDR %v of node %v", podCIDR, node.ObjectMeta.Name)
+				continue
+			}
+			// Dual-stack nodes have an address of each family
+			gateway := nodeAddress(node, addrType, nil != network.IP.To4())
+			if "" == gateway {
+				log.Debugf("[ROUTE] Node %v has no %v address for pod CIDR %v",
+					node.ObjectMeta.Name, addrType, podCIDR)
+				continue
+			}
+			route := icontrol.Route{
+				Name:        rm.routeName(node.ObjectMeta.Name, network),
+				Partition:   RoutePartition,
+				Network:     network.String(),
+				Gateway:     gateway,
+				Description: rm.description(),
+			}
+			routes[route.Name] = route
+		}
+	}
+	return routes
+}
+
+// syncRoutes creates, updates and deletes the routes of the controller on
+// BIG-IP to match routes
+func (rm *RouteMgr) syncRoutes(routes map[string]icontrol.Route) error {
+	current, err := rm.client.Routes(RoutePartition)
+	if nil != err {
+		return err
+	}
+	existing := make(map[string]icontrol.Route)
+	for _, route := range current {
+		if route.Description == rm.description() {
+			existing[route.Name] = route
+		}
+	}
+
+	var errs []string
+	for _, name := range sortedNames(existing) {
+		if _, ok := routes[name]; !ok {
+			log.Infof("[ROUTE] Deleting static route %v", name)
+			if err := rm.client.DeleteRoute(RoutePartition, name); nil != err {
+				errs = append(errs, fmt.Sprintf("deleting route %v: %v", name, err))
+			}
+		}
+	}
+	for _, name := range sortedNames(routes) {
+		route := routes[name]
+		old, ok := existing[name]
+		if !ok {
+			log.Infof("[ROUTE] Creating static route %v to %v via %v",
+				name, route.Network, route.Gateway)
+			if err := rm.client.CreateRoute(route); nil != err {
+				errs = append(errs, fmt.Sprintf("creating route %v: %v", name, err))
+			}
+		} else if !sameAddress(old.Gateway, route.Gateway) {
+			log.Infof("[ROUTE] Updating static route %v to %v via %v",
+				name, route.Network, route.Gateway)
+			err := rm.client.UpdateRouteGateway(RoutePartition, name, route.Gateway)
+			if nil != err {
+				errs = append(errs, fmt.Sprintf("updating route %v: %v", name, err))
+			}
+		}
+	}
+	if 0 != len(errs) {
+		return fmt.Errorf("%v", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// routeName returns the name of the route to network of node, as in
+// k8s-<owner>-<node>-10.244.1.0_24
+func (rm *RouteMgr) routeName(node string, network *net.IPNet) string {
+	ones, _ := network.Mask.Size()
+	// Colons of IPv6 addresses are not allowed in names
+	addr := strings.Replace(network.IP.String(), ":", ".", -1)
+	return fmt.Sprintf("k8s-%s-%s-%s_%d", rm.owner, node, addr, ones)
+}
+
+// description marks the routes of the controller
+func (rm *RouteMgr) description() string {
+	return "k8s-bigip-ctlr " + rm.owner
+}
+
+func nodeAddress(node v1.Node, addrType v1.NodeAddressType, ipv4 bool) string {
+	for _, addr := range node.Status.Addresses {
+		ip := net.ParseIP(addr.Address)
+		if addr.Type == addrType && nil != ip && ipv4 == (nil != ip.To4()) {
+			return addr.Address
+		}
+	}
+	return ""
+}
+
+// sameAddress compares addresses, as BIG-IP may write them differently
+func sameAddress(a, b string) bool {
+	ipA, ipB := net.ParseIP(a), net.ParseIP(b)
+	if nil == ipA || nil == ipB {
+		return a == b
+	}
+	return ipA.Equal(ipB)
+}
+
+func sortedNames(routes map[string]icontrol.Route) []string {
+	var names []string
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,161 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package staticroute
+
+import (
+	"fmt"
+
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/icontrol"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type mockRouteClient struct {
+	routes map[string]icontrol.Route
+	calls  []string
+	err    error
+}
+
+func (mc *mockRouteClient) Routes(partition string) ([]icontrol.Route, error) {
+	var routes []icontrol.Route
+	for _, name := range sortedNames(mc.routes) {
+		if mc.routes[name].Partition == partition {
+			routes = append(routes, mc.routes[name])
+		}
+	}
+	return routes, mc.err
+}
+
+func (mc *mockRouteClient) CreateRoute(route icontrol.Route) error {
+	mc.calls = append(mc.calls, "create "+route.Name)
+	mc.routes[route.Name] = route
+	return nil
+}
+
+func (mc *mockRouteClient) UpdateRouteGateway(partition, name, gateway string) error {
+	mc.calls = append(mc.calls, "update "+name)
+	route := mc.routes[name]
+	route.Gateway = gateway
+	mc.routes[name] = route
+	return nil
+}
+
+func (mc *mockRouteClient) DeleteRoute(partition, name string) error {
+	mc.calls = append(mc.calls, "delete "+name)
+	delete(mc.routes, name)
+	return nil
+}
+
+func newNode(name string, podCIDRs []string, addresses ...string) v1.Node {
+	node := v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1.NodeSpec{PodCIDRs: podCIDRs},
+	}
+	if len(podCIDRs) > 0 {
+		node.Spec.PodCIDR = podCIDRs[0]
+	}
+	for _, addr := range addresses {
+		node.Status.Addresses = append(node.Status.Addresses,
+			v1.NodeAddress{Type: v1.NodeInternalIP, Address: addr})
+	}
+	return node
+}
+
+var _ = Describe("Static Route Manager Tests", func() {
+	var client *mockRouteClient
+	var rm *RouteMgr
+
+	route := func(name, network, gateway string) icontrol.Route {
+		return icontrol.Route{
+			Name:        name,
+			Partition:   RoutePartition,
+			Network:     network,
+			Gateway:     gateway,
+			Description: "k8s-bigip-ctlr kubernetes",
+		}
+	}
+
+	BeforeEach(func() {
+		client = &mockRouteClient{routes: map[string]icontrol.Route{
+			// Not managed by the controller
+			"default": {Name: "default", Partition: RoutePartition, Network: "default", Gateway: "10.1.1.1"},
+		}}
+		var err error
+		rm, err = NewRouteMgr(client, "kubernetes", true)
+		Expect(err).To(BeNil())
+	})
+
+	It("checks its parameters", func() {
+		_, err := NewRouteMgr(nil, "kubernetes", true)
+		Expect(err).ToNot(BeNil())
+		_, err = NewRouteMgr(client, "", true)
+		Expect(err).ToNot(BeNil())
+	})
+
+	It("routes the pod CIDRs of the nodes", func() {
+		rm.ProcessNodeUpdate([]v1.Node{
+			newNode("node0", []string{"10.244.0.0/24"}, "192.168.1.10"),
+			newNode("node1", []string{"10.244.1.0/24", "fd00:10:244:1::/64"},
+				"192.168.1.11", "2001:db8::11"),
+			// Without pod CIDR or address of the family of its pod CIDR
+			newNode("node2", nil, "192.168.1.12"),
+			newNode("node3", []string{"fd00:10:244:3::/64"}, "192.168.1.13"),
+		}, nil)
+		Expect(client.routes).To(Equal(map[string]icontrol.Route{
+			"default": client.routes["default"],
+			"k8s-kubernetes-node0-10.244.0.0_24": route(
+				"k8s-kubernetes-node0-10.244.0.0_24", "10.244.0.0/24", "192.168.1.10"),
+			"k8s-kubernetes-node1-10.244.1.0_24": route(
+				"k8s-kubernetes-node1-10.244.1.0_24", "10.244.1.0/24", "192.168.1.11"),
+			"k8s-kubernetes-node1-fd00.10.244.1.._64": route(
+				"k8s-kubernetes-node1-fd00.10.244.1.._64", "fd00:10:244:1::/64", "2001:db8::11"),
+		}))
+
+		// Unchanged
+		client.calls = nil
+		rm.ProcessNodeUpdate([]v1.Node{
+			newNode("node0", []string{"10.244.0.0/24"}, "192.168.1.10"),
+			newNode("node1", []string{"10.244.1.0/24", "fd00:10:244:1::/64"},
+				"192.168.1.11", "2001:db8::11"),
+		}, nil)
+		Expect(client.calls).To(BeEmpty())
+
+		// node0 changes address, node1 leaves
+		rm.ProcessNodeUpdate([]v1.Node{
+			newNode("node0", []string{"10.244.0.0/24"}, "192.168.1.20"),
+		}, nil)
+		Expect(client.calls).To(Equal([]string{
+			"delete k8s-kubernetes-node1-10.244.1.0_24",
+			"delete k8s-kubernetes-node1-fd00.10.244.1.._64",
+			"update k8s-kubernetes-node0-10.244.0.0_24",
+		}))
+		Expect(client.routes).To(HaveLen(2))
+		Expect(client.routes["k8s-kubernetes-node0-10.244.0.0_24"].Gateway).To(Equal("192.168.1.20"))
+	})
+
+	It("leaves the routes unchanged on errors", func() {
+		rm.ProcessNodeUpdate(nil, fmt.Errorf("failed to list nodes"))
+		rm.ProcessNodeUpdate("not nodes", nil)
+		client.err = fmt.Errorf("BIG-IP unavailable")
+		rm.ProcessNodeUpdate([]v1.Node{}, nil)
+		Expect(client.calls).To(BeEmpty())
+		Expect(client.routes).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,13 @@
+package staticroute_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestStaticRoute(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "StaticRoute Suite")
+}
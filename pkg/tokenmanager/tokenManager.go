@@ -0,0 +1,198 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+)
+
+const (
+	loginPath   = "/mgmt/shared/authn/login"
+	tokenHeader = "X-F5-Auth-Token"
+	// Default BIG-IP login provider for local and remote users
+	DefaultLoginProvider = "tmos"
+	// Token lifetime assumed when BIG-IP does not report one
+	defaultTokenTimeout = 1200 * time.Second
+	// Refresh the token this long before BIG-IP expires it
+	refreshMargin = 60 * time.Second
+)
+
+// TokenManager logs in to BIG-IP and authenticates iControl REST requests
+// with the X-F5-Auth-Token header instead of basic auth. The token is
+// refreshed before it expires and re-acquired when BIG-IP rejects it.
+type TokenManager struct {
+	sync.Mutex
+	bigIPURL      string
+	username      string
+	password      string
+	loginProvider string
+	httpClient    *http.Client
+	token         string
+	// Time after which the token is renewed, ahead of its expiry
+	refreshAt time.Time
+}
+
+type loginRequest struct {
+	Username          string `json:"username"`
+	Password          string `json:"password"`
+	LoginProviderName string `json:"loginProviderName"`
+}
+
+type loginResponse struct {
+	Token struct {
+		Token   string `json:"token"`
+		Timeout int    `json:"timeout"`
+	} `json:"token"`
+}
+
+// NewTokenManager returns a TokenManager that logs in through httpClient.
+// The token is acquired lazily on the first request.
+func NewTokenManager(
+	bigIPURL, username, password, loginProvider string,
+	httpClient *http.Client,
+) *TokenManager {
+	if loginProvider == "" {
+		loginProvider = DefaultLoginProvider
+	}
+	return &TokenManager{
+		bigIPURL:      bigIPURL,
+		username:      username,
+		password:      password,
+		loginProvider: loginProvider,
+		httpClient:    httpClient,
+	}
+}
+
+// GetToken returns a valid token, logging in again if the current one is
+// missing or about to expire.
+func (tm *TokenManager) GetToken() (string, error) {
+	tm.Lock()
+	defer tm.Unlock()
+	if tm.token != "" && time.Now().Before(tm.refreshAt) {
+		return tm.token, nil
+	}
+	return tm.login()
+}
+
+// Invalidate drops the current token so that the next request logs in again
+func (tm *TokenManager) Invalidate() {
+	tm.Lock()
+	defer tm.Unlock()
+	tm.token = ""
+}
+
+// SetCredentials replaces the credentials used to log in and drops the
+// current token.
+func (tm *TokenManager) SetCredentials(bigIPURL, username, password string) {
+	tm.Lock()
+	defer tm.Unlock()
+	tm.bigIPURL = bigIPURL
+	tm.username = username
+	tm.password = password
+	tm.token = ""
+}
+
+// Do authenticates and sends req. If BIG-IP answers 401 the token is
+// re-acquired and the request is sent once more.
+func (tm *TokenManager) Do(req *http.Request) (*http.Response, error) {
+	token, err := tm.GetToken()
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(tokenHeader, token)
+	httpResp, err := tm.httpClient.Do(req)
+	if err != nil || httpResp.StatusCode != http.StatusUnauthorized {
+		return httpResp, err
+	}
+
+	log.Debugf("[AUTH] BIG-IP rejected the auth token, logging in again")
+	httpResp.Body.Close()
+	tm.Invalidate()
+	if req.Body != nil {
+		if req.GetBody == nil {
+			return nil, fmt.Errorf("unable to resend request to %v", req.URL)
+		}
+		if req.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+	if token, err = tm.GetToken(); err != nil {
+		return nil, err
+	}
+	req.Header.Set(tokenHeader, token)
+	return tm.httpClient.Do(req)
+}
+
+// login must be called with the lock held
+func (tm *TokenManager) login() (string, error) {
+	body, err := json.Marshal(loginRequest{
+		Username:          tm.username,
+		Password:          tm.password,
+		LoginProviderName: tm.loginProvider,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", tm.bigIPURL+loginPath, bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	log.Debugf("[AUTH] Logging in to BIG-IP with login provider %v", tm.loginProvider)
+	httpResp, err := tm.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("BIG-IP login failed: %v", err)
+	}
+	defer httpResp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return "", fmt.Errorf("BIG-IP login failed: %v", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("BIG-IP login failed with status code %v", httpResp.StatusCode)
+	}
+	var loginResp loginResponse
+	if err = json.Unmarshal(respBody, &loginResp); err != nil {
+		return "", fmt.Errorf("BIG-IP login response unmarshal failed: %v", err)
+	}
+	if loginResp.Token.Token == "" {
+		return "", fmt.Errorf("BIG-IP login response has no token")
+	}
+
+	timeout := defaultTokenTimeout
+	if loginResp.Token.Timeout > 0 {
+		timeout = time.Duration(loginResp.Token.Timeout) * time.Second
+	}
+	margin := refreshMargin
+	if margin > timeout/2 {
+		margin = timeout / 2
+	}
+	tm.token = loginResp.Token.Token
+	tm.refreshAt = time.Now().Add(timeout - margin)
+	log.Debugf("[AUTH] Acquired BIG-IP auth token valid for %v", timeout)
+	return tm.token, nil
+}
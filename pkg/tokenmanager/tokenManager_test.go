@@ -0,0 +1,123 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tokenmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type mockBigIP struct {
+	server  *httptest.Server
+	logins  int
+	valid   string
+	lastReq loginRequest
+	bodies  []string
+}
+
+func newMockBigIP() *mockBigIP {
+	bigip := &mockBigIP{}
+	bigip.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == loginPath {
+			bigip.logins++
+			json.NewDecoder(r.Body).Decode(&bigip.lastReq)
+			bigip.valid = fmt.Sprintf("token-%d", bigip.logins)
+			fmt.Fprintf(w, `{"token": {"token": "%s", "timeout": 1200}}`, bigip.valid)
+			return
+		}
+		if r.Header.Get(tokenHeader) != bigip.valid || r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		bigip.bodies = append(bigip.bodies, string(body))
+		w.Write([]byte("{}"))
+	}))
+	return bigip
+}
+
+var _ = Describe("Token Manager Tests", func() {
+	var bigip *mockBigIP
+	var tm *TokenManager
+
+	BeforeEach(func() {
+		bigip = newMockBigIP()
+		tm = NewTokenManager(bigip.server.URL, "admin", "secret", "", bigip.server.Client())
+	})
+	AfterEach(func() {
+		bigip.server.Close()
+	})
+
+	It("Logs in once and reuses the token", func() {
+		for i := 0; i < 3; i++ {
+			req, _ := http.NewRequest("GET", bigip.server.URL+"/mgmt/shared/appsvcs/info", nil)
+			resp, err := tm.Do(req)
+			Expect(err).To(BeNil())
+			Expect(resp.StatusCode).To(Equal(http.StatusOK))
+			resp.Body.Close()
+		}
+		Expect(bigip.logins).To(Equal(1))
+		Expect(bigip.lastReq).To(Equal(loginRequest{
+			Username:          "admin",
+			Password:          "secret",
+			LoginProviderName: DefaultLoginProvider,
+		}))
+	})
+
+	It("Logs in again and resends the body when the token is rejected", func() {
+		_, err := tm.GetToken()
+		Expect(err).To(BeNil())
+		// BIG-IP revokes the token
+		bigip.valid = "revoked"
+
+		req, _ := http.NewRequest("POST", bigip.server.URL+"/mgmt/shared/appsvcs/declare",
+			bytes.NewBufferString(`{"class": "AS3"}`))
+		resp, err := tm.Do(req)
+		Expect(err).To(BeNil())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		resp.Body.Close()
+		Expect(bigip.logins).To(Equal(2))
+		Expect(bigip.bodies).To(Equal([]string{`{"class": "AS3"}`}))
+	})
+
+	It("Drops the token when credentials change", func() {
+		_, err := tm.GetToken()
+		Expect(err).To(BeNil())
+		tm.SetCredentials(bigip.server.URL, "admin", "rotated")
+		_, err = tm.GetToken()
+		Expect(err).To(BeNil())
+		Expect(bigip.logins).To(Equal(2))
+		Expect(bigip.lastReq.Password).To(Equal("rotated"))
+	})
+
+	It("Reports failed logins", func() {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer failing.Close()
+		tm = NewTokenManager(failing.URL, "admin", "wrong", "tacacs", failing.Client())
+		_, err := tm.GetToken()
+		Expect(err).NotTo(BeNil())
+	})
+})
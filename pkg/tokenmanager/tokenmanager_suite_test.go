@@ -0,0 +1,13 @@
+package tokenmanager_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTokenManager(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "TokenManager Suite")
+}
@@ -0,0 +1,75 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const (
+	// Spans are sent in batches of batchSize, or every flushInterval
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+	// Spans ended while queueSize spans wait to be sent are dropped
+	queueSize = 4096
+)
+
+// NewTracerProvider creates a tracer provider handing the spans of the
+// service serviceName to exporter in batches. The provider must be shut
+// down on exit to send the spans still queued.
+func NewTracerProvider(exporter sdktrace.SpanExporter, serviceName string) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter,
+			sdktrace.WithMaxExportBatchSize(batchSize),
+			sdktrace.WithBatchTimeout(flushInterval),
+			sdktrace.WithMaxQueueSize(queueSize),
+		),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+}
+
+// InMemoryExporter keeps the spans in memory, for tests
+type InMemoryExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+// NewInMemoryExporter creates an empty InMemoryExporter
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{tracetest.NewInMemoryExporter()}
+}
+
+// TracerProvider creates a tracer provider handing each span to e when it
+// ends
+func (e *InMemoryExporter) TracerProvider() *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(e))
+}
+
+// Named returns the exported spans named name, in the order they ended
+func (e *InMemoryExporter) Named(name string) tracetest.SpanStubs {
+	var named tracetest.SpanStubs
+	for _, span := range e.GetSpans() {
+		if span.Name == name {
+			named = append(named, span)
+		}
+	}
+	return named
+}
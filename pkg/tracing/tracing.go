@@ -0,0 +1,164 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package tracing records spans of the pipeline from informer events to the
+// BIG-IP posts with the OpenTelemetry SDK. Spans are started from the tracer
+// provider set with SetTracerProvider; with the default no-op provider no
+// span is recorded.
+package tracing
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type (
+	// SpanContext identifies a span, to start its children
+	SpanContext = trace.SpanContext
+	// Span is an operation being traced
+	Span = trace.Span
+	// SpanKind is the OpenTelemetry span kind
+	SpanKind = trace.SpanKind
+	// StatusCode is the OpenTelemetry span status code
+	StatusCode = codes.Code
+	// Attribute is a key and a value describing a span
+	Attribute = attribute.KeyValue
+)
+
+const (
+	SpanKindInternal = trace.SpanKindInternal
+	SpanKindClient   = trace.SpanKindClient
+)
+
+const (
+	StatusUnset = codes.Unset
+	StatusOK    = codes.Ok
+	StatusError = codes.Error
+)
+
+// Span names of the pipeline
+const (
+	SpanInformerEvent    = "informer.event"
+	SpanEnqueue          = "workqueue.enqueue"
+	SpanSyncVirtual      = "syncVirtualServer"
+	SpanProcessResource  = "processResource"
+	SpanDeployResource   = "deployResource"
+	SpanBuildDeclaration = "as3.buildDeclaration"
+	SpanPost             = "as3.post"
+)
+
+// scopeName is the instrumentation scope of the spans
+const scopeName = "github.com/F5Networks/k8s-bigip-ctlr"
+
+// String returns an attribute with a string value
+func String(key, value string) Attribute {
+	return attribute.String(key, value)
+}
+
+// Int returns an attribute with an int value
+func Int(key string, value int) Attribute {
+	return attribute.Int(key, value)
+}
+
+// Bool returns an attribute with a bool value
+func Bool(key string, value bool) Attribute {
+	return attribute.Bool(key, value)
+}
+
+var (
+	// provider holds the current tracer provider, read by every Start
+	provider atomic.Value
+	// providerMutex serializes the changes to provider
+	providerMutex sync.Mutex
+)
+
+type providerHolder struct {
+	trace.TracerProvider
+}
+
+func init() {
+	provider.Store(providerHolder{trace.NewNoopTracerProvider()})
+}
+
+// SetTracerProvider sets the provider of the spans started afterwards and
+// returns the previous one. nil disables tracing.
+func SetTracerProvider(tp trace.TracerProvider) trace.TracerProvider {
+	if tp == nil {
+		tp = trace.NewNoopTracerProvider()
+	}
+	providerMutex.Lock()
+	defer providerMutex.Unlock()
+	previous := provider.Load().(providerHolder).TracerProvider
+	provider.Store(providerHolder{tp})
+	return previous
+}
+
+// Start starts a span named name, a child of parent or the root of a new
+// trace when parent is not valid
+func Start(parent SpanContext, name string, attrs ...Attribute) Span {
+	return StartKind(parent, name, SpanKindInternal, attrs...)
+}
+
+// StartKind starts a span of the given kind, see Start
+func StartKind(parent SpanContext, name string, kind SpanKind, attrs ...Attribute) Span {
+	ctx := context.Background()
+	if parent.IsValid() {
+		ctx = trace.ContextWithSpanContext(ctx, parent)
+	}
+	tracer := provider.Load().(providerHolder).Tracer(scopeName)
+	_, span := tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+	return span
+}
+
+// Pending keeps the span context that queued a work item until a worker
+// takes the item. Work queues merge identical items, so the first context
+// added for an item is kept.
+type Pending struct {
+	mutex  sync.Mutex
+	traces map[interface{}]SpanContext
+}
+
+// NewPending creates an empty Pending
+func NewPending() *Pending {
+	return &Pending{traces: make(map[interface{}]SpanContext)}
+}
+
+// Add keeps sc for item, unless item already has a context or sc is not
+// valid
+func (p *Pending) Add(item interface{}, sc SpanContext) {
+	if !sc.IsValid() {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, found := p.traces[item]; !found {
+		p.traces[item] = sc
+	}
+}
+
+// Take returns and forgets the context kept for item
+func (p *Pending) Take(item interface{}) SpanContext {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	sc := p.traces[item]
+	delete(p.traces, item)
+	return sc
+}
@@ -0,0 +1,138 @@
+/*-
+ * Copyright (c) 2016-2019, F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/F5Networks/k8s-bigip-ctlr/pkg/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var _ = Describe("Tracing Tests", func() {
+	var exporter *InMemoryExporter
+	var previous trace.TracerProvider
+
+	BeforeEach(func() {
+		exporter = NewInMemoryExporter()
+		previous = SetTracerProvider(exporter.TracerProvider())
+	})
+	AfterEach(func() {
+		SetTracerProvider(previous)
+	})
+
+	It("Records nothing when disabled", func() {
+		SetTracerProvider(nil)
+		span := Start(SpanContext{}, SpanEnqueue)
+		Expect(span.IsRecording()).To(BeFalse())
+		Expect(span.SpanContext().IsValid()).To(BeFalse())
+		span.SetAttributes(String("namespace", "default"))
+		span.End()
+		Expect(exporter.GetSpans()).To(BeEmpty())
+	})
+
+	It("Records parent and child spans", func() {
+		root := Start(SpanContext{}, SpanInformerEvent, String("kind", "Service"))
+		child := StartKind(root.SpanContext(), SpanPost, SpanKindClient)
+		child.SetAttributes(Int("http.status_code", 200), Bool("changed", true))
+		child.End()
+		root.SetStatus(StatusError, "failed")
+		root.End()
+
+		spans := exporter.GetSpans()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name).To(Equal(SpanPost))
+		Expect(spans[0].SpanKind).To(Equal(SpanKindClient))
+		Expect(spans[0].Parent).To(Equal(root.SpanContext()))
+		Expect(spans[0].SpanContext.TraceID()).To(Equal(root.SpanContext().TraceID()))
+		Expect(spans[0].Status.Code).To(Equal(StatusUnset))
+		Expect(spans[0].Attributes).To(ConsistOf(Int("http.status_code", 200), Bool("changed", true)))
+
+		Expect(spans[1].Name).To(Equal(SpanInformerEvent))
+		Expect(spans[1].Parent.IsValid()).To(BeFalse())
+		Expect(spans[1].Status.Code).To(Equal(StatusError))
+		Expect(spans[1].Status.Description).To(Equal("failed"))
+		Expect(spans[1].Attributes).To(ConsistOf(String("kind", "Service")))
+
+		Expect(exporter.Named(SpanPost)).To(HaveLen(1))
+	})
+
+	It("Keeps the first pending context of an item", func() {
+		pending := NewPending()
+		first := Start(SpanContext{}, SpanEnqueue).SpanContext()
+		second := Start(SpanContext{}, SpanEnqueue).SpanContext()
+		pending.Add("key", SpanContext{})
+		pending.Add("key", first)
+		pending.Add("key", second)
+		Expect(pending.Take("key")).To(Equal(first))
+		Expect(pending.Take("key").IsValid()).To(BeFalse())
+	})
+
+	It("Exports spans to an OTLP collector", func() {
+		var body map[string]interface{}
+		var header string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header = r.Header.Get("Authorization")
+			data, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(data, &body)
+		}))
+		defer server.Close()
+
+		otlp := NewOTLPExporter(server.URL, map[string]string{"Authorization": "Bearer token"})
+		tp := NewTracerProvider(otlp, "k8s-bigip-ctlr")
+		SetTracerProvider(tp)
+		root := Start(SpanContext{}, SpanInformerEvent)
+		child := Start(root.SpanContext(), SpanEnqueue, String("namespace", "default"), Int("size", 10))
+		child.End()
+		root.RecordError(errors.New("failed"))
+		root.SetStatus(StatusError, "failed")
+		root.End()
+		Expect(tp.Shutdown(context.Background())).To(BeNil())
+
+		Expect(header).To(Equal("Bearer token"))
+		resourceSpans := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		resource := resourceSpans["resource"].(map[string]interface{})
+		Expect(resource["attributes"]).To(ConsistOf(map[string]interface{}{
+			"key": "service.name", "value": map[string]interface{}{"stringValue": "k8s-bigip-ctlr"}}))
+		scopeSpans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})
+		Expect(scopeSpans["scope"]).To(Equal(map[string]interface{}{"name": "github.com/F5Networks/k8s-bigip-ctlr"}))
+		spans := scopeSpans["spans"].([]interface{})
+		Expect(spans).To(HaveLen(2))
+
+		enqueue := spans[0].(map[string]interface{})
+		Expect(enqueue["name"]).To(Equal(SpanEnqueue))
+		Expect(enqueue["kind"]).To(Equal(float64(1)))
+		Expect(enqueue["traceId"]).To(Equal(root.SpanContext().TraceID().String()))
+		Expect(enqueue["parentSpanId"]).To(Equal(root.SpanContext().SpanID().String()))
+		Expect(enqueue["attributes"]).To(ConsistOf(
+			map[string]interface{}{"key": "namespace", "value": map[string]interface{}{"stringValue": "default"}},
+			map[string]interface{}{"key": "size", "value": map[string]interface{}{"intValue": "10"}},
+		))
+
+		event := spans[1].(map[string]interface{})
+		Expect(event).NotTo(HaveKey("parentSpanId"))
+		Expect(event["spanId"]).To(Equal(root.SpanContext().SpanID().String()))
+		Expect(event["status"]).To(Equal(map[string]interface{}{"code": float64(2), "message": "failed"}))
+	})
+})
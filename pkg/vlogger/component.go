@@ -0,0 +1,152 @@
+// Copyright (c) 2019, F5 Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//component.go:
+//  Logs the messages of a component, filtered by the level of that component.
+//
+package vlogger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Components whose log level can be set separately
+const (
+	ComponentAS3        = "as3"
+	ComponentCCCL       = "cccl"
+	ComponentCRManager  = "crmanager"
+	ComponentAppManager = "appmanager"
+	ComponentVxLAN      = "vxlan"
+	ComponentPollers    = "pollers"
+)
+
+// Components lists the components whose log level can be set separately
+var Components = []string{
+	ComponentAS3,
+	ComponentCCCL,
+	ComponentCRManager,
+	ComponentAppManager,
+	ComponentVxLAN,
+	ComponentPollers,
+}
+
+// ComponentLogger has the same calls as the package-level functions, but
+// filters the messages by the level of its component. Packages declare one
+// as their package-level log variable.
+type ComponentLogger struct {
+	name string
+}
+
+// NewComponentLogger creates a logger for the component name
+func NewComponentLogger(name string) *ComponentLogger {
+	return &ComponentLogger{name: strings.ToLower(name)}
+}
+
+// Name returns the component of the logger
+func (cl *ComponentLogger) Name() string {
+	return cl.name
+}
+
+// Enabled reports whether messages at level are logged for the component,
+// to skip building expensive messages
+func (cl *ComponentLogger) Enabled(level LogLevel) bool {
+	return enabled(cl.name, level)
+}
+
+// WithFields returns an Entry that logs messages with fields attached,
+// filtered by the level of the component
+func (cl *ComponentLogger) WithFields(fields Fields) *Entry {
+	return &Entry{component: cl.name, fields: fields}
+}
+
+func (cl *ComponentLogger) Debug(msg string) {
+	if enabled(cl.name, LL_DEBUG) {
+		vlog[LL_DEBUG].Debug(msg)
+	}
+}
+
+func (cl *ComponentLogger) Debugf(format string, params ...interface{}) {
+	if enabled(cl.name, LL_DEBUG) {
+		vlog[LL_DEBUG].Debugf(format, params...)
+	}
+}
+
+func (cl *ComponentLogger) Info(msg string) {
+	if enabled(cl.name, LL_INFO) {
+		vlog[LL_INFO].Info(msg)
+	}
+}
+
+func (cl *ComponentLogger) Infof(format string, params ...interface{}) {
+	if enabled(cl.name, LL_INFO) {
+		vlog[LL_INFO].Infof(format, params...)
+	}
+}
+
+func (cl *ComponentLogger) Warning(msg string) {
+	if enabled(cl.name, LL_WARNING) {
+		vlog[LL_WARNING].Warning(msg)
+	}
+}
+
+func (cl *ComponentLogger) Warningf(format string, params ...interface{}) {
+	if enabled(cl.name, LL_WARNING) {
+		vlog[LL_WARNING].Warningf(format, params...)
+	}
+}
+
+func (cl *ComponentLogger) Error(msg string) {
+	if enabled(cl.name, LL_ERROR) {
+		vlog[LL_ERROR].Error(msg)
+	}
+}
+
+func (cl *ComponentLogger) Errorf(format string, params ...interface{}) {
+	if enabled(cl.name, LL_ERROR) {
+		vlog[LL_ERROR].Errorf(format, params...)
+	}
+}
+
+func (cl *ComponentLogger) Critical(msg string) {
+	if enabled(cl.name, LL_CRITICAL) {
+		vlog[LL_CRITICAL].Critical(msg)
+	}
+}
+
+func (cl *ComponentLogger) Criticalf(format string, params ...interface{}) {
+	if enabled(cl.name, LL_CRITICAL) {
+		vlog[LL_CRITICAL].Criticalf(format, params...)
+	}
+}
+
+// Fatal logs regardless of the component level, see the package-level Fatal
+func (cl *ComponentLogger) Fatal(msg string) {
+	Fatal(msg)
+}
+
+// Fatalf logs regardless of the component level, see the package-level Fatalf
+func (cl *ComponentLogger) Fatalf(format string, params ...interface{}) {
+	Fatal(fmt.Sprintf(format, params...))
+}
+
+// Panic logs regardless of the component level, see the package-level Panic
+func (cl *ComponentLogger) Panic(msg string) {
+	Panic(msg)
+}
+
+// Panicf logs regardless of the component level, see the package-level Panicf
+func (cl *ComponentLogger) Panicf(format string, params ...interface{}) {
+	Panic(fmt.Sprintf(format, params...))
+}
@@ -104,6 +104,35 @@ Two related defines can also be used (mainly as a convenience for logger registr
 Note that certain concrete packages will have their own fine-grained filtering for
 logging.  However, the package-level controls will supercede these finer controls.
 
+COMPONENT LOG LEVELS
+
+Components can log at their own level, overriding the global one.  A package
+declares a ComponentLogger as its package-level log variable:
+
+  var log = vlogger.NewComponentLogger(vlogger.ComponentAS3)
+
+Its calls are the same as the package-level functions, filtered by the level
+of the component.  The levels are changed with:
+
+  SetLevels(levels Levels)
+  SetComponentLogLevel(component string, level LogLevel)
+  GetLevels() Levels
+
+Each change replaces the whole set of levels, which logging calls read
+atomically without locking.
+
+STRUCTURED FIELDS
+
+Messages can carry the component, the namespace and name of a resource, and a
+sync cycle ID as structured fields:
+
+  log.WithFields(log.Fields{Component: "as3", Namespace: ns, Name: name}).Infof(...)
+
+Loggers implementing FieldLogger, like the JSON logger (jsonlog.NewJSONLogger),
+record them as separate fields and attribute messages without an explicit
+component by their "[AS3]" style prefix.  Other loggers get the fields
+appended to the message.
+
 COMPATIBILITY ISSUES
 
 Log levels do not always map 1-to-1 with the underlying 3rd-party logging library.
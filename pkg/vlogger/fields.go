@@ -0,0 +1,143 @@
+// Copyright (c) 2019, F5 Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//fields.go:
+//  Attaches structured fields to log messages.
+//
+package vlogger
+
+import (
+	"fmt"
+	"strings"
+)
+
+type (
+	// Fields are structured data attached to a log message.
+	Fields struct {
+		// Component that logs the message, e.g. as3 or cccl. Messages
+		// without it are attributed by their "[AS3]" style prefix.
+		Component string
+		// Namespace and name of the resource the message is about
+		Namespace string
+		Name      string
+		// Identifies the sync cycle the message belongs to
+		SyncID string
+	}
+
+	// FieldLogger is implemented by loggers that record Fields separately
+	// from the message. Like the other logging calls, LogFields filters
+	// messages below the logger's level.
+	FieldLogger interface {
+		LogFields(level LogLevel, fields Fields, msg string)
+	}
+
+	// Entry logs messages with Fields attached.
+	Entry struct {
+		// Component whose level filters the messages
+		component string
+		fields    Fields
+	}
+)
+
+// WithFields returns an Entry that logs messages with fields attached.
+// Loggers that do not implement FieldLogger get the fields appended to the
+// message. The messages are filtered by the level of fields.Component.
+func WithFields(fields Fields) *Entry {
+	return &Entry{component: strings.ToLower(fields.Component), fields: fields}
+}
+
+// Debugf logs a formatted debug level message with the entry fields
+func (e *Entry) Debugf(format string, params ...interface{}) {
+	e.logf(LL_DEBUG, format, params...)
+}
+
+// Infof logs a formatted info level message with the entry fields
+func (e *Entry) Infof(format string, params ...interface{}) {
+	e.logf(LL_INFO, format, params...)
+}
+
+// Warningf logs a formatted warning level message with the entry fields
+func (e *Entry) Warningf(format string, params ...interface{}) {
+	e.logf(LL_WARNING, format, params...)
+}
+
+// Errorf logs a formatted error level message with the entry fields
+func (e *Entry) Errorf(format string, params ...interface{}) {
+	e.logf(LL_ERROR, format, params...)
+}
+
+// Criticalf logs a formatted critical level message with the entry fields
+func (e *Entry) Criticalf(format string, params ...interface{}) {
+	e.logf(LL_CRITICAL, format, params...)
+}
+
+func (e *Entry) logf(level LogLevel, format string, params ...interface{}) {
+	if !enabled(e.component, level) {
+		return
+	}
+	msg := fmt.Sprintf(format, params...)
+	logger := vlog[level]
+	if fl, ok := logger.(FieldLogger); ok {
+		fl.LogFields(level, e.fields, msg)
+		return
+	}
+	msg = e.fields.format(msg)
+	switch level {
+	case LL_DEBUG:
+		logger.Debug(msg)
+	case LL_INFO:
+		logger.Info(msg)
+	case LL_WARNING:
+		logger.Warning(msg)
+	case LL_ERROR:
+		logger.Error(msg)
+	default:
+		logger.Critical(msg)
+	}
+}
+
+// format renders the fields into msg for loggers that only take text
+func (f Fields) format(msg string) string {
+	if f.Component != "" {
+		msg = "[" + strings.ToUpper(f.Component) + "] " + msg
+	}
+	var extra []string
+	if f.Name != "" {
+		if f.Namespace != "" {
+			extra = append(extra, "resource: "+f.Namespace+"/"+f.Name)
+		} else {
+			extra = append(extra, "resource: "+f.Name)
+		}
+	}
+	if f.SyncID != "" {
+		extra = append(extra, "sync: "+f.SyncID)
+	}
+	if len(extra) != 0 {
+		msg += " (" + strings.Join(extra, ", ") + ")"
+	}
+	return msg
+}
+
+// SplitComponent separates the "[AS3]" style component prefix from msg and
+// returns the component in lower case, empty when msg has no prefix.
+func SplitComponent(msg string) (string, string) {
+	if !strings.HasPrefix(msg, "[") {
+		return "", msg
+	}
+	end := strings.Index(msg, "]")
+	if end <= 1 || strings.ContainsAny(msg[1:end], " \t\n") {
+		return "", msg
+	}
+	return strings.ToLower(msg[1:end]), strings.TrimLeft(msg[end+1:], " ")
+}
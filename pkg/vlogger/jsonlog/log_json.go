@@ -0,0 +1,167 @@
+// Copyright (c) 2019, F5 Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//log_json.go:
+//  Provides JSON logging, one object per line, through the common interface.
+//  To use, create the logger object with the following syntax:
+//    NewJSONLogger()
+//
+package jsonlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+
+	vlog "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+)
+
+type (
+	jsonLogger struct {
+		// slLogLevel uses syslog's definitions which have higher priority
+		// levels defined in descending order (0 is highest)
+		slLogLevel syslog.Priority
+		out        io.Writer
+		// Serializes writes so that records do not interleave
+		mutex sync.Mutex
+	}
+
+	record struct {
+		Level     string `json:"level"`
+		Timestamp string `json:"timestamp"`
+		Component string `json:"component,omitempty"`
+		Message   string `json:"message"`
+		Namespace string `json:"namespace,omitempty"`
+		Name      string `json:"name,omitempty"`
+		SyncID    string `json:"syncId,omitempty"`
+	}
+)
+
+// syslogLevels maps vlogger log levels to syslog's definitions
+var syslogLevels = map[vlog.LogLevel]syslog.Priority{
+	vlog.LL_DEBUG:    syslog.LOG_DEBUG,
+	vlog.LL_INFO:     syslog.LOG_INFO,
+	vlog.LL_WARNING:  syslog.LOG_WARNING,
+	vlog.LL_ERROR:    syslog.LOG_ERR,
+	vlog.LL_CRITICAL: syslog.LOG_CRIT,
+}
+
+// NewJSONLogger creates a logger object that prints log messages as JSON
+// objects to stderr.
+func NewJSONLogger() *jsonLogger {
+	return NewJSONLoggerExt(os.Stderr)
+}
+
+// NewJSONLoggerExt creates a logger object that prints log messages as
+// JSON objects to out.
+func NewJSONLoggerExt(out io.Writer) *jsonLogger {
+	return &jsonLogger{
+		slLogLevel: syslog.LOG_DEBUG,
+		out:        out,
+	}
+}
+
+func (jl *jsonLogger) enabled(level vlog.LogLevel) bool {
+	return jl.slLogLevel >= syslogLevels[level]
+}
+
+func (jl *jsonLogger) LogFields(level vlog.LogLevel, fields vlog.Fields, msg string) {
+	if !jl.enabled(level) {
+		return
+	}
+	component, msg := vlog.SplitComponent(msg)
+	if fields.Component != "" {
+		component = fields.Component
+	}
+	data, err := json.Marshal(record{
+		Level:     level.String(),
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Component: component,
+		Message:   msg,
+		Namespace: fields.Namespace,
+		Name:      fields.Name,
+		SyncID:    fields.SyncID,
+	})
+	if err != nil {
+		return
+	}
+	jl.mutex.Lock()
+	defer jl.mutex.Unlock()
+	jl.out.Write(append(data, '\n'))
+}
+
+func (jl *jsonLogger) Debug(msg string) {
+	jl.LogFields(vlog.LL_DEBUG, vlog.Fields{}, msg)
+}
+
+func (jl *jsonLogger) Debugf(format string, params ...interface{}) {
+	if jl.enabled(vlog.LL_DEBUG) {
+		jl.LogFields(vlog.LL_DEBUG, vlog.Fields{}, fmt.Sprintf(format, params...))
+	}
+}
+
+func (jl *jsonLogger) Info(msg string) {
+	jl.LogFields(vlog.LL_INFO, vlog.Fields{}, msg)
+}
+
+func (jl *jsonLogger) Infof(format string, params ...interface{}) {
+	if jl.enabled(vlog.LL_INFO) {
+		jl.LogFields(vlog.LL_INFO, vlog.Fields{}, fmt.Sprintf(format, params...))
+	}
+}
+
+func (jl *jsonLogger) Warning(msg string) {
+	jl.LogFields(vlog.LL_WARNING, vlog.Fields{}, msg)
+}
+
+func (jl *jsonLogger) Warningf(format string, params ...interface{}) {
+	if jl.enabled(vlog.LL_WARNING) {
+		jl.LogFields(vlog.LL_WARNING, vlog.Fields{}, fmt.Sprintf(format, params...))
+	}
+}
+
+func (jl *jsonLogger) Error(msg string) {
+	jl.LogFields(vlog.LL_ERROR, vlog.Fields{}, msg)
+}
+
+func (jl *jsonLogger) Errorf(format string, params ...interface{}) {
+	if jl.enabled(vlog.LL_ERROR) {
+		jl.LogFields(vlog.LL_ERROR, vlog.Fields{}, fmt.Sprintf(format, params...))
+	}
+}
+
+func (jl *jsonLogger) Critical(msg string) {
+	jl.LogFields(vlog.LL_CRITICAL, vlog.Fields{}, msg)
+}
+
+func (jl *jsonLogger) Criticalf(format string, params ...interface{}) {
+	if jl.enabled(vlog.LL_CRITICAL) {
+		jl.LogFields(vlog.LL_CRITICAL, vlog.Fields{}, fmt.Sprintf(format, params...))
+	}
+}
+
+func (jl *jsonLogger) SetLogLevel(slLogLevel syslog.Priority) {
+	jl.slLogLevel = slLogLevel
+}
+
+func (jl *jsonLogger) GetLogLevel() syslog.Priority {
+	return jl.slLogLevel
+}
+
+func (jl *jsonLogger) Close() {
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2019, F5 Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jsonlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/syslog"
+	"strings"
+	"time"
+
+	vlog "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSON Logger Tests", func() {
+	var out *bytes.Buffer
+	var jl *jsonLogger
+
+	records := func() []map[string]interface{} {
+		var recs []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var rec map[string]interface{}
+			Expect(json.Unmarshal([]byte(line), &rec)).To(Succeed())
+			recs = append(recs, rec)
+		}
+		return recs
+	}
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		jl = NewJSONLoggerExt(out)
+	})
+
+	It("Writes one JSON object per line", func() {
+		jl.Info("first")
+		jl.Warningf("second %d", 2)
+		recs := records()
+		Expect(recs).To(HaveLen(2))
+		Expect(recs[0]["level"]).To(Equal("info"))
+		Expect(recs[0]["message"]).To(Equal("first"))
+		Expect(recs[1]["level"]).To(Equal("warning"))
+		Expect(recs[1]["message"]).To(Equal("second 2"))
+
+		ts, err := time.Parse(time.RFC3339Nano, recs[0]["timestamp"].(string))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ts.Location()).To(Equal(time.UTC))
+	})
+
+	It("Encodes the fields of a record", func() {
+		jl.LogFields(vlog.LL_ERROR, vlog.Fields{
+			Namespace: "default",
+			Name:      "vs1",
+			SyncID:    "42",
+		}, "[AS3] post failed: \"quoted\"")
+		recs := records()
+		Expect(recs).To(HaveLen(1))
+		Expect(recs[0]).To(HaveKeyWithValue("component", "as3"))
+		Expect(recs[0]).To(HaveKeyWithValue("message", "post failed: \"quoted\""))
+		Expect(recs[0]).To(HaveKeyWithValue("namespace", "default"))
+		Expect(recs[0]).To(HaveKeyWithValue("name", "vs1"))
+		Expect(recs[0]).To(HaveKeyWithValue("syncId", "42"))
+
+		out.Reset()
+		jl.LogFields(vlog.LL_INFO, vlog.Fields{Component: "vxlan"}, "[AS3] message")
+		Expect(records()[0]).To(HaveKeyWithValue("component", "vxlan"))
+	})
+
+	It("Omits empty fields", func() {
+		jl.Debug("no fields")
+		rec := records()[0]
+		Expect(rec).To(HaveLen(3))
+		Expect(rec).To(HaveKey("level"))
+		Expect(rec).To(HaveKey("timestamp"))
+		Expect(rec).To(HaveKeyWithValue("message", "no fields"))
+	})
+
+	It("Filters records below the log level", func() {
+		Expect(jl.GetLogLevel()).To(Equal(syslog.LOG_DEBUG))
+		jl.SetLogLevel(syslog.LOG_WARNING)
+		Expect(jl.GetLogLevel()).To(Equal(syslog.LOG_WARNING))
+
+		jl.Debug("debug")
+		jl.Debugf("debug %v", 1)
+		jl.Info("info")
+		jl.Infof("info %v", 1)
+		jl.Warning("warning")
+		jl.Errorf("error %v", 1)
+		jl.Critical("critical")
+
+		var levels []interface{}
+		for _, rec := range records() {
+			levels = append(levels, rec["level"])
+		}
+		Expect(levels).To(Equal([]interface{}{"warning", "error", "critical"}))
+	})
+})
@@ -0,0 +1,125 @@
+// Copyright (c) 2019, F5 Networks, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//levels.go:
+//  Global and per-component log levels that can be changed at runtime.
+//
+package vlogger
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Levels are the global log level and the per-component levels overriding it
+type Levels struct {
+	Global     LogLevel            `json:"global"`
+	Components map[string]LogLevel `json:"components,omitempty"`
+}
+
+var (
+	// levels holds the current Levels. It is replaced as a whole and never
+	// modified, so that logging calls read it without locking.
+	levels atomic.Value
+
+	// levelsMutex serializes the changes to levels
+	levelsMutex sync.Mutex
+)
+
+func init() {
+	levels.Store(Levels{Global: LL_DEBUG})
+}
+
+// copy returns a deep copy of l, as the components map is shared
+func (l Levels) copy() Levels {
+	c := Levels{
+		Global:     l.Global,
+		Components: make(map[string]LogLevel, len(l.Components)),
+	}
+	for component, level := range l.Components {
+		c.Components[component] = level
+	}
+	return c
+}
+
+// level returns the level applying to component
+func (l Levels) level(component string) LogLevel {
+	if level, ok := l.Components[component]; ok {
+		return level
+	}
+	return l.Global
+}
+
+// enabled reports whether messages at level are logged for component. An
+// empty component is filtered by the global level only.
+func enabled(component string, level LogLevel) bool {
+	return level >= levels.Load().(Levels).level(component)
+}
+
+// SetLevels replaces the global and all the component levels at once.
+// Components missing from l log at the global level.
+func SetLevels(l Levels) {
+	components := make(map[string]LogLevel, len(l.Components))
+	for component, level := range l.Components {
+		components[strings.ToLower(component)] = level
+	}
+	l.Components = components
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	levels.Store(l)
+}
+
+// GetLevels returns the global and the component levels
+func GetLevels() Levels {
+	return levels.Load().(Levels).copy()
+}
+
+// SetComponentLogLevel sets the level of a single component
+func SetComponentLogLevel(component string, level LogLevel) {
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	l := levels.Load().(Levels).copy()
+	l.Components[strings.ToLower(component)] = level
+	levels.Store(l)
+}
+
+// ParseLevels parses the component levels of spec, a comma separated list
+// of component=level pairs, e.g. "as3=debug,vxlan=warning". A pair without
+// a component, e.g. "info", sets the global level.
+func ParseLevels(spec string, global LogLevel) (Levels, error) {
+	l := Levels{Global: global, Components: make(map[string]LogLevel)}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		component, name := "", pair
+		if i := strings.Index(pair, "="); i >= 0 {
+			component = strings.ToLower(strings.TrimSpace(pair[:i]))
+			name = strings.TrimSpace(pair[i+1:])
+		}
+		level := NewLogLevel(name)
+		if level == nil {
+			return Levels{}, fmt.Errorf("Unknown log level %q in %q", name, pair)
+		}
+		if component == "" || component == "global" {
+			l.Global = *level
+		} else {
+			l.Components[component] = *level
+		}
+	}
+	return l, nil
+}
@@ -120,24 +120,8 @@ type (
 	}
 )
 
-var (
-	// vlog specifies loggers associated with each log level (could all be the same logger).
-	vlog [LL_LOGLEVEL_SIZE]Logger
-
-	// logLevel indicates the current package-level filtering being applied
-	// (may be further restricted by specific concrete loggers).
-	logLevel LogLevel = LL_DEBUG
-
-	// logLevelToSyslogLevel maps vlogger log levels to the internal representation used
-	// by the implementations (which use syslog's definitions).
-	logLevelToSyslogLevel = [LL_LOGLEVEL_SIZE]syslog.Priority{
-		syslog.LOG_DEBUG,
-		syslog.LOG_INFO,
-		syslog.LOG_WARNING,
-		syslog.LOG_ERR,
-		syslog.LOG_CRIT,
-	}
-)
+// vlog specifies loggers associated with each log level (could all be the same logger).
+var vlog [LL_LOGLEVEL_SIZE]Logger
 
 // RegisterLogger must be called to map a concrete logger object with each log level.
 func RegisterLogger(minLogLevel, maxLogLevel LogLevel, log Logger) {
@@ -148,62 +132,80 @@ func RegisterLogger(minLogLevel, maxLogLevel LogLevel, log Logger) {
 
 // Debug sends a message to the logger object to record debug/trace level statements
 func Debug(msg string) {
-	vlog[LL_DEBUG].Debug(msg)
+	if enabled("", LL_DEBUG) {
+		vlog[LL_DEBUG].Debug(msg)
+	}
 }
 
 // Debugf formats a message before sending it to the logger object to record
 // debug/trace level statements
 func Debugf(format string, params ...interface{}) {
-	vlog[LL_DEBUG].Debugf(format, params...)
+	if enabled("", LL_DEBUG) {
+		vlog[LL_DEBUG].Debugf(format, params...)
+	}
 }
 
 // Info sends a message to the logger object to record informational level statements
 // (these should be statements that can normally be logged without causing performance
 // issues).
 func Info(msg string) {
-	vlog[LL_INFO].Info(msg)
+	if enabled("", LL_INFO) {
+		vlog[LL_INFO].Info(msg)
+	}
 }
 
 // Infof formats a message before sending it to the logger object to record
 // informational level statements (there should be statements that can normally
 // be logged without causing performance issues).
 func Infof(format string, params ...interface{}) {
-	vlog[LL_INFO].Infof(format, params...)
+	if enabled("", LL_INFO) {
+		vlog[LL_INFO].Infof(format, params...)
+	}
 }
 
 // Warning sends a message to the logger object to record warning level statements
 // (these indication conditions that are unexpected or may cause issues but are not
 // normally going to affect the program execution).
 func Warning(msg string) {
-	vlog[LL_WARNING].Warning(msg)
+	if enabled("", LL_WARNING) {
+		vlog[LL_WARNING].Warning(msg)
+	}
 }
 
 // Warningf formats a message before sending it to the logger object to record
 // warning level statements (these indication conditions that are unexpected or
 // may cause issues but are not normally going to affect the program execution).
 func Warningf(format string, params ...interface{}) {
-	vlog[LL_WARNING].Warningf(format, params...)
+	if enabled("", LL_WARNING) {
+		vlog[LL_WARNING].Warningf(format, params...)
+	}
 }
 
 // Error sends a message to the logger object to record error level statements
 // (these indicate conditions that should not occur and may indicate a failure
 // in performing the requested action).
 func Error(msg string) {
-	vlog[LL_ERROR].Error(msg)
+	if enabled("", LL_ERROR) {
+		vlog[LL_ERROR].Error(msg)
+	}
 }
 
 // Errorf formats a message before sending it to the logger object to record
 // error level statements (these indicate conditions that should not occur
 // and may indicate a failure in performing the requested action).
 func Errorf(format string, params ...interface{}) {
-	vlog[LL_ERROR].Errorf(format, params...)
+	if enabled("", LL_ERROR) {
+		vlog[LL_ERROR].Errorf(format, params...)
+	}
 }
 
 // Critical sends a message to the logger object to record critical level statements
 // (these indicate conditions that should never occur and might cause a failure/crash
 // of the executing program or unexpected outcome from the requested action).
 func Critical(msg string) {
-	vlog[LL_CRITICAL].Critical(msg)
+	if enabled("", LL_CRITICAL) {
+		vlog[LL_CRITICAL].Critical(msg)
+	}
 }
 
 // Criticalf formats a message before sending it to the logger object to record
@@ -211,7 +213,9 @@ func Critical(msg string) {
 // and might cause a failure/crash of the executing program or unexpected
 // outcome from the requested action).
 func Criticalf(format string, params ...interface{}) {
-	vlog[LL_CRITICAL].Criticalf(format, params...)
+	if enabled("", LL_CRITICAL) {
+		vlog[LL_CRITICAL].Criticalf(format, params...)
+	}
 }
 
 // Fatal sends a CRITICAL message to the logger object and then exits.
@@ -249,22 +253,19 @@ func Panicf(format string, params ...interface{}) {
 	panic(msg)
 }
 
-// SetLogLevel sets the current package-level filtering
+// SetLogLevel sets the current package-level filtering. It applies to the
+// components without a level of their own.
 func SetLogLevel(level LogLevel) {
-	logLevel = level
-
-	// Update all loggers to the new level
-	slLogLevel := logLevelToSyslogLevel[logLevel]
-	for i, _ := range vlog {
-		if vlog[i] != nil {
-			vlog[i].SetLogLevel(slLogLevel)
-		}
-	}
+	levelsMutex.Lock()
+	defer levelsMutex.Unlock()
+	l := levels.Load().(Levels).copy()
+	l.Global = level
+	levels.Store(l)
 }
 
 // GetLogLevel returns the current package-level filtering
 func GetLogLevel() LogLevel {
-	return logLevel
+	return levels.Load().(Levels).Global
 }
 
 // Close informs the configured loggers that they are being closed and
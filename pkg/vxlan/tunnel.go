@@ -0,0 +1,166 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vxlan
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+// Types of the overlay networks whose tunnels are discovered
+const (
+	OpenShiftSDN  = "openshift-sdn"
+	Flannel       = "flannel"
+	OVNKubernetes = "ovn-kubernetes"
+)
+
+// Node annotations of the overlay networks
+const (
+	flannelPublicIP     = "flannel.alpha.coreos.com/public-ip"
+	flannelBackendData  = "flannel.alpha.coreos.com/backend-data"
+	ovnHybridSubnet     = "k8s.ovn.org/hybrid-overlay-node-subnet"
+	ovnHybridGatewayMAC = "k8s.ovn.org/hybrid-overlay-distributed-router-gateway-mac"
+)
+
+// TunnelDiscovery finds the VTEPs of the nodes of an overlay network, to
+// which BIG-IP sends the traffic of the pods through its tunnel
+type TunnelDiscovery interface {
+	// VTEP returns the MAC and IP addresses of the FDB record of the VTEP
+	// of node, whose address is addr. Both are empty when the node has no
+	// VTEP.
+	VTEP(node *v1.Node, addr string) (mac, endpoint string)
+	// PodMAC returns the MAC address of the ARP entries of the pods of node
+	PodMAC(node *v1.Node) (string, error)
+}
+
+// NewTunnelDiscovery returns the TunnelDiscovery of the overlay network of
+// type tunnelType
+func NewTunnelDiscovery(tunnelType string) (TunnelDiscovery, error) {
+	switch tunnelType {
+	case OpenShiftSDN:
+		return openShiftSDNDiscovery{}, nil
+	case Flannel:
+		return flannelDiscovery{}, nil
+	case OVNKubernetes:
+		return ovnDiscovery{}, nil
+	}
+	return nil, fmt.Errorf("unsupported tunnel type: %s", tunnelType)
+}
+
+// openShiftSDNDiscovery derives a fake MAC from the address of the nodes
+type openShiftSDNDiscovery struct{}
+
+func (openShiftSDNDiscovery) VTEP(node *v1.Node, addr string) (string, string) {
+	if "" == addr {
+		return "", ""
+	}
+	return ipToMac(addr), addr
+}
+
+func (openShiftSDNDiscovery) PodMAC(node *v1.Node) (string, error) {
+	return "", fmt.Errorf("OpenShift SDN does not use ARP entries for pods")
+}
+
+// flannelDiscovery reads the VTEP of the nodes from their flannel
+// annotations
+type flannelDiscovery struct{}
+
+func (flannelDiscovery) VTEP(node *v1.Node, addr string) (string, string) {
+	mac, endpoint := "", addr
+	if "" != addr {
+		// Set the name to a fake MAC, overwritten with the real MAC
+		mac = ipToMac(addr)
+	}
+	if pip, ok := node.ObjectMeta.Annotations[flannelPublicIP]; ok {
+		endpoint = pip
+	}
+	if atn, ok := node.ObjectMeta.Annotations[flannelBackendData]; ok {
+		vtepMAC, err := parseVtepMac(atn, node.ObjectMeta.Name)
+		if nil != err {
+			log.Errorf("[VxLAN] %v", err)
+		} else if endpoint != "" {
+			mac = vtepMAC
+		}
+	}
+	return mac, endpoint
+}
+
+func (flannelDiscovery) PodMAC(node *v1.Node) (string, error) {
+	if _, ok := node.ObjectMeta.Annotations[flannelPublicIP]; ok {
+		if atn, ok := node.ObjectMeta.Annotations[flannelBackendData]; ok {
+			return parseVtepMac(atn, node.ObjectMeta.Name)
+		}
+	}
+	return "", fmt.Errorf("node '%s' has no flannel VTEP annotations", node.ObjectMeta.Name)
+}
+
+func parseVtepMac(mac, nodeName string) (string, error) {
+	split := strings.Split(mac, "\"")
+	if len(split) < 5 {
+		err := fmt.Errorf("flannel.alpha.coreos.com/backend-data annotation for "+
+			"node '%s' has invalid format; cannot validate VtepMac. "+
+			"Should be of the form: '{\"VtepMAC\":\"<mac>\"}'", nodeName)
+		return "", err
+	} else {
+		return split[3], nil
+	}
+}
+
+// ovnDiscovery reads the VTEP of the nodes from their OVN-Kubernetes hybrid
+// overlay annotations. The nodes the hybrid overlay has not given a subnet
+// and a distributed router gateway MAC yet are not part of the overlay. The
+// VXLAN endpoint of a node is its address, and the pods of a node are
+// reached through the MAC of its distributed router gateway.
+type ovnDiscovery struct{}
+
+func (ovnDiscovery) VTEP(node *v1.Node, addr string) (string, string) {
+	mac, err := ovnHybridOverlayMAC(node)
+	if nil != err {
+		log.Debugf("[VxLAN] %v", err)
+		return "", ""
+	}
+	return mac, addr
+}
+
+func (ovnDiscovery) PodMAC(node *v1.Node) (string, error) {
+	return ovnHybridOverlayMAC(node)
+}
+
+// ovnHybridOverlayMAC returns the distributed router gateway MAC of node, an
+// error when node is not part of the hybrid overlay
+func ovnHybridOverlayMAC(node *v1.Node) (string, error) {
+	subnet, ok := node.ObjectMeta.Annotations[ovnHybridSubnet]
+	if !ok {
+		return "", fmt.Errorf("node '%s' has no %s annotation", node.ObjectMeta.Name, ovnHybridSubnet)
+	}
+	if _, _, err := net.ParseCIDR(subnet); nil != err {
+		return "", fmt.Errorf("%s annotation for node '%s' has invalid format: %v",
+			ovnHybridSubnet, node.ObjectMeta.Name, err)
+	}
+	mac, ok := node.ObjectMeta.Annotations[ovnHybridGatewayMAC]
+	if !ok {
+		return "", fmt.Errorf("node '%s' has no %s annotation", node.ObjectMeta.Name, ovnHybridGatewayMAC)
+	}
+	if _, err := net.ParseMAC(mac); nil != err {
+		return "", fmt.Errorf("%s annotation for node '%s' has invalid format: %v",
+			ovnHybridGatewayMAC, node.ObjectMeta.Name, err)
+	}
+	return mac, nil
+}
@@ -0,0 +1,139 @@
+/*-
+ * Copyright (c) 2017,2018,2019 F5 Networks, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vxlan
+
+import (
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Tunnel Discovery Tests", func() {
+	internal := func(addrs ...string) []v1.NodeAddress {
+		var nodeAddrs []v1.NodeAddress
+		for _, addr := range addrs {
+			nodeAddrs = append(nodeAddrs, v1.NodeAddress{Type: "InternalIP", Address: addr})
+		}
+		return nodeAddrs
+	}
+
+	It("discovers the tunnels of the supported networks", func() {
+		for _, tunnelType := range []string{OpenShiftSDN, Flannel, OVNKubernetes} {
+			discovery, err := NewTunnelDiscovery(tunnelType)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(discovery).ToNot(BeNil())
+		}
+		discovery, err := NewTunnelDiscovery("gobbledy-goo")
+		Expect(err).To(HaveOccurred())
+		Expect(discovery).To(BeNil())
+	})
+
+	It("uses fake MACs with OpenShift SDN", func() {
+		node := newNode("node0", "0", false, internal("127.0.0.1"), nil)
+		mac, endpoint := openShiftSDNDiscovery{}.VTEP(node, "127.0.0.1")
+		Expect(mac).To(Equal("0a:0a:7f:00:00:01"))
+		Expect(endpoint).To(Equal("127.0.0.1"))
+
+		mac, endpoint = openShiftSDNDiscovery{}.VTEP(node, "")
+		Expect(mac).To(BeEmpty())
+		Expect(endpoint).To(BeEmpty())
+	})
+
+	It("reads the VTEPs of flannel from the node annotations", func() {
+		node := newNode("node0", "0", false, internal("127.0.0.1"), map[string]string{
+			"flannel.alpha.coreos.com/backend-data": "{\"VtepMAC\":\"12:ab:34:cd:56:ef\"}",
+			"flannel.alpha.coreos.com/public-ip":    "127.0.0.10",
+		})
+		mac, endpoint := flannelDiscovery{}.VTEP(node, "127.0.0.1")
+		Expect(mac).To(Equal("12:ab:34:cd:56:ef"))
+		Expect(endpoint).To(Equal("127.0.0.10"))
+		mac, err := flannelDiscovery{}.PodMAC(node)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mac).To(Equal("12:ab:34:cd:56:ef"))
+
+		node.ObjectMeta.Annotations = nil
+		mac, endpoint = flannelDiscovery{}.VTEP(node, "127.0.0.1")
+		Expect(mac).To(Equal("0a:0a:7f:00:00:01"))
+		Expect(endpoint).To(Equal("127.0.0.1"))
+		_, err = flannelDiscovery{}.PodMAC(node)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("reads the VTEPs of OVN-Kubernetes from the hybrid overlay annotations", func() {
+		node := newNode("node0", "0", false, internal("127.0.0.1"), map[string]string{
+			"k8s.ovn.org/hybrid-overlay-node-subnet":                    "10.128.2.0/23",
+			"k8s.ovn.org/hybrid-overlay-distributed-router-gateway-mac": "0a:58:0a:80:02:03",
+		})
+		mac, endpoint := ovnDiscovery{}.VTEP(node, "127.0.0.1")
+		Expect(mac).To(Equal("0a:58:0a:80:02:03"))
+		Expect(endpoint).To(Equal("127.0.0.1"))
+		mac, err := ovnDiscovery{}.PodMAC(node)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(mac).To(Equal("0a:58:0a:80:02:03"))
+
+		// Not part of the hybrid overlay yet
+		for _, annotations := range []map[string]string{
+			{},
+			{"k8s.ovn.org/hybrid-overlay-node-subnet": "10.128.2.0/23"},
+			{"k8s.ovn.org/hybrid-overlay-distributed-router-gateway-mac": "0a:58:0a:80:02:03"},
+			{
+				"k8s.ovn.org/hybrid-overlay-node-subnet":                    "invalid",
+				"k8s.ovn.org/hybrid-overlay-distributed-router-gateway-mac": "0a:58:0a:80:02:03",
+			},
+			{
+				"k8s.ovn.org/hybrid-overlay-node-subnet":                    "10.128.2.0/23",
+				"k8s.ovn.org/hybrid-overlay-distributed-router-gateway-mac": "invalid",
+			},
+		} {
+			node.ObjectMeta.Annotations = annotations
+			mac, endpoint = ovnDiscovery{}.VTEP(node, "127.0.0.1")
+			Expect(mac).To(BeEmpty())
+			Expect(endpoint).To(BeEmpty())
+			_, err = ovnDiscovery{}.PodMAC(node)
+			Expect(err).To(HaveOccurred())
+		}
+	})
+
+	It("writes the fdb records of the OVN-Kubernetes nodes", func() {
+		mock := &test.MockWriter{
+			FailStyle: test.Success,
+			Sections:  make(map[string]interface{}),
+		}
+		vxMgr, err := NewVxlanMgr("maintain", "vxlan-ovn", ovnDiscovery{}, true, mock, nil)
+		Expect(err).ToNot(HaveOccurred())
+
+		nodes := []v1.Node{
+			*newNode("node0", "0", false, internal("127.0.0.1"), map[string]string{
+				"k8s.ovn.org/hybrid-overlay-node-subnet":                    "10.128.0.0/23",
+				"k8s.ovn.org/hybrid-overlay-distributed-router-gateway-mac": "0a:58:0a:80:00:03",
+			}),
+			*newNode("node1", "1", false, internal("127.0.0.2"), nil),
+		}
+		vxMgr.ProcessNodeUpdate(nodes, nil)
+		Expect(mock.WrittenTimes).To(Equal(1))
+		mock.Lock()
+		defer mock.Unlock()
+		Expect(mock.Sections["vxlan-fdb"]).To(Equal(fdbSection{
+			TunnelName: "vxlan-ovn",
+			Records: []fdbRecord{
+				{Name: "0a:58:0a:80:00:03", Endpoint: "127.0.0.1"},
+			},
+		}))
+	})
+})
@@ -18,12 +18,11 @@ package vxlan
 
 import (
 	"fmt"
-	"strconv"
-	"strings"
+	"net"
 	"time"
 
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/resource"
-	log "github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
+	"github.com/F5Networks/k8s-bigip-ctlr/pkg/vlogger"
 	"github.com/F5Networks/k8s-bigip-ctlr/pkg/writer"
 
 	v1 "k8s.io/api/core/v1"
@@ -31,6 +30,8 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+var log = vlogger.NewComponentLogger(vlogger.ComponentVxLAN)
+
 type fdbSection struct {
 	TunnelName string      `json:"name"`
 	Records    []fdbRecord `json:"records"`
@@ -54,6 +55,7 @@ type arpEntry struct {
 type VxlanMgr struct {
 	mode       string
 	vxLAN      string
+	discovery  TunnelDiscovery
 	useNodeInt bool
 	config     writer.Writer
 	podChan    <-chan interface{}
@@ -62,6 +64,7 @@ type VxlanMgr struct {
 func NewVxlanMgr(
 	mode string,
 	vxLAN string,
+	discovery TunnelDiscovery,
 	useNodeInternal bool,
 	config writer.Writer,
 	eventChan <-chan interface{},
@@ -70,6 +73,8 @@ func NewVxlanMgr(
 		return nil, fmt.Errorf("required parameter mode not supplied")
 	} else if 0 == len(vxLAN) {
 		return nil, fmt.Errorf("required parameter vxlan not supplied")
+	} else if nil == discovery {
+		return nil, fmt.Errorf("required parameter discovery not supplied")
 	} else if nil == config {
 		return nil, fmt.Errorf("required parameter ConfigWriter not supplied")
 	}
@@ -81,6 +86,7 @@ func NewVxlanMgr(
 	vxMgr := &VxlanMgr{
 		mode:       mode,
 		vxLAN:      vxLAN,
+		discovery:  discovery,
 		useNodeInt: useNodeInternal,
 		config:     config,
 		podChan:    eventChan,
@@ -122,31 +128,14 @@ func (vxm *VxlanMgr) ProcessNodeUpdate(obj interface{}, err error) {
 		if notExecutable == true {
 			continue
 		}
-		nodeAddrs := node.Status.Addresses
-		rec := fdbRecord{}
-		for _, addr := range nodeAddrs {
+		var nodeAddr string
+		for _, addr := range node.Status.Addresses {
 			if addr.Type == addrType {
-				rec.Endpoint = addr.Address
-				// Initially set the name to a fake MAC (for OpenShift use)
-				// For flannel, this will be overwritten with the real MAC
-				rec.Name = ipv4ToMac(addr.Address)
-			}
-		}
-		// Will only exist in Flannel/Kubernetes
-		if pip, ok := node.ObjectMeta.Annotations["flannel.alpha.coreos.com/public-ip"]; ok {
-			if rec.Endpoint != pip {
-				rec.Endpoint = pip
-			}
-		}
-		if atn, ok := node.ObjectMeta.Annotations["flannel.alpha.coreos.com/backend-data"]; ok {
-			var mac string
-			mac, err = parseVtepMac(atn, node.ObjectMeta.Name)
-			if nil != err {
-				log.Errorf("[VxLAN] %v", err)
-			} else if rec.Endpoint != "" {
-				rec.Name = mac
+				nodeAddr = addr.Address
 			}
 		}
+		rec := fdbRecord{}
+		rec.Name, rec.Endpoint = vxm.discovery.VTEP(&node, nodeAddr)
 		if rec != (fdbRecord{}) {
 			records = append(records, rec)
 		}
@@ -178,18 +167,18 @@ func (vxm *VxlanMgr) ProcessNodeUpdate(obj interface{}, err error) {
 	}
 }
 
-// Convert an IPV4 string to a fake MAC address.
-func ipv4ToMac(addr string) string {
-	ip := strings.Split(addr, ".")
-	if len(ip) != 4 {
-		log.Errorf("[VxLAN] Bad IPv4 address format specified for FDB record: %s", addr)
+// Convert an IP string to a fake MAC address. IPv4 addresses keep all of
+// their bytes, IPv6 addresses their last five.
+func ipToMac(addr string) string {
+	ip := net.ParseIP(addr)
+	if nil == ip {
+		log.Errorf("[VxLAN] Bad IP address format specified for FDB record: %s", addr)
 		return ""
 	}
-	var intIP [4]int
-	for i, val := range ip {
-		intIP[i], _ = strconv.Atoi(val)
+	if ip4 := ip.To4(); nil != ip4 {
+		return fmt.Sprintf("0a:0a:%02x:%02x:%02x:%02x", ip4[0], ip4[1], ip4[2], ip4[3])
 	}
-	return fmt.Sprintf("0a:0a:%02x:%02x:%02x:%02x", intIP[0], intIP[1], intIP[2], intIP[3])
+	return fmt.Sprintf("0a:%02x:%02x:%02x:%02x:%02x", ip[11], ip[12], ip[13], ip[14], ip[15])
 }
 
 // Listen for updates from resource containing pod names (for arp entries)
@@ -224,7 +213,7 @@ func (vxm *VxlanMgr) addArpForPods(pods interface{}, kubeClient kubernetes.Inter
 	}
 	for _, pod := range pods.([]resource.Member) {
 		var mac string
-		mac, err = getVtepMac(pod, kubePods, kubeNodes)
+		mac, err = vxm.getVtepMac(pod, kubePods, kubeNodes)
 		if nil != err {
 			log.Errorf("[VxLAN] %v", err)
 			return
@@ -260,7 +249,7 @@ func (vxm *VxlanMgr) addArpForPods(pods interface{}, kubeClient kubernetes.Inter
 }
 
 // Gets the VtepMac from the Node running this Pod
-func getVtepMac(
+func (vxm *VxlanMgr) getVtepMac(
 	pod resource.Member,
 	kubePods *v1.PodList,
 	kubeNodes *v1.NodeList,
@@ -270,11 +259,9 @@ func getVtepMac(
 		if kPod.Status.PodIP == pod.Address {
 			// Get the Node for this Pod
 			for _, node := range kubeNodes.Items {
-				if _, ok := node.ObjectMeta.Annotations["flannel.alpha.coreos.com/public-ip"]; ok &&
-					node.ObjectMeta.Name == kPod.Spec.NodeName {
-					if mac, ok :=
-						node.ObjectMeta.Annotations["flannel.alpha.coreos.com/backend-data"]; ok {
-						return parseVtepMac(mac, node.ObjectMeta.Name)
+				if node.ObjectMeta.Name == kPod.Spec.NodeName {
+					if mac, err := vxm.discovery.PodMAC(&node); nil == err {
+						return mac, nil
 					}
 				}
 			}
@@ -282,15 +269,3 @@ func getVtepMac(
 	}
 	return "", fmt.Errorf("Vxlan manager could not get VtepMac for %s's node.", pod.Address)
 }
-
-func parseVtepMac(mac, nodeName string) (string, error) {
-	split := strings.Split(mac, "\"")
-	if len(split) < 5 {
-		err := fmt.Errorf("flannel.alpha.coreos.com/backend-data annotation for "+
-			"node '%s' has invalid format; cannot validate VtepMac. "+
-			"Should be of the form: '{\"VtepMAC\":\"<mac>\"}'", nodeName)
-		return "", err
-	} else {
-		return split[3], nil
-	}
-}
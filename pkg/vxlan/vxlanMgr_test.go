@@ -90,23 +90,27 @@ var _ = Describe("VxlanMgr Tests", func() {
 			Sections:  make(map[string]interface{}),
 		}
 
-		vxMgr, err := NewVxlanMgr("", "vxlan500", true, mock, nil)
+		vxMgr, err := NewVxlanMgr("", "vxlan500", flannelDiscovery{}, true, mock, nil)
 		Expect(err).To(HaveOccurred())
 		Expect(vxMgr).To(BeNil())
 
-		vxMgr, err = NewVxlanMgr("gobbledy-goo", "vxlan500", true, mock, nil)
+		vxMgr, err = NewVxlanMgr("gobbledy-goo", "vxlan500", flannelDiscovery{}, true, mock, nil)
 		Expect(err).To(HaveOccurred())
 		Expect(vxMgr).To(BeNil())
 
-		vxMgr, err = NewVxlanMgr("maintain", "", true, mock, nil)
+		vxMgr, err = NewVxlanMgr("maintain", "", flannelDiscovery{}, true, mock, nil)
 		Expect(err).To(HaveOccurred())
 		Expect(vxMgr).To(BeNil())
 
-		vxMgr, err = NewVxlanMgr("maintain", "vxlan500", true, nil, nil)
+		vxMgr, err = NewVxlanMgr("maintain", "vxlan500", flannelDiscovery{}, true, nil, nil)
 		Expect(err).To(HaveOccurred())
 		Expect(vxMgr).To(BeNil())
 
-		vxMgr, err = NewVxlanMgr("maintain", "vxlan500", true, mock, nil)
+		vxMgr, err = NewVxlanMgr("maintain", "vxlan500", nil, true, mock, nil)
+		Expect(err).To(HaveOccurred())
+		Expect(vxMgr).To(BeNil())
+
+		vxMgr, err = NewVxlanMgr("maintain", "vxlan500", flannelDiscovery{}, true, mock, nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(vxMgr).ToNot(BeNil())
 	})
@@ -117,7 +121,7 @@ var _ = Describe("VxlanMgr Tests", func() {
 			Sections:  make(map[string]interface{}),
 		}
 
-		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", true, mock, nil)
+		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", flannelDiscovery{}, true, mock, nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(func() {
 			vxMgr.ProcessNodeUpdate(struct{}{}, fmt.Errorf("an error"))
@@ -131,7 +135,7 @@ var _ = Describe("VxlanMgr Tests", func() {
 			Sections:  make(map[string]interface{}),
 		}
 
-		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", true, mock, nil)
+		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", flannelDiscovery{}, true, mock, nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(func() {
 			vxMgr.ProcessNodeUpdate(struct{}{}, nil)
@@ -147,7 +151,7 @@ var _ = Describe("VxlanMgr Tests", func() {
 
 		nodeList := getNodeList()
 
-		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", true, mock, nil)
+		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", flannelDiscovery{}, true, mock, nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(func() {
 			vxMgr.ProcessNodeUpdate(nodeList, nil)
@@ -261,6 +265,14 @@ var _ = Describe("VxlanMgr Tests", func() {
 		Expect(section).To(Equal(expected))
 	})
 
+	It("converts IPv4 and IPv6 addresses to fake MACs", func() {
+		Expect(ipToMac("127.1.1.2")).To(Equal("0a:0a:7f:01:01:02"))
+		Expect(ipToMac("2001:db8::a:7f01:102")).To(Equal("0a:0a:7f:01:01:02"))
+		Expect(ipToMac("fd00::1")).To(Equal("0a:00:00:00:00:01"))
+		Expect(ipToMac("127.1.1")).To(BeEmpty())
+		Expect(ipToMac("")).To(BeEmpty())
+	})
+
 	It("writes fdb records - SendFail", func() {
 		mock := &test.MockWriter{
 			FailStyle: test.ImmediateFail,
@@ -269,7 +281,7 @@ var _ = Describe("VxlanMgr Tests", func() {
 
 		nodeList := getNodeList()
 
-		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", true, mock, nil)
+		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", flannelDiscovery{}, true, mock, nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(func() {
 			vxMgr.ProcessNodeUpdate(nodeList, nil)
@@ -285,7 +297,7 @@ var _ = Describe("VxlanMgr Tests", func() {
 
 		nodeList := getNodeList()
 
-		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", true, mock, nil)
+		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", flannelDiscovery{}, true, mock, nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(func() {
 			vxMgr.ProcessNodeUpdate(nodeList, nil)
@@ -301,7 +313,7 @@ var _ = Describe("VxlanMgr Tests", func() {
 
 		nodeList := getNodeList()
 
-		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", true, mock, nil)
+		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", flannelDiscovery{}, true, mock, nil)
 		Expect(err).ToNot(HaveOccurred())
 		Expect(func() {
 			vxMgr.ProcessNodeUpdate(nodeList, nil)
@@ -316,7 +328,7 @@ var _ = Describe("VxlanMgr Tests", func() {
 		}
 		fakeClient := fake.NewSimpleClientset()
 		eventChan := make(chan interface{})
-		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", true, mock, eventChan)
+		vxMgr, err := NewVxlanMgr("maintain", "vxlan500", flannelDiscovery{}, true, mock, eventChan)
 		Expect(err).ToNot(HaveOccurred())
 		vxMgr.useNodeInt = true
 
@@ -22,6 +22,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 	"syscall"
 	"time"
 
@@ -49,6 +50,9 @@ type configWriter struct {
 	stopCh     chan struct{}
 	dataCh     chan configSection
 	sectionMap map[string]interface{}
+	// Sections last written to configFile
+	output      []byte
+	outputMutex sync.RWMutex
 }
 
 type configSection struct {
@@ -86,6 +90,16 @@ func (cw *configWriter) GetOutputFilename() string {
 	return cw.configFile
 }
 
+// Sections returns the sections last written for the python driver
+func (cw *configWriter) Sections() json.RawMessage {
+	cw.outputMutex.RLock()
+	defer cw.outputMutex.RUnlock()
+	if cw.output == nil {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(cw.output)
+}
+
 func (cw *configWriter) Stop() {
 	defer func() {
 		if r := recover(); r != nil {
@@ -241,6 +255,9 @@ func (cw *configWriter) waitData() {
 					}
 					go respondErr(cs.errorCh, err)
 				} else {
+					cw.outputMutex.Lock()
+					cw.output = output
+					cw.outputMutex.Unlock()
 					log.Debugf("[CCCL] ConfigWriter (%p) successfully wrote section (%s)",
 						cw, cs.name)
 					go respondDone(cs.doneCh)
@@ -274,6 +274,7 @@ var _ = Describe("Config Writer Tests", func() {
 			written, err := ioutil.ReadFile(f)
 			Expect(err).To(BeNil())
 			Expect(written).To(Equal(expected))
+			Expect(cw.(*configWriter).Sections()).To(MatchJSON(expected))
 
 			// test empty section and overwrite
 			empty := struct {